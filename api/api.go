@@ -88,6 +88,8 @@ func (srv *Server) initAPI() {
 	// Miner API Calls
 	if srv.miner != nil {
 		router.GET("/miner", srv.minerHandler)
+		router.GET("/miner/block-template", srv.minerBlockTemplateHandlerGET)
+		router.POST("/miner/submit-block", srv.minerSubmitBlockHandlerPOST)
 		router.GET("/miner/header", srv.minerHeaderHandlerGET)
 		router.POST("/miner/header", srv.minerHeaderHandlerPOST)
 		router.GET("/miner/start", srv.minerStartHandler)
@@ -120,6 +122,7 @@ func (srv *Server) initAPI() {
 
 	// TransactionPool API Calls
 	if srv.tpool != nil {
+		router.GET("/transactionpool/fee", srv.transactionpoolFeeHandler)
 		router.GET("/transactionpool/transactions", srv.transactionpoolTransactionsHandler)
 	}
 
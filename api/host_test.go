@@ -4,9 +4,9 @@ import (
 	"net/url"
 	"path/filepath"
 	"testing"
-	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // TestIntegrationHosting tests that the host correctly receives payment for
@@ -60,20 +60,128 @@ func TestIntegrationHosting(t *testing.T) {
 	}
 	// only one piece will be uploaded (10% at current redundancy)
 	var rf RenterFiles
-	for i := 0; i < 200 && (len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10); i++ {
-		st.getAPI("/renter/files", &rf)
-		time.Sleep(50 * time.Millisecond)
+	st.getAPI("/renter/files", &rf)
+	for i := 0; i < 50 && (len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10); i++ {
+		st.getAPI("/renter/files?waitForChange=true", &rf)
 	}
 	if len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10 {
 		t.Fatal("the uploading is not succeeding for some reason:", rf.Files[0])
 	}
 
+	// The contract should be reported as potential revenue.
+	var hg HostGET
+	err = st.getAPI("/host", &hg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hg.FinancialMetrics.PotentialStorageRevenue.IsZero() {
+		t.Error("host does not report potential storage revenue for the contract")
+	}
+	if !hg.FinancialMetrics.StorageRevenue.IsZero() {
+		t.Error("host reports storage revenue before the storage proof")
+	}
+
 	// Mine blocks until the host recognizes profit. The host will wait for 12
 	// blocks after the storage window has closed to report the profit, a total
 	// of 40 blocks should be mined.
 	for i := 0; i < 40; i++ {
 		st.miner.AddBlock()
 	}
+
+	// The potential revenue should now be actual revenue, and no collateral
+	// should have been lost.
+	expectedRevenue := hg.FinancialMetrics.PotentialStorageRevenue
+	err = st.getAPI("/host", &hg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hg.FinancialMetrics.StorageRevenue.Cmp(expectedRevenue) != 0 {
+		t.Errorf("host reports storage revenue of %v, expected %v", hg.FinancialMetrics.StorageRevenue, expectedRevenue)
+	}
+	if !hg.FinancialMetrics.PotentialStorageRevenue.IsZero() {
+		t.Error("host still reports potential storage revenue:", hg.FinancialMetrics.PotentialStorageRevenue)
+	}
+	if !hg.FinancialMetrics.LockedStorageCollateral.IsZero() {
+		t.Error("host still reports locked collateral:", hg.FinancialMetrics.LockedStorageCollateral)
+	}
+	if !hg.FinancialMetrics.LostStorageCollateral.IsZero() {
+		t.Error("host reports lost collateral:", hg.FinancialMetrics.LostStorageCollateral)
+	}
+}
+
+// TestIntegrationHostingCollateralRatio tests that a renter can upload to a
+// host whose collateral follows its storage price through a collateral ratio
+// and is lower than the static collateral of the host.
+func TestIntegrationHostingCollateralRatio(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationHostingCollateralRatio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Raise the static collateral above the collateral given by the ratio.
+	// The settings are changed before the host announces, so that the renter
+	// learns the new collateral when it scans the host.
+	settings := st.host.InternalSettings()
+	settings.Collateral = settings.MinimumStoragePrice.Mul(types.NewCurrency64(5))
+	err = st.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.host.SetCollateralRatio(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.host.ExternalSettings().Collateral.Cmp(settings.MinimumStoragePrice) != 0 {
+		t.Fatal("host does not advertise the collateral given by the ratio")
+	}
+
+	// announce the host and start accepting contracts
+	err = st.announceHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.acceptContracts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.setHostStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create contracts
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", "10000000000000000000000000000")
+	allowanceValues.Set("period", "5")
+	err = st.stdPostAPI("/renter/allowance", allowanceValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create a file and upload it to the host
+	path := filepath.Join(build.SiaTestingDir, "api", "TestIntegrationHostingCollateralRatio", "test.dat")
+	err = createRandFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	err = st.stdPostAPI("/renter/upload/test", uploadValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	st.getAPI("/renter/files", &rf)
+	for i := 0; i < 50 && (len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10); i++ {
+		st.getAPI("/renter/files?waitForChange=true", &rf)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].UploadProgress < 10 {
+		t.Fatal("the uploading is not succeeding for some reason:", rf.Files[0])
+	}
 }
 
 /*
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
@@ -18,6 +20,14 @@ type (
 		CPUMining        bool `json:"cpumining"`
 		StaleBlocksMined int  `json:"staleblocksmined"`
 	}
+
+	// MinerBlockTemplateGET contains a block that is ready for nonce grinding
+	// and the target that the block must meet. It is returned after a GET
+	// request to /miner/block-template.
+	MinerBlockTemplateGET struct {
+		Block  types.Block  `json:"block"`
+		Target types.Target `json:"target"`
+	}
 )
 
 // minerHandler handles the API call that queries the miner's status.
@@ -71,3 +81,38 @@ func (srv *Server) minerHeaderHandlerPOST(w http.ResponseWriter, req *http.Reque
 	}
 	writeSuccess(w)
 }
+
+// minerBlockTemplateHandlerGET handles the API call that retrieves a full
+// block for work, allowing external miners to choose their own nonces and
+// arbitrary data.
+func (srv *Server) minerBlockTemplateHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	b, target, err := srv.miner.BlockForWork()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, MinerBlockTemplateGET{
+		Block:  b,
+		Target: target,
+	})
+}
+
+// minerSubmitBlockHandlerPOST handles the API call to submit a solved block
+// to the miner. The block is read as json from the request body.
+func (srv *Server) minerSubmitBlockHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var b types.Block
+	err := json.NewDecoder(req.Body).Decode(&b)
+	if err != nil {
+		writeError(w, "could not decode block: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = srv.miner.SubmitBlock(b)
+	if err == modules.ErrNonExtendingBlock {
+		writeError(w, "block is stale: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
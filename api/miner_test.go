@@ -1,7 +1,10 @@
 package api
 
 import (
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -147,3 +150,69 @@ func TestIntegrationMinerHeader(t *testing.T) {
 		t.Errorf("block height did not increase after trying to mine a block through the api, started at %v and ended at %v", startingHeight, st.cs.Height())
 	}
 }
+
+// TestIntegrationMinerBlockTemplate checks that blocks can be mined through
+// the block template and submit block calls.
+func TestIntegrationMinerBlockTemplate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationMinerBlockTemplate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	startingHeight := st.cs.Height()
+
+	var mbt MinerBlockTemplateGET
+	err = st.getAPI("/miner/block-template", &mbt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Solve the block twice, so that the second solution is stale once the
+	// first has been submitted.
+	solve := func(b types.Block) types.Block {
+		for {
+			solved, ok := st.miner.SolveBlock(b, mbt.Target)
+			if ok {
+				return solved
+			}
+			b.Nonce[7]++
+		}
+	}
+	b1 := solve(mbt.Block)
+	b2 := b1
+	b2.Timestamp++
+	b2 = solve(b2)
+
+	submit := func(b types.Block) *http.Response {
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := HttpPOST("http://"+st.server.listener.Addr().String()+"/miner/submit-block", string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := submit(b1)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatal("expected status 204, got", resp.StatusCode)
+	}
+	if st.cs.Height() != startingHeight+1 {
+		t.Fatal("submitted block was not added to the blockchain")
+	}
+
+	resp = submit(b2)
+	defer resp.Body.Close()
+	msg, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(msg), "stale") {
+		t.Errorf("expected a stale block error, got status %v: %s", resp.StatusCode, msg)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -26,6 +27,21 @@ var (
 		}
 		panic("unrecognized release constant in api")
 	}()
+
+	// renterFilesWaitTimeout is the longest that a call to /renter/files
+	// with 'waitForChange' set will block before responding.
+	renterFilesWaitTimeout = func() time.Duration {
+		if build.Release == "dev" {
+			return 30 * time.Second
+		}
+		if build.Release == "standard" {
+			return 30 * time.Second
+		}
+		if build.Release == "testing" {
+			return 5 * time.Second
+		}
+		panic("unrecognized release constant in api")
+	}()
 )
 
 type (
@@ -156,8 +172,16 @@ func (srv *Server) renterRenameHandler(w http.ResponseWriter, req *http.Request,
 	writeSuccess(w)
 }
 
-// renterFilesHandler handles the API call to list all of the files.
+// renterFilesHandler handles the API call to list all of the files. If
+// 'waitForChange' is set, the call blocks until the upload progress or status
+// of a file changes, or until renterFilesWaitTimeout has elapsed.
 func (srv *Server) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if req.FormValue("waitForChange") == "true" {
+		select {
+		case <-srv.renter.FileUpdates():
+		case <-time.After(renterFilesWaitTimeout):
+		}
+	}
 	writeJSON(w, RenterFiles{
 		Files: srv.renter.FileList(),
 	})
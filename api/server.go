@@ -133,7 +133,11 @@ func (srv *Server) Close() error {
 			}
 		}
 	}
-	// TODO: close transaction pool
+	if srv.tpool != nil {
+		if err := srv.tpool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("transactionpool.Close failed: %v", err))
+		}
+	}
 	if srv.cs != nil {
 		if err := srv.cs.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("consensusset.Close failed: %v", err))
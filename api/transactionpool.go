@@ -3,17 +3,127 @@ package api
 import (
 	"net/http"
 
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
 
-type TransactionPoolGET struct {
-	Transactions []types.Transaction `json:"transactions"`
+type (
+	// TransactionPoolGET contains the transactions in the transaction pool,
+	// both as a flat list and grouped into their transaction sets.
+	TransactionPoolGET struct {
+		Transactions    []types.Transaction  `json:"transactions"`
+		TransactionSets []TransactionPoolSet `json:"transactionsets"`
+	}
+
+	// TransactionPoolSet is a transaction set in the transaction pool.
+	TransactionPoolSet struct {
+		Size         int                          `json:"size"`
+		Transactions []TransactionPoolTransaction `json:"transactions"`
+	}
+
+	// TransactionPoolTransaction is a transaction in the transaction pool,
+	// along with its id and its encoded size in bytes.
+	TransactionPoolTransaction struct {
+		ID          types.TransactionID `json:"id"`
+		Size        int                 `json:"size"`
+		Transaction types.Transaction   `json:"transaction"`
+	}
+
+	// TransactionPoolFeeGET contains the fee recommendations of the
+	// transaction pool for a typical transaction set, along with the size of
+	// the transaction pool that the recommendations are derived from.
+	TransactionPoolFeeGET struct {
+		Minimum     types.Currency `json:"minimum"`
+		Recommended types.Currency `json:"recommended"`
+
+		PoolSize        int `json:"poolsize"`
+		TransactionSets int `json:"transactionsets"`
+	}
+)
+
+// transactionpoolFeeHandler handles the API call to get the fee
+// recommendations of the transaction pool.
+func (srv *Server) transactionpoolFeeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	min, recommended := srv.tpool.RecommendedFee()
+	size, count := srv.tpool.TransactionPoolSize()
+	writeJSON(w, TransactionPoolFeeGET{
+		Minimum:     min,
+		Recommended: recommended,
+
+		PoolSize:        size,
+		TransactionSets: count,
+	})
+}
+
+// transactionTouchesAddress returns true if the transaction spends from or
+// pays to the provided address.
+func transactionTouchesAddress(txn types.Transaction, addr types.UnlockHash) bool {
+	for _, sci := range txn.SiacoinInputs {
+		if sci.UnlockConditions.UnlockHash() == addr {
+			return true
+		}
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		if sco.UnlockHash == addr {
+			return true
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		if sfi.UnlockConditions.UnlockHash() == addr || sfi.ClaimUnlockHash == addr {
+			return true
+		}
+	}
+	for _, sfo := range txn.SiafundOutputs {
+		if sfo.UnlockHash == addr {
+			return true
+		}
+	}
+	return false
 }
 
 // transactionpoolTransactionsHandler handles the API call to get the
-// transaction pool trasactions.
+// transaction pool trasactions. If an address is provided, only the
+// transaction sets that spend from or pay to the address are returned.
 func (srv *Server) transactionpoolTransactionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	writeJSON(w, TransactionPoolGET{Transactions: srv.tpool.TransactionList()})
+	var filter *types.UnlockHash
+	if req.FormValue("address") != "" {
+		addr, err := scanAddress(req.FormValue("address"))
+		if err != nil {
+			writeError(w, "error after call to /transactionpool/transactions: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter = &addr
+	}
+
+	tpg := TransactionPoolGET{
+		Transactions:    []types.Transaction{},
+		TransactionSets: []TransactionPoolSet{},
+	}
+	for _, set := range srv.tpool.TransactionSets() {
+		relevant := filter == nil
+		for _, txn := range set {
+			if !relevant && transactionTouchesAddress(txn, *filter) {
+				relevant = true
+			}
+		}
+		if !relevant {
+			continue
+		}
+
+		var tps TransactionPoolSet
+		for _, txn := range set {
+			size := len(encoding.Marshal(txn))
+			tps.Size += size
+			tps.Transactions = append(tps.Transactions, TransactionPoolTransaction{
+				ID:          txn.ID(),
+				Size:        size,
+				Transaction: txn,
+			})
+			tpg.Transactions = append(tpg.Transactions, txn)
+		}
+		tpg.TransactionSets = append(tpg.TransactionSets, tps)
+	}
+	writeJSON(w, tpg)
 }
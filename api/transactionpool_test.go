@@ -0,0 +1,125 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationTransactionPoolFee checks the GET call to the
+// /transactionpool/fee endpoint.
+func TestIntegrationTransactionPoolFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationTransactionPoolFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Put a transaction into the transaction pool.
+	_, err = st.wallet.SendSiacoins(types.NewCurrency64(1e3), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tpfg TransactionPoolFeeGET
+	err = st.getAPI("/transactionpool/fee", &tpfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	min, recommended := st.tpool.RecommendedFee()
+	if tpfg.Minimum.Cmp(min) != 0 || tpfg.Recommended.Cmp(recommended) != 0 {
+		t.Error("fees do not match the transaction pool:", tpfg.Minimum, tpfg.Recommended)
+	}
+	if tpfg.Recommended.Cmp(tpfg.Minimum) <= 0 {
+		t.Error("recommended fee should exceed the minimum fee")
+	}
+	size, count := st.tpool.TransactionPoolSize()
+	if tpfg.PoolSize != size || tpfg.TransactionSets != count {
+		t.Error("pool size does not match the transaction pool:", tpfg.PoolSize, tpfg.TransactionSets)
+	}
+	if count == 0 {
+		t.Error("transaction pool should not be empty")
+	}
+}
+
+// TestIntegrationTransactionPoolTransactions checks the GET call to the
+// /transactionpool/transactions endpoint, including the address filter.
+func TestIntegrationTransactionPoolTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationTransactionPoolTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Send coins to a new address, putting a transaction set into the pool.
+	uc, err := st.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := uc.UnlockHash()
+	_, err = st.wallet.SendSiacoins(types.NewCurrency64(1e3), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tpg TransactionPoolGET
+	err = st.getAPI("/transactionpool/transactions", &tpg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, count := st.tpool.TransactionPoolSize()
+	if len(tpg.TransactionSets) != count || count == 0 {
+		t.Fatal("wrong number of transaction sets:", len(tpg.TransactionSets), count)
+	}
+	found := false
+	for _, set := range tpg.TransactionSets {
+		setSize := 0
+		for _, txn := range set.Transactions {
+			if txn.ID != txn.Transaction.ID() {
+				t.Error("transaction id does not match the transaction")
+			}
+			setSize += txn.Size
+			for _, sco := range txn.Transaction.SiacoinOutputs {
+				if sco.UnlockHash == addr {
+					found = true
+				}
+			}
+		}
+		if set.Size != setSize {
+			t.Error("set size does not match the size of its transactions")
+		}
+	}
+	if !found {
+		t.Error("payment was not found in the transaction pool")
+	}
+
+	// Filter by the address that was paid.
+	err = st.getAPI("/transactionpool/transactions?address="+addr.String(), &tpg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpg.TransactionSets) != 1 {
+		t.Error("expected one transaction set paying to the address, got", len(tpg.TransactionSets))
+	}
+
+	// Filter by an address that is not in the transaction pool.
+	err = st.getAPI("/transactionpool/transactions?address="+types.UnlockHash{1}.String(), &tpg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpg.TransactionSets) != 0 || len(tpg.Transactions) != 0 {
+		t.Error("expected no transactions for an unused address")
+	}
+
+	// Try an invalid address.
+	err = st.getAPI("/transactionpool/transactions?address=foo", &tpg)
+	if err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -369,6 +370,93 @@ func (srv *Server) walletTransactionHandler(w http.ResponseWriter, req *http.Req
 	})
 }
 
+// fundTypeSpecifiers maps the fund types accepted by the 'fundtype' parameter
+// of /wallet/transactions to the specifiers of the inputs and outputs that
+// move those funds.
+var fundTypeSpecifiers = map[string][]types.Specifier{
+	"siacoin": {types.SpecifierClaimOutput, types.SpecifierMinerFee, types.SpecifierMinerPayout, types.SpecifierSiacoinInput, types.SpecifierSiacoinOutput},
+	"siafund": {types.SpecifierSiafundInput, types.SpecifierSiafundOutput},
+}
+
+// walletTransactionFilter selects the processed transactions that have an
+// input or output of the desired fund type with a value in the desired range.
+type walletTransactionFilter struct {
+	fundTypes []types.Specifier
+	min       *types.Currency
+	max       *types.Currency
+}
+
+// matches returns true if the fund type and value are selected by the filter.
+func (wtf walletTransactionFilter) matches(fundType types.Specifier, value types.Currency) bool {
+	if wtf.fundTypes != nil {
+		found := false
+		for _, ft := range wtf.fundTypes {
+			if ft == fundType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if wtf.min != nil && value.Cmp(*wtf.min) < 0 {
+		return false
+	}
+	if wtf.max != nil && value.Cmp(*wtf.max) > 0 {
+		return false
+	}
+	return true
+}
+
+// filter returns the processed transactions that are selected by the filter.
+func (wtf walletTransactionFilter) filter(pts []modules.ProcessedTransaction) []modules.ProcessedTransaction {
+	if wtf.fundTypes == nil && wtf.min == nil && wtf.max == nil {
+		return pts
+	}
+	filtered := []modules.ProcessedTransaction{}
+	for _, pt := range pts {
+		selected := false
+		for _, input := range pt.Inputs {
+			selected = selected || wtf.matches(input.FundType, input.Value)
+		}
+		for _, output := range pt.Outputs {
+			selected = selected || wtf.matches(output.FundType, output.Value)
+		}
+		if selected {
+			filtered = append(filtered, pt)
+		}
+	}
+	return filtered
+}
+
+// scanWalletTransactionFilter reads the 'min', 'max' and 'fundtype'
+// parameters of a call to /wallet/transactions.
+func scanWalletTransactionFilter(req *http.Request) (wtf walletTransactionFilter, err error) {
+	if req.FormValue("min") != "" {
+		min, ok := scanAmount(req.FormValue("min"))
+		if !ok {
+			return walletTransactionFilter{}, errors.New("could not read 'min'")
+		}
+		wtf.min = &min
+	}
+	if req.FormValue("max") != "" {
+		max, ok := scanAmount(req.FormValue("max"))
+		if !ok {
+			return walletTransactionFilter{}, errors.New("could not read 'max'")
+		}
+		wtf.max = &max
+	}
+	if req.FormValue("fundtype") != "" {
+		fundTypes, exists := fundTypeSpecifiers[req.FormValue("fundtype")]
+		if !exists {
+			return walletTransactionFilter{}, errors.New("'fundtype' must be 'siacoin' or 'siafund'")
+		}
+		wtf.fundTypes = fundTypes
+	}
+	return wtf, nil
+}
+
 // walletTransactionsHandler handles API calls to /wallet/transactions.
 func (srv *Server) walletTransactionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Get the start and end blocks.
@@ -382,6 +470,11 @@ func (srv *Server) walletTransactionsHandler(w http.ResponseWriter, req *http.Re
 		writeError(w, "error after call to /wallet/transactions: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	wtf, err := scanWalletTransactionFilter(req)
+	if err != nil {
+		writeError(w, "error after call to /wallet/transactions: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	confirmedTxns, err := srv.wallet.Transactions(types.BlockHeight(start), types.BlockHeight(end))
 	if err != nil {
 		writeError(w, "error after call to /wallet/transactions: "+err.Error(), http.StatusBadRequest)
@@ -390,8 +483,8 @@ func (srv *Server) walletTransactionsHandler(w http.ResponseWriter, req *http.Re
 	unconfirmedTxns := srv.wallet.UnconfirmedTransactions()
 
 	writeJSON(w, WalletTransactionsGET{
-		ConfirmedTransactions:   confirmedTxns,
-		UnconfirmedTransactions: unconfirmedTxns,
+		ConfirmedTransactions:   wtf.filter(confirmedTxns),
+		UnconfirmedTransactions: wtf.filter(unconfirmedTxns),
 	})
 }
 
@@ -32,7 +32,7 @@ func TestIntegrationWalletGETEncrypted(t *testing.T) {
 	if err != nil {
 		t.Fatal("Failed to create consensus set:", err)
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		t.Fatal("Failed to create tpool:", err)
 	}
@@ -97,7 +97,7 @@ func TestIntegrationWalletBlankEncrypt(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -283,3 +283,84 @@ func TestIntegrationWalletTransactionGETid(t *testing.T) {
 		t.Error("fund type should be a miner payout")
 	}
 }
+
+// TestIntegrationWalletTransactionsGETfilter checks that the /wallet/transactions
+// call filters transactions by value and fund type.
+func TestIntegrationWalletTransactionsGETfilter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createServerTester("TestIntegrationWalletTransactionsGETfilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var all WalletTransactionsGET
+	err = st.getAPI("/wallet/transactions?startheight=0&endheight=10", &all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.ConfirmedTransactions) < 2 {
+		t.Fatal("expecting a few wallet transactions, corresponding to miner payouts.")
+	}
+
+	// Filter by the exact value of the first miner payout.
+	payout := all.ConfirmedTransactions[0].Outputs[0].Value
+	var wtg WalletTransactionsGET
+	err = st.getAPI(fmt.Sprintf("/wallet/transactions?startheight=0&endheight=10&min=%v&max=%v", payout, payout), &wtg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wtg.ConfirmedTransactions) == 0 || len(wtg.ConfirmedTransactions) >= len(all.ConfirmedTransactions) {
+		t.Fatal("wrong number of transactions in the value range:", len(wtg.ConfirmedTransactions))
+	}
+	for _, pt := range wtg.ConfirmedTransactions {
+		found := false
+		for _, output := range pt.Outputs {
+			if output.Value.Cmp(payout) == 0 {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("transaction outside of the value range was returned")
+		}
+	}
+
+	// A minimum above every payout should return nothing.
+	err = st.getAPI(fmt.Sprintf("/wallet/transactions?startheight=0&endheight=10&min=%v", types.CalculateCoinbase(0)), &wtg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wtg.ConfirmedTransactions) != 0 {
+		t.Error("expected no transactions above the minimum, got", len(wtg.ConfirmedTransactions))
+	}
+
+	// Miner payouts are siacoin transactions.
+	err = st.getAPI("/wallet/transactions?startheight=0&endheight=10&fundtype=siacoin", &wtg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wtg.ConfirmedTransactions) != len(all.ConfirmedTransactions) {
+		t.Error("siacoin filter dropped transactions:", len(wtg.ConfirmedTransactions))
+	}
+	err = st.getAPI("/wallet/transactions?startheight=0&endheight=10&fundtype=siafund", &wtg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range wtg.ConfirmedTransactions {
+		if len(pt.Outputs) == 1 && pt.Outputs[0].FundType == types.SpecifierMinerPayout {
+			t.Error("siafund filter returned a miner payout")
+		}
+	}
+
+	// Try some invalid parameters.
+	err = st.getAPI("/wallet/transactions?startheight=0&endheight=10&fundtype=foo", &wtg)
+	if err == nil {
+		t.Error("expected an error for an invalid fund type")
+	}
+	err = st.getAPI("/wallet/transactions?startheight=0&endheight=10&min=foo", &wtg)
+	if err == nil {
+		t.Error("expected an error for an invalid minimum")
+	}
+}
@@ -3,6 +3,8 @@ package modules
 import (
 	"errors"
 
+	"golang.org/x/net/context"
+
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -50,6 +52,11 @@ var (
 	// in a fork that is the heaviest known fork - the consensus set has not
 	// changed as a result of seeing the block.
 	ErrNonExtendingBlock = errors.New("block does not extend the longest fork")
+
+	// ErrUnrecognizedFileContractID indicates that a file contract could not
+	// be found in the consensus set, either because it was never created or
+	// because it has already expired or been resolved.
+	ErrUnrecognizedFileContractID = errors.New("cannot fetch storage proof segment for unknown file contract")
 )
 
 type (
@@ -186,6 +193,12 @@ type (
 		// described by the ConsensusChangeX variables in this package.
 		ConsensusSetSubscribe(ConsensusSetSubscriber, ConsensusChangeID) error
 
+		// ConsensusSetSubscribeWithContext is the same as
+		// ConsensusSetSubscribe, except that the catch-up stops if 'ctx' is
+		// cancelled, in which case the subscriber is removed and the error of
+		// the context is returned.
+		ConsensusSetSubscribeWithContext(context.Context, ConsensusSetSubscriber, ConsensusChangeID) error
+
 		// CurrentBlock returns the latest block in the heaviest known
 		// blockchain.
 		CurrentBlock() types.Block
@@ -883,7 +883,10 @@ func TestTaxHardfork(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fcIndex := txnBuilder.AddFileContract(fc)
+	fcIndex, err := txnBuilder.AddFileContract(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		t.Fatal(err)
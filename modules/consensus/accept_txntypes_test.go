@@ -110,7 +110,10 @@ func (cst *consensusSetTester) testSpendSiacoinsBlock() {
 	if err != nil {
 		panic(err)
 	}
-	outputIndex := txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: txnValue, UnlockHash: destAddr})
+	outputIndex, err := txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: txnValue, UnlockHash: destAddr})
+	if err != nil {
+		panic(err)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		panic(err)
@@ -202,7 +205,10 @@ func (cst *consensusSetTester) testValidStorageProofBlocks() {
 	if err != nil {
 		panic(err)
 	}
-	fcIndex := txnBuilder.AddFileContract(fc)
+	fcIndex, err := txnBuilder.AddFileContract(fc)
+	if err != nil {
+		panic(err)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		panic(err)
@@ -326,7 +332,10 @@ func (cst *consensusSetTester) testMissedStorageProofBlocks() {
 	if err != nil {
 		panic(err)
 	}
-	fcIndex := txnBuilder.AddFileContract(fc)
+	fcIndex, err := txnBuilder.AddFileContract(fc)
+	if err != nil {
+		panic(err)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		panic(err)
@@ -461,7 +470,10 @@ func (cst *consensusSetTester) testFileContractRevision() {
 	if err != nil {
 		panic(err)
 	}
-	fcIndex := txnBuilder.AddFileContract(fc)
+	fcIndex, err := txnBuilder.AddFileContract(fc)
+	if err != nil {
+		panic(err)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		panic(err)
@@ -573,7 +585,10 @@ func (cst *consensusSetTester) testSpendSiafunds() {
 	if err != nil {
 		panic(err)
 	}
-	outputIndex := txnBuilder.AddSiafundOutput(types.SiafundOutput{Value: txnValue, UnlockHash: destAddr})
+	outputIndex, err := txnBuilder.AddSiafundOutput(types.SiafundOutput{Value: txnValue, UnlockHash: destAddr})
+	if err != nil {
+		panic(err)
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		panic(err)
@@ -775,7 +790,10 @@ func (cst *consensusSetTester) testPaymentChannelBlocks() error {
 	if err != nil {
 		return err
 	}
-	scoFundIndex := fundTxnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: channelSize, UnlockHash: channelFundingAddr})
+	scoFundIndex, err := fundTxnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: channelSize, UnlockHash: channelFundingAddr})
+	if err != nil {
+		return err
+	}
 	fundTxnSet, err := fundTxnBuilder.Sign(true)
 	if err != nil {
 		return err
@@ -952,7 +970,10 @@ func (cst *consensusSetTester) testPaymentChannelBlocks() error {
 		if err != nil {
 			return err
 		}
-		scoFundIndex := fundTxnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: channelSize, UnlockHash: channelFundingAddr})
+		scoFundIndex, err := fundTxnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: channelSize, UnlockHash: channelFundingAddr})
+		if err != nil {
+			return err
+		}
 		fundTxnSet, err := fundTxnBuilder.Sign(true)
 		if err != nil {
 			return err
@@ -1073,7 +1094,10 @@ func (cst *consensusSetTester) testPaymentChannelBlocks() error {
 		if err != nil {
 			return err
 		}
-		scoFundIndex := fundTxnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: channelSize, UnlockHash: channelFundingAddr})
+		scoFundIndex, err := fundTxnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: channelSize, UnlockHash: channelFundingAddr})
+		if err != nil {
+			return err
+		}
 		fundTxnSet, err := fundTxnBuilder.Sign(true)
 		if err != nil {
 			return err
@@ -1,6 +1,8 @@
 package consensus
 
 import (
+	"golang.org/x/net/context"
+
 	"github.com/NebulousLabs/Sia/modules"
 
 	"github.com/NebulousLabs/bolt"
@@ -98,11 +100,13 @@ func (cs *ConsensusSet) readlockUpdateSubscribers(ce changeEntry) {
 }
 
 // initializeSubscribe will take a subscriber and feed them all of the
-// consensus changes that have occurred since the change provided.
+// consensus changes that have occurred since the change provided. If 'ctx' is
+// cancelled, no more changes are sent and the error of the context is
+// returned.
 //
 // As a special case, using an empty id as the start will have all the changes
 // sent to the modules starting with the genesis block.
-func (cs *ConsensusSet) initializeSubscribe(subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
+func (cs *ConsensusSet) initializeSubscribe(ctx context.Context, subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
 	return cs.db.View(func(tx *bolt.Tx) error {
 		// 'exists' and 'entry' are going to be pointed to the first entry that
 		// has not yet been seen by subscriber.
@@ -141,6 +145,11 @@ func (cs *ConsensusSet) initializeSubscribe(subscriber modules.ConsensusSetSubsc
 
 		// Send all remaining consensus changes to the subscriber.
 		for exists {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
 			cc, err := cs.computeConsensusChange(tx, entry)
 			if err != nil {
 				return err
@@ -165,7 +174,7 @@ func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSub
 	defer cs.mu.DemotedUnlock()
 
 	// Get the input module caught up to the currenct consnesus set.
-	err := cs.initializeSubscribe(subscriber, start)
+	err := cs.initializeSubscribe(context.Background(), subscriber, start)
 	if err != nil {
 		return err
 	}
@@ -173,6 +182,21 @@ func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSub
 	return nil
 }
 
+// ConsensusSetSubscribeWithContext is the same as ConsensusSetSubscribe,
+// except that the catch-up stops as soon as 'ctx' is cancelled. The subscriber
+// is then removed and the error of the context is returned.
+func (cs *ConsensusSet) ConsensusSetSubscribeWithContext(ctx context.Context, subscriber modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
+	cs.mu.Lock()
+	cs.subscribers = append(cs.subscribers, subscriber)
+	cs.mu.Demote()
+	err := cs.initializeSubscribe(ctx, subscriber, start)
+	cs.mu.DemotedUnlock()
+	if err != nil && err == ctx.Err() {
+		cs.Unsubscribe(subscriber)
+	}
+	return err
+}
+
 // Unsubscribe removes a subscriber from the list of subscribers, allowing for
 // garbage collection and rescanning. If the subscriber is not found in the
 // subscriber database, no action is taken.
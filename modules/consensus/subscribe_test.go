@@ -3,6 +3,8 @@ package consensus
 import (
 	"testing"
 
+	"golang.org/x/net/context"
+
 	"github.com/NebulousLabs/Sia/modules"
 )
 
@@ -97,3 +99,46 @@ func TestUnitUnsubscribe(t *testing.T) {
 		t.Error("mock subscriber was not correctly unsubscribed")
 	}
 }
+
+// cancellingSubscriber cancels its context after receiving its first change.
+type cancellingSubscriber struct {
+	mockSubscriber
+	cancel func()
+}
+
+// ProcessConsensusChange adds a consensus change to the subscriber and cancels
+// the context.
+func (cs *cancellingSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	cs.mockSubscriber.ProcessConsensusChange(cc)
+	cs.cancel()
+}
+
+// TestUnitSubscribeWithContext checks that the consensus set stops catching up
+// a subscriber once the context is cancelled, and removes the subscriber.
+func TestUnitSubscribeWithContext(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	cst, err := createConsensusSetTester("TestUnitSubscribeWithContext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cs := &cancellingSubscriber{cancel: cancel}
+	err = cst.cs.ConsensusSetSubscribeWithContext(ctx, cs, modules.ConsensusChangeBeginning)
+	if err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if len(cs.updates) != 1 {
+		t.Fatal("subscriber received changes after the context was cancelled:", len(cs.updates))
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.updates) != 1 {
+		t.Error("cancelled subscriber was not removed")
+	}
+}
@@ -23,7 +23,6 @@ var (
 	errSiacoinInputOutputMismatch = errors.New("siacoin inputs do not equal siacoin outputs for transaction")
 	errSiafundInputOutputMismatch = errors.New("siafund inputs do not equal siafund outputs for transaction")
 	errUnfinishedFileContract     = errors.New("file contract window has not yet openend")
-	errUnrecognizedFileContractID = errors.New("cannot fetch storage proof segment for unknown file contract")
 	errWrongUnlockConditions      = errors.New("transaction contains incorrect unlock conditions")
 )
 
@@ -64,7 +63,7 @@ func storageProofSegment(tx *bolt.Tx, fcid types.FileContractID) (uint64, error)
 	fcBucket := tx.Bucket(FileContracts)
 	fcBytes := fcBucket.Get(fcid[:])
 	if fcBytes == nil {
-		return 0, errUnrecognizedFileContractID
+		return 0, modules.ErrUnrecognizedFileContractID
 	}
 
 	// Decode the file contract.
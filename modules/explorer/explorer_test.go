@@ -42,7 +42,7 @@ func createExplorerTester(name string) (*explorerTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +62,7 @@ func createExplorerTester(name string) (*explorerTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	m, err := miner.New(cs, tp, w, filepath.Join(testdir, modules.RenterDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(testdir, modules.RenterDir))
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +109,7 @@ func (et *explorerTester) reorgToBlank() error {
 	if err != nil {
 		return err
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(dir, modules.TransactionPoolDir))
 	if err != nil {
 		return err
 	}
@@ -129,7 +129,7 @@ func (et *explorerTester) reorgToBlank() error {
 	if err != nil {
 		return err
 	}
-	m, err := miner.New(cs, tp, w, filepath.Join(dir, modules.RenterDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(dir, modules.RenterDir))
 	if err != nil {
 		return err
 	}
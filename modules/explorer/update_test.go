@@ -62,7 +62,7 @@ func TestIntegrationExplorerFileContractMetrics(t *testing.T) {
 		ValidProofOutputs:  fcOutputs,
 		MissedProofOutputs: fcOutputs,
 	}
-	_ = builder.AddFileContract(fc)
+	builder.AddFileContract(fc)
 	txns, err := builder.Sign(true)
 	if err != nil {
 		t.Fatal(err)
@@ -113,7 +113,7 @@ func TestIntegrationExplorerFileContractMetrics(t *testing.T) {
 		ValidProofOutputs:  fcOutputs,
 		MissedProofOutputs: fcOutputs,
 	}
-	_ = builder.AddFileContract(fc)
+	builder.AddFileContract(fc)
 	txns, err = builder.Sign(true)
 	if err != nil {
 		t.Fatal(err)
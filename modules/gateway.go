@@ -2,6 +2,8 @@ package modules
 
 import (
 	"net"
+
+	"golang.org/x/net/context"
 )
 
 const (
@@ -67,9 +69,17 @@ type (
 		// Address returns the Gateway's address.
 		Address() NetAddress
 
+		// OutboundPeers returns the peers that the Gateway connected to, as
+		// opposed to the peers that connected to the Gateway.
+		OutboundPeers() []Peer
+
 		// Peers returns the addresses that the Gateway is currently connected to.
 		Peers() []Peer
 
+		// PeersByVersion returns the peers whose version is at least
+		// 'minVersion'. Peers with malformed versions are never returned.
+		PeersByVersion(minVersion string) []Peer
+
 		// RegisterRPC registers a function to handle incoming connections that
 		// supply the given RPC ID.
 		RegisterRPC(string, RPCFunc)
@@ -86,6 +96,15 @@ type (
 		// given peers in parallel.
 		Broadcast(name string, obj interface{}, peers []Peer)
 
+		// BroadcastExcept transmits obj, prefaced by the RPC name, to all of
+		// the given peers other than 'except' in parallel.
+		BroadcastExcept(name string, obj interface{}, peers []Peer, except NetAddress)
+
+		// BroadcastExceptWithContext is the same as BroadcastExcept, but stops
+		// sending obj once 'ctx' is cancelled. Peers that have not been sent
+		// obj yet are skipped, and unresponsive peers are not retried.
+		BroadcastExceptWithContext(ctx context.Context, name string, obj interface{}, peers []Peer, except NetAddress)
+
 		// Close safely stops the Gateway's listener process.
 		Close() error
 	}
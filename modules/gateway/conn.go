@@ -2,9 +2,29 @@ package gateway
 
 import (
 	"net"
+
+	"github.com/NebulousLabs/Sia/modules"
 )
 
+// peerAddr is the net.Addr of a peer, as known to the gateway.
+type peerAddr modules.NetAddress
+
+// Network implements net.Addr.
+func (pa peerAddr) Network() string { return "tcp" }
+
+// String implements net.Addr.
+func (pa peerAddr) String() string { return string(pa) }
+
 // peerConn is a simple type that implements the modules.PeerConn interface.
 type peerConn struct {
 	net.Conn
+	addr modules.NetAddress
+}
+
+// RemoteAddr returns the address that the gateway knows the peer by, so that
+// RPCs can pass it to the gateway to refer to the peer. It differs from the
+// address of the underlying connection for peers that were dialed by
+// hostname.
+func (pc *peerConn) RemoteAddr() net.Addr {
+	return peerAddr(pc.addr)
 }
@@ -57,7 +57,7 @@ func (p *peer) open() (modules.PeerConn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &peerConn{conn}, nil
+	return &peerConn{conn, p.NetAddress}, nil
 }
 
 func (p *peer) accept() (modules.PeerConn, error) {
@@ -65,7 +65,7 @@ func (p *peer) accept() (modules.PeerConn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &peerConn{conn}, nil
+	return &peerConn{conn, p.NetAddress}, nil
 }
 
 // addPeer adds a peer to the Gateway's peer list and spawns a listener thread
@@ -335,3 +335,31 @@ func (g *Gateway) Peers() []modules.Peer {
 	}
 	return peers
 }
+
+// OutboundPeers returns the connected peers that the Gateway dialed itself.
+func (g *Gateway) OutboundPeers() []modules.Peer {
+	id := g.mu.RLock()
+	defer g.mu.RUnlock(id)
+	var peers []modules.Peer
+	for _, p := range g.peers {
+		if !p.Inbound {
+			peers = append(peers, p.Peer)
+		}
+	}
+	return peers
+}
+
+// PeersByVersion returns the connected peers whose version is at least
+// 'minVersion'. Peers that reported a malformed version are treated as being
+// below every version.
+func (g *Gateway) PeersByVersion(minVersion string) []modules.Peer {
+	id := g.mu.RLock()
+	defer g.mu.RUnlock(id)
+	var peers []modules.Peer
+	for _, p := range g.peers {
+		if build.IsVersion(p.Version) && build.VersionCmp(p.Version, minVersion) >= 0 {
+			peers = append(peers, p.Peer)
+		}
+	}
+	return peers
+}
@@ -66,6 +66,52 @@ func TestRandomInboundPeer(t *testing.T) {
 	}
 }
 
+// TestPeersByVersion checks that PeersByVersion and OutboundPeers filter the
+// connected peers correctly.
+func TestPeersByVersion(t *testing.T) {
+	g := newTestingGateway("TestPeersByVersion", t)
+	defer g.Close()
+	id := g.mu.Lock()
+	for _, p := range []modules.Peer{
+		{NetAddress: "foo.com:1", Version: "0.0.0", Inbound: true},
+		{NetAddress: "foo.com:2", Version: "0.4.6"},
+		{NetAddress: "foo.com:3", Version: "0.4.7", Inbound: true},
+		{NetAddress: "foo.com:4", Version: "9.9.9"},
+		{NetAddress: "foo.com:5", Version: "foo"},
+		{NetAddress: "foo.com:6", Version: "bar.9"},
+	} {
+		g.addPeer(&peer{
+			Peer: p,
+			sess: muxado.Client(new(dummyConn)),
+		})
+	}
+	g.mu.Unlock(id)
+
+	peers := g.PeersByVersion("0.4.7")
+	if len(peers) != 2 {
+		t.Fatal("expected 2 peers with version >= v0.4.7, got", len(peers))
+	}
+	for _, p := range peers {
+		if p.Version != "0.4.7" && p.Version != "9.9.9" {
+			t.Error("PeersByVersion returned a peer with version < v0.4.7:", p.Version)
+		}
+	}
+	// Malformed versions should be below every version.
+	if len(g.PeersByVersion("0")) != 4 {
+		t.Error("PeersByVersion returned peers with malformed versions")
+	}
+
+	peers = g.OutboundPeers()
+	if len(peers) != 4 {
+		t.Fatal("expected 4 outbound peers, got", len(peers))
+	}
+	for _, p := range peers {
+		if p.Inbound {
+			t.Error("OutboundPeers returned an inbound peer")
+		}
+	}
+}
+
 func TestListen(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
@@ -134,11 +136,51 @@ func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 // object and disconnect. This is why Broadcast takes an interface{} instead of
 // an RPCFunc.
 func (g *Gateway) Broadcast(name string, obj interface{}, peers []modules.Peer) {
+	g.broadcast(context.Background(), name, obj, peers)
+}
+
+// BroadcastExcept calls Broadcast on all of the specified peers other than
+// 'except'. It is used to relay an object without sending it back to the peer
+// that it came from.
+func (g *Gateway) BroadcastExcept(name string, obj interface{}, peers []modules.Peer, except modules.NetAddress) {
+	g.BroadcastExceptWithContext(context.Background(), name, obj, peers, except)
+}
+
+// BroadcastExceptWithContext calls BroadcastExcept, but stops broadcasting
+// once 'ctx' is cancelled. Writes to peers that are in progress are aborted by
+// closing the connection, and peers that failed are not retried.
+func (g *Gateway) BroadcastExceptWithContext(ctx context.Context, name string, obj interface{}, peers []modules.Peer, except modules.NetAddress) {
+	var filtered []modules.Peer
+	for _, p := range peers {
+		if p.NetAddress != except {
+			filtered = append(filtered, p)
+		}
+	}
+	g.broadcast(ctx, name, obj, filtered)
+}
+
+// broadcast calls a one-way RPC on all of the specified peers in parallel,
+// retrying each peer that fails once. The broadcast stops once 'ctx' is
+// cancelled.
+func (g *Gateway) broadcast(ctx context.Context, name string, obj interface{}, peers []modules.Peer) {
 	g.log.Printf("INFO: broadcasting RPC \"%v\" to %v peers", name, len(peers))
 
 	// only encode obj once, instead of using WriteObject
 	enc := encoding.Marshal(obj)
 	fn := func(conn modules.PeerConn) error {
+		// Close the connection if the context is cancelled, so that a write
+		// to an unresponsive peer does not hold up the broadcast.
+		if ctx.Done() != nil {
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+		}
 		return encoding.WritePrefix(conn, enc)
 	}
 
@@ -146,13 +188,20 @@ func (g *Gateway) Broadcast(name string, obj interface{}, peers []modules.Peer)
 	wg.Add(len(peers))
 	for _, p := range peers {
 		go func(addr modules.NetAddress) {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
 			err := g.RPC(addr, name, fn)
 			if err != nil {
 				// try one more time before giving up
-				time.Sleep(10 * time.Second)
+				select {
+				case <-time.After(10 * time.Second):
+				case <-ctx.Done():
+					return
+				}
 				g.RPC(addr, name, fn)
 			}
-			wg.Done()
 		}(p.NetAddress)
 	}
 	wg.Wait()
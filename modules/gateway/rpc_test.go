@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 )
@@ -284,6 +286,47 @@ func TestBroadcast(t *testing.T) {
 	}
 }
 
+// TestBroadcastExcept tests that BroadcastExcept does not broadcast to the
+// excluded peer.
+func TestBroadcastExcept(t *testing.T) {
+	g1 := newTestingGateway("TestBroadcastExcept1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestBroadcastExcept2", t)
+	defer g2.Close()
+	g3 := newTestingGateway("TestBroadcastExcept3", t)
+	defer g3.Close()
+
+	err := g1.Connect(g2.Address())
+	if err != nil {
+		t.Fatal("failed to connect:", err)
+	}
+	err = g1.Connect(g3.Address())
+	if err != nil {
+		t.Fatal("failed to connect:", err)
+	}
+
+	g2DoneChan := make(chan struct{}, 1)
+	g3DoneChan := make(chan struct{}, 1)
+	g2.RegisterRPC("Recv", func(conn modules.PeerConn) error {
+		g2DoneChan <- struct{}{}
+		return nil
+	})
+	g3.RegisterRPC("Recv", func(conn modules.PeerConn) error {
+		g3DoneChan <- struct{}{}
+		return nil
+	})
+
+	g1.BroadcastExcept("Recv", "foo", g1.Peers(), g2.Address())
+	select {
+	case <-g2DoneChan:
+		t.Error("broadcast was sent to the excluded peer")
+	case <-g3DoneChan:
+		// Only g3 should receive the broadcast.
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("g3 did not receive the broadcast")
+	}
+}
+
 // TestOutboundAndInboundRPCs tests that both inbound and outbound connections
 // can successfully make RPC calls.
 func TestOutboundAndInboundRPCs(t *testing.T) {
@@ -379,3 +422,86 @@ func TestCallingRPCFromRPC(t *testing.T) {
 		t.Fatal("expected BAR RPC to be called")
 	}
 }
+
+// TestBroadcastExceptWithContext checks that a cancelled broadcast is not sent
+// to peers, and that unresponsive peers are not retried once the broadcast is
+// cancelled.
+func TestBroadcastExceptWithContext(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g1 := newTestingGateway("TestBroadcastExceptWithContext1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestBroadcastExceptWithContext2", t)
+	defer g2.Close()
+
+	err := g1.Connect(g2.Address())
+	if err != nil {
+		t.Fatal("failed to connect:", err)
+	}
+	g2DoneChan := make(chan struct{}, 1)
+	g2.RegisterRPC("Recv", func(conn modules.PeerConn) error {
+		g2DoneChan <- struct{}{}
+		return nil
+	})
+
+	// A broadcast with a cancelled context should not reach any peer.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g1.BroadcastExceptWithContext(ctx, "Recv", "foo", g1.Peers(), "")
+	select {
+	case <-g2DoneChan:
+		t.Error("cancelled broadcast was sent")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// A live context should broadcast as usual.
+	g1.BroadcastExceptWithContext(context.Background(), "Recv", "foo", g1.Peers(), "")
+	select {
+	case <-g2DoneChan:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("g2 did not receive the broadcast")
+	}
+
+	// A peer that the gateway is not connected to fails and would normally
+	// be retried after a delay. Cancelling the context should end the
+	// broadcast without waiting for the retry.
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	g1.BroadcastExceptWithContext(ctx, "Recv", "foo", []modules.Peer{{NetAddress: "foo.com:123"}}, "")
+	if time.Since(start) > time.Second {
+		t.Error("cancelled broadcast waited to retry an unresponsive peer")
+	}
+}
+
+// TestPeerConnRemoteAddr checks that the connections of an RPC report the
+// address that the gateway knows the peer by, even when the peer was dialed
+// by hostname.
+func TestPeerConnRemoteAddr(t *testing.T) {
+	g1 := newTestingGateway("TestPeerConnRemoteAddr1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestPeerConnRemoteAddr2", t)
+	defer g2.Close()
+
+	g2.RegisterRPC("PING", func(conn modules.PeerConn) error { return nil })
+	hostAddr := modules.NetAddress("localhost:" + g2.Address().Port())
+	err := g1.Connect(hostAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remote modules.NetAddress
+	err = g1.RPC(hostAddr, "PING", func(conn modules.PeerConn) error {
+		remote = modules.NetAddress(conn.RemoteAddr().String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote != hostAddr {
+		t.Fatalf("connection reported the remote address %v, expected %v", remote, hostAddr)
+	}
+}
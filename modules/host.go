@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"io"
+
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -53,6 +55,12 @@ type (
 		MaxCollateralFraction types.Currency `json:"maxcollateralfraction"`
 		MaxCollateral         types.Currency `json:"maxcollateral"`
 
+		// CollateralRatio is the collateral that the host offers relative to
+		// its storage price. When the ratio is non-zero, the host advertises
+		// its effective storage price multiplied by the ratio as its
+		// collateral, and the static Collateral above is ignored.
+		CollateralRatio float64 `json:"collateralratio"`
+
 		DownloadLimitGrowth uint64 `json:"downloadlimitgrowth"` // Bytes per second that get added to the limit for how much download bandwidth the host is allowed to use.
 		DownloadLimitCap    uint64 `json:"downloadlimitcap"`    // The maximum size of the limit for how much download bandwidth the host is allowed to use.
 		DownloadSpeedLimit  uint64 `json:"downloadspeedlimit"`  // The maximum download speed for all combined host connections.
@@ -64,6 +72,39 @@ type (
 		MinimumDownloadBandwidthPrice types.Currency `json:"minimumdownloadbandwidthprice"`
 		MinimumStoragePrice           types.Currency `json:"storageprice"`
 		MinimumUploadBandwidthPrice   types.Currency `json:"minimumuploadbandwidthprice"`
+
+		// The effective prices are the prices that the host is currently
+		// advertising. They equal the static prices above unless dynamic
+		// pricing is enabled. The effective prices are reported by
+		// InternalSettings and ignored by SetInternalSettings.
+		EffectiveDownloadBandwidthPrice types.Currency `json:"effectivedownloadbandwidthprice"`
+		EffectiveStoragePrice           types.Currency `json:"effectivestorageprice"`
+		EffectiveUploadBandwidthPrice   types.Currency `json:"effectiveuploadbandwidthprice"`
+
+		// EffectiveCollateral is the collateral that the host is currently
+		// advertising, and EffectiveMaxCollateral is the most collateral the
+		// host can put into a new contract, limited by MaxCollateral, the
+		// remaining collateral budget and the confirmed balance of the
+		// wallet. Both are reported by InternalSettings and ignored by
+		// SetInternalSettings.
+		EffectiveCollateral    types.Currency `json:"effectivecollateral"`
+		EffectiveMaxCollateral types.Currency `json:"effectivemaxcollateral"`
+	}
+
+	// DynamicPricingConfig sets the bounds within which the host adjusts its
+	// storage and bandwidth prices based on how much of its storage is in
+	// use. Prices are at the minimum when the storage folders are empty and
+	// at the maximum when they are full. When dynamic pricing is disabled,
+	// the host uses the static prices of its internal settings.
+	DynamicPricingConfig struct {
+		Enabled bool `json:"enabled"`
+
+		MinDownloadBandwidthPrice types.Currency `json:"mindownloadbandwidthprice"`
+		MaxDownloadBandwidthPrice types.Currency `json:"maxdownloadbandwidthprice"`
+		MinStoragePrice           types.Currency `json:"minstorageprice"`
+		MaxStoragePrice           types.Currency `json:"maxstorageprice"`
+		MinUploadBandwidthPrice   types.Currency `json:"minuploadbandwidthprice"`
+		MaxUploadBandwidthPrice   types.Currency `json:"maxuploadbandwidthprice"`
 	}
 
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
@@ -81,18 +122,47 @@ type (
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
 	}
 
+	// HostContract describes a storage obligation that the host is actively
+	// managing.
+	HostContract struct {
+		ID               types.FileContractID `json:"id"`
+		DataSize         uint64               `json:"datasize"`
+		ExpirationHeight types.BlockHeight    `json:"expirationheight"`
+		LockedCollateral types.Currency       `json:"lockedcollateral"`
+	}
+
 	// A Host can take storage from disk and offer it to the network, managing
 	// things such as announcements, settings, and implementing all of the RPCs
 	// of the host protocol.
 	Host interface {
+		// AcceptingContracts returns whether the host is accepting new file
+		// contracts.
+		AcceptingContracts() bool
+
 		// Announce submits a host announcement to the blockchain.
 		Announce() error
 
 		// AnnounceAddress submits an announcement using the given address.
 		AnnounceAddress(NetAddress) error
 
+		// BandwidthUsage returns the total number of bytes that the host has
+		// uploaded and downloaded over its lifetime.
+		BandwidthUsage() (up, down uint64)
+
+		// BackupContracts writes all of the storage obligations held by the
+		// host to the provided writer.
+		BackupContracts(io.Writer) error
+
 		ExternalSettings() HostExternalSettings
 
+		// Contracts returns the storage obligations that the host is
+		// actively managing, ordered by expiration height.
+		Contracts() []HostContract
+
+		// ContractRejections returns the number of file contract proposals
+		// that the host has rejected since startup, grouped by reason.
+		ContractRejections() map[RejectionReason]uint64
+
 		// FinancialMetrics returns the financial statistics of the host.
 		FinancialMetrics() HostFinancialMetrics
 
@@ -103,9 +173,40 @@ type (
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
+		// RestoreContracts reads storage obligations written by
+		// BackupContracts, re-validating them against the consensus set
+		// before the host resumes managing them.
+		RestoreContracts(io.Reader) error
+
+		// SetAcceptingContracts sets whether the host accepts new file
+		// contracts. Existing contracts are still revised, downloaded from
+		// and proven while the host is not accepting contracts.
+		SetAcceptingContracts(bool) error
+
+		// SetBandwidthLimit sets the maximum number of bytes per second that
+		// the host will upload and download. A limit of zero means unlimited.
+		SetBandwidthLimit(uploadBytesPerSec, downloadBytesPerSec int64) error
+
+		// SetCollateralRatio sets the collateral that the host offers
+		// relative to its storage price. A ratio of zero makes the host offer
+		// the static collateral of its internal settings.
+		SetCollateralRatio(ratio float64) error
+
+		// SetDownloadBandwidthPrice sets the price per byte that the host
+		// charges for downloads.
+		SetDownloadBandwidthPrice(types.Currency) error
+
+		// SetDynamicPricing configures the host to adjust its storage and
+		// bandwidth prices within the given bounds as its storage fills up.
+		SetDynamicPricing(DynamicPricingConfig) error
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
+		// SetUploadBandwidthPrice sets the price per byte that the host
+		// charges for uploads.
+		SetUploadBandwidthPrice(types.Currency) error
+
 		// The storage manager provides an interface for adding and removing
 		// storage folders and data sectors to the host.
 		StorageManager
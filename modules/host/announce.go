@@ -11,6 +11,10 @@ var (
 	// is locked.
 	errAnnWalletLocked = errors.New("cannot announce the host while the wallet is locked")
 
+	// errAnnUnreachable is returned if the host is unable to connect to the
+	// address that it has been asked to announce.
+	errAnnUnreachable = errors.New("cannot announce the host, the address is not reachable")
+
 	// errUnknownAddress is returned if the host is unable to determine a
 	// public address for itself to use in the announcement.
 	errUnknownAddress = errors.New("host cannot announce, does not seem to have a valid address.")
@@ -44,8 +48,16 @@ func (h *Host) announce(addr modules.NetAddress) error {
 		txnBuilder.Drop()
 		return err
 	}
-	_ = txnBuilder.AddMinerFee(fee)
-	_ = txnBuilder.AddArbitraryData(signedAnnouncement)
+	_, err = txnBuilder.AddMinerFee(fee)
+	if err != nil {
+		txnBuilder.Drop()
+		return err
+	}
+	_, err = txnBuilder.AddArbitraryData(signedAnnouncement)
+	if err != nil {
+		txnBuilder.Drop()
+		return err
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		txnBuilder.Drop()
@@ -86,8 +98,22 @@ func (h *Host) Announce() error {
 }
 
 // AnnounceAddress submits a host announcement to the blockchain to announce a
-// specific address. No checks for validity are performed on the address.
+// specific address, such as the public address of a host that is behind a NAT
+// or a reverse proxy. The address must be valid and the host must be able to
+// connect to it, otherwise renters would be unable to reach the host.
 func (h *Host) AnnounceAddress(addr modules.NetAddress) error {
+	err := addr.IsValid()
+	if err != nil {
+		return err
+	}
+	// The address is dialed before the host is locked, as the dial can take
+	// a while to time out.
+	conn, err := h.dependencies.dial("tcp", string(addr), announceDialTimeout)
+	if err != nil {
+		return composeErrors(errAnnUnreachable, err)
+	}
+	conn.Close()
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.resourceLock.RLock()
@@ -2,7 +2,11 @@ package host
 
 import (
 	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -113,9 +117,27 @@ func TestHostAnnounceAddress(t *testing.T) {
 	}
 	defer af.Close()
 
-	// Create an announcement, then use the address finding module to scan the
-	// blockchain for the host's address.
-	addr := modules.NetAddress("foo.com:1234")
+	// Addresses that are invalid or that the host cannot connect to are
+	// rejected.
+	err = ht.host.AnnounceAddress("foo")
+	if err == nil {
+		t.Error("host announced an invalid address")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := modules.NetAddress(l.Addr().String())
+	l.Close()
+	err = ht.host.AnnounceAddress(closedAddr)
+	if err == nil || !strings.Contains(err.Error(), errAnnUnreachable.Error()) {
+		t.Error("expected errAnnUnreachable, got", err)
+	}
+
+	// Create an announcement using an address other than the one detected by
+	// the host, then use the address finding module to scan the blockchain
+	// for the host's address.
+	addr := modules.NetAddress(net.JoinHostPort("127.0.0.1", ht.host.port))
 	err = ht.host.AnnounceAddress(addr)
 	if err != nil {
 		t.Fatal(err)
@@ -134,3 +156,42 @@ func TestHostAnnounceAddress(t *testing.T) {
 		t.Error("announcement has wrong host key")
 	}
 }
+
+// dependencyErrDial is a dependency that returns an error when Dial is
+// called.
+type dependencyErrDial struct {
+	productionDependencies
+}
+
+func (dependencyErrDial) dial(string, string, time.Duration) (net.Conn, error) {
+	return nil, mockErrDial
+}
+
+// TestHostAnnounceAddressFailedDial checks that the host does not announce an
+// address that it failed to dial.
+func TestHostAnnounceAddressFailedDial(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := blankHostTester("TestHostAnnounceAddressFailedDial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHost(dependencyErrDial{}, ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	err = h.AnnounceAddress(h.NetAddress())
+	if err == nil || !strings.Contains(err.Error(), mockErrDial.Error()) {
+		t.Error("expected the dial error, got", err)
+	}
+	if h.announced {
+		t.Error("host is marked as announced after a failed dial")
+	}
+}
@@ -0,0 +1,156 @@
+package host
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// backupMetadata is the header that gets written to a contract backup, and
+	// is used to recognize contract backups when restoring.
+	backupMetadata = persist.Metadata{
+		Header:  "Sia Host Contract Backup",
+		Version: "0.5.2",
+	}
+
+	// errNilBackupStream is returned if BackupContracts or RestoreContracts is
+	// called without a stream to write to or read from.
+	errNilBackupStream = errors.New("cannot use a nil stream for a contract backup")
+)
+
+// BackupContracts writes every storage obligation held by the host to 'w'.
+// The backup contains the file contract ids, the origin and revision
+// transaction sets, and the sector roots of each obligation, which is
+// everything that is needed to resume managing the obligations as long as the
+// sector data itself is still available to the storage manager.
+func (h *Host) BackupContracts(w io.Writer) error {
+	if w == nil {
+		return errNilBackupStream
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+
+	var sos []storageObligation
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return err
+			}
+			sos = append(sos, so)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return persist.Save(backupMetadata, sos, w)
+}
+
+// RestoreContracts reads a contract backup created by BackupContracts from 'r'
+// and adds the storage obligations within to the host. Each obligation is
+// re-validated against the consensus set before being added - obligations
+// whose file contracts no longer exist and cannot be resubmitted, or whose
+// proof windows have already closed, are dropped. Obligations that the host is
+// already tracking are left untouched.
+func (h *Host) RestoreContracts(r io.Reader) error {
+	if r == nil {
+		return errNilBackupStream
+	}
+	var sos []storageObligation
+	err := persist.Load(backupMetadata, &sos, r)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+
+	for _, so := range sos {
+		soid := so.id()
+		if _, exists := h.lockedStorageObligations[soid]; exists {
+			continue
+		}
+		var known bool
+		err := h.db.View(func(tx *bolt.Tx) error {
+			known = tx.Bucket(bucketStorageObligations).Get(soid[:]) != nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if known {
+			continue
+		}
+
+		// There is no point in restoring an obligation for which a storage
+		// proof can no longer be submitted.
+		if h.blockHeight >= so.proofDeadline() {
+			continue
+		}
+
+		// Check that the file contract is still present in the consensus set.
+		// If it is not, the origin transaction set may simply have never been
+		// confirmed, in which case it is resubmitted. If the resubmission
+		// fails, the file contract no longer exists and the obligation is
+		// dropped.
+		_, err = h.cs.StorageProofSegment(soid)
+		if err == modules.ErrUnrecognizedFileContractID {
+			err = h.tpool.AcceptTransactionSet(so.OriginTransactionSet)
+			if err != nil && err != modules.ErrDuplicateTransactionSet {
+				continue
+			}
+			so.OriginConfirmed = false
+		} else {
+			so.OriginConfirmed = true
+		}
+
+		err = h.db.Update(func(tx *bolt.Tx) error {
+			return putStorageObligation(tx, so)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Update the host financial metrics with regards to this storage
+		// obligation.
+		h.addObligationMetrics(so)
+
+		// Queue the action items for the obligation, skipping any that the
+		// host has already missed.
+		err = h.queueActionItem(h.blockHeight+resubmissionTimeout, soid)
+		if err != nil {
+			return err
+		}
+		if so.expiration() > h.blockHeight+revisionSubmissionBuffer {
+			err = h.queueActionItem(so.expiration()-revisionSubmissionBuffer, soid)
+			if err != nil {
+				return err
+			}
+		}
+		if so.expiration()+resubmissionTimeout > h.blockHeight {
+			err = h.queueActionItem(so.expiration()+resubmissionTimeout, soid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return h.save()
+}
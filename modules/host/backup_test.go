@@ -0,0 +1,117 @@
+package host
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestContractBackupRestore checks that storage obligations can be written to
+// a backup and restored after being lost from the host database, and that
+// obligations whose file contracts have left the consensus set are dropped
+// during a restore.
+func TestContractBackupRestore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestContractBackupRestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a storage obligation to the host and confirm it on the blockchain.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.lockStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.addStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.unlockStorageObligation(so)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	soid := so.id()
+
+	// Create a backup, then remove the obligation from the database to
+	// simulate a lost database.
+	var backup bytes.Buffer
+	err = ht.host.BackupContracts(&backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupBytes := backup.Bytes()
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).Delete(soid[:])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the backup and check that the obligation is back.
+	err = ht.host.RestoreContracts(bytes.NewReader(backupBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored storageObligation
+	err = ht.host.db.View(func(tx *bolt.Tx) error {
+		restored, err = getStorageObligation(tx, soid)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.OriginConfirmed {
+		t.Error("restored storage obligation should be marked as confirmed")
+	}
+	if restored.expiration() != so.expiration() {
+		t.Error("restored storage obligation has the wrong expiration")
+	}
+
+	// Restoring a second time should be a no-op.
+	err = ht.host.RestoreContracts(bytes.NewReader(backupBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine until the file contract has been resolved and removed from the
+	// consensus set. A restore should then drop the obligation.
+	for i := types.BlockHeight(0); i <= revisionSubmissionBuffer*2+1; i++ {
+		_, err := ht.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = ht.host.RestoreContracts(bytes.NewReader(backupBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.db.View(func(tx *bolt.Tx) error {
+		_, err = getStorageObligation(tx, soid)
+		return err
+	})
+	if err != errNoStorageObligation {
+		t.Fatal("expected obligation with resolved file contract to be dropped, got", err)
+	}
+
+	// Check that nil streams are rejected.
+	if ht.host.BackupContracts(nil) != errNilBackupStream {
+		t.Error("expected errNilBackupStream")
+	}
+	if ht.host.RestoreContracts(nil) != errNilBackupStream {
+		t.Error("expected errNilBackupStream")
+	}
+}
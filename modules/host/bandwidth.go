@@ -0,0 +1,134 @@
+package host
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// errNegativeBandwidthLimit is returned when a bandwidth limit is set to
+	// a negative value.
+	errNegativeBandwidthLimit = errors.New("bandwidth limit cannot be negative")
+)
+
+type (
+	// A bandwidthLimiter enforces a limit on the number of bytes per second
+	// that are transferred in one direction, shared by every connection of
+	// the host. A limit of zero means that transfers are unlimited. The limit
+	// is the first field so that it is aligned for atomic access on 32bit
+	// systems, which requires the limiter to be allocated separately.
+	bandwidthLimiter struct {
+		limit int64 // bytes per second, accessed atomically.
+
+		mu   sync.Mutex
+		next time.Time // the earliest time at which the next transfer can start.
+	}
+
+	// rateLimitedConn is a net.Conn that counts the bytes transferred by the
+	// host and throttles them according to the bandwidth limits of the host.
+	rateLimitedConn struct {
+		net.Conn
+		h *Host
+	}
+
+	// rateLimitedListener wraps the listener of the host so that every
+	// accepted connection is a rateLimitedConn.
+	rateLimitedListener struct {
+		net.Listener
+		h *Host
+	}
+)
+
+// chunkSize returns the largest number of bytes that should be transferred at
+// once, so that a single large transfer does not exceed the limit in a burst.
+// If there is no limit, 'n' is returned.
+func (bl *bandwidthLimiter) chunkSize(n int) int {
+	limit := atomic.LoadInt64(&bl.limit)
+	if limit > 0 && int64(n) > limit {
+		return int(limit)
+	}
+	return n
+}
+
+// wait blocks until 'n' more bytes can be transferred without exceeding the
+// limit.
+func (bl *bandwidthLimiter) wait(n int) {
+	limit := atomic.LoadInt64(&bl.limit)
+	if limit == 0 || n == 0 {
+		return
+	}
+	bl.mu.Lock()
+	now := time.Now()
+	if bl.next.Before(now) {
+		bl.next = now
+	}
+	bl.next = bl.next.Add(time.Duration(int64(n) * int64(time.Second) / limit))
+	delay := bl.next.Sub(now)
+	bl.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// Read reads data sent to the host, counting it against the download limit.
+func (c *rateLimitedConn) Read(b []byte) (int, error) {
+	b = b[:c.h.downloadLimiter.chunkSize(len(b))]
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.h.atomicDownloadBytes, uint64(n))
+	c.h.downloadLimiter.wait(n)
+	return n, err
+}
+
+// Write writes data sent by the host, counting it against the upload limit.
+func (c *rateLimitedConn) Write(b []byte) (int, error) {
+	var written int
+	for written < len(b) {
+		chunk := b[written:]
+		chunk = chunk[:c.h.uploadLimiter.chunkSize(len(chunk))]
+		c.h.uploadLimiter.wait(len(chunk))
+		n, err := c.Conn.Write(chunk)
+		atomic.AddUint64(&c.h.atomicUploadBytes, uint64(n))
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Accept waits for the next connection to the host and wraps it in a
+// rateLimitedConn.
+func (l *rateLimitedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedConn{Conn: conn, h: l.h}, nil
+}
+
+// BandwidthUsage returns the total number of bytes that the host has uploaded
+// to and downloaded from its peers over its lifetime.
+func (h *Host) BandwidthUsage() (up, down uint64) {
+	return atomic.LoadUint64(&h.atomicUploadBytes), atomic.LoadUint64(&h.atomicDownloadBytes)
+}
+
+// SetBandwidthLimit sets the maximum number of bytes per second that the host
+// will upload and download, shared across all connections. A limit of zero
+// means that the bandwidth in that direction is unlimited.
+func (h *Host) SetBandwidthLimit(uploadBytesPerSec, downloadBytesPerSec int64) error {
+	if uploadBytesPerSec < 0 || downloadBytesPerSec < 0 {
+		return errNegativeBandwidthLimit
+	}
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	atomic.StoreInt64(&h.uploadLimiter.limit, uploadBytesPerSec)
+	atomic.StoreInt64(&h.downloadLimiter.limit, downloadBytesPerSec)
+	return h.save()
+}
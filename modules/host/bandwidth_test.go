@@ -0,0 +1,107 @@
+package host
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestBandwidthLimiter checks that the bandwidth limiter delays transfers
+// that exceed the limit, and does nothing when there is no limit.
+func TestBandwidthLimiter(t *testing.T) {
+	var bl bandwidthLimiter
+	start := time.Now()
+	bl.wait(1e9)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("unlimited bandwidth limiter delayed a transfer")
+	}
+	if bl.chunkSize(1e6) != 1e6 {
+		t.Error("unlimited bandwidth limiter split a transfer")
+	}
+
+	// Transferring 2e5 bytes at 1e6 bytes per second should take at least
+	// 200ms.
+	bl.limit = 1e6
+	if bl.chunkSize(2e6) != 1e6 {
+		t.Error("large transfers should be split into chunks of the limit")
+	}
+	start = time.Now()
+	bl.wait(1e5)
+	bl.wait(1e5)
+	if time.Since(start) < 150*time.Millisecond {
+		t.Error("bandwidth limiter did not delay transfers:", time.Since(start))
+	}
+}
+
+// TestBandwidthUsage checks that the bandwidth of the host is counted, and
+// that the totals and limits persist across restarts.
+func TestBandwidthUsage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := blankHostTester("TestBandwidthUsage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ht.host.SetBandwidthLimit(-1, 0) != errNegativeBandwidthLimit {
+		t.Error("expected errNegativeBandwidthLimit")
+	}
+	err = ht.host.SetBandwidthLimit(1e6, 2e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Request the settings of the host.
+	conn, err := net.Dial("tcp", ht.host.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req bytes.Buffer
+	err = encoding.WriteObject(&req, modules.RPCSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqLen := req.Len()
+	_, err = req.WriteTo(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	resp, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	up, down := ht.host.BandwidthUsage()
+	if up != uint64(len(resp)) || up == 0 {
+		t.Errorf("expected the host to upload %v bytes, got %v", len(resp), up)
+	}
+	if down != uint64(reqLen) {
+		t.Error("host counted the wrong number of downloaded bytes:", down)
+	}
+
+	// Restart the host and check that the totals and limits persist.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	up2, down2 := h.BandwidthUsage()
+	if up2 != up || down2 != down {
+		t.Error("bandwidth usage did not persist:", up2, down2)
+	}
+	if h.uploadLimiter.limit != 1e6 || h.downloadLimiter.limit != 2e6 {
+		t.Error("bandwidth limits did not persist")
+	}
+}
@@ -0,0 +1,82 @@
+package host
+
+import (
+	"errors"
+	"math"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errInsufficientCollateralFunds is returned if a renter proposes a file
+	// contract that expects more collateral from the host than the host's
+	// wallet holds in confirmed siacoins.
+	errInsufficientCollateralFunds = errors.New("host wallet does not have enough confirmed siacoins to cover the collateral of the file contract")
+
+	// errInvalidCollateralRatio is returned if the host is given a collateral
+	// ratio that is negative or not a finite number.
+	errInvalidCollateralRatio = errors.New("collateral ratio must be a finite, non-negative number")
+)
+
+// validCollateralRatio returns true if 'ratio' can be used as the collateral
+// ratio of the host.
+func validCollateralRatio(ratio float64) bool {
+	return ratio >= 0 && !math.IsInf(ratio, 0) && !math.IsNaN(ratio)
+}
+
+// effectiveCollateral returns the collateral that the host is currently
+// advertising. With a collateral ratio set, the collateral follows the
+// effective storage price, including any dynamic pricing adjustments.
+func (h *Host) effectiveCollateral() types.Currency {
+	if h.settings.CollateralRatio == 0 {
+		return h.settings.Collateral
+	}
+	storagePrice, _, _ := h.effectivePrices()
+	return storagePrice.MulFloat(h.settings.CollateralRatio)
+}
+
+// maxContractCollateral returns the most collateral that the host can put
+// into a single new file contract given the confirmed siacoin balance of its
+// wallet. The amount is limited by the MaxCollateral setting, the unused part
+// of the collateral budget, and the balance, so that a single renter cannot
+// lock away more than the host is able or willing to risk.
+func (h *Host) maxContractCollateral(balance types.Currency) types.Currency {
+	maxCollateral := h.settings.MaxCollateral
+	var remainingBudget types.Currency
+	if h.settings.CollateralBudget.Cmp(h.financialMetrics.LockedStorageCollateral) > 0 {
+		remainingBudget = h.settings.CollateralBudget.Sub(h.financialMetrics.LockedStorageCollateral)
+	}
+	if remainingBudget.Cmp(maxCollateral) < 0 {
+		maxCollateral = remainingBudget
+	}
+	if balance.Cmp(maxCollateral) < 0 {
+		maxCollateral = balance
+	}
+	return maxCollateral
+}
+
+// SetCollateralRatio sets the collateral that the host offers relative to its
+// storage price. A ratio of 2 offers twice the storage price as collateral,
+// and a ratio of zero restores the static collateral of the internal
+// settings. The amount of collateral in any one contract remains capped by
+// MaxCollateral and by the confirmed balance of the host's wallet.
+func (h *Host) SetCollateralRatio(ratio float64) error {
+	if !validCollateralRatio(ratio) {
+		return errInvalidCollateralRatio
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+
+	h.settings.CollateralRatio = ratio
+	h.revisionNumber++
+	err := h.saveSync()
+	if err != nil {
+		return errors.New("collateral ratio updated, but failed saving to disk: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCollateralRatio checks that the host advertises collateral that follows
+// its storage price when a collateral ratio is set, and that the effective
+// collateral values are reported by InternalSettings.
+func TestCollateralRatio(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestCollateralRatio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.host.Close()
+
+	if ht.host.SetCollateralRatio(-1) != errInvalidCollateralRatio {
+		t.Fatal("expected errInvalidCollateralRatio")
+	}
+	settings := ht.host.InternalSettings()
+	if settings.EffectiveCollateral.Cmp(settings.Collateral) != 0 {
+		t.Fatal("host without a collateral ratio should offer the static collateral")
+	}
+
+	err = ht.host.SetCollateralRatio(2.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := defaultStoragePrice.MulFloat(2.5)
+	if ht.host.ExternalSettings().Collateral.Cmp(expected) != 0 {
+		t.Fatal("host does not advertise collateral that follows the storage price")
+	}
+	settings = ht.host.InternalSettings()
+	if settings.CollateralRatio != 2.5 || settings.EffectiveCollateral.Cmp(expected) != 0 {
+		t.Fatal("internal settings do not report the collateral ratio")
+	}
+
+	// The maximum collateral of a contract is limited by the wallet balance
+	// once the balance is the smallest limit.
+	balance, _, _ := ht.wallet.ConfirmedBalance()
+	if settings.EffectiveMaxCollateral.Cmp(settings.MaxCollateral) > 0 || settings.EffectiveMaxCollateral.Cmp(balance) > 0 {
+		t.Fatal("effective max collateral exceeds its limits")
+	}
+	settings.MaxCollateral = balance.Mul(types.NewCurrency64(2))
+	settings.CollateralBudget = balance.Mul(types.NewCurrency64(2))
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings = ht.host.InternalSettings()
+	if settings.EffectiveMaxCollateral.Cmp(balance) != 0 {
+		t.Fatal("effective max collateral should equal the wallet balance, got", settings.EffectiveMaxCollateral)
+	}
+	settings.CollateralRatio = -1
+	if ht.host.SetInternalSettings(settings) != errInvalidCollateralRatio {
+		t.Fatal("expected errInvalidCollateralRatio")
+	}
+}
+
+// TestVerifyContractInsufficientCollateralFunds checks that the host rejects a
+// file contract that expects more collateral than the host's wallet holds.
+func TestVerifyContractInsufficientCollateralFunds(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestVerifyContractInsufficientCollateralFunds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.host.Close()
+
+	// Raise the collateral limits of the host so that only the wallet
+	// balance stands in the way of the contract.
+	balance, _, _ := ht.wallet.ConfirmedBalance()
+	settings := ht.host.InternalSettings()
+	settings.MaxCollateral = balance.Mul(types.NewCurrency64(2))
+	settings.CollateralBudget = balance.Mul(types.NewCurrency64(2))
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collateral := balance.Add(types.NewCurrency64(1))
+	hostPayout := settings.MinimumContractPrice.Add(collateral)
+	windowStart := ht.host.blockHeight + revisionSubmissionBuffer + 1
+	txnSet := []types.Transaction{{
+		FileContracts: []types.FileContract{{
+			WindowStart: windowStart,
+			WindowEnd:   windowStart + settings.WindowSize,
+			Payout:      hostPayout.Mul(types.NewCurrency64(2)),
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Value: hostPayout},
+				{Value: hostPayout, UnlockHash: ht.host.unlockHash},
+			},
+			MissedProofOutputs: []types.SiacoinOutput{
+				{Value: hostPayout},
+				{Value: hostPayout, UnlockHash: ht.host.unlockHash},
+				{},
+			},
+		}},
+	}}
+	err = ht.host.managedVerifyNewContract(txnSet, crypto.PublicKey{})
+	if err != errInsufficientCollateralFunds {
+		t.Fatal("expected errInsufficientCollateralFunds, got", err)
+	}
+}
+
+// TestVerifyContractEffectiveMaxCollateral checks that the host rejects a file
+// contract that expects more collateral than the EffectiveMaxCollateral
+// reported by the host, and accepts the reported amount.
+func TestVerifyContractEffectiveMaxCollateral(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestVerifyContractEffectiveMaxCollateral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.host.Close()
+
+	// Lock away part of the collateral budget, so that the remaining budget
+	// is the tightest limit on the collateral of a new contract.
+	balance, _, _ := ht.wallet.ConfirmedBalance()
+	settings := ht.host.InternalSettings()
+	settings.MaxCollateral = balance
+	settings.CollateralBudget = balance
+	err = ht.host.SetInternalSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host.mu.Lock()
+	ht.host.financialMetrics.LockedStorageCollateral = balance.Div(types.NewCurrency64(2))
+	ht.host.mu.Unlock()
+	maxCollateral := ht.host.InternalSettings().EffectiveMaxCollateral
+	if maxCollateral.Cmp(balance.Sub(balance.Div(types.NewCurrency64(2)))) != 0 {
+		t.Fatal("effective max collateral should be the remaining budget, got", maxCollateral)
+	}
+
+	contract := func(collateral types.Currency) []types.Transaction {
+		hostPayout := settings.MinimumContractPrice.Add(collateral)
+		windowStart := ht.host.blockHeight + revisionSubmissionBuffer + 1
+		return []types.Transaction{{
+			FileContracts: []types.FileContract{{
+				WindowStart: windowStart,
+				WindowEnd:   windowStart + settings.WindowSize,
+				Payout:      hostPayout.Mul(types.NewCurrency64(2)),
+				ValidProofOutputs: []types.SiacoinOutput{
+					{Value: hostPayout},
+					{Value: hostPayout, UnlockHash: ht.host.unlockHash},
+				},
+				MissedProofOutputs: []types.SiacoinOutput{
+					{Value: hostPayout},
+					{Value: hostPayout, UnlockHash: ht.host.unlockHash},
+					{},
+				},
+			}},
+		}}
+	}
+	err = ht.host.managedVerifyNewContract(contract(maxCollateral.Add(types.NewCurrency64(1))), crypto.PublicKey{})
+	if err != errCollateralBudgetExceeded {
+		t.Fatal("expected errCollateralBudgetExceeded, got", err)
+	}
+	// The reported collateral passes the collateral checks, and the
+	// contract is only rejected for its unlock hash.
+	err = ht.host.managedVerifyNewContract(contract(maxCollateral), crypto.PublicKey{})
+	if err != errBadContractUnlockHash {
+		t.Fatal("expected errBadContractUnlockHash, got", err)
+	}
+}
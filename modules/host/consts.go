@@ -9,6 +9,11 @@ import (
 )
 
 const (
+	// announceDialTimeout is the amount of time that the host will wait when
+	// dialing an address that it is about to announce, to check that the
+	// address is reachable.
+	announceDialTimeout = 30 * time.Second
+
 	// defaultMaxDuration defines the maximum number of blocks into the future
 	// that the host will accept for the duration of an incoming file contract
 	// obligation. 6 months is chosen because hosts are expected to be
@@ -134,6 +139,39 @@ var (
 		panic("unrecognized release constant in host - defaultWindowSize")
 	}()
 
+	// dynamicPricingInterval is the amount of time between adjustments of the
+	// prices of a host that has dynamic pricing enabled.
+	dynamicPricingInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute
+		}
+		if build.Release == "standard" {
+			return 10 * time.Minute
+		}
+		if build.Release == "testing" {
+			return 100 * time.Millisecond
+		}
+		panic("unrecognized release constant in host - dynamicPricingInterval")
+	}()
+
+	// maximumContractNegotiations sets the maximum number of file contract
+	// negotiations that the host will run at a time. Renters that try to
+	// negotiate while the limit is reached are told that the host is rate
+	// limited, and are asked to try again once the negotiations in progress
+	// have had time to finish.
+	maximumContractNegotiations = func() uint64 {
+		if build.Release == "dev" {
+			return 10
+		}
+		if build.Release == "standard" {
+			return 50
+		}
+		if build.Release == "testing" {
+			return 3
+		}
+		panic("unrecognized release constant in host - maximumContractNegotiations")
+	}()
+
 	// maximumLockedStorageObligations sets the maximum number of storage
 	// obligations that are allowed to be locked at a time. The map uses an
 	// in-memory lock, but also a locked storage obligation could be reading a
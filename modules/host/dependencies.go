@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/persist"
 )
@@ -14,6 +15,7 @@ import (
 // Fake errors that get returned when a simulated failure of a dependency is
 // desired for testing.
 var (
+	mockErrDial         = errors.New("simulated Dial failure")
 	mockErrListen       = errors.New("simulated Listen failure")
 	mockErrLoadFile     = errors.New("simulated LoadFile failure")
 	mockErrMkdirAll     = errors.New("simulated MkdirAll failure")
@@ -31,6 +33,10 @@ var (
 type (
 	// dependencies defines all of the dependencies of the Host.
 	dependencies interface {
+		// dial gives the host the ability to open outgoing connections, such
+		// as when checking that an announced address is reachable.
+		dial(string, string, time.Duration) (net.Conn, error)
+
 		// listen gives the host the ability to receive incoming connections.
 		listen(string, string) (net.Listener, error)
 
@@ -93,6 +99,11 @@ func composeErrors(errs ...error) error {
 	return errors.New(strings.Join(errStrings, "; "))
 }
 
+// dial gives the host the ability to open outgoing connections.
+func (productionDependencies) dial(s1, s2 string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(s1, s2, timeout)
+}
+
 // listen gives the host the ability to receive incoming connections.
 func (productionDependencies) listen(s1, s2 string) (net.Listener, error) {
 	return net.Listen(s1, s2)
@@ -129,6 +129,15 @@ type Host struct {
 	atomicSettingsCalls       uint64
 	atomicUnrecognizedCalls   uint64
 
+	// Bandwidth Metrics - the total number of bytes uploaded and downloaded
+	// by the host through its listener.
+	atomicDownloadBytes uint64
+	atomicUploadBytes   uint64
+
+	// atomicContractNegotiations is the number of file contract negotiations
+	// that are currently in progress.
+	atomicContractNegotiations uint64
+
 	// Dependencies.
 	cs     modules.ConsensusSet
 	tpool  modules.TransactionPool
@@ -175,6 +184,24 @@ type Host struct {
 	// damage can be done even with this attack.
 	lockedStorageObligations map[types.FileContractID]struct{} // Which storage obligations are currently being modified.
 
+	// contractRejections counts the file contract proposals that the host has
+	// rejected since startup, grouped by reason.
+	contractRejections map[modules.RejectionReason]uint64
+
+	// When dynamic pricing is enabled, the host periodically scales its
+	// storage and bandwidth prices between the bounds of the dynamic pricing
+	// config according to the utilization of its storage. The dynamic prices
+	// are the prices most recently computed.
+	dynamicPricing                modules.DynamicPricingConfig
+	dynamicDownloadBandwidthPrice types.Currency
+	dynamicStoragePrice           types.Currency
+	dynamicUploadBandwidthPrice   types.Currency
+
+	// The bandwidth limiters throttle the connections accepted by the
+	// listener of the host.
+	downloadLimiter *bandwidthLimiter
+	uploadLimiter   *bandwidthLimiter
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	listener   net.Listener
@@ -188,8 +215,13 @@ type Host struct {
 	// unless they are planning on manipulating the 'closed' variable.
 	// Readlocks are used so that multiple functions can use resources
 	// simultaneously, but the resources are not closed until all functions
-	// accessing them have returned.
+	// accessing them have returned. closeChan is closed when the host starts
+	// closing, so that background loops stop waiting and return. closeOnce
+	// makes sure that the resources are only released by the first call to
+	// Close.
 	closed       bool
+	closeChan    chan struct{}
+	closeOnce    sync.Once
 	resourceLock sync.RWMutex
 }
 
@@ -240,8 +272,14 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		wallet:       wallet,
 		dependencies: dependencies,
 
+		contractRejections:       make(map[modules.RejectionReason]uint64),
 		lockedStorageObligations: make(map[types.FileContractID]struct{}),
 
+		downloadLimiter: new(bandwidthLimiter),
+		uploadLimiter:   new(bandwidthLimiter),
+
+		closeChan: make(chan struct{}),
+
 		persistDir: persistDir,
 	}
 
@@ -297,6 +335,12 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		return nil, err
 	}
 
+	// The dynamic prices are computed before the host is established on the
+	// network, so that the first settings that renters see already use them.
+	h.mu.Lock()
+	h.updateDynamicPrices()
+	h.mu.Unlock()
+
 	// Get the host established on the network.
 	err = h.initNetworking(listenerAddress)
 	if err != nil {
@@ -305,6 +349,8 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		return nil, err
 	}
 
+	// Start adjusting the prices of the host once nothing else can fail.
+	go h.threadedAdjustPrices()
 	return h, nil
 }
 
@@ -315,6 +361,14 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.
 
 // Close shuts down the host, preparing it for garbage collection.
 func (h *Host) Close() (composedError error) {
+	// Only the first call to Close shuts the host down, later calls would
+	// close the channels and files of the host a second time.
+	first := false
+	h.closeOnce.Do(func() { first = true })
+	if !first {
+		return errHostClosed
+	}
+
 	// Unsubscribe the host from the consensus set. Call will not terminate
 	// until the last consensus update has been sent to the host.
 	// Unsubscription must happen before any resources are released or
@@ -330,6 +384,9 @@ func (h *Host) Close() (composedError error) {
 		composedError = composeErrors(composedError, err)
 	}
 
+	// Signal the background loops to stop waiting.
+	close(h.closeChan)
+
 	// Grab the resource lock and indicate that the host is closing. Concurrent
 	// functions hold the resource lock until they terminate, meaning that no
 	// threaded function will be running by the time the resource lock is
@@ -409,6 +466,10 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		}
 	}
 
+	if !validCollateralRatio(settings.CollateralRatio) {
+		return errInvalidCollateralRatio
+	}
+
 	if settings.NetAddress != "" {
 		err := settings.NetAddress.IsValid()
 		if err != nil {
@@ -423,7 +484,14 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		h.announced = false
 	}
 
+	// The effective values are computed by the host, not set by the user.
+	settings.EffectiveDownloadBandwidthPrice = types.Currency{}
+	settings.EffectiveStoragePrice = types.Currency{}
+	settings.EffectiveUploadBandwidthPrice = types.Currency{}
+	settings.EffectiveCollateral = types.Currency{}
+	settings.EffectiveMaxCollateral = types.Currency{}
 	h.settings = settings
+	h.updateDynamicPrices()
 	h.revisionNumber++
 
 	err := h.saveSync()
@@ -433,9 +501,86 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 	return nil
 }
 
-// InternalSettings returns the settings of a host.
+// AcceptingContracts returns whether the host is accepting new file
+// contracts.
+func (h *Host) AcceptingContracts() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.settings.AcceptingContracts
+}
+
+// SetAcceptingContracts sets whether the host accepts new file contracts.
+// Turning contracts off puts the host into a maintenance mode, for example
+// before a planned reboot: renters are refused new contracts, but existing
+// contracts can still be revised and downloaded from, and storage proofs are
+// still submitted. The host needs an unlock hash to accept contracts.
+func (h *Host) SetAcceptingContracts(accepting bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+	if accepting {
+		err := h.checkUnlockHash()
+		if err != nil {
+			return errors.New("host cannot accept contracts, no unlock hash: " + err.Error())
+		}
+	}
+
+	h.settings.AcceptingContracts = accepting
+	h.revisionNumber++
+	err := h.saveSync()
+	if err != nil {
+		return errors.New("accepting contracts updated, but failed saving to disk: " + err.Error())
+	}
+	return nil
+}
+
+// setBandwidthPrice sets one of the bandwidth prices of the host. The
+// revision number of the host settings is bumped so that renters notice the
+// new price.
+func (h *Host) setBandwidthPrice(price *types.Currency, newPrice types.Currency) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+
+	*price = newPrice
+	h.revisionNumber++
+	err := h.saveSync()
+	if err != nil {
+		return errors.New("bandwidth price updated, but failed saving to disk: " + err.Error())
+	}
+	return nil
+}
+
+// SetDownloadBandwidthPrice sets the price per byte that the host charges for
+// downloads, independent of the storage price.
+func (h *Host) SetDownloadBandwidthPrice(price types.Currency) error {
+	return h.setBandwidthPrice(&h.settings.MinimumDownloadBandwidthPrice, price)
+}
+
+// SetUploadBandwidthPrice sets the price per byte that the host charges for
+// uploads, independent of the storage price.
+func (h *Host) SetUploadBandwidthPrice(price types.Currency) error {
+	return h.setBandwidthPrice(&h.settings.MinimumUploadBandwidthPrice, price)
+}
+
+// InternalSettings returns the settings of a host, including the prices and
+// collateral that the host is currently advertising.
 func (h *Host) InternalSettings() modules.HostInternalSettings {
+	// The wallet is queried without holding the host lock.
+	balance, _, _ := h.wallet.ConfirmedBalance()
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.settings
+	settings := h.settings
+	settings.EffectiveStoragePrice, settings.EffectiveDownloadBandwidthPrice, settings.EffectiveUploadBandwidthPrice = h.effectivePrices()
+	settings.EffectiveCollateral = h.effectiveCollateral()
+	settings.EffectiveMaxCollateral = h.maxContractCollateral(balance)
+	return settings
 }
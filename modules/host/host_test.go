@@ -2,8 +2,10 @@ package host
 
 import (
 	// "errors"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -114,7 +116,7 @@ func blankHostTester(name string) (*hostTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +124,7 @@ func blankHostTester(name string) (*hostTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	m, err := miner.New(cs, tp, w, filepath.Join(testdir, modules.MinerDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(testdir, modules.MinerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -374,6 +376,130 @@ func TestSetAndGetInternalSettings(t *testing.T) {
 	}
 }
 
+// TestSetBandwidthPrices checks that the download and upload bandwidth prices
+// can be set independently of each other and of the storage price.
+func TestSetBandwidthPrices(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestSetBandwidthPrices")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloadPrice := defaultDownloadBandwidthPrice.Mul(types.NewCurrency64(3))
+	uploadPrice := defaultUploadBandwidthPrice.Mul(types.NewCurrency64(5))
+	err = ht.host.SetDownloadBandwidthPrice(downloadPrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.SetUploadBandwidthPrice(uploadPrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := ht.host.InternalSettings()
+	if settings.MinimumDownloadBandwidthPrice.Cmp(downloadPrice) != 0 {
+		t.Error("download bandwidth price was not updated")
+	}
+	if settings.MinimumUploadBandwidthPrice.Cmp(uploadPrice) != 0 {
+		t.Error("upload bandwidth price was not updated")
+	}
+	if settings.MinimumStoragePrice.Cmp(defaultStoragePrice) != 0 {
+		t.Error("storage price should not change when bandwidth prices are set")
+	}
+	external := ht.host.ExternalSettings()
+	if external.DownloadBandwidthPrice.Cmp(downloadPrice) != 0 || external.UploadBandwidthPrice.Cmp(uploadPrice) != 0 {
+		t.Error("external settings do not report the new bandwidth prices")
+	}
+}
+
+// TestSetAcceptingContracts checks that the host can be put into maintenance
+// mode, in which the contract formation RPC is refused.
+func TestSetAcceptingContracts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestSetAcceptingContracts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ht.host.SetAcceptingContracts(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ht.host.AcceptingContracts() || !ht.host.ExternalSettings().AcceptingContracts {
+		t.Fatal("host is not accepting contracts")
+	}
+	err = ht.host.SetAcceptingContracts(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.host.AcceptingContracts() || ht.host.ExternalSettings().AcceptingContracts {
+		t.Fatal("host is still accepting contracts")
+	}
+
+	// The contract formation RPC sends the settings followed by an explicit
+	// rejection, without counting as an errored call.
+	hostConn, renterConn := net.Pipe()
+	defer renterConn.Close()
+	rejection := make(chan error)
+	go func() {
+		var pk crypto.PublicKey
+		copy(pk[:], ht.host.publicKey.Key)
+		var settings modules.HostExternalSettings
+		err := crypto.ReadSignedObject(renterConn, &settings, modules.NegotiateMaxHostExternalSettingsLen, pk)
+		if err != nil {
+			rejection <- err
+			return
+		}
+		rejection <- modules.ReadNegotiationAcceptance(renterConn)
+	}()
+	err = ht.host.managedRPCFormContract(hostConn)
+	hostConn.Close()
+	if err != nil {
+		t.Fatal("refusing a contract should not be an error, got", err)
+	}
+	err = <-rejection
+	if err == nil || !strings.Contains(err.Error(), errNotAcceptingContracts.Error()) {
+		t.Fatal("expected the renter to receive errNotAcceptingContracts, got", err)
+	}
+
+	// The setting is persisted.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebootHost, err := New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebootHost.AcceptingContracts() {
+		t.Error("maintenance mode was not persisted")
+	}
+}
+
+// TestHostDoubleClose checks that closing the host a second time returns an
+// error instead of panicking.
+func TestHostDoubleClose(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestHostDoubleClose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.host.Close()
+	if err != errHostClosed {
+		t.Fatal("expected errHostClosed, got", err)
+	}
+}
+
 /*
 // TestSetAndGetSettings checks that the functions for interacting with the
 // hosts settings object are working as expected.
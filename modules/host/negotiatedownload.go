@@ -101,6 +101,7 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	blockHeight := h.blockHeight
 	secretKey := h.secretKey
 	settings := h.settings
+	_, settings.MinimumDownloadBandwidthPrice, _ = h.effectivePrices()
 	h.mu.RUnlock()
 
 	// Read the download requests, followed by the file contract revision that
@@ -3,6 +3,7 @@ package host
 import (
 	"errors"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -61,6 +62,10 @@ var (
 	// nil file contract transaction set during file contract negotiation.
 	errEmptyFileContractTransactionSet = errors.New("file contract transaction set is nil - invalid!")
 
+	// errNotAcceptingContracts is sent to a renter that tries to form a file
+	// contract while the host is not accepting contracts.
+	errNotAcceptingContracts = errors.New("host is not accepting contracts")
+
 	// errNonEmptyFC is returned if a renter tries to make a new file contract
 	// that has a FileSize which is not zero.
 	errNonEmptyFC = errors.New("new file contract should have no data in it")
@@ -123,7 +128,11 @@ func (h *Host) managedAddCollateral(txnSet []types.Transaction, settings modules
 // to the caller.
 func (h *Host) managedFinalizeContract(builder modules.TransactionBuilder, renterPK crypto.PublicKey, renterSignatures []types.TransactionSignature, renterRevisionSignature types.TransactionSignature) ([]types.TransactionSignature, types.TransactionSignature, error) {
 	for _, sig := range renterSignatures {
-		builder.AddTransactionSignature(sig)
+		_, err := builder.AddTransactionSignature(sig)
+		if err != nil {
+			builder.Drop()
+			return nil, types.TransactionSignature{}, err
+		}
 	}
 	fullTxnSet, err := builder.Sign(true)
 	if err != nil {
@@ -222,15 +231,32 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 	if err != nil {
 		return err
 	}
-	// If the host is not accepting contracts, the connection can be closed.
-	// The renter has been given enough information in the host settings to
-	// understand that the connection is going to be closed.
+	// If the host is not accepting contracts, the renter is told so
+	// explicitly. Refusing a contract in that case is not an error on the
+	// side of the host, so only a failure to send the rejection is returned.
 	h.mu.RLock()
 	settings := h.settings
 	h.mu.RUnlock()
 	if !settings.AcceptingContracts {
+		err = modules.WriteNegotiationRejection(conn, errNotAcceptingContracts)
+		if err != errNotAcceptingContracts {
+			return err
+		}
+		return nil
+	}
+	// The number of negotiations that run at a time is limited. When the
+	// limit is reached the renter is told to try again once the negotiations
+	// in progress have had time to finish.
+	if atomic.AddUint64(&h.atomicContractNegotiations, 1) > maximumContractNegotiations {
+		atomic.AddUint64(&h.atomicContractNegotiations, ^uint64(0))
+		rejection := h.managedContractRejection(errTooManyNegotiations)
+		err = modules.WriteNegotiationRejection(conn, rejection)
+		if err != rejection {
+			return err
+		}
 		return nil
 	}
+	defer atomic.AddUint64(&h.atomicContractNegotiations, ^uint64(0))
 
 	// Extend the deadline to meet the rest of file contract negotiation.
 	conn.SetDeadline(time.Now().Add(modules.NegotiateFileContractTime))
@@ -263,7 +289,7 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 	if err != nil {
 		// The incoming file contract is not acceptable to the host, indicate
 		// why to the renter.
-		return modules.WriteNegotiationRejection(conn, err)
+		return modules.WriteNegotiationRejection(conn, h.managedContractRejection(err))
 	}
 	// The host adds collateral to the transaction.
 	txnBuilder, newParents, newInputs, newOutputs, err := h.managedAddCollateral(txnSet, settings)
@@ -345,12 +371,15 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 		return errEmptyFileContractTransactionSet
 	}
 
+	// The wallet is queried without holding the host lock.
+	balance, _, _ := h.wallet.ConfirmedBalance()
 	h.mu.RLock()
 	blockHeight := h.blockHeight
 	publicKey := h.publicKey
 	settings := h.settings
 	unlockHash := h.unlockHash
 	lockedStorageCollateral := h.financialMetrics.LockedStorageCollateral
+	maxCollateral := h.maxContractCollateral(balance)
 	h.mu.RUnlock()
 	fc := txnSet[len(txnSet)-1].FileContracts[0]
 
@@ -406,15 +435,26 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 	if expectedCollateralFraction.Cmp(settings.MaxCollateralFraction) > 0 {
 		return errBadCollateralFraction
 	}
-	// Check that the collateral does not exceed the maximum amount of
-	// collateral allowed.
-	if expectedCollateral.Cmp(settings.MaxCollateral) > 0 {
-		return errMaxCollateralReached
-	}
-	// Check that the host has enough room in the collateral budget to add this
-	// collateral.
-	if lockedStorageCollateral.Add(expectedCollateral).Cmp(settings.CollateralBudget) > 0 {
-		return errCollateralBudgetExceeded
+	// Check that the collateral does not exceed the most collateral that the
+	// host can put into the contract, which the host reports as the
+	// EffectiveMaxCollateral of its internal settings. The error names the
+	// limit that was reached: the MaxCollateral setting, the collateral
+	// budget, or the wallet balance.
+	if expectedCollateral.Cmp(maxCollateral) > 0 {
+		if expectedCollateral.Cmp(settings.MaxCollateral) > 0 {
+			return errMaxCollateralReached
+		}
+		if lockedStorageCollateral.Add(expectedCollateral).Cmp(settings.CollateralBudget) > 0 {
+			return errCollateralBudgetExceeded
+		}
+		return errInsufficientCollateralFunds
+	}
+	// Check that the host has storage remaining. A host without any storage
+	// folders has not been configured for storage yet, and is not considered
+	// full.
+	totalStorage, remainingStorage := h.capacity()
+	if totalStorage > 0 && remainingStorage < modules.SectorSize {
+		return errHostFull
 	}
 
 	// The unlock hash for the file contract must match the unlock hash that
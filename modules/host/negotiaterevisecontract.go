@@ -123,6 +123,8 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation) er
 	// Read some variables from the host for use later in the function.
 	h.mu.RLock()
 	settings := h.settings
+	settings.MinimumStoragePrice, _, settings.MinimumUploadBandwidthPrice = h.effectivePrices()
+	settings.Collateral = h.effectiveCollateral()
 	secretKey := h.secretKey
 	blockHeight := h.blockHeight
 	h.mu.RUnlock()
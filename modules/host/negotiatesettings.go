@@ -26,6 +26,7 @@ func (h *Host) capacity() (total, remaining uint64) {
 // externalSettings compiles and returns the external settings for the host.
 func (h *Host) externalSettings() modules.HostExternalSettings {
 	totalStorage, remainingStorage := h.capacity()
+	storagePrice, downloadPrice, uploadPrice := h.effectivePrices()
 	var netAddr modules.NetAddress
 	if h.settings.NetAddress != "" {
 		netAddr = h.settings.NetAddress
@@ -44,14 +45,14 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 		UnlockHash:           h.unlockHash,
 		WindowSize:           h.settings.WindowSize,
 
-		Collateral:            h.settings.Collateral,
+		Collateral:            h.effectiveCollateral(),
 		MaxCollateralFraction: h.settings.MaxCollateralFraction,
 		MaxCollateral:         h.settings.MaxCollateral,
 
 		ContractPrice:          h.settings.MinimumContractPrice,
-		DownloadBandwidthPrice: h.settings.MinimumDownloadBandwidthPrice,
-		StoragePrice:           h.settings.MinimumStoragePrice,
-		UploadBandwidthPrice:   h.settings.MinimumUploadBandwidthPrice,
+		DownloadBandwidthPrice: downloadPrice,
+		StoragePrice:           storagePrice,
+		UploadBandwidthPrice:   uploadPrice,
 
 		RevisionNumber: h.revisionNumber,
 		Version:        build.Version,
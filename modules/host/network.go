@@ -40,11 +40,13 @@ func (h *Host) threadedUpdateHostname() {
 // initNetworking performs actions like port forwarding, and gets the host
 // established on the network.
 func (h *Host) initNetworking(address string) (err error) {
-	// Create listener and set address.
-	h.listener, err = h.dependencies.listen("tcp", address)
+	// Create listener and set address. The listener is wrapped so that the
+	// bandwidth of every connection is counted and throttled.
+	listener, err := h.dependencies.listen("tcp", address)
 	if err != nil {
 		return err
 	}
+	h.listener = &rateLimitedListener{Listener: listener, h: h}
 	_, port, err := net.SplitHostPort(h.listener.Addr().String())
 	if err != nil {
 		return err
@@ -25,6 +25,12 @@ type persistence struct {
 	SettingsCalls       uint64
 	UnrecognizedCalls   uint64
 
+	// Bandwidth Metrics and Limits.
+	DownloadBytes uint64
+	UploadBytes   uint64
+	DownloadLimit int64
+	UploadLimit   int64
+
 	// Consensus Tracking.
 	BlockHeight  types.BlockHeight
 	RecentChange modules.ConsensusChangeID
@@ -38,6 +44,9 @@ type persistence struct {
 	SecretKey        crypto.SecretKey
 	Settings         modules.HostInternalSettings
 	UnlockHash       types.UnlockHash
+
+	// Pricing.
+	DynamicPricing modules.DynamicPricingConfig
 }
 
 // persistData returns the data in the Host that will be saved to disk.
@@ -53,6 +62,12 @@ func (h *Host) persistData() persistence {
 		SettingsCalls:       atomic.LoadUint64(&h.atomicSettingsCalls),
 		UnrecognizedCalls:   atomic.LoadUint64(&h.atomicUnrecognizedCalls),
 
+		// Bandwidth Metrics and Limits.
+		DownloadBytes: atomic.LoadUint64(&h.atomicDownloadBytes),
+		UploadBytes:   atomic.LoadUint64(&h.atomicUploadBytes),
+		DownloadLimit: atomic.LoadInt64(&h.downloadLimiter.limit),
+		UploadLimit:   atomic.LoadInt64(&h.uploadLimiter.limit),
+
 		// Consensus Tracking.
 		BlockHeight:  h.blockHeight,
 		RecentChange: h.recentChange,
@@ -66,6 +81,9 @@ func (h *Host) persistData() persistence {
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+
+		// Pricing.
+		DynamicPricing: h.dynamicPricing,
 	}
 }
 
@@ -150,6 +168,12 @@ func (h *Host) load() error {
 	atomic.StoreUint64(&h.atomicSettingsCalls, p.SettingsCalls)
 	atomic.StoreUint64(&h.atomicUnrecognizedCalls, p.UnrecognizedCalls)
 
+	// Copy over bandwidth tracking.
+	atomic.StoreUint64(&h.atomicDownloadBytes, p.DownloadBytes)
+	atomic.StoreUint64(&h.atomicUploadBytes, p.UploadBytes)
+	atomic.StoreInt64(&h.downloadLimiter.limit, p.DownloadLimit)
+	atomic.StoreInt64(&h.uploadLimiter.limit, p.UploadLimit)
+
 	// Copy over consensus tracking.
 	h.blockHeight = p.BlockHeight
 	h.recentChange = p.RecentChange
@@ -172,6 +196,9 @@ func (h *Host) load() error {
 	}
 	h.unlockHash = p.UnlockHash
 
+	// Copy over pricing.
+	h.dynamicPricing = p.DynamicPricing
+
 	err = h.initConsensusSubscription()
 	if err != nil {
 		return err
@@ -0,0 +1,121 @@
+package host
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// utilizationPrecision is the denominator used when computing the
+	// fraction of the host's storage that is in use.
+	utilizationPrecision = 1e6
+)
+
+var (
+	errInvalidPriceBounds = errors.New("dynamic pricing minimum price cannot exceed the maximum price")
+)
+
+// scalePrice returns the price between 'min' and 'max' that corresponds to
+// 'used' out of 'total' bytes of storage being in use.
+func scalePrice(min, max types.Currency, used, total uint64) types.Currency {
+	if total == 0 {
+		return min
+	}
+	utilization := types.NewCurrency64(used).Mul(types.NewCurrency64(utilizationPrecision)).Div(types.NewCurrency64(total))
+	return min.Add(max.Sub(min).Mul(utilization).Div(types.NewCurrency64(utilizationPrecision)))
+}
+
+// effectivePrices returns the storage and bandwidth prices that the host is
+// currently advertising.
+func (h *Host) effectivePrices() (storage, download, upload types.Currency) {
+	if !h.dynamicPricing.Enabled {
+		return h.settings.MinimumStoragePrice, h.settings.MinimumDownloadBandwidthPrice, h.settings.MinimumUploadBandwidthPrice
+	}
+	return h.dynamicStoragePrice, h.dynamicDownloadBandwidthPrice, h.dynamicUploadBandwidthPrice
+}
+
+// updateDynamicPrices recomputes the dynamic prices of the host from the
+// current utilization of its storage folders, and returns whether the
+// effective prices changed.
+func (h *Host) updateDynamicPrices() bool {
+	if !h.dynamicPricing.Enabled {
+		return false
+	}
+	total, remaining := h.capacity()
+	var used uint64
+	if remaining < total {
+		used = total - remaining
+	}
+	storage := scalePrice(h.dynamicPricing.MinStoragePrice, h.dynamicPricing.MaxStoragePrice, used, total)
+	download := scalePrice(h.dynamicPricing.MinDownloadBandwidthPrice, h.dynamicPricing.MaxDownloadBandwidthPrice, used, total)
+	upload := scalePrice(h.dynamicPricing.MinUploadBandwidthPrice, h.dynamicPricing.MaxUploadBandwidthPrice, used, total)
+	changed := storage.Cmp(h.dynamicStoragePrice) != 0 || download.Cmp(h.dynamicDownloadBandwidthPrice) != 0 || upload.Cmp(h.dynamicUploadBandwidthPrice) != 0
+	h.dynamicStoragePrice = storage
+	h.dynamicDownloadBandwidthPrice = download
+	h.dynamicUploadBandwidthPrice = upload
+	return changed
+}
+
+// threadedAdjustPrices periodically adjusts the dynamic prices of the host to
+// the utilization of its storage. The revision number of the host settings is
+// bumped whenever the prices change so that renters notice the new prices.
+// The loop returns when the host is closed.
+func (h *Host) threadedAdjustPrices() {
+	for {
+		h.mu.Lock()
+		h.resourceLock.RLock()
+		if h.closed {
+			h.resourceLock.RUnlock()
+			h.mu.Unlock()
+			return
+		}
+		if h.updateDynamicPrices() {
+			h.revisionNumber++
+			err := h.save()
+			if err != nil {
+				h.log.Println("WARN: failed to save the host after adjusting its prices:", err)
+			}
+		}
+		h.resourceLock.RUnlock()
+		h.mu.Unlock()
+
+		select {
+		case <-time.After(dynamicPricingInterval):
+		case <-h.closeChan:
+			return
+		}
+	}
+}
+
+// SetDynamicPricing configures the host to adjust its storage and bandwidth
+// prices within the bounds of 'config' as its storage folders fill up. The
+// prices are adjusted every dynamicPricingInterval. Disabling dynamic pricing
+// restores the static prices of the internal settings.
+func (h *Host) SetDynamicPricing(config modules.DynamicPricingConfig) error {
+	if config.Enabled {
+		if config.MinStoragePrice.Cmp(config.MaxStoragePrice) > 0 ||
+			config.MinDownloadBandwidthPrice.Cmp(config.MaxDownloadBandwidthPrice) > 0 ||
+			config.MinUploadBandwidthPrice.Cmp(config.MaxUploadBandwidthPrice) > 0 {
+			return errInvalidPriceBounds
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return errHostClosed
+	}
+
+	h.dynamicPricing = config
+	h.updateDynamicPrices()
+	h.revisionNumber++
+	err := h.saveSync()
+	if err != nil {
+		return errors.New("dynamic pricing updated, but failed saving to disk: " + err.Error())
+	}
+	return nil
+}
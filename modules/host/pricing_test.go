@@ -0,0 +1,81 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestDynamicPricing checks that a host with dynamic pricing enabled raises
+// its prices as its storage fills up, and that disabling dynamic pricing
+// restores the static prices.
+func TestDynamicPricing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestDynamicPricing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.host.Close()
+
+	config := modules.DynamicPricingConfig{
+		Enabled:                   true,
+		MinDownloadBandwidthPrice: types.NewCurrency64(100),
+		MaxDownloadBandwidthPrice: types.NewCurrency64(300),
+		MinStoragePrice:           types.NewCurrency64(1000),
+		MaxStoragePrice:           types.NewCurrency64(3000),
+		MinUploadBandwidthPrice:   types.NewCurrency64(10),
+		MaxUploadBandwidthPrice:   types.NewCurrency64(30),
+	}
+	invalid := config
+	invalid.MinStoragePrice = invalid.MaxStoragePrice.Add(types.NewCurrency64(1))
+	if ht.host.SetDynamicPricing(invalid) != errInvalidPriceBounds {
+		t.Fatal("expected errInvalidPriceBounds")
+	}
+	err = ht.host.SetDynamicPricing(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The storage of the host is empty, so the minimum prices are used.
+	es := ht.host.ExternalSettings()
+	if es.StoragePrice.Cmp(config.MinStoragePrice) != 0 || es.DownloadBandwidthPrice.Cmp(config.MinDownloadBandwidthPrice) != 0 || es.UploadBandwidthPrice.Cmp(config.MinUploadBandwidthPrice) != 0 {
+		t.Fatal("empty host should advertise the minimum prices")
+	}
+	is := ht.host.InternalSettings()
+	if is.EffectiveStoragePrice.Cmp(config.MinStoragePrice) != 0 {
+		t.Fatal("internal settings do not report the effective storage price")
+	}
+
+	// Fill half of the host's storage and wait for the prices to be adjusted.
+	total, _ := ht.host.capacity()
+	for i := uint64(0); i < total/modules.SectorSize/2; i++ {
+		sectorData, err := crypto.RandBytes(int(modules.SectorSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.AddSector(crypto.MerkleRoot(sectorData), ht.cs.Height()+10, sectorData)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(dynamicPricingInterval * 3)
+	es = ht.host.ExternalSettings()
+	if es.StoragePrice.Cmp(types.NewCurrency64(2000)) != 0 || es.DownloadBandwidthPrice.Cmp(types.NewCurrency64(200)) != 0 || es.UploadBandwidthPrice.Cmp(types.NewCurrency64(20)) != 0 {
+		t.Fatal("half full host should advertise the middle prices, got", es.StoragePrice, es.DownloadBandwidthPrice, es.UploadBandwidthPrice)
+	}
+
+	// Disabling dynamic pricing restores the static prices.
+	err = ht.host.SetDynamicPricing(modules.DynamicPricingConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	es = ht.host.ExternalSettings()
+	if es.StoragePrice.Cmp(defaultStoragePrice) != 0 || es.DownloadBandwidthPrice.Cmp(defaultDownloadBandwidthPrice) != 0 {
+		t.Fatal("host should fall back to the static prices")
+	}
+}
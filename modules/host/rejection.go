@@ -0,0 +1,110 @@
+package host
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errHostFull is returned if a renter tries to form a contract with a
+	// host that has no storage remaining.
+	errHostFull = errors.New("host has no storage remaining and cannot accept the file contract")
+
+	// errTooManyNegotiations is returned if a renter tries to form a contract
+	// while the host is already running as many contract negotiations as it
+	// allows at a time.
+	errTooManyNegotiations = errors.New("host is running too many contract negotiations, try again later")
+)
+
+// managedNextExpiration returns the estimated amount of time until the next
+// storage obligation reaches its proof deadline, at which point the storage
+// and collateral of the obligation are released. Zero is returned if the host
+// has no storage obligations that will expire.
+//
+// Every storage obligation that has not yet reached its proof deadline has an
+// action item queued at or before the deadline, so only the action items up to
+// the earliest deadline found so far need to be visited, instead of every
+// storage obligation that the host has.
+func (h *Host) managedNextExpiration() time.Duration {
+	h.mu.RLock()
+	blockHeight := h.blockHeight
+	h.mu.RUnlock()
+
+	var next types.BlockHeight
+	err := h.db.View(func(tx *bolt.Tx) error {
+		startBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(startBytes, uint64(blockHeight+1))
+		c := tx.Bucket(bucketActionItems).Cursor()
+		for k, v := c.Seek(startBytes); k != nil; k, v = c.Next() {
+			height := types.BlockHeight(binary.BigEndian.Uint64(k))
+			if next != 0 && height >= next {
+				break
+			}
+			for i := 0; i+len(types.FileContractID{}) <= len(v); i += len(types.FileContractID{}) {
+				var soid types.FileContractID
+				copy(soid[:], v[i:])
+				so, err := getStorageObligation(tx, soid)
+				if err == errNoStorageObligation {
+					// The obligation has already been removed.
+					continue
+				} else if err != nil {
+					return err
+				}
+				deadline := so.proofDeadline()
+				if deadline > blockHeight && (next == 0 || deadline < next) {
+					next = deadline
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil || next == 0 {
+		return 0
+	}
+	return time.Duration(next-blockHeight) * time.Duration(types.BlockFrequency) * time.Second
+}
+
+// managedContractRejection converts an error that caused the host to reject a
+// file contract proposal into a structured rejection, so that the renter can
+// decide whether and when to try again. The rejection is also counted so that
+// host operators can see why contracts are being rejected.
+func (h *Host) managedContractRejection(err error) modules.NegotiationRejection {
+	rejection := modules.NegotiationRejection{
+		Reason:  modules.RejectionReasonInvalid,
+		Message: err.Error(),
+	}
+	switch err {
+	case errCollateralBudgetExceeded, errInsufficientCollateralFunds:
+		rejection.Reason = modules.RejectionReasonCollateralBudget
+		rejection.RetryAfter = h.managedNextExpiration()
+	case errHostFull:
+		rejection.Reason = modules.RejectionReasonFull
+		rejection.RetryAfter = h.managedNextExpiration()
+	case errTooManyNegotiations:
+		rejection.Reason = modules.RejectionReasonRateLimited
+		rejection.RetryAfter = fileContractNegotiationTimeout
+	}
+
+	h.mu.Lock()
+	h.contractRejections[rejection.Reason]++
+	h.mu.Unlock()
+	return rejection
+}
+
+// ContractRejections returns the number of file contract proposals that the
+// host has rejected since startup, grouped by the reason for the rejection.
+func (h *Host) ContractRejections() map[modules.RejectionReason]uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rejections := make(map[modules.RejectionReason]uint64)
+	for reason, count := range h.contractRejections {
+		rejections[reason] = count
+	}
+	return rejections
+}
@@ -0,0 +1,52 @@
+package host
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestContractRejection checks that errors encountered while verifying a
+// file contract are converted into the correct rejection reasons, and that
+// the rejections are counted.
+func TestContractRejection(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester("TestContractRejection")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		err    error
+		reason modules.RejectionReason
+	}{
+		{errCollateralBudgetExceeded, modules.RejectionReasonCollateralBudget},
+		{errHostFull, modules.RejectionReasonFull},
+		{errHostFull, modules.RejectionReasonFull},
+		{errors.New("bad contract"), modules.RejectionReasonInvalid},
+		{errTooManyNegotiations, modules.RejectionReasonRateLimited},
+	}
+	for _, test := range tests {
+		rejection := ht.host.managedContractRejection(test.err)
+		if rejection.Reason != test.reason {
+			t.Errorf("expected reason %v for %v, got %v", test.reason, test.err, rejection.Reason)
+		}
+		if rejection.Message != test.err.Error() {
+			t.Error("rejection has the wrong message:", rejection.Message)
+		}
+		if test.reason == modules.RejectionReasonRateLimited && rejection.RetryAfter != fileContractNegotiationTimeout {
+			t.Error("rate limited rejection has the wrong retry interval:", rejection.RetryAfter)
+		}
+	}
+
+	rejections := ht.host.ContractRejections()
+	if rejections[modules.RejectionReasonCollateralBudget] != 1 ||
+		rejections[modules.RejectionReasonFull] != 2 ||
+		rejections[modules.RejectionReasonInvalid] != 1 ||
+		rejections[modules.RejectionReasonRateLimited] != 1 {
+		t.Error("rejections were not counted correctly:", rejections)
+	}
+}
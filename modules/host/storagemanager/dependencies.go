@@ -11,7 +11,7 @@ import (
 )
 
 // Fake errors that get returned when a simulated failure of a dependency is
-// desired for testing.
+// desired for testing or fault injection.
 var (
 	mockErrListen       = errors.New("simulated Listen failure")
 	mockErrLoadFile     = errors.New("simulated LoadFile failure")
@@ -45,6 +45,12 @@ type (
 		// with large volumes of persistent data.
 		openDatabase(persist.Metadata, string) (*persist.BoltDatabase, error)
 
+		// openSectorStore opens the backend that holds the sector data of the
+		// storage folders. A nil sectorStore indicates that sectors should
+		// be kept in the storage folders on the local filesystem, using the
+		// file operations of the dependencies.
+		openSectorStore(persistDir string) (sectorStore, error)
+
 		// randRead fills the input bytes with random data.
 		randRead([]byte) (int, error)
 
@@ -60,6 +66,25 @@ type (
 		// writeFile writes data to the filesystem using the provided filename.
 		writeFile(string, []byte, os.FileMode) error
 	}
+
+	// sectorStore is a backend that holds the sector data of the storage
+	// folders, such as an object store. Sectors are addressed by the uid
+	// string of their storage folder and by their sector key. The storage
+	// folder metadata, including capacity accounting, is always kept by the
+	// storage manager.
+	sectorStore interface {
+		// close releases any resources held by the sector store.
+		close() error
+
+		// readSector reads a sector in full from the sector store.
+		readSector(folder, sectorKey string) ([]byte, error)
+
+		// removeSector removes a sector from the sector store.
+		removeSector(folder, sectorKey string) error
+
+		// writeSector writes a sector to the sector store.
+		writeSector(folder, sectorKey string, data []byte) error
+	}
 )
 
 type (
@@ -112,6 +137,12 @@ func (productionDependencies) openDatabase(m persist.Metadata, s string) (*persi
 	return persist.OpenDatabase(m, s)
 }
 
+// openSectorStore returns a nil sector store, indicating that sectors are kept
+// in the storage folders on the local filesystem.
+func (productionDependencies) openSectorStore(string) (sectorStore, error) {
+	return nil, nil
+}
+
 // randRead fills the input bytes with random data.
 func (productionDependencies) randRead(b []byte) (int, error) {
 	return rand.Read(b)
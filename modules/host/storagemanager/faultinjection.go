@@ -0,0 +1,157 @@
+package storagemanager
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+var (
+	// errBadFaultProbability is returned if the fault injection probability
+	// is not between 0 and 1.
+	errBadFaultProbability = errors.New("fault injection probability must be between 0 and 1")
+
+	// errUnknownFaultOperation is returned if fault injection is requested
+	// for an operation that is not a dependency of the storage manager.
+	errUnknownFaultOperation = errors.New("unrecognized fault injection operation")
+
+	// faultOperations is the set of dependency operations that can have
+	// faults injected.
+	faultOperations = map[string]struct{}{
+		"loadFile":     {},
+		"mkdirAll":     {},
+		"newLogger":    {},
+		"openDatabase": {},
+		"readFile":     {},
+		"removeFile":   {},
+		"symlink":      {},
+		"writeFile":    {},
+	}
+)
+
+// faultInjectionDependencies wraps the dependencies of the storage manager,
+// randomly returning simulated errors for the selected operations.
+type faultInjectionDependencies struct {
+	dependencies
+
+	ops         map[string]struct{}
+	probability float64
+}
+
+// fault returns true if the operation has been selected for fault injection
+// and the random roll indicates that the operation should fail.
+func (fid faultInjectionDependencies) fault(op string) bool {
+	_, exists := fid.ops[op]
+	return exists && rand.Float64() < fid.probability
+}
+
+// loadFile loads a persistence structure from disk, or returns a simulated
+// failure.
+func (fid faultInjectionDependencies) loadFile(m persist.Metadata, i interface{}, s string) error {
+	if fid.fault("loadFile") {
+		return mockErrLoadFile
+	}
+	return fid.dependencies.loadFile(m, i, s)
+}
+
+// mkdirAll creates a chain of folders on the filesystem, or returns a
+// simulated failure.
+func (fid faultInjectionDependencies) mkdirAll(s string, fm os.FileMode) error {
+	if fid.fault("mkdirAll") {
+		return mockErrMkdirAll
+	}
+	return fid.dependencies.mkdirAll(s, fm)
+}
+
+// newLogger creates a logger, or returns a simulated failure.
+func (fid faultInjectionDependencies) newLogger(s string) (*persist.Logger, error) {
+	if fid.fault("newLogger") {
+		return nil, mockErrNewLogger
+	}
+	return fid.dependencies.newLogger(s)
+}
+
+// openDatabase opens a database, or returns a simulated failure.
+func (fid faultInjectionDependencies) openDatabase(m persist.Metadata, s string) (*persist.BoltDatabase, error) {
+	if fid.fault("openDatabase") {
+		return nil, mockErrOpenDatabase
+	}
+	return fid.dependencies.openDatabase(m, s)
+}
+
+// readFile reads a file from the filesystem, or returns a simulated failure.
+func (fid faultInjectionDependencies) readFile(s string) ([]byte, error) {
+	if fid.fault("readFile") {
+		return nil, mockErrReadFile
+	}
+	return fid.dependencies.readFile(s)
+}
+
+// removeFile removes a file from the filesystem, or returns a simulated
+// failure.
+func (fid faultInjectionDependencies) removeFile(s string) error {
+	if fid.fault("removeFile") {
+		return mockErrRemoveFile
+	}
+	return fid.dependencies.removeFile(s)
+}
+
+// symlink creates a symlink between a source and a destination file, or
+// returns a simulated failure.
+func (fid faultInjectionDependencies) symlink(s1, s2 string) error {
+	if fid.fault("symlink") {
+		return mockErrSymlink
+	}
+	return fid.dependencies.symlink(s1, s2)
+}
+
+// writeFile writes a file to the filesystem, or returns a simulated failure.
+func (fid faultInjectionDependencies) writeFile(s string, b []byte, fm os.FileMode) error {
+	if fid.fault("writeFile") {
+		return mockErrWriteFile
+	}
+	return fid.dependencies.writeFile(s, b, fm)
+}
+
+// SetFaultInjection will cause the named dependency operations of the storage
+// manager to randomly return simulated errors with the given probability. A
+// probability of zero removes the fault injection wrapper entirely, meaning
+// that there is no overhead when fault injection is disabled.
+func (sm *StorageManager) SetFaultInjection(probability float64, ops []string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+
+	// Check that the input is valid.
+	if probability < 0 || probability > 1 {
+		return errBadFaultProbability
+	}
+	opSet := make(map[string]struct{})
+	for _, op := range ops {
+		if _, exists := faultOperations[op]; !exists {
+			return errUnknownFaultOperation
+		}
+		opSet[op] = struct{}{}
+	}
+
+	// Strip any existing fault injection before applying the new settings.
+	if fid, ok := sm.dependencies.(faultInjectionDependencies); ok {
+		sm.dependencies = fid.dependencies
+	}
+	if probability == 0 || len(opSet) == 0 {
+		return nil
+	}
+	sm.dependencies = faultInjectionDependencies{
+		dependencies: sm.dependencies,
+
+		ops:         opSet,
+		probability: probability,
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package storagemanager
+
+import (
+	"testing"
+)
+
+// TestSetFaultInjection checks that SetFaultInjection causes the selected
+// operations to fail, and that disabling it restores the original
+// dependencies.
+func TestSetFaultInjection(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestSetFaultInjection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+
+	// Try some invalid inputs.
+	err = smt.sm.SetFaultInjection(1.1, []string{"readFile"})
+	if err != errBadFaultProbability {
+		t.Error("expected errBadFaultProbability, got", err)
+	}
+	err = smt.sm.SetFaultInjection(0.5, []string{"listen"})
+	if err != errUnknownFaultOperation {
+		t.Error("expected errUnknownFaultOperation, got", err)
+	}
+	if _, ok := smt.sm.dependencies.(productionDependencies); !ok {
+		t.Fatal("invalid inputs should not change the dependencies")
+	}
+
+	// Make every write fail, and check that only writes are affected.
+	err = smt.sm.SetFaultInjection(1, []string{"writeFile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.dependencies.writeFile(smt.persistDir+"/faulty", []byte{1, 2}, 0700)
+	if err != mockErrWriteFile {
+		t.Error("expected mockErrWriteFile, got", err)
+	}
+	err = smt.sm.dependencies.mkdirAll(smt.persistDir, 0700)
+	if err != nil {
+		t.Error("fault was injected into an operation that was not selected:", err)
+	}
+
+	// Changing the settings should not stack wrappers, and a probability of
+	// zero should restore the production dependencies.
+	err = smt.sm.SetFaultInjection(1, []string{"readFile", "removeFile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fid, ok := smt.sm.dependencies.(faultInjectionDependencies)
+	if !ok {
+		t.Fatal("fault injection was not enabled")
+	}
+	if _, ok := fid.dependencies.(productionDependencies); !ok {
+		t.Error("fault injection wrappers were stacked")
+	}
+	err = smt.sm.SetFaultInjection(0, []string{"readFile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := smt.sm.dependencies.(productionDependencies); !ok {
+		t.Error("disabling fault injection did not restore the dependencies")
+	}
+}
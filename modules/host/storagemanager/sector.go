@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -77,6 +76,10 @@ var (
 
 	// errSectorNotFound is returned when a lookup for a sector fails.
 	errSectorNotFound = errors.New("could not find the desired sector")
+
+	// errSectorFolderMissing is returned when a sector is recorded as being
+	// in a storage folder that the storage manager does not have.
+	errSectorFolderMissing = errors.New("sector is recorded in a storage folder that does not exist")
 )
 
 // sectorUsage indicates how a sector is being used. Each block height
@@ -121,6 +124,33 @@ func (sm *StorageManager) sectorID(sectorRootBytes []byte) []byte {
 	return id
 }
 
+// readSector reads a sector from the storage folder with the provided uid
+// string, using the sector store if there is one.
+func (sm *StorageManager) readSector(folder string, sectorKey []byte) ([]byte, error) {
+	if sm.sectorStore != nil {
+		return sm.sectorStore.readSector(folder, string(sectorKey))
+	}
+	return sm.dependencies.readFile(filepath.Join(sm.persistDir, folder, string(sectorKey)))
+}
+
+// removeSector removes a sector from the storage folder with the provided uid
+// string, using the sector store if there is one.
+func (sm *StorageManager) removeSector(folder string, sectorKey []byte) error {
+	if sm.sectorStore != nil {
+		return sm.sectorStore.removeSector(folder, string(sectorKey))
+	}
+	return sm.dependencies.removeFile(filepath.Join(sm.persistDir, folder, string(sectorKey)))
+}
+
+// writeSector writes a sector to the storage folder with the provided uid
+// string, using the sector store if there is one.
+func (sm *StorageManager) writeSector(folder string, sectorKey []byte, data []byte) error {
+	if sm.sectorStore != nil {
+		return sm.sectorStore.writeSector(folder, string(sectorKey), data)
+	}
+	return sm.dependencies.writeFile(filepath.Join(sm.persistDir, folder, string(sectorKey)), data, 0700)
+}
+
 // AddSector will add a data sector to the host, correctly selecting the
 // storage folder in which the sector belongs.
 func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.BlockHeight, sectorData []byte) error {
@@ -185,8 +215,7 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 		potentialFolders := sm.storageFolders
 		emptiestFolder, emptiestIndex := emptiestStorageFolder(potentialFolders)
 		for emptiestFolder != nil {
-			sectorPath := filepath.Join(sm.persistDir, emptiestFolder.uidString(), string(sectorKey))
-			err := sm.dependencies.writeFile(sectorPath, sectorData, 0700)
+			err := sm.writeSector(emptiestFolder.uidString(), sectorKey, sectorData)
 			if err != nil {
 				// Indicate to the user that the storage folder is having write
 				// trouble.
@@ -195,7 +224,7 @@ func (sm *StorageManager) AddSector(sectorRoot crypto.Hash, expiryHeight types.B
 				// Remove the attempted write - an an incomplete write can
 				// leave a partial file on disk. Error is not checked, we
 				// already know the disk is having trouble.
-				_ = sm.dependencies.removeFile(sectorPath)
+				_ = sm.removeSector(emptiestFolder.uidString(), sectorKey)
 
 				// Remove the failed folder from the list of folders that can
 				// be tried.
@@ -236,6 +265,7 @@ func (sm *StorageManager) ReadSector(sectorRoot crypto.Hash) (sectorBytes []byte
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	readFailed := false
 	err = sm.db.View(func(tx *bolt.Tx) error {
 		bsu := tx.Bucket(bucketSectorUsage)
 		sectorKey := sm.sectorID(sectorRoot[:])
@@ -249,16 +279,27 @@ func (sm *StorageManager) ReadSector(sectorRoot crypto.Hash) (sectorBytes []byte
 			return err
 		}
 
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(su.StorageFolder), string(sectorKey))
-		sectorBytes, err = ioutil.ReadFile(sectorPath)
+		sf := sm.storageFolder(su.StorageFolder)
+		if sf == nil {
+			sm.log.Println("WARN: sector usage points to a storage folder that does not exist")
+			return errSectorFolderMissing
+		}
+		sectorBytes, err = sm.readSector(hex.EncodeToString(su.StorageFolder), sectorKey)
 		if err != nil {
-			// Mark the read failure in the sector.
-			sf := sm.storageFolder(su.StorageFolder)
+			// Mark the read failure in the storage folder.
 			sf.FailedReads++
+			readFailed = true
 			return err
 		}
+		sf.SuccessfulReads++
 		return nil
 	})
+	if readFailed {
+		// Persist the read failure so that the health of the storage folder
+		// survives a restart. Successful reads are persisted the next time
+		// the storage manager saves, to avoid a disk write on every read.
+		_ = sm.save()
+	}
 	return
 }
 
@@ -328,8 +369,7 @@ func (sm *StorageManager) RemoveSector(sectorRoot crypto.Hash, expiryHeight type
 
 		// Remove the sector from the physical disk and update the storage
 		// folder metadata.
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(usage.StorageFolder), string(sectorKey))
-		err = sm.dependencies.removeFile(sectorPath)
+		err = sm.removeSector(hex.EncodeToString(usage.StorageFolder), sectorKey)
 		if err != nil {
 			// Indicate that the storage folder is having write troubles.
 			folder.FailedWrites++
@@ -387,8 +427,7 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 		// Remove the sector from the physical disk and update the storage
 		// folder metadata. The file is removed from disk as early as possible
 		// to prevent potential errors from stopping the delete.
-		sectorPath := filepath.Join(sm.persistDir, hex.EncodeToString(usage.StorageFolder), string(sectorKey))
-		err = sm.dependencies.removeFile(sectorPath)
+		err = sm.removeSector(hex.EncodeToString(usage.StorageFolder), sectorKey)
 		if err != nil {
 			// Indicate that the storage folder is having write troubles.
 			folder.FailedWrites++
@@ -406,3 +445,34 @@ func (sm *StorageManager) DeleteSector(sectorRoot crypto.Hash) error {
 		return bsu.Delete(sectorKey)
 	})
 }
+
+// DeduplicationStats reports how effectively the storage manager is sharing
+// physical sectors between virtual sectors. 'uniqueSectors' is the number of
+// physical sectors on disk, 'totalReferences' is the number of virtual
+// sectors pointing to them, and 'bytesSaved' is the amount of disk space that
+// would have been consumed if every reference had its own physical copy.
+func (sm *StorageManager) DeduplicationStats() (uniqueSectors, totalReferences, bytesSaved uint64) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	err := sm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorUsage).ForEach(func(_, usageBytes []byte) error {
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			uniqueSectors++
+			totalReferences += uint64(len(usage.Expiry))
+			return nil
+		})
+	})
+	if err != nil {
+		sm.log.Println("WARN: unable to compute deduplication stats:", err)
+		return 0, 0, 0
+	}
+	if totalReferences > uniqueSectors {
+		bytesSaved = (totalReferences - uniqueSectors) * modules.SectorSize
+	}
+	return uniqueSectors, totalReferences, bytesSaved
+}
@@ -1,11 +1,78 @@
 package storagemanager
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+var errMockSectorStoreClose = errors.New("mocked sector store close error")
+
+type (
+	// memSectorStore is a sector store that keeps all sectors in memory.
+	memSectorStore struct {
+		closeErr error
+		mu       sync.Mutex
+		sectors  map[string][]byte
+	}
+
+	// memSectorStoreDependencies replaces the local filesystem sector storage
+	// with a memSectorStore.
+	memSectorStoreDependencies struct {
+		productionDependencies
+		store *memSectorStore
+	}
+)
+
+// close returns the close error of the memSectorStore.
+func (mss *memSectorStore) close() error {
+	return mss.closeErr
+}
+
+// readSector returns a sector from the memSectorStore.
+func (mss *memSectorStore) readSector(folder, sectorKey string) ([]byte, error) {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	data, exists := mss.sectors[folder+"/"+sectorKey]
+	if !exists {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+// removeSector deletes a sector from the memSectorStore.
+func (mss *memSectorStore) removeSector(folder, sectorKey string) error {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	_, exists := mss.sectors[folder+"/"+sectorKey]
+	if !exists {
+		return os.ErrNotExist
+	}
+	delete(mss.sectors, folder+"/"+sectorKey)
+	return nil
+}
+
+// writeSector adds a sector to the memSectorStore.
+func (mss *memSectorStore) writeSector(folder, sectorKey string, data []byte) error {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	mss.sectors[folder+"/"+sectorKey] = append([]byte(nil), data...)
+	return nil
+}
+
+// openSectorStore returns the memSectorStore of the dependencies.
+func (msd memSectorStoreDependencies) openSectorStore(string) (sectorStore, error) {
+	return msd.store, nil
+}
+
 // TestMaxVirtualSectors checks that the max virtual sector limit is enforced
 // when adding sectors.
 func TestMaxVirtualSectors(t *testing.T) {
@@ -77,3 +144,257 @@ func TestBadSectorAdd(t *testing.T) {
 	_ = smt.sm.AddSector(sectorRoot, 1, sectorData[:1])
 	t.Fatal("panic not thrown")
 }
+
+// TestSectorStore checks that sectors are kept in the sector store provided by
+// the dependencies instead of in the storage folders on disk.
+func TestSectorStore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.StorageManagerDir, "TestSectorStore")
+	store := &memSectorStore{
+		closeErr: errMockSectorStoreClose,
+		sectors:  make(map[string][]byte),
+	}
+	sm, err := newStorageManager(memSectorStoreDependencies{store: store}, filepath.Join(testdir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a storage folder and a sector.
+	storageFolderOne := filepath.Join(testdir, "hd1")
+	err = os.Mkdir(storageFolderOne, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sm.AddSector(sectorRoot, 1, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sector should be in the sector store, and not on disk.
+	if len(store.sectors) != 1 {
+		t.Fatal("sector was not added to the sector store")
+	}
+	for _, data := range store.sectors {
+		if !bytes.Equal(data, sectorData) {
+			t.Error("sector store holds the wrong data")
+		}
+	}
+	infos, err := ioutil.ReadDir(storageFolderOne)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 {
+		t.Error("sector was written to the storage folder on disk")
+	}
+
+	// Read the sector back, then remove it.
+	readData, err := sm.ReadSector(sectorRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readData, sectorData) {
+		t.Error("sector read from the sector store has the wrong data")
+	}
+	err = sm.RemoveSector(sectorRoot, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.sectors) != 0 {
+		t.Error("sector was not removed from the sector store")
+	}
+
+	// The error of the sector store should be reported when closing.
+	err = sm.Close()
+	if err == nil {
+		t.Error("sector store close error was not reported")
+	}
+}
+
+// TestReadSectorHealth checks that reads of sectors are tracked in the health
+// statistics of the storage folder, and that read failures survive a restart.
+func TestReadSectorHealth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestReadSectorHealth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+
+	// Add a storage folder and a sector.
+	storageFolderOne := filepath.Join(smt.persistDir, "hd1")
+	err = os.Mkdir(storageFolderOne, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddSector(sectorRoot, 1, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A successful read should be reported.
+	_, err = smt.sm.ReadSector(sectorRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs := smt.sm.StorageFolders()
+	if sfs[0].SuccessfulReads != 1 || sfs[0].FailedReads != 0 {
+		t.Error("successful read was not reported:", sfs[0].SuccessfulReads, sfs[0].FailedReads)
+	}
+
+	// Break the storage folder and read the sector again.
+	smt.sm.dependencies = faultyFS{brokenSubstrings: []string{smt.sm.storageFolders[0].uidString()}}
+	_, err = smt.sm.ReadSector(sectorRoot)
+	if err != mockErrReadFile {
+		t.Fatal(err)
+	}
+	sfs = smt.sm.StorageFolders()
+	if sfs[0].SuccessfulReads != 1 || sfs[0].FailedReads != 1 {
+		t.Error("failed read was not reported:", sfs[0].SuccessfulReads, sfs[0].FailedReads)
+	}
+
+	// Restart the storage manager, the read failure should be persisted.
+	smt.sm.dependencies = productionDependencies{}
+	err = smt.sm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	smt.sm, err = New(filepath.Join(smt.persistDir, modules.StorageManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs = smt.sm.StorageFolders()
+	if sfs[0].Capacity != minimumStorageFolderSize || sfs[0].CapacityRemaining != minimumStorageFolderSize-modules.SectorSize {
+		t.Error("storage folder usage was not reported correctly after a restart")
+	}
+	if sfs[0].FailedReads != 1 {
+		t.Error("failed read was not persisted:", sfs[0].FailedReads)
+	}
+}
+
+// TestReadSectorMissingFolder checks that reading a sector whose storage
+// folder is unknown to the storage manager returns an error.
+func TestReadSectorMissingFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestReadSectorMissingFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+
+	// Add a storage folder and a sector.
+	storageFolderOne := filepath.Join(smt.persistDir, "hd1")
+	err = os.Mkdir(storageFolderOne, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddSector(sectorRoot, 1, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Forget the storage folder without updating the sector usage.
+	smt.sm.mu.Lock()
+	sfs := smt.sm.storageFolders
+	smt.sm.storageFolders = nil
+	smt.sm.mu.Unlock()
+	_, err = smt.sm.ReadSector(sectorRoot)
+	if err != errSectorFolderMissing {
+		t.Fatal("expected errSectorFolderMissing, got", err)
+	}
+	smt.sm.mu.Lock()
+	smt.sm.storageFolders = sfs
+	smt.sm.mu.Unlock()
+}
+
+// TestDeduplicationStats checks that adding the same sector twice stores a
+// single physical copy, and that the deduplication stats count both
+// references until one of them is removed.
+func TestDeduplicationStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestDeduplicationStats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+
+	err = smt.sm.AddStorageFolder(smt.persistDir, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique, refs, saved := smt.sm.DeduplicationStats()
+	if unique != 0 || refs != 0 || saved != 0 {
+		t.Fatal("empty storage manager has deduplication stats:", unique, refs, saved)
+	}
+
+	// Upload the same sector twice, with different expirations.
+	sectorRoot, sectorData, err := createSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddSector(sectorRoot, 1, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smt.sm.AddSector(sectorRoot, 2, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique, refs, saved = smt.sm.DeduplicationStats()
+	if unique != 1 || refs != 2 || saved != modules.SectorSize {
+		t.Error("duplicate sector was not deduplicated:", unique, refs, saved)
+	}
+	sfs := smt.sm.StorageFolders()
+	if sfs[0].CapacityRemaining != minimumStorageFolderSize-modules.SectorSize {
+		t.Error("duplicate sector consumed more than one sector of storage")
+	}
+
+	// Removing one reference should keep the physical sector.
+	err = smt.sm.RemoveSector(sectorRoot, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique, refs, saved = smt.sm.DeduplicationStats()
+	if unique != 1 || refs != 1 || saved != 0 {
+		t.Error("wrong deduplication stats after removing a reference:", unique, refs, saved)
+	}
+	_, err = smt.sm.ReadSector(sectorRoot)
+	if err != nil {
+		t.Fatal("sector was freed while it was still referenced:", err)
+	}
+}
@@ -87,6 +87,11 @@ var (
 	// reduced in size.
 	errInsufficientRemainingStorageForShrink = errors.New("not enough storage remaining to support shrinking of disk")
 
+	// errInsufficientStorageForMove is returned if the destination of a
+	// sector move runs out of space before all of the sectors have been
+	// moved.
+	errInsufficientStorageForMove = errors.New("destination storage folder does not have enough storage remaining to hold the sectors being moved")
+
 	// errLargeStorageFolder is returned if a new storage folder or a resized
 	// storage folder would exceed the maximum allowed size.
 	errLargeStorageFolder = fmt.Errorf("maximum allowed size for a storage folder is %v bytes", maximumStorageFolderSize)
@@ -95,6 +100,14 @@ var (
 	// storage folders has been reached.
 	errMaxStorageFolders = fmt.Errorf("host can only accept up to %v storage folders", maximumStorageFolders)
 
+	// errMoveFolderRemoved is returned if a storage folder is removed while
+	// sectors are being moved off of or onto it.
+	errMoveFolderRemoved = errors.New("storage folder was removed while sectors were being moved")
+
+	// errMoveSameStorageFolder is returned if sectors are moved from a
+	// storage folder to itself.
+	errMoveSameStorageFolder = errors.New("cannot move sectors from a storage folder to itself")
+
 	// errNoResize is returned if a new size is provided for a storage folder
 	// that is the same as the current size of the storage folder.
 	errNoResize = errors.New("storage folder selected for resize, but new size is same as current size")
@@ -103,6 +116,10 @@ var (
 	// enough to meet the requirements for the minimum storage folder size.
 	errSmallStorageFolder = fmt.Errorf("minimum allowed size for a storage folder is %v bytes", minimumStorageFolderSize)
 
+	// errStorageFolderNotFound is returned if there is no storage folder at
+	// the provided path.
+	errStorageFolderNotFound = errors.New("no storage folder exists at that path")
+
 	// errStorageFolderNotFolder is returned if a storage folder gets added
 	// that is not a folder.
 	errStorageFolderNotFolder = errors.New("must use to an existing folder")
@@ -156,6 +173,10 @@ type storageFolder struct {
 	FailedWrites     uint64
 	SuccessfulReads  uint64
 	SuccessfulWrites uint64
+
+	// sectorsToMove is the number of sectors that MoveSectors has yet to move
+	// out of the storage folder. It is not persisted.
+	sectorsToMove uint64
 }
 
 // emptiestStorageFolder takes a set of storage folders and returns the storage
@@ -260,9 +281,8 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 			success := false
 			emptiestFolder, emptiestIndex := emptiestStorageFolder(availableFolders)
 			for emptiestFolder != nil {
-				oldSectorPath := filepath.Join(sm.persistDir, offloadFolder.uidString(), string(currentSectorID))
 				// Try reading the sector from disk.
-				sectorData, err := sm.dependencies.readFile(oldSectorPath)
+				sectorData, err := sm.readSector(offloadFolder.uidString(), currentSectorID)
 				if err != nil {
 					// Inidicate that the storage folder is having read
 					// troubles.
@@ -277,8 +297,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 				offloadFolder.SuccessfulReads++
 
 				// Try writing the sector to the emptiest storage folder.
-				newSectorPath := filepath.Join(sm.persistDir, emptiestFolder.uidString(), string(currentSectorID))
-				err = sm.dependencies.writeFile(newSectorPath, sectorData, 0700)
+				err = sm.writeSector(emptiestFolder.uidString(), currentSectorID, sectorData)
 				if err != nil {
 					// Indicate that the storage folder is having write
 					// troubles.
@@ -287,7 +306,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 					// After the failed write, try removing any garbage that
 					// may have gotten left behind. The error is not checked,
 					// as it is known that the disk is having write troubles.
-					_ = sm.dependencies.removeFile(newSectorPath)
+					_ = sm.removeSector(emptiestFolder.uidString(), currentSectorID)
 
 					// Because the write failed, we should move on to the next
 					// storage folder, and remove the current storage folder
@@ -300,7 +319,7 @@ func (sm *StorageManager) offloadStorageFolder(offloadFolder *storageFolder, dat
 				}
 				// Indicate that the storage folder is doing successful writes.
 				emptiestFolder.SuccessfulWrites++
-				err = sm.dependencies.removeFile(oldSectorPath)
+				err = sm.removeSector(offloadFolder.uidString(), currentSectorID)
 				if err != nil {
 					// Indicate that the storage folder is having write
 					// troubles.
@@ -360,6 +379,95 @@ func (sm *StorageManager) storageFolder(uid []byte) *storageFolder {
 	return nil
 }
 
+// storageFolderByPath returns the first storage folder in the host with the
+// input path. If the storage folder is not found, nil is returned.
+func (sm *StorageManager) storageFolderByPath(path string) *storageFolder {
+	for _, sf := range sm.storageFolders {
+		if sf.Path == path {
+			return sf
+		}
+	}
+	return nil
+}
+
+// moveSector moves a single sector from the storage folder 'from' to the
+// storage folder 'to'. The sector is written to the destination before the
+// sector usage database is updated, and only removed from the source once the
+// update has been committed, so that an interruption at any point leaves the
+// database pointing to a complete copy of the sector. Sectors that have been
+// removed or moved since they were listed are skipped.
+func (sm *StorageManager) moveSector(from, to *storageFolder, sectorID []byte) error {
+	if sm.storageFolder(from.UID) != from || sm.storageFolder(to.UID) != to {
+		return errMoveFolderRemoved
+	}
+	if to.SizeRemaining < modules.SectorSize {
+		return errInsufficientStorageForMove
+	}
+
+	written := false
+	err := sm.db.Update(func(tx *bolt.Tx) error {
+		bsu := tx.Bucket(bucketSectorUsage)
+		usageBytes := bsu.Get(sectorID)
+		if usageBytes == nil {
+			return nil
+		}
+		var usage sectorUsage
+		err := json.Unmarshal(usageBytes, &usage)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(usage.StorageFolder, from.UID) {
+			return nil
+		}
+
+		sectorData, err := sm.readSector(from.uidString(), sectorID)
+		if err != nil {
+			from.FailedReads++
+			return err
+		}
+		from.SuccessfulReads++
+		err = sm.writeSector(to.uidString(), sectorID, sectorData)
+		if err != nil {
+			// Try removing any garbage that may have been left behind by
+			// the failed write.
+			to.FailedWrites++
+			_ = sm.removeSector(to.uidString(), sectorID)
+			return err
+		}
+		to.SuccessfulWrites++
+		written = true
+
+		usage.StorageFolder = to.UID
+		newUsageBytes, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+		return bsu.Put(sectorID, newUsageBytes)
+	})
+	if err != nil {
+		// The database still points to the source folder, remove the copy
+		// that was written to the destination.
+		if written {
+			_ = sm.removeSector(to.uidString(), sectorID)
+		}
+		return err
+	}
+	if !written {
+		return nil
+	}
+
+	// The database now points to the destination, remove the original copy.
+	err = sm.removeSector(from.uidString(), sectorID)
+	if err != nil {
+		from.FailedWrites++
+	} else {
+		from.SuccessfulWrites++
+	}
+	from.SizeRemaining += modules.SectorSize
+	to.SizeRemaining -= modules.SectorSize
+	return sm.save()
+}
+
 // uidString returns the string value of the storage folder's UID. This string
 // maps to the filename of the symlink that is used to point to the folder that
 // holds all of the sector data contained by the storage folder.
@@ -572,6 +680,77 @@ func (sm *StorageManager) ResizeStorageFolder(storageFolderIndex int, newSize ui
 	return sm.saveSync()
 }
 
+// MoveSectors moves every sector in the storage folder at path 'fromFolder'
+// to the storage folder at path 'toFolder', such as when retiring a disk. The
+// storage manager is only locked while a single sector is being moved, so the
+// host keeps serving requests during the move, and the number of sectors that
+// remain to be moved is reported by StorageFolders. A sector that cannot be
+// moved is left in the source folder and the move continues with the next
+// sector; the failures are returned together once the move has finished. The
+// move stops early if the destination runs out of space.
+func (sm *StorageManager) MoveSectors(fromFolder, toFolder string) error {
+	sm.resourceLock.RLock()
+	defer sm.resourceLock.RUnlock()
+	if sm.closed {
+		return errStorageManagerClosed
+	}
+
+	// Find the storage folders and list the sectors that need to be moved.
+	sm.mu.Lock()
+	from := sm.storageFolderByPath(fromFolder)
+	to := sm.storageFolderByPath(toFolder)
+	if from == nil || to == nil {
+		sm.mu.Unlock()
+		return errStorageFolderNotFound
+	}
+	if from == to {
+		sm.mu.Unlock()
+		return errMoveSameStorageFolder
+	}
+	var sectorIDs [][]byte
+	err := sm.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSectorUsage).ForEach(func(sectorID, usageBytes []byte) error {
+			var usage sectorUsage
+			err := json.Unmarshal(usageBytes, &usage)
+			if err != nil {
+				return err
+			}
+			if !usage.Corrupted && bytes.Equal(usage.StorageFolder, from.UID) {
+				sectorIDs = append(sectorIDs, append([]byte(nil), sectorID...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		sm.mu.Unlock()
+		return err
+	}
+	from.sectorsToMove = uint64(len(sectorIDs))
+	sm.mu.Unlock()
+
+	// Move the sectors one at a time, releasing the lock in between.
+	var moveErrs []error
+	for _, sectorID := range sectorIDs {
+		sm.mu.Lock()
+		err := sm.moveSector(from, to, sectorID)
+		from.sectorsToMove--
+		sm.mu.Unlock()
+		if err == errInsufficientStorageForMove || err == errMoveFolderRemoved {
+			moveErrs = append(moveErrs, err)
+			break
+		}
+		if err != nil {
+			moveErrs = append(moveErrs, fmt.Errorf("unable to move sector %x: %v", sectorID, err))
+		}
+	}
+
+	sm.mu.Lock()
+	from.sectorsToMove = 0
+	moveErrs = append(moveErrs, sm.saveSync())
+	sm.mu.Unlock()
+	return composeErrors(moveErrs...)
+}
+
 // StorageFolders provides information about all of the storage folders in the
 // host.
 func (sm *StorageManager) StorageFolders() (sfms []modules.StorageFolderMetadata) {
@@ -588,6 +767,8 @@ func (sm *StorageManager) StorageFolders() (sfms []modules.StorageFolderMetadata
 			FailedWrites:     sf.FailedWrites,
 			SuccessfulReads:  sf.SuccessfulReads,
 			SuccessfulWrites: sf.SuccessfulWrites,
+
+			SectorsToMove: sf.sectorsToMove,
 		})
 	}
 	return sfms
@@ -1,6 +1,9 @@
 package storagemanager
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -338,3 +341,84 @@ func TestEmptiestStorageFolder(t *testing.T) {
 		}
 	}
 }
+
+// TestMoveSectors checks that MoveSectors moves every sector from one storage
+// folder to another, and that the sectors can be read after the move.
+func TestMoveSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	smt, err := newStorageManagerTester("TestMoveSectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smt.Close()
+
+	// Add a storage folder and fill it with a few sectors.
+	storageFolderOne := filepath.Join(smt.persistDir, "manager drive 1")
+	storageFolderTwo := filepath.Join(smt.persistDir, "manager drive 2")
+	for _, path := range []string{storageFolderOne, storageFolderTwo} {
+		err = os.Mkdir(path, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = smt.sm.AddStorageFolder(storageFolderOne, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectors := make(map[crypto.Hash][]byte)
+	for i := 0; i < 3; i++ {
+		sectorRoot, sectorData, err := createSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = smt.sm.AddSector(sectorRoot, 10, sectorData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sectors[sectorRoot] = sectorData
+	}
+
+	// Add a second storage folder and move the sectors onto it.
+	err = smt.sm.AddStorageFolder(storageFolderTwo, minimumStorageFolderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smt.sm.MoveSectors(storageFolderOne, storageFolderOne) != errMoveSameStorageFolder {
+		t.Error("expected errMoveSameStorageFolder")
+	}
+	if smt.sm.MoveSectors(storageFolderOne, smt.persistDir) != errStorageFolderNotFound {
+		t.Error("expected errStorageFolderNotFound")
+	}
+	err = smt.sm.MoveSectors(storageFolderOne, storageFolderTwo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the storage folder stats and the files on disk.
+	sfs := smt.sm.StorageFolders()
+	if sfs[0].CapacityRemaining != sfs[0].Capacity || sfs[0].SectorsToMove != 0 {
+		t.Error("sectors were not moved out of the first storage folder:", sfs[0])
+	}
+	if sfs[1].Capacity-sfs[1].CapacityRemaining != 3*modules.SectorSize {
+		t.Error("sectors were not moved into the second storage folder:", sfs[1])
+	}
+	for sectorRoot, sectorData := range sectors {
+		sectorID := string(smt.sm.sectorID(sectorRoot[:]))
+		if _, err := os.Stat(filepath.Join(storageFolderOne, sectorID)); !os.IsNotExist(err) {
+			t.Error("moved sector was not removed from the first storage folder")
+		}
+		if _, err := os.Stat(filepath.Join(storageFolderTwo, sectorID)); err != nil {
+			t.Error("moved sector is not in the second storage folder:", err)
+		}
+		readData, err := smt.sm.ReadSector(sectorRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(readData, sectorData) {
+			t.Error("moved sector has the wrong data")
+		}
+	}
+}
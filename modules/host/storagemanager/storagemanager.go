@@ -50,6 +50,10 @@ type StorageManager struct {
 	sectorSalt     crypto.Hash
 	storageFolders []*storageFolder
 
+	// sectorStore holds the sector data of the storage folders. If it is nil,
+	// sectors are kept in the storage folders on the local filesystem.
+	sectorStore sectorStore
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	log        *persist.Logger
@@ -86,6 +90,14 @@ func (sm *StorageManager) Close() (composedError error) {
 		composedError = composeErrors(composedError, err)
 	}
 
+	// Close the sector store.
+	if sm.sectorStore != nil {
+		err = sm.sectorStore.close()
+		if err != nil {
+			composedError = composeErrors(composedError, err)
+		}
+	}
+
 	// Save the latest host state.
 	sm.mu.Lock()
 	err = sm.saveSync()
@@ -150,6 +162,14 @@ func newStorageManager(dependencies dependencies, persistDir string) (*StorageMa
 		_ = sm.db.Close()
 		return nil, err
 	}
+
+	// Open the backend that holds the sector data.
+	sm.sectorStore, err = dependencies.openSectorStore(sm.persistDir)
+	if err != nil {
+		_ = sm.log.Close()
+		_ = sm.db.Close()
+		return nil, err
+	}
 	return sm, nil
 }
 
@@ -28,9 +28,11 @@ package host
 // TODO: Make sure that not too many action items are being created.
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"sort"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -173,6 +175,15 @@ func (so *storageObligation) expiration() types.BlockHeight {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0].WindowStart
 }
 
+// fileSize returns the size of the data covered by the latest revision of the
+// storage obligation.
+func (so *storageObligation) fileSize() uint64 {
+	if len(so.RevisionTransactionSet) > 0 {
+		return so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0].NewFileSize
+	}
+	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts[0].FileSize
+}
+
 // id returns the id of the storage obligation, which is definied by the file
 // contract id of the file contract that governs the storage contract.
 func (so *storageObligation) id() types.FileContractID {
@@ -282,6 +293,18 @@ func (h *Host) queueActionItem(height types.BlockHeight, id types.FileContractID
 	})
 }
 
+// addObligationMetrics adds the potential revenue, the collateral and the
+// fees of a storage obligation to the financial metrics of the host.
+func (h *Host) addObligationMetrics(so storageObligation) {
+	h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Add(so.ContractCost)
+	h.financialMetrics.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral.Add(so.LockedCollateral)
+	h.financialMetrics.PotentialStorageRevenue = h.financialMetrics.PotentialStorageRevenue.Add(so.PotentialStorageRevenue)
+	h.financialMetrics.PotentialDownloadBandwidthRevenue = h.financialMetrics.PotentialDownloadBandwidthRevenue.Add(so.PotentialDownloadRevenue)
+	h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
+	h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
+	h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+}
+
 // addStorageObligation adds a storage obligation to the host. Because this
 // operation can return errors, the transactions should not be submitted to the
 // blockchain until after this function has indicated success. All of the
@@ -356,13 +379,7 @@ func (h *Host) addStorageObligation(so *storageObligation) error {
 
 	// Update the host financial metrics with regards to this storage
 	// obligation.
-	h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Add(so.ContractCost)
-	h.financialMetrics.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral.Add(so.LockedCollateral)
-	h.financialMetrics.PotentialStorageRevenue = h.financialMetrics.PotentialStorageRevenue.Add(so.PotentialStorageRevenue)
-	h.financialMetrics.PotentialDownloadBandwidthRevenue = h.financialMetrics.PotentialDownloadBandwidthRevenue.Add(so.PotentialDownloadRevenue)
-	h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
-	h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
-	h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+	h.addObligationMetrics(*so)
 
 	// Set an action item that will have the host verify that the file contract
 	// has been submitted to the blockchain, then another to submit the file
@@ -585,7 +602,8 @@ func (h *Host) handleActionItem(so *storageObligation) {
 		// the storage obligation should be removed. This check should come
 		// after logging the errror so that the function can quit.
 		_, t := err.(modules.ConsensusConflict)
-		if t {
+		_, c := err.(modules.ConflictError)
+		if t || c {
 			err = h.removeStorageObligation(so, obligationRejected)
 			if err != nil {
 				h.log.Println(err)
@@ -646,7 +664,7 @@ func (h *Host) handleActionItem(so *storageObligation) {
 		if err != nil {
 			h.log.Println(err)
 		}
-		builder.AddMinerFee(requiredFee)
+		_, err = builder.AddMinerFee(requiredFee)
 		if err != nil {
 			h.log.Println(err)
 		}
@@ -724,8 +742,16 @@ func (h *Host) handleActionItem(so *storageObligation) {
 		if err != nil {
 			return
 		}
-		builder.AddMinerFee(requiredFee)
-		builder.AddStorageProof(sp)
+		_, err = builder.AddMinerFee(requiredFee)
+		if err != nil {
+			builder.Drop()
+			return
+		}
+		_, err = builder.AddStorageProof(sp)
+		if err != nil {
+			builder.Drop()
+			return
+		}
 		storageProofSet, err := builder.Sign(true)
 		if err != nil {
 			return
@@ -777,3 +803,54 @@ func (h *Host) unlockStorageObligation(so *storageObligation) error {
 	delete(h.lockedStorageObligations, so.id())
 	return nil
 }
+
+// contractsByExpiration sorts host contracts by expiration height, breaking
+// ties with the contract id.
+type contractsByExpiration []modules.HostContract
+
+func (cs contractsByExpiration) Len() int      { return len(cs) }
+func (cs contractsByExpiration) Swap(i, j int) { cs[i], cs[j] = cs[j], cs[i] }
+func (cs contractsByExpiration) Less(i, j int) bool {
+	if cs[i].ExpirationHeight != cs[j].ExpirationHeight {
+		return cs[i].ExpirationHeight < cs[j].ExpirationHeight
+	}
+	return bytes.Compare(cs[i].ID[:], cs[j].ID[:]) < 0
+}
+
+// Contracts returns the storage obligations that the host is actively
+// managing, ordered by expiration height. The obligations are read from the
+// host database in a read-only transaction, so the call does not block
+// contracts that are being negotiated.
+func (h *Host) Contracts() []modules.HostContract {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.resourceLock.RLock()
+	defer h.resourceLock.RUnlock()
+	if h.closed {
+		return nil
+	}
+
+	var contracts []modules.HostContract
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return err
+			}
+			contracts = append(contracts, modules.HostContract{
+				ID:               so.id(),
+				DataSize:         so.fileSize(),
+				ExpirationHeight: so.expiration(),
+				LockedCollateral: so.LockedCollateral,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		h.log.Println("ERROR: could not read the storage obligations of the host:", err)
+		return nil
+	}
+	sort.Sort(contractsByExpiration(contracts))
+	return contracts
+}
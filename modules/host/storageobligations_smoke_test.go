@@ -6,6 +6,7 @@ package host
 // correctly.
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -40,7 +41,7 @@ func (ht *hostTester) newTesterStorageObligation() (*storageObligation, error) {
 		return nil, err
 	}
 	// Add the file contract that consumes the funds.
-	_ = builder.AddFileContract(types.FileContract{
+	builder.AddFileContract(types.FileContract{
 		// Because this file contract needs to be able to accept file contract
 		// revisions, the expiration is put more than
 		// 'revisionSubmissionBuffer' blocks into the future.
@@ -700,3 +701,84 @@ func TestAutoRevisionSubmission(t *testing.T) {
 		t.Fatal("the host should be reporting revenue after a successful storage proof")
 	}
 }
+
+// TestDuplicateSectorStorageObligations checks that two storage obligations
+// storing the same sector share a single physical copy, and that the sector is
+// only freed once neither obligation references it.
+func TestDuplicateSectorStorageObligations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestDuplicateSectorStorageObligations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoot, sectorData, err := randSector()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create two storage obligations that each store the same sector.
+	var sos []*storageObligation
+	for i := 0; i < 2; i++ {
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.lockStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.addStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.SectorRoots = []crypto.Hash{sectorRoot}
+		err = ht.host.modifyStorageObligation(so, nil, []crypto.Hash{sectorRoot}, [][]byte{sectorData})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.unlockStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sos = append(sos, so)
+	}
+	unique, refs, saved := ht.host.DeduplicationStats()
+	if unique != 1 || refs != 2 || saved != modules.SectorSize {
+		t.Fatalf("unexpected deduplication stats: %v unique, %v references, %v bytes saved", unique, refs, saved)
+	}
+
+	// Removing the first obligation should leave the sector readable for the
+	// second obligation.
+	err = ht.host.removeStorageObligation(sos[0], obligationRejected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique, refs, saved = ht.host.DeduplicationStats()
+	if unique != 1 || refs != 1 || saved != 0 {
+		t.Fatalf("unexpected deduplication stats: %v unique, %v references, %v bytes saved", unique, refs, saved)
+	}
+	readData, err := ht.host.ReadSector(sectorRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readData, sectorData) {
+		t.Fatal("shared sector data was corrupted after removing a reference")
+	}
+
+	// Removing the second obligation should free the sector.
+	err = ht.host.removeStorageObligation(sos[1], obligationRejected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique, refs, _ = ht.host.DeduplicationStats()
+	if unique != 0 || refs != 0 {
+		t.Fatalf("sector was not freed: %v unique, %v references", unique, refs)
+	}
+	_, err = ht.host.ReadSector(sectorRoot)
+	if err == nil {
+		t.Fatal("sector should not be readable after all references are removed")
+	}
+}
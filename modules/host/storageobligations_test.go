@@ -80,3 +80,66 @@ func TestStorageObligationID(t *testing.T) {
 		t.Error("id function of storage obligation incorrect for file contracts with dependencies")
 	}
 }
+
+// TestHostContracts checks that the host reports the storage obligations it
+// is managing, ordered by expiration height.
+func TestHostContracts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester("TestHostContracts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ht.host.Contracts()) != 0 {
+		t.Fatal("new host should not have any contracts")
+	}
+
+	// Add two storage obligations, the second expiring one block after the
+	// first. The second is added first to check the ordering.
+	so1, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so2, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	so2.LockedCollateral = types.NewCurrency64(25)
+	for _, so := range []*storageObligation{so2, so1} {
+		err = ht.host.lockStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.addStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ht.host.unlockStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contracts := ht.host.Contracts()
+	if len(contracts) != 2 {
+		t.Fatal("expected 2 contracts, got", len(contracts))
+	}
+	if contracts[0].ID != so1.id() || contracts[1].ID != so2.id() {
+		t.Error("contracts are not ordered by expiration")
+	}
+	if contracts[0].ExpirationHeight != so1.expiration() || contracts[1].ExpirationHeight != so2.expiration() {
+		t.Error("contracts have the wrong expiration heights")
+	}
+	if contracts[1].LockedCollateral.Cmp(so2.LockedCollateral) != 0 {
+		t.Error("contract has the wrong locked collateral")
+	}
+	if contracts[0].DataSize != 0 {
+		t.Error("blank contract should have no data")
+	}
+}
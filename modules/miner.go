@@ -2,6 +2,7 @@ package modules
 
 import (
 	"io"
+	"time"
 
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -12,6 +13,20 @@ const (
 	MinerDir = "miner"
 )
 
+const (
+	// MiningPriorityNormal lets the cpu miner hash without interruption. It
+	// is the default priority.
+	MiningPriorityNormal MiningPriority = iota
+
+	// MiningPriorityLow makes the cpu miner pause between batches of hashes,
+	// so that it takes roughly half as much cpu time and leaves room for
+	// interactive programs on the same machine.
+	MiningPriorityLow
+)
+
+// MiningPriority sets how aggressively the cpu miner uses the cpu.
+type MiningPriority int
+
 // BlockManager contains functions that can interface with external miners,
 // providing and receiving blocks that have experienced nonce grinding.
 type BlockManager interface {
@@ -24,25 +39,119 @@ type BlockManager interface {
 	// valid target.
 	SubmitHeader(types.BlockHeader) error
 
+	// BlockForWork returns a block that is ready for nonce grinding, along
+	// with the target that it must meet. External miners can solve the
+	// block and hand it back using SubmitBlock.
+	BlockForWork() (types.Block, types.Target, error)
+
+	// SubmitBlock takes a block that has been solved outside of the miner,
+	// such as a block from BlockForWork, and submits it to the consensus
+	// set. An error is returned if the block does not meet the current
+	// target, is stale, or is invalid.
+	SubmitBlock(types.Block) error
+
 	// BlocksMined returns the number of blocks and stale blocks that have been
 	// mined using this miner.
 	BlocksMined() (goodBlocks, staleBlocks int)
+
+	// ResetMiningStats clears the blocks found by the miner and the number
+	// of hashes attempted by the cpu miner. Both are otherwise kept across
+	// restarts.
+	ResetMiningStats() error
+
+	// SetBlockArbitraryData sets the arbitrary data that is added to the
+	// blocks created by the miner.
+	SetBlockArbitraryData(data []byte) error
+
+	// SetPayoutAddress sets the address that the miner payouts of the blocks
+	// created by the miner are sent to.
+	SetPayoutAddress(addr types.UnlockHash) error
 }
 
 // CPUMiner provides access to a single-threaded cpu miner.
 type CPUMiner interface {
+	// BlockTimeEstimate returns the expected time needed for the cpu miner to
+	// find a block at the current target and hashrate. A negative duration
+	// is returned if the cpu miner is not hashing.
+	BlockTimeEstimate() time.Duration
+
+	// CPUAffinity returns the logical cpus that the cpu miner is pinned to.
+	// An empty result means the cpu miner may run on any cpu.
+	CPUAffinity() []int
+
 	// CPUHashrate returns the hashrate of the cpu miner in hashes per second.
 	CPUHashrate() int
 
 	// Mining returns true if the cpu miner is enabled, and false otherwise.
 	CPUMining() bool
 
+	// HashesAttempted returns the total number of hashes that the cpu miner
+	// has attempted since the mining stats were last reset.
+	HashesAttempted() uint64
+
+	// MinPeersForMining returns the number of peers that the gateway must
+	// report before the cpu miner will hash.
+	MinPeersForMining() int
+
+	// MineWhileUnsynced returns whether the cpu miner hashes while the
+	// consensus set is not synced.
+	MineWhileUnsynced() bool
+
+	// MiningPriority returns the priority of the cpu miner.
+	MiningPriority() MiningPriority
+
+	// PoolConnected returns true if the cpu miner is connected to a mining
+	// pool that has authorized the worker.
+	PoolConnected() bool
+
+	// PoolShares returns the number of shares that have been accepted and
+	// rejected by the mining pool since pool mining was started.
+	PoolShares() (accepted, rejected int)
+
+	// SetCPUAffinity pins the cpu miner to the provided logical cpus. An
+	// empty list allows the cpu miner to run on any cpu.
+	SetCPUAffinity(cores []int) error
+
+	// SetMiningPriority sets the priority of the cpu miner. At low priority
+	// the cpu miner sleeps between batches of hashes.
+	SetMiningPriority(p MiningPriority) error
+
+	// SetHashrateWindow sets the time constant of the moving average that
+	// smooths the reported cpu hashrate.
+	SetHashrateWindow(d time.Duration) error
+
+	// SetMinPeersForMining sets the number of peers that the gateway must
+	// report before the cpu miner will hash. The cpu miner pauses whenever
+	// the gateway has fewer peers. Zero disables the check.
+	SetMinPeersForMining(n int) error
+
+	// SetMineWhileUnsynced sets whether the cpu miner hashes while the
+	// consensus set is not synced. By default the cpu miner pauses until the
+	// consensus set is synced.
+	SetMineWhileUnsynced(mine bool)
+
 	// StartMining turns on the miner, which will endlessly work for new
 	// blocks.
 	StartCPUMining()
 
+	// StartCPUMiningThreads turns on the miner with 'n' mining threads.
+	StartCPUMiningThreads(n int) error
+
+	// StartPoolMining connects the cpu miner to a stratum-style mining pool
+	// and mines shares for the pool as 'workerName'.
+	StartPoolMining(poolURL string, workerName string) error
+
 	// StopMining turns off the miner, but keeps the same number of threads.
+	// Pool mining is stopped as well.
 	StopCPUMining()
+
+	// SubscribeBlockFound registers a function that is called in its own
+	// goroutine whenever the cpu miner finds a block. The returned function
+	// removes the subscription.
+	SubscribeBlockFound(fn func(types.Block)) (unsubscribe func())
+
+	// Target returns the target that blocks created by the miner must meet.
+	Target() types.Target
 }
 
 // TestMiner provides direct acesss to block fetching, solving, and
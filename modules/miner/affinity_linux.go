@@ -0,0 +1,61 @@
+// +build linux
+
+package miner
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// affinitySupported indicates whether the cpu miner is able to pin its thread
+// to specific cores on this platform.
+const affinitySupported = true
+
+// setThreadAffinity pins the calling OS thread to the provided logical cpus.
+// The calling goroutine must be locked to its OS thread.
+func setThreadAffinity(cores []int) error {
+	mask := make([]uint64, (maxCore(cores)+64)/64)
+	for _, core := range cores {
+		mask[core/64] |= 1 << uint(core%64)
+	}
+	// A pid of 0 indicates the calling thread.
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// processAffinity returns the logical cpus that the calling thread is allowed
+// to run on, as reported by sched_getaffinity.
+func processAffinity() ([]int, error) {
+	// The mask is large enough for the kernel's default CPU_SETSIZE.
+	mask := make([]uint64, 1024/64)
+	// A pid of 0 indicates the calling thread. The number of bytes written to
+	// the mask is returned.
+	n, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return nil, errno
+	}
+	var cores []int
+	for i, word := range mask[:n/8] {
+		for bit := 0; bit < 64; bit++ {
+			if word&(1<<uint(bit)) != 0 {
+				cores = append(cores, i*64+bit)
+			}
+		}
+	}
+	return cores, nil
+}
+
+// maxCore returns the largest core index in 'cores', or 0 if 'cores' is
+// empty.
+func maxCore(cores []int) int {
+	max := 0
+	for _, core := range cores {
+		if core > max {
+			max = core
+		}
+	}
+	return max
+}
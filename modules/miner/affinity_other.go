@@ -0,0 +1,21 @@
+// +build !linux
+
+package miner
+
+import (
+	"errors"
+)
+
+// affinitySupported indicates whether the cpu miner is able to pin its thread
+// to specific cores on this platform.
+const affinitySupported = false
+
+// processAffinity is not supported on this platform.
+func processAffinity() ([]int, error) {
+	return nil, errors.New("cpu affinity is not supported on this platform")
+}
+
+// setThreadAffinity is not supported on this platform.
+func setThreadAffinity([]int) error {
+	return errors.New("cpu affinity is not supported on this platform")
+}
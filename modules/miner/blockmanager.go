@@ -1,6 +1,7 @@
 package miner
 
 import (
+	"bytes"
 	"crypto/rand"
 	"errors"
 	"time"
@@ -10,8 +11,17 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+const (
+	// maxBlockArbitraryDataSize is the largest amount of arbitrary data that
+	// can be added to the blocks created by the miner. The miner leaves 5e3
+	// bytes of every block free for the header, the payouts, and the
+	// arbitrary data transaction, so the limit must stay below that.
+	maxBlockArbitraryDataSize = 4e3
+)
+
 var (
-	errLateHeader = errors.New("header is old, block could not be recovered")
+	errArbitraryDataTooLarge = errors.New("block arbitrary data is too large to fit in a block")
+	errLateHeader            = errors.New("header is old, block could not be recovered")
 )
 
 // blockForWork returns a block that is ready for nonce grinding, including
@@ -31,13 +41,22 @@ func (m *Miner) blockForWork() types.Block {
 	if err != nil {
 		m.log.Println(err)
 	}
-	b.MinerPayouts = []types.SiacoinOutput{{Value: b.CalculateSubsidy(m.persist.Height + 1), UnlockHash: m.persist.Address}}
+	payoutAddress := m.persist.Address
+	if m.persist.PayoutAddress != (types.UnlockHash{}) {
+		payoutAddress = m.persist.PayoutAddress
+	}
+	b.MinerPayouts = []types.SiacoinOutput{{Value: b.CalculateSubsidy(m.persist.Height + 1), UnlockHash: payoutAddress}}
 
-	// Add an arb-data txn to the block to create a unique merkle root.
+	// Add an arb-data txn to the block to create a unique merkle root. The
+	// random data must come first, HeaderForWork overwrites it for every
+	// header.
 	randBytes, _ := crypto.RandBytes(types.SpecifierLen)
 	randTxn := types.Transaction{
 		ArbitraryData: [][]byte{append(modules.PrefixNonSia[:], randBytes...)},
 	}
+	if len(m.persist.BlockArbitraryData) > 0 {
+		randTxn.ArbitraryData = append(randTxn.ArbitraryData, m.persist.BlockArbitraryData)
+	}
 	b.Transactions = append([]types.Transaction{randTxn}, b.Transactions...)
 
 	return b
@@ -126,10 +145,7 @@ func (m *Miner) managedSubmitBlock(b types.Block) error {
 	err := m.cs.AcceptBlock(b)
 	// Add the miner to the blocks list if the only problem is that it's stale.
 	if err == modules.ErrNonExtendingBlock {
-		m.mu.Lock()
-		m.persist.BlocksFound = append(m.persist.BlocksFound, b.ID())
-		m.mu.Unlock()
-		m.log.Println("Mined a stale block - block appears valid but does not extend the blockchain")
+		m.managedRecordStaleBlock(b)
 		return err
 	}
 	if err == modules.ErrBlockUnsolved {
@@ -141,14 +157,28 @@ func (m *Miner) managedSubmitBlock(b types.Block) error {
 		m.log.Critical("ERROR: an invalid block was submitted:", err)
 		return err
 	}
+	return m.managedRecordBlock(b)
+}
+
+// managedRecordStaleBlock adds a block that is valid but does not extend the
+// blockchain to the list of blocks found by the miner.
+func (m *Miner) managedRecordStaleBlock(b types.Block) {
+	m.mu.Lock()
+	m.persist.BlocksFound = append(m.persist.BlocksFound, b.ID())
+	m.mu.Unlock()
+	m.log.Println("Mined a stale block - block appears valid but does not extend the blockchain")
+}
+
+// managedRecordBlock adds a block that was accepted by the consensus set to
+// the list of blocks found by the miner, and grabs a new payout address.
+func (m *Miner) managedRecordBlock(b types.Block) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Grab a new address for the miner. Call may fail if the wallet is locked
 	// or if the wallet addresses have been exhausted.
 	m.persist.BlocksFound = append(m.persist.BlocksFound, b.ID())
-	var uc types.UnlockConditions
-	uc, err = m.wallet.NextAddress()
+	uc, err := m.wallet.NextAddress()
 	if err != nil {
 		return err
 	}
@@ -156,6 +186,34 @@ func (m *Miner) managedSubmitBlock(b types.Block) error {
 	return m.saveSync()
 }
 
+// SubmitBlock accepts a block that has been solved outside of the miner, for
+// example by a block returned from BlockForWork being ground on by external
+// hardware. modules.ErrBlockUnsolved is returned if the block does not meet
+// the current target, and modules.ErrNonExtendingBlock is returned if the
+// block is stale. Unlike blocks found by the cpu miner, a block that is
+// rejected by the consensus set is reported to the caller instead of being
+// treated as a developer error. SubmitBlock is safe to call while the cpu
+// miner is running.
+func (m *Miner) SubmitBlock(b types.Block) error {
+	m.mu.RLock()
+	target := m.persist.Target
+	m.mu.RUnlock()
+	id := b.ID()
+	if bytes.Compare(target[:], id[:]) < 0 {
+		return modules.ErrBlockUnsolved
+	}
+
+	err := m.cs.AcceptBlock(b)
+	if err == modules.ErrNonExtendingBlock {
+		m.managedRecordStaleBlock(b)
+		return err
+	}
+	if err != nil {
+		return errors.New("block was rejected by the consensus set: " + err.Error())
+	}
+	return m.managedRecordBlock(b)
+}
+
 // SubmitHeader accepts a block header.
 func (m *Miner) SubmitHeader(bh types.BlockHeader) error {
 	// Because a call to managedSubmitBlock is required at the end of this
@@ -182,7 +240,10 @@ func (m *Miner) SubmitHeader(bh types.BlockHeader) error {
 		txns := make([]types.Transaction, len(b.Transactions))
 		copy(txns, b.Transactions)
 		b.Transactions = txns
-		b.Transactions[0].ArbitraryData = [][]byte{arbData[:]}
+		arbDataSlice := make([][]byte, len(b.Transactions[0].ArbitraryData))
+		copy(arbDataSlice, b.Transactions[0].ArbitraryData)
+		arbDataSlice[0] = arbData[:]
+		b.Transactions[0].ArbitraryData = arbDataSlice
 		b.Nonce = nonce
 
 		// Sanity check - block should have same id as header.
@@ -203,3 +264,29 @@ func (m *Miner) SubmitHeader(bh types.BlockHeader) error {
 	}
 	return nil
 }
+
+// SetBlockArbitraryData sets the arbitrary data that is added to every block
+// created by the miner. Passing an empty slice stops the miner from adding
+// arbitrary data. An error is returned if the data is large enough that blocks
+// could exceed the block size limit.
+func (m *Miner) SetBlockArbitraryData(data []byte) error {
+	if len(data) > maxBlockArbitraryDataSize {
+		return errArbitraryDataTooLarge
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.persist.BlockArbitraryData = append([]byte(nil), data...)
+	m.newSourceBlock()
+	return m.saveSync()
+}
+
+// SetPayoutAddress sets the address that the miner payouts of every block
+// created by the miner are sent to. Passing the empty address returns the
+// miner to paying a fresh wallet address for every block.
+func (m *Miner) SetPayoutAddress(addr types.UnlockHash) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.persist.PayoutAddress = addr
+	m.newSourceBlock()
+	return m.saveSync()
+}
@@ -56,6 +56,40 @@ func TestIntegrationHeaderForWork(t *testing.T) {
 	}
 }
 
+// TestIntegrationHeaderForWorkArbitraryData checks that blocks solved through
+// HeaderForWork and SubmitHeader keep the arbitrary data set by
+// SetBlockArbitraryData.
+func TestIntegrationHeaderForWorkArbitraryData(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationHeaderForWorkArbitraryData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arbData := append(modules.PrefixNonSia[:], []byte("pool tag")...)
+	err = mt.miner.SetBlockArbitraryData(arbData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, target, err := mt.miner.HeaderForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mt.miner.SubmitHeader(solveHeader(header, target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := mt.cs.CurrentBlock()
+	if b.ParentID != header.ParentID {
+		t.Fatal("submitted header did not extend the blockchain")
+	}
+	if len(b.Transactions) == 0 || len(b.Transactions[0].ArbitraryData) != 2 || !bytes.Equal(b.Transactions[0].ArbitraryData[1], arbData) {
+		t.Fatal("block is missing the arbitrary data of the miner")
+	}
+}
+
 // TestIntegrationHeaderForWorkUpdates checks that HeaderForWork starts
 // returning headers on the new block after a block has been submitted to the
 // consensus set.
@@ -222,3 +256,151 @@ func TestIntegrationHeaderRequestOverflow(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// TestIntegrationSubmitBlock checks that blocks solved outside of the miner
+// can be submitted, and that unsolved, stale, and invalid blocks are reported.
+func TestIntegrationSubmitBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationSubmitBlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Grab two blocks for work and solve both of them.
+	b1, target, err := mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, _, err := mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsolved := b1
+	for {
+		id := unsolved.ID()
+		if bytes.Compare(target[:], id[:]) < 0 {
+			break
+		}
+		unsolved.Nonce[0]++
+	}
+	b1, solved := mt.miner.SolveBlock(b1, target)
+	if !solved {
+		t.Fatal("could not solve block")
+	}
+	b2, solved = mt.miner.SolveBlock(b2, target)
+	if !solved {
+		t.Fatal("could not solve block")
+	}
+
+	// The unsolved block should be rejected without reaching consensus.
+	if mt.miner.SubmitBlock(unsolved) != modules.ErrBlockUnsolved {
+		t.Error("expected ErrBlockUnsolved")
+	}
+	startHeight := mt.cs.Height()
+	err = mt.miner.SubmitBlock(b1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.cs.Height() != startHeight+1 {
+		t.Error("submitted block was not added to the consensus set")
+	}
+	// The second block has the same parent, and is now stale.
+	if mt.miner.SubmitBlock(b2) != modules.ErrNonExtendingBlock {
+		t.Error("expected ErrNonExtendingBlock")
+	}
+	goodBlocks, staleBlocks := mt.miner.BlocksMined()
+	if goodBlocks != 1 || staleBlocks != 1 {
+		t.Errorf("expected 1 good and 1 stale block, got %v and %v", goodBlocks, staleBlocks)
+	}
+
+	// A solved block with an invalid payout should be reported as an error.
+	b3, target, err := mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b3.MinerPayouts[0].Value = b3.MinerPayouts[0].Value.Add(types.NewCurrency64(1))
+	b3, solved = mt.miner.SolveBlock(b3, target)
+	if !solved {
+		t.Fatal("could not solve block")
+	}
+	if mt.miner.SubmitBlock(b3) == nil {
+		t.Error("invalid block was accepted")
+	}
+}
+
+// TestIntegrationBlockTemplate checks that the payout address and arbitrary
+// data set by the user are used in the blocks created by the miner.
+func TestIntegrationBlockTemplate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationBlockTemplate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mt.miner.SetBlockArbitraryData(make([]byte, maxBlockArbitraryDataSize+1))
+	if err != errArbitraryDataTooLarge {
+		t.Fatal("expected errArbitraryDataTooLarge, got", err)
+	}
+	payoutAddress := types.UnlockHash{1, 2, 3}
+	arbData := append(modules.PrefixNonSia[:], []byte("solo miner")...)
+	err = mt.miner.SetPayoutAddress(payoutAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mt.miner.SetBlockArbitraryData(arbData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks from BlockForWork and from the cpu miner should both use the
+	// template.
+	b, _, err := mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.MinerPayouts[0].UnlockHash != payoutAddress {
+		t.Error("block does not pay the payout address")
+	}
+	if len(b.Transactions[0].ArbitraryData) != 2 || !bytes.Equal(b.Transactions[0].ArbitraryData[1], arbData) {
+		t.Error("block does not contain the arbitrary data")
+	}
+	_, err = mt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb := mt.cs.CurrentBlock()
+	if cb.MinerPayouts[0].UnlockHash != payoutAddress {
+		t.Error("mined block does not pay the payout address")
+	}
+	if !bytes.Equal(cb.Transactions[0].ArbitraryData[1], arbData) {
+		t.Error("mined block does not contain the arbitrary data")
+	}
+
+	// Clearing the template should return the miner to its default behavior.
+	err = mt.miner.SetPayoutAddress(types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mt.miner.SetBlockArbitraryData(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err = mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.MinerPayouts[0].UnlockHash == payoutAddress {
+		t.Error("block still pays the payout address")
+	}
+	if len(b.Transactions[0].ArbitraryData) != 1 {
+		t.Error("block still contains the arbitrary data")
+	}
+}
@@ -1,61 +1,244 @@
 package miner
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
 	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
-// threadedMine starts a gothread that does CPU mining. threadedMine is the
-// only function that should be setting the mining flag to true.
-func (m *Miner) threadedMine() {
-	// There should not be another thread mining, and mining should be enabled.
-	m.mu.Lock()
-	if m.mining || !m.miningOn {
-		m.mu.Unlock()
-		return
+var (
+	errDuplicateCPUCore = errors.New("cpu affinity lists the same core more than once")
+	errInvalidCPUCore   = errors.New("cpu affinity contains a core that is not available to the process")
+	errNegativeMinPeers = errors.New("minimum number of peers for mining cannot be negative")
+	errNoMiningThreads  = errors.New("cpu miner needs at least one thread")
+	errUnknownPriority  = errors.New("unknown mining priority")
+
+	errInvalidHashrateWindow = errors.New("hashrate window must be positive")
+
+	// processCores holds the logical cpus that the process is allowed to run
+	// on. The cpus are read when the package is loaded, before any mining
+	// thread has been pinned, and need not be numbered 0 through NumCPU-1 if
+	// the process is restricted to a cpuset. If the affinity of the process
+	// cannot be read, every cpu up to NumCPU is assumed to be available.
+	processCores = func() []int {
+		cores, err := processAffinity()
+		if err == nil && len(cores) > 0 {
+			return cores
+		}
+		cores = make([]int, runtime.NumCPU())
+		for i := range cores {
+			cores[i] = i
+		}
+		return cores
+	}()
+)
+
+// allCores returns the indices of every logical cpu available to the process.
+func allCores() []int {
+	return append([]int(nil), processCores...)
+}
+
+// movingAverage folds a hashrate sample that was measured over 'elapsed' into
+// an exponential moving average with the time constant 'window'. An average
+// of zero indicates that there is no history, and the sample is returned.
+func movingAverage(average, sample int64, elapsed, window time.Duration) int64 {
+	if average == 0 {
+		return sample
 	}
-	m.mining = true
-	m.mu.Unlock()
+	alpha := 1 - math.Exp(-float64(elapsed)/float64(window))
+	return int64(alpha*float64(sample) + (1-alpha)*float64(average))
+}
+
+// threadedMine is a cpu mining worker. 'thread' is the index of the worker
+// within the current batch, and is used to record the hashrate of the worker.
+// The last worker to exit sets the mining flag to false.
+func (m *Miner) threadedMine(thread int) {
+	defer func() {
+		m.mu.Lock()
+		m.hashRates[thread] = 0
+		m.miningThreads--
+		if m.miningThreads == 0 {
+			m.mining = false
+		}
+		m.mu.Unlock()
+	}()
+
+	// The mining thread is locked to its OS thread so that it can be pinned to
+	// the cores in the cpu affinity. Before the thread is handed back to the
+	// runtime, it is allowed to run on all cores again.
+	runtime.LockOSThread()
+	var affinityVersion int
+	defer func() {
+		if affinityVersion != 0 {
+			err := setThreadAffinity(allCores())
+			if err != nil {
+				m.log.Println("WARN: could not reset cpu affinity of the cpu miner:", err)
+			}
+		}
+		runtime.UnlockOSThread()
+	}()
 
 	// Solve blocks repeatedly, keeping track of how fast hashing is occuring.
 	cycleStart := time.Now()
+	paused := false
 	for {
 		// Kill the thread if mining has been turned off.
 		m.mu.Lock()
 		if !m.miningOn {
-			m.mining = false
 			m.mu.Unlock()
 			return
 		}
-		bfw := m.blockForWork()
-		target := m.persist.Target
+
+		// Pause mining while the consensus set is not synced to the tip of the
+		// network, or while the gateway is connected to too few peers. The
+		// miner lock is released while the other modules are queried. The
+		// hashrate of the thread is zeroed for the length of the pause. Pool
+		// mining grinds on the pool's blocks, so it only pauses while the
+		// pool has not sent any work.
+		pool := m.pool
+		minPeers := m.minPeers
+		mineWhileUnsynced := m.mineWhileUnsynced
 		m.mu.Unlock()
+		pauseReason := ""
+		var work poolWork
+		if pool != nil {
+			var ok bool
+			work, ok = pool.nextWork()
+			if !ok {
+				pauseReason = "waiting for work from the mining pool"
+			}
+		} else if !mineWhileUnsynced && !m.cs.Synced() {
+			pauseReason = "the consensus set is not synced"
+		} else if minPeers > 0 {
+			numPeers := len(m.gateway.Peers())
+			if numPeers < minPeers {
+				pauseReason = fmt.Sprintf("connected to %v peers but at least %v are required", numPeers, minPeers)
+			}
+		}
+		if pauseReason != "" {
+			if !paused {
+				m.log.Println("WARN: pausing cpu mining,", pauseReason)
+				paused = true
+			}
+			m.mu.Lock()
+			m.hashRates[thread] = 0
+			m.mu.Unlock()
+			time.Sleep(peerCheckInterval)
+			continue
+		}
+		if paused {
+			m.log.Println("Resuming cpu mining.")
+			paused = false
+			cycleStart = time.Now()
+		}
 
-		// Grab a block and try to solve it.
-		b, solved := m.SolveBlock(bfw, target)
-		if solved {
+		m.mu.Lock()
+		if affinityVersion != m.cpuAffinityVersion {
+			cores := m.cpuAffinity
+			if len(cores) == 0 {
+				cores = allCores()
+			}
+			err := setThreadAffinity(cores)
+			if err != nil {
+				m.log.Println("WARN: could not apply cpu affinity to the cpu miner:", err)
+			}
+			affinityVersion = m.cpuAffinityVersion
+		}
+		if pool == nil {
+			work = poolWork{block: m.blockForWork(), target: m.persist.Target}
+		}
+		priority := m.priority
+		m.mu.Unlock()
+
+		// Grab a block and try to solve it. At low priority the thread then
+		// sleeps for as long as the batch took, which halves the cpu time
+		// used. The sleep is counted as part of the cycle so that the
+		// reported hashrate matches the throttled speed. A solved block is
+		// submitted without sleeping, so that it is not delayed. Blocks
+		// solved for a pool are submitted to the pool as shares.
+		batchStart := time.Now()
+		b, attempts, solved := solveBlockFrom(work.block, work.target, work.startNonce)
+		if !solved && priority == modules.MiningPriorityLow {
+			time.Sleep(time.Since(batchStart))
+		}
+		if solved && pool != nil {
+			err := pool.submitShare(work.jobID, b)
+			if err != nil {
+				m.log.Println("ERROR: could not submit a share to the mining pool:", err)
+			}
+		} else if solved {
 			err := m.managedSubmitBlock(b)
 			if err != nil {
 				m.log.Println("ERROR: An error occurred while cpu mining:", err)
+			} else {
+				m.managedNotifyBlockFound(b)
 			}
 		}
 
 		// Update the hashrate. If the block was solved, the full set of
-		// iterations was not completed, so the hashrate should not be updated.
+		// iterations was not completed, so the cycle cannot be measured and
+		// the average is left as it is. The cycle counter is still reset so
+		// that the time spent submitting the block is not counted against the
+		// next cycle.
 		m.mu.Lock()
+		elapsed := time.Since(cycleStart)
+		cycleStart = time.Now()
 		if !solved {
-			nanosecondsElapsed := 1 + time.Since(cycleStart).Nanoseconds() // Add 1 to prevent divide by zero errors.
-			cycleStart = time.Now()                                        // Reset the cycle counter as soon as the previous value is measured.
-			m.hashRate = 1e9 * solveAttempts / nanosecondsElapsed
+			sample := 1e9 * solveAttempts / (1 + elapsed.Nanoseconds()) // Add 1 to prevent divide by zero errors.
+			m.hashRates[thread] = movingAverage(m.hashRates[thread], sample, elapsed, m.hashrateWindow)
 		}
+		m.persist.HashesAttempted += attempts
 		m.mu.Unlock()
 	}
 }
 
-// CPUHashrate returns an estimated cpu hashrate.
+// cpuHashrate returns the hashrate of the cpu miner, summed over all of the
+// cpu mining threads. The caller must hold the miner lock.
+func (m *Miner) cpuHashrate() int64 {
+	var hashRate int64
+	for _, rate := range m.hashRates {
+		hashRate += rate
+	}
+	return hashRate
+}
+
+// CPUHashrate returns an estimated cpu hashrate, summed over all of the cpu
+// mining threads.
 func (m *Miner) CPUHashrate() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return int(m.hashRate)
+	return int(m.cpuHashrate())
+}
+
+// blockTimeEstimate returns the expected time needed to find a block with
+// the given target when hashing at 'hashrate' hashes per second. A negative
+// duration is returned if the hashrate is zero.
+func blockTimeEstimate(target types.Target, hashrate int64) time.Duration {
+	if hashrate <= 0 {
+		return time.Duration(-1)
+	}
+	// The difficulty is the expected number of hashes needed to find a block.
+	nanos := new(big.Int).Mul(target.Difficulty().Big(), big.NewInt(int64(time.Second)))
+	nanos.Div(nanos, big.NewInt(hashrate))
+	if nanos.Cmp(big.NewInt(math.MaxInt64)) > 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(nanos.Int64())
+}
+
+// BlockTimeEstimate returns the expected time needed for the cpu miner to
+// find a block at the current target and hashrate. A negative duration is
+// returned if the cpu miner is not hashing.
+func (m *Miner) BlockTimeEstimate() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return blockTimeEstimate(m.persist.Target, m.cpuHashrate())
 }
 
 // CPUMining indicates whether the cpu miner is running.
@@ -65,20 +248,210 @@ func (m *Miner) CPUMining() bool {
 	return m.miningOn
 }
 
+// startCPUMining enables the cpu miner and launches 'threads' mining workers.
+// If workers are already running, no new workers are launched. The caller
+// must hold the miner lock.
+func (m *Miner) startCPUMining(threads int) {
+	m.miningOn = true
+	if m.mining {
+		return
+	}
+	m.mining = true
+	m.miningThreads = threads
+	m.hashRates = make([]int64, threads)
+	for i := 0; i < threads; i++ {
+		go m.threadedMine(i)
+	}
+}
+
 // StartCPUMining will start a single threaded cpu miner. If the miner is
-// already running, nothing will happen.
+// already running, nothing will happen. The miner will not hash until the
+// consensus set is synced and the gateway is connected to at least
+// MinPeersForMining peers.
 func (m *Miner) StartCPUMining() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.miningOn = true
-	go m.threadedMine()
+	m.startCPUMining(1)
 }
 
-// StopCPUMining will stop the cpu miner. If the cpu miner is already stopped,
-// nothing will happen.
+// StartCPUMiningThreads starts a cpu miner with 'n' independent mining
+// threads. If the miner is already running, nothing will happen, even if it
+// is running with a different number of threads. The number of threads is
+// also used when pool mining is started.
+func (m *Miner) StartCPUMiningThreads(n int) error {
+	if n < 1 {
+		return errNoMiningThreads
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cpuThreads = n
+	m.startCPUMining(n)
+	return nil
+}
+
+// StopCPUMining will stop all of the cpu mining threads, and disconnect from
+// the mining pool if the cpu miner is pool mining. If the cpu miner is already
+// stopped, nothing will happen.
 func (m *Miner) StopCPUMining() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.hashRate = 0
+	if m.pool != nil {
+		m.pool.close()
+		m.pool = nil
+	}
+	for i := range m.hashRates {
+		m.hashRates[i] = 0
+	}
 	m.miningOn = false
 }
+
+// SetHashrateWindow sets the time constant of the moving average that is used
+// to smooth the reported hashrate. Longer windows give a steadier hashrate
+// that is slower to follow changes in speed.
+func (m *Miner) SetHashrateWindow(d time.Duration) error {
+	if d <= 0 {
+		return errInvalidHashrateWindow
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashrateWindow = d
+	return nil
+}
+
+// MinPeersForMining returns the number of peers that the gateway must report
+// before the cpu miner will hash.
+func (m *Miner) MinPeersForMining() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.minPeers
+}
+
+// SetMinPeersForMining sets the number of peers that the gateway must report
+// before the cpu miner will hash. If the number of peers drops below 'n' while
+// mining, the cpu miner pauses until connectivity recovers. Setting 'n' to zero
+// disables the check.
+func (m *Miner) SetMinPeersForMining(n int) error {
+	if n < 0 {
+		return errNegativeMinPeers
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.minPeers = n
+	return nil
+}
+
+// MineWhileUnsynced returns whether the cpu miner hashes while the consensus
+// set is not synced to the tip of the network.
+func (m *Miner) MineWhileUnsynced() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mineWhileUnsynced
+}
+
+// SetMineWhileUnsynced sets whether the cpu miner hashes while the consensus
+// set is not synced to the tip of the network. Blocks mined on a stale tip are
+// very likely to be orphaned, so by default the cpu miner pauses until the
+// consensus set reports that it is synced.
+func (m *Miner) SetMineWhileUnsynced(mine bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mineWhileUnsynced = mine
+}
+
+// MiningPriority returns the priority of the cpu miner.
+func (m *Miner) MiningPriority() modules.MiningPriority {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.priority
+}
+
+// SetMiningPriority sets the priority of the cpu miner. At
+// modules.MiningPriorityLow each mining thread sleeps between batches of
+// hashes, roughly halving the cpu time used by the miner. The priority only
+// throttles the miner itself; the scheduling priority of the process is not
+// changed, and pinning the miner to cores with SetCPUAffinity is best-effort
+// and not supported on every platform.
+func (m *Miner) SetMiningPriority(p modules.MiningPriority) error {
+	if p != modules.MiningPriorityNormal && p != modules.MiningPriorityLow {
+		return errUnknownPriority
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.priority = p
+	return nil
+}
+
+// CPUAffinity returns the logical cpus that the cpu miner is pinned to. An
+// empty result means that the cpu miner may run on any cpu.
+func (m *Miner) CPUAffinity() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]int(nil), m.cpuAffinity...)
+}
+
+// SetCPUAffinity pins the cpu miner to the provided logical cpus, which are
+// indexed from 0 and must be available to the process. Passing an empty list
+// allows the cpu miner to run on any cpu available to the process.
+// On platforms that do not support cpu affinity, a warning is logged and the
+// affinity is left unchanged.
+func (m *Miner) SetCPUAffinity(cores []int) error {
+	available := make(map[int]struct{})
+	for _, core := range allCores() {
+		available[core] = struct{}{}
+	}
+	seen := make(map[int]struct{})
+	for _, core := range cores {
+		if _, exists := available[core]; !exists {
+			return errInvalidCPUCore
+		}
+		if _, exists := seen[core]; exists {
+			return errDuplicateCPUCore
+		}
+		seen[core] = struct{}{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !affinitySupported {
+		m.log.Println("WARN: cpu affinity is not supported on this platform, the cpu miner will not be pinned")
+		return nil
+	}
+	m.cpuAffinity = append([]int(nil), cores...)
+	m.cpuAffinityVersion++
+	return nil
+}
+
+// managedNotifyBlockFound calls every block found subscriber with a block
+// found by the cpu miner. Each subscriber is called in its own goroutine so
+// that a slow subscriber cannot stall the cpu miner.
+func (m *Miner) managedNotifyBlockFound(b types.Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, fn := range m.blockFoundSubscribers {
+		go fn(b)
+	}
+}
+
+// SubscribeBlockFound registers a function that is called whenever the cpu
+// miner finds a block that is accepted by the consensus set. The function is
+// called in its own goroutine. The returned function removes the
+// subscription, and can safely be called more than once.
+func (m *Miner) SubscribeBlockFound(fn func(types.Block)) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextBlockFoundID
+	m.nextBlockFoundID++
+	m.blockFoundSubscribers[id] = fn
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.blockFoundSubscribers, id)
+	}
+}
+
+// Target returns the target that blocks created by the miner must meet.
+func (m *Miner) Target() types.Target {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.persist.Target
+}
@@ -14,9 +14,10 @@ import (
 )
 
 var (
-	errNilCS     = errors.New("miner cannot use a nil consensus set")
-	errNilTpool  = errors.New("miner cannot use a nil transaction pool")
-	errNilWallet = errors.New("miner cannot use a nil wallet")
+	errNilCS      = errors.New("miner cannot use a nil consensus set")
+	errNilGateway = errors.New("miner cannot use a nil gateway")
+	errNilTpool   = errors.New("miner cannot use a nil transaction pool")
+	errNilWallet  = errors.New("miner cannot use a nil wallet")
 
 	// HeaderMemory is the number of previous calls to 'header'
 	// that are remembered. Additionally, 'header' will only poll for a
@@ -66,15 +67,65 @@ var (
 		}
 		panic("unrecognized build.Release")
 	}()
+
+	// defaultMinPeersForMining is the number of peers that the gateway must
+	// report before the cpu miner will start hashing. Mining without peers
+	// produces blocks that nobody else will see, and is likely to result in
+	// wasted work on a fork.
+	defaultMinPeersForMining = func() int {
+		if build.Release == "dev" {
+			return 0
+		}
+		if build.Release == "standard" {
+			return 2
+		}
+		if build.Release == "testing" {
+			return 0
+		}
+		panic("unrecognized build.Release")
+	}()
+
+	// defaultHashrateWindow is the default time constant of the moving
+	// average that is used to smooth the hashrate of the cpu miner. Samples
+	// older than the window have a diminishing effect on the reported
+	// hashrate.
+	defaultHashrateWindow = func() time.Duration {
+		if build.Release == "dev" {
+			return 10 * time.Second
+		}
+		if build.Release == "standard" {
+			return 30 * time.Second
+		}
+		if build.Release == "testing" {
+			return 1 * time.Second
+		}
+		panic("unrecognized build.Release")
+	}()
+
+	// peerCheckInterval is the amount of time that the cpu miner waits before
+	// checking the number of peers again while mining is paused.
+	peerCheckInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return 5 * time.Second
+		}
+		if build.Release == "standard" {
+			return 10 * time.Second
+		}
+		if build.Release == "testing" {
+			return 50 * time.Millisecond
+		}
+		panic("unrecognized build.Release")
+	}()
 )
 
 // Miner struct contains all variables the miner needs
 // in order to create and submit blocks.
 type Miner struct {
 	// Module dependencies.
-	cs     modules.ConsensusSet
-	tpool  modules.TransactionPool
-	wallet modules.Wallet
+	cs      modules.ConsensusSet
+	gateway modules.Gateway
+	tpool   modules.TransactionPool
+	wallet  modules.Wallet
 
 	// BlockManager variables. Becaues blocks are large, one block is used to
 	// make many headers which can be used by miners. Headers include an
@@ -95,9 +146,43 @@ type Miner struct {
 	memProgress     int                                            // The index of the most recent header used in headerMem.
 
 	// CPUMiner variables.
-	miningOn bool  // indicates if the miner is supposed to be running
-	mining   bool  // indicates if the miner is actually running
-	hashRate int64 // indicates hashes per second
+	miningOn      bool    // indicates if the miner is supposed to be running
+	mining        bool    // indicates if the miner is actually running
+	miningThreads int     // the number of cpu mining threads that are running
+	hashRates     []int64 // indicates hashes per second of each mining thread
+	cpuThreads    int     // the number of threads that pool mining is started with
+
+	// pool is the connection to the mining pool while the cpu miner is pool
+	// mining, and nil while it is mining solo.
+	pool *poolClient
+
+	// hashrateWindow is the time constant of the moving average used to
+	// smooth the hashrate of each mining thread.
+	hashrateWindow time.Duration
+
+	// cpuAffinity is the set of logical cpus that the cpu miner is pinned to.
+	// cpuAffinityVersion is incremented every time the affinity changes so
+	// that the mining thread knows to reapply it.
+	cpuAffinity        []int
+	cpuAffinityVersion int
+
+	// minPeers is the number of peers the gateway must have before the cpu
+	// miner will hash. A value of zero disables the check.
+	minPeers int
+
+	// mineWhileUnsynced disables the check that pauses the cpu miner while
+	// the consensus set is not synced.
+	mineWhileUnsynced bool
+
+	// priority controls whether the cpu miner sleeps between batches of
+	// hashes to leave cpu time for other programs.
+	priority modules.MiningPriority
+
+	// blockFoundSubscribers are called whenever the cpu miner finds a block.
+	// Each subscriber is keyed by the id that was handed out when it
+	// subscribed.
+	blockFoundSubscribers map[int]func(types.Block)
+	nextBlockFoundID      int
 
 	// Utils
 	log        *persist.Logger
@@ -134,7 +219,7 @@ func (m *Miner) startupRescan() error {
 }
 
 // New returns a ready-to-go miner that is not mining.
-func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Wallet, persistDir string) (*Miner, error) {
+func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Wallet, g modules.Gateway, persistDir string) (*Miner, error) {
 	// Create the miner and its dependencies.
 	if cs == nil {
 		return nil, errNilCS
@@ -145,20 +230,30 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Walle
 	if w == nil {
 		return nil, errNilWallet
 	}
+	if g == nil {
+		return nil, errNilGateway
+	}
 
 	// Assemble the miner. The miner is assembled without an address because
 	// the wallet is likely not unlocked yet. The miner will grab an address
 	// after the miner is unlocked (this must be coded manually for each
 	// function that potentially requires the miner to have an address.
 	m := &Miner{
-		cs:     cs,
-		tpool:  tpool,
-		wallet: w,
+		cs:      cs,
+		gateway: g,
+		tpool:   tpool,
+		wallet:  w,
 
 		blockMem:   make(map[types.BlockHeader]*types.Block),
 		arbDataMem: make(map[types.BlockHeader][crypto.EntropySize]byte),
 		headerMem:  make([]types.BlockHeader, HeaderMemory),
 
+		cpuThreads:     1,
+		minPeers:       defaultMinPeersForMining,
+		hashrateWindow: defaultHashrateWindow,
+
+		blockFoundSubscribers: make(map[int]func(types.Block)),
+
 		persistDir: persistDir,
 	}
 
@@ -198,6 +293,9 @@ func (m *Miner) Close() error {
 	defer m.mu.Unlock()
 
 	m.cs.Unsubscribe(m)
+	if m.pool != nil {
+		m.pool.close()
+	}
 
 	var errs []error
 	if err := m.saveSync(); err != nil {
@@ -238,3 +336,23 @@ func (m *Miner) BlocksMined() (goodBlocks, staleBlocks int) {
 	}
 	return
 }
+
+// HashesAttempted returns the total number of hashes that the cpu miner has
+// attempted. The total is saved whenever the miner persistence is saved, so it
+// survives restarts.
+func (m *Miner) HashesAttempted() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.persist.HashesAttempted
+}
+
+// ResetMiningStats clears the blocks found by the miner and the number of
+// hashes attempted by the cpu miner, for operators who want lifetime stats to
+// start over. The hashrate estimate is not affected.
+func (m *Miner) ResetMiningStats() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.persist.BlocksFound = nil
+	m.persist.HashesAttempted = 0
+	return m.saveSync()
+}
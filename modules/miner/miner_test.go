@@ -3,8 +3,10 @@ package miner
 import (
 	"bytes"
 	"crypto/rand"
+	"math"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -43,7 +45,7 @@ func createMinerTester(name string) (*minerTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +66,7 @@ func createMinerTester(name string) (*minerTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	m, err := New(cs, tp, w, filepath.Join(testdir, modules.MinerDir))
+	m, err := New(cs, tp, w, g, filepath.Join(testdir, modules.MinerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -132,19 +134,23 @@ func TestIntegrationNilMinerDependencies(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = New(mt.cs, mt.tpool, nil, "")
+	_, err = New(mt.cs, mt.tpool, nil, mt.gateway, "")
 	if err != errNilWallet {
 		t.Fatal(err)
 	}
-	_, err = New(mt.cs, nil, mt.wallet, "")
+	_, err = New(mt.cs, nil, mt.wallet, mt.gateway, "")
 	if err != errNilTpool {
 		t.Fatal(err)
 	}
-	_, err = New(nil, mt.tpool, mt.wallet, "")
+	_, err = New(nil, mt.tpool, mt.wallet, mt.gateway, "")
 	if err != errNilCS {
 		t.Fatal(err)
 	}
-	_, err = New(nil, nil, nil, "")
+	_, err = New(mt.cs, mt.tpool, mt.wallet, nil, "")
+	if err != errNilGateway {
+		t.Fatal(err)
+	}
+	_, err = New(nil, nil, nil, nil, "")
 	if err == nil {
 		t.Fatal(err)
 	}
@@ -212,7 +218,7 @@ func TestIntegrationBlocksMined(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	rebootMiner, err := New(mt.cs, mt.tpool, mt.wallet, filepath.Join(mt.persistDir, modules.MinerDir))
+	rebootMiner, err := New(mt.cs, mt.tpool, mt.wallet, mt.gateway, filepath.Join(mt.persistDir, modules.MinerDir))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,6 +231,70 @@ func TestIntegrationBlocksMined(t *testing.T) {
 	}
 }
 
+// TestIntegrationMiningStatsPersist checks that the blocks found and the
+// hashes attempted by the cpu miner survive a restart, and that they can be
+// reset.
+func TestIntegrationMiningStatsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationMiningStatsPersist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a few blocks with the cpu miner.
+	startHeight := mt.cs.Height()
+	mt.miner.StartCPUMining()
+	for i := 0; i < 100 && mt.cs.Height() < startHeight+3; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	mt.miner.StopCPUMining()
+
+	// Wait for the mining threads to exit, so that they do not submit blocks
+	// while the miner is being closed.
+	for i := 0; i < 100; i++ {
+		mt.miner.mu.Lock()
+		mining := mt.miner.mining
+		mt.miner.mu.Unlock()
+		if !mining {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	goodBlocks, staleBlocks := mt.miner.BlocksMined()
+	hashes := mt.miner.HashesAttempted()
+	if goodBlocks == 0 || hashes == 0 {
+		t.Fatal("cpu miner did not record any mining stats")
+	}
+
+	// Restart the miner. The stats should be the same as before.
+	err = mt.miner.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(mt.cs, mt.tpool, mt.wallet, mt.gateway, filepath.Join(mt.persistDir, modules.MinerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebootGood, rebootStale := m.BlocksMined()
+	if rebootGood != goodBlocks || rebootStale != staleBlocks {
+		t.Error("blocks found were not persisted")
+	}
+	if m.HashesAttempted() < hashes {
+		t.Error("hashes attempted were not persisted:", m.HashesAttempted(), hashes)
+	}
+
+	err = m.ResetMiningStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebootGood, rebootStale = m.BlocksMined()
+	if rebootGood != 0 || rebootStale != 0 || m.HashesAttempted() != 0 {
+		t.Error("mining stats were not reset")
+	}
+}
+
 // TestIntegrationAutoRescan triggers a rescan during a call to New and
 // verifies that the rescanning happens correctly. The rerscan is triggered by
 // a call to New, instead of getting called directly.
@@ -257,7 +327,7 @@ func TestIntegrationAutoRescan(t *testing.T) {
 	}
 
 	// Verify that rescanning resolved the corruption in the miner.
-	m, err := New(mt.cs, mt.tpool, mt.wallet, filepath.Join(mt.persistDir, modules.MinerDir))
+	m, err := New(mt.cs, mt.tpool, mt.wallet, mt.gateway, filepath.Join(mt.persistDir, modules.MinerDir))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -312,3 +382,390 @@ func TestIntegrationStartupRescan(t *testing.T) {
 		t.Error("rescan failed, ended up at the wrong target")
 	}
 }
+
+// TestIntegrationCPUAffinity checks that the cpu miner validates and reports
+// its cpu affinity, and that it keeps mining while pinned.
+func TestIntegrationCPUAffinity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationCPUAffinity")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid core lists should be rejected.
+	if mt.miner.SetCPUAffinity([]int{-1}) != errInvalidCPUCore {
+		t.Error("expected errInvalidCPUCore for a negative core")
+	}
+	cores := allCores()
+	pastLast := 0
+	for _, core := range cores {
+		if core >= pastLast {
+			pastLast = core + 1
+		}
+	}
+	if mt.miner.SetCPUAffinity([]int{pastLast}) != errInvalidCPUCore {
+		t.Error("expected errInvalidCPUCore for a core past the available cores")
+	}
+	if mt.miner.SetCPUAffinity([]int{cores[0], cores[0]}) != errDuplicateCPUCore {
+		t.Error("expected errDuplicateCPUCore")
+	}
+	if len(mt.miner.CPUAffinity()) != 0 {
+		t.Fatal("rejected affinity was applied")
+	}
+
+	// Pin the miner to the first available core.
+	err = mt.miner.SetCPUAffinity([]int{cores[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	affinity := mt.miner.CPUAffinity()
+	if affinitySupported && (len(affinity) != 1 || affinity[0] != cores[0]) {
+		t.Fatal("cpu affinity was not applied:", affinity)
+	}
+
+	// Mine while pinned, then clear the affinity.
+	startHeight := mt.cs.Height()
+	mt.miner.StartCPUMining()
+	for i := 0; i < 100 && mt.cs.Height() == startHeight; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if mt.cs.Height() == startHeight {
+		t.Error("cpu miner did not find any blocks while pinned")
+	}
+	err = mt.miner.SetCPUAffinity(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt.miner.StopCPUMining()
+	if len(mt.miner.CPUAffinity()) != 0 {
+		t.Error("cpu affinity was not cleared")
+	}
+}
+
+// TestIntegrationMinPeersForMining checks that the cpu miner does not find
+// blocks while the gateway has too few peers, and that it resumes mining once
+// enough peers have connected.
+func TestIntegrationMinPeersForMining(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationMinPeersForMining")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.MinPeersForMining() != defaultMinPeersForMining {
+		t.Error("miner did not start with the default minimum number of peers")
+	}
+	if mt.miner.SetMinPeersForMining(-1) != errNegativeMinPeers {
+		t.Error("expected errNegativeMinPeers")
+	}
+
+	// Require a peer and start mining. The miner should not find any blocks.
+	err = mt.miner.SetMinPeersForMining(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startHeight := mt.cs.Height()
+	mt.miner.StartCPUMining()
+	defer mt.miner.StopCPUMining()
+	time.Sleep(peerCheckInterval * 10)
+	if mt.cs.Height() != startHeight {
+		t.Fatal("cpu miner found blocks without enough peers")
+	}
+	if !mt.miner.CPUMining() {
+		t.Error("paused cpu miner should still report that mining is enabled")
+	}
+
+	// Connect a peer, the miner should resume.
+	peer, err := gateway.New("localhost:0", filepath.Join(mt.persistDir, "peer", modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	err = mt.gateway.Connect(peer.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && mt.cs.Height() == startHeight; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if mt.cs.Height() == startHeight {
+		t.Error("cpu miner did not resume after a peer connected")
+	}
+}
+
+// unsyncedConsensusSet is a consensus set that reports that it is not synced
+// with the network.
+type unsyncedConsensusSet struct {
+	modules.ConsensusSet
+}
+
+// Synced always returns false.
+func (unsyncedConsensusSet) Synced() bool { return false }
+
+// TestIntegrationMineWhileUnsynced checks that the cpu miner does not find
+// blocks while the consensus set is not synced, unless mining while unsynced
+// has been enabled.
+func TestIntegrationMineWhileUnsynced(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationMineWhileUnsynced")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.MineWhileUnsynced() {
+		t.Error("miner should not mine while unsynced by default")
+	}
+	mt.miner.mu.Lock()
+	mt.miner.cs = unsyncedConsensusSet{mt.cs}
+	mt.miner.mu.Unlock()
+
+	// Start mining. The miner should not find any blocks or report a
+	// hashrate.
+	startHeight := mt.cs.Height()
+	mt.miner.StartCPUMining()
+	defer mt.miner.StopCPUMining()
+	time.Sleep(peerCheckInterval * 10)
+	if mt.cs.Height() != startHeight {
+		t.Fatal("cpu miner found blocks while the consensus set was not synced")
+	}
+	if mt.miner.CPUHashrate() != 0 {
+		t.Error("paused cpu miner reported a hashrate:", mt.miner.CPUHashrate())
+	}
+
+	// Allow mining while unsynced, the miner should resume.
+	mt.miner.SetMineWhileUnsynced(true)
+	for i := 0; i < 100 && mt.cs.Height() == startHeight; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if mt.cs.Height() == startHeight {
+		t.Error("cpu miner did not resume after mining while unsynced was enabled")
+	}
+}
+
+// TestIntegrationMiningPriority checks that the cpu miner keeps finding
+// blocks at low priority.
+func TestIntegrationMiningPriority(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationMiningPriority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.MiningPriority() != modules.MiningPriorityNormal {
+		t.Error("miner did not start at normal priority")
+	}
+	if mt.miner.SetMiningPriority(modules.MiningPriority(-1)) != errUnknownPriority {
+		t.Error("expected errUnknownPriority")
+	}
+
+	err = mt.miner.SetMiningPriority(modules.MiningPriorityLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.MiningPriority() != modules.MiningPriorityLow {
+		t.Error("priority was not set")
+	}
+	startHeight := mt.cs.Height()
+	mt.miner.StartCPUMining()
+	defer mt.miner.StopCPUMining()
+	for i := 0; i < 100 && mt.cs.Height() == startHeight; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if mt.cs.Height() == startHeight {
+		t.Error("cpu miner did not find blocks at low priority")
+	}
+}
+
+// TestIntegrationCPUMiningThreads checks that the cpu miner can be started
+// with multiple threads, and that all of the threads stop together.
+func TestIntegrationCPUMiningThreads(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationCPUMiningThreads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.StartCPUMiningThreads(0) != errNoMiningThreads {
+		t.Error("expected errNoMiningThreads")
+	}
+
+	startHeight := mt.cs.Height()
+	err = mt.miner.StartCPUMiningThreads(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second batch should not be launched while the first is running.
+	err = mt.miner.StartCPUMiningThreads(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt.miner.mu.Lock()
+	threads := mt.miner.miningThreads
+	mt.miner.mu.Unlock()
+	if threads != 2 {
+		t.Error("expected 2 mining threads, got", threads)
+	}
+	for i := 0; i < 100 && mt.cs.Height() == startHeight; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if mt.cs.Height() == startHeight {
+		t.Error("multi-threaded cpu miner did not find any blocks")
+	}
+
+	// Stopping the miner should stop every thread.
+	mt.miner.StopCPUMining()
+	for i := 0; i < 100; i++ {
+		mt.miner.mu.Lock()
+		mining := mt.miner.mining
+		mt.miner.mu.Unlock()
+		if !mining {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	mt.miner.mu.Lock()
+	mining, threads := mt.miner.mining, mt.miner.miningThreads
+	mt.miner.mu.Unlock()
+	if mining || threads != 0 {
+		t.Error("mining threads are still running after the miner was stopped")
+	}
+	if mt.miner.CPUHashrate() != 0 {
+		t.Error("stopped miner reports a nonzero hashrate")
+	}
+	if mt.miner.CPUMining() {
+		t.Error("miner reports mining after being stopped")
+	}
+}
+
+// TestIntegrationSubscribeBlockFound checks that block found subscribers are
+// called with the blocks found by the cpu miner, and that unsubscribing stops
+// the calls.
+func TestIntegrationSubscribeBlockFound(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationSubscribeBlockFound")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(chan types.Block, 100)
+	unsubscribe := mt.miner.SubscribeBlockFound(func(b types.Block) {
+		found <- b
+	})
+	// A blocking subscriber should not stall the cpu miner.
+	block := make(chan struct{})
+	unsubscribeSlow := mt.miner.SubscribeBlockFound(func(types.Block) {
+		<-block
+	})
+	defer close(block)
+
+	startHeight := mt.cs.Height()
+	mt.miner.StartCPUMining()
+	var b types.Block
+	select {
+	case b = <-found:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber was not told about a found block")
+	}
+	if !mt.cs.InCurrentPath(b.ID()) {
+		t.Error("subscriber was given a block that is not in the consensus set")
+	}
+	for i := 0; i < 100 && mt.cs.Height() < startHeight+2; i++ {
+		time.Sleep(time.Millisecond * 50)
+	}
+	if mt.cs.Height() < startHeight+2 {
+		t.Error("a slow subscriber stalled the cpu miner")
+	}
+	mt.miner.StopCPUMining()
+
+	// Unsubscribing twice should be harmless, and no subscribers should
+	// remain.
+	unsubscribe()
+	unsubscribe()
+	unsubscribeSlow()
+	mt.miner.mu.Lock()
+	numSubscribers := len(mt.miner.blockFoundSubscribers)
+	mt.miner.mu.Unlock()
+	if numSubscribers != 0 {
+		t.Error("subscribers remain after unsubscribing:", numSubscribers)
+	}
+}
+
+// TestHashrateMovingAverage checks that hashrate samples are smoothed by the
+// moving average according to the hashrate window.
+func TestHashrateMovingAverage(t *testing.T) {
+	// The first sample seeds the average.
+	if movingAverage(0, 1000, time.Second, time.Second) != 1000 {
+		t.Error("first sample should seed the average")
+	}
+	// A sample that is short relative to the window barely moves the average.
+	avg := movingAverage(1000, 0, time.Millisecond, time.Minute)
+	if avg < 990 || avg >= 1000 {
+		t.Error("short sample moved the average too much:", avg)
+	}
+	// A sample that is long relative to the window dominates the average.
+	avg = movingAverage(1000, 0, time.Hour, time.Second)
+	if avg != 0 {
+		t.Error("long sample did not dominate the average:", avg)
+	}
+
+	mt, err := createMinerTester("TestHashrateMovingAverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.SetHashrateWindow(0) != errInvalidHashrateWindow {
+		t.Error("expected errInvalidHashrateWindow")
+	}
+	err = mt.miner.SetHashrateWindow(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.hashrateWindow != time.Minute {
+		t.Error("hashrate window was not set")
+	}
+}
+
+// TestBlockTimeEstimate checks that the block time estimate is derived from
+// the target and the hashrate of the cpu miner.
+func TestBlockTimeEstimate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestBlockTimeEstimate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, target, err := mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.Target() != target {
+		t.Error("miner target does not match the target of the block for work")
+	}
+
+	// A miner that is not hashing cannot estimate the block time.
+	if mt.miner.BlockTimeEstimate() != time.Duration(-1) {
+		t.Error("expected a negative estimate while the miner is not hashing")
+	}
+
+	// The estimate is the difficulty divided by the hashrate.
+	hashes, err := target.Difficulty().Uint64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockTimeEstimate(target, int64(hashes)) != time.Second {
+		t.Error("hashing at the difficulty per second should take one second per block")
+	}
+	var hardest types.Target
+	hardest[len(hardest)-1] = 1
+	if blockTimeEstimate(hardest, 1) != time.Duration(math.MaxInt64) {
+		t.Error("estimate should saturate instead of overflowing")
+	}
+}
@@ -30,6 +30,17 @@ type (
 		Address       types.UnlockHash
 		BlocksFound   []types.BlockID
 		UnsolvedBlock types.Block
+
+		// HashesAttempted is the number of hashes that the cpu miner has
+		// attempted over the lifetime of the miner, or since the mining
+		// stats were last reset.
+		HashesAttempted uint64
+
+		// PayoutAddress and BlockArbitraryData are set by the user to
+		// override the payout address and to add arbitrary data to the
+		// blocks created by the miner.
+		PayoutAddress      types.UnlockHash
+		BlockArbitraryData []byte
 	}
 )
 
@@ -0,0 +1,465 @@
+package miner
+
+// poolminer.go implements a client for a stratum-style mining pool. Messages
+// are newline delimited JSON-RPC objects. After connecting, the miner sends
+// 'mining.subscribe' and 'mining.authorize' with the worker name. The pool
+// sends 'mining.set_target' with the hex encoded share target, and
+// 'mining.notify' with a job id and the hex encoded block to grind on. Shares
+// are submitted with 'mining.submit', giving the worker name, the job id, and
+// the hex encoded nonce, and the pool answers with a boolean result.
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// poolDialTimeout is the amount of time that the miner waits to connect
+	// to a mining pool.
+	poolDialTimeout = 30 * time.Second
+
+	// maxPoolReconnectInterval is the longest amount of time that the miner
+	// waits between attempts to reconnect to a mining pool.
+	maxPoolReconnectInterval = 5 * time.Minute
+
+	// poolURLScheme is the optional scheme of a pool url.
+	poolURLScheme = "stratum+tcp://"
+
+	// The ids of the requests that are sent when connecting to a pool. Share
+	// submissions use the ids that follow.
+	poolSubscribeID = 1
+	poolAuthorizeID = 2
+)
+
+var (
+	errAlreadyMining  = errors.New("cpu miner is already running")
+	errInvalidPoolURL = errors.New("pool url must be of the form [stratum+tcp://]host:port")
+	errNoWorkerName   = errors.New("pool mining requires a worker name")
+	errPoolClosed     = errors.New("connection to the mining pool was closed")
+
+	// poolReconnectInterval is the amount of time that the miner waits before
+	// reconnecting to a mining pool after the connection drops. The interval
+	// doubles after each failed attempt, up to maxPoolReconnectInterval.
+	poolReconnectInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return 5 * time.Second
+		}
+		if build.Release == "standard" {
+			return 10 * time.Second
+		}
+		if build.Release == "testing" {
+			return 50 * time.Millisecond
+		}
+		panic("unrecognized build.Release")
+	}()
+)
+
+type (
+	// stratumRequest is a request sent from the miner to the pool.
+	stratumRequest struct {
+		ID     uint64        `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+
+	// stratumMessage is a response or notification sent from the pool to the
+	// miner. Notifications have a method and no id.
+	stratumMessage struct {
+		ID     *uint64           `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+		Result json.RawMessage   `json:"result"`
+		Error  json.RawMessage   `json:"error"`
+	}
+
+	// poolWork is a range of nonces of a pool job that a mining thread can
+	// grind on.
+	poolWork struct {
+		jobID      string
+		block      types.Block
+		target     types.Target
+		startNonce uint64
+	}
+
+	// poolClient is a connection to a mining pool. If the connection drops,
+	// the client reconnects until it is closed.
+	poolClient struct {
+		addr   string
+		conn   net.Conn
+		log    *persist.Logger
+		worker string
+
+		// connected is set once the pool has authorized the worker, and
+		// cleared when the connection drops or is closed. closeChan is closed
+		// when the client is closed.
+		connected bool
+		closed    bool
+		closeChan chan struct{}
+
+		// jobID and job are the most recent job sent by the pool, and
+		// nextNonce is the first nonce of the job that has not been handed
+		// out to a mining thread. hasJob and hasTarget are set once the pool
+		// has sent a job and a target.
+		jobID     string
+		job       types.Block
+		target    types.Target
+		hasJob    bool
+		hasTarget bool
+		nextNonce uint64
+
+		// pendingShares holds the ids of the share submissions that the pool
+		// has not answered yet.
+		nextID         uint64
+		pendingShares  map[uint64]struct{}
+		sharesAccepted int
+		sharesRejected int
+
+		mu      sync.Mutex
+		writeMu sync.Mutex
+	}
+)
+
+// parsePoolURL returns the address of the pool at 'poolURL'.
+func parsePoolURL(poolURL string) (string, error) {
+	addr := strings.TrimPrefix(poolURL, poolURLScheme)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" || port == "" {
+		return "", errInvalidPoolURL
+	}
+	return addr, nil
+}
+
+// dialPool connects to the pool at 'addr' and authorizes 'worker'. The
+// connection is read in the background until it is closed.
+func dialPool(addr string, worker string, log *persist.Logger) (*poolClient, error) {
+	pc := &poolClient{
+		addr:          addr,
+		log:           log,
+		worker:        worker,
+		closeChan:     make(chan struct{}),
+		nextID:        poolAuthorizeID + 1,
+		pendingShares: make(map[uint64]struct{}),
+	}
+	conn, err := pc.connect()
+	if err != nil {
+		return nil, err
+	}
+	pc.conn = conn
+	go pc.threadedListen()
+	return pc, nil
+}
+
+// connect dials the pool, subscribes, and authorizes the worker.
+func (pc *poolClient) connect() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", pc.addr, poolDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	err = pc.write(conn, stratumRequest{ID: poolSubscribeID, Method: "mining.subscribe", Params: []interface{}{"siad/" + build.Version}})
+	if err == nil {
+		err = pc.write(conn, stratumRequest{ID: poolAuthorizeID, Method: "mining.authorize", Params: []interface{}{pc.worker, ""}})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// reconnect connects to the pool again after the connection has dropped,
+// backing off between failed attempts. errPoolClosed is returned if the
+// client is closed while reconnecting.
+func (pc *poolClient) reconnect() (net.Conn, error) {
+	interval := poolReconnectInterval
+	for {
+		select {
+		case <-pc.closeChan:
+			return nil, errPoolClosed
+		case <-time.After(interval):
+		}
+		conn, err := pc.connect()
+		if err == nil {
+			return conn, nil
+		}
+		pc.log.Println("WARN: could not reconnect to the mining pool:", err)
+		interval *= 2
+		if interval > maxPoolReconnectInterval {
+			interval = maxPoolReconnectInterval
+		}
+	}
+}
+
+// write writes a request to 'conn'.
+func (pc *poolClient) write(conn net.Conn, req stratumRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	pc.writeMu.Lock()
+	defer pc.writeMu.Unlock()
+	_, err = conn.Write(append(b, '\n'))
+	return err
+}
+
+// send writes a request to the pool over the current connection.
+func (pc *poolClient) send(req stratumRequest) error {
+	pc.mu.Lock()
+	conn := pc.conn
+	pc.mu.Unlock()
+	return pc.write(conn, req)
+}
+
+// close closes the connection to the pool.
+func (pc *poolClient) close() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.closed {
+		return
+	}
+	pc.closed = true
+	pc.connected = false
+	close(pc.closeChan)
+	pc.conn.Close()
+}
+
+// threadedListen reads the messages sent by the pool until the client is
+// closed. When the connection drops, the work of the pool is discarded and the
+// client reconnects.
+func (pc *poolClient) threadedListen() {
+	defer pc.close()
+	for {
+		pc.mu.Lock()
+		conn := pc.conn
+		pc.mu.Unlock()
+		pc.listen(conn)
+		conn.Close()
+
+		// Stop handing out work until the pool has authorized the worker and
+		// sent a new job and target. Shares that are still pending will never
+		// be answered.
+		pc.mu.Lock()
+		closed := pc.closed
+		pc.connected = false
+		pc.hasJob = false
+		pc.hasTarget = false
+		pc.pendingShares = make(map[uint64]struct{})
+		pc.mu.Unlock()
+		if closed {
+			return
+		}
+		pc.log.Println("WARN: lost the connection to the mining pool, reconnecting")
+
+		conn, err := pc.reconnect()
+		if err != nil {
+			return
+		}
+		pc.mu.Lock()
+		if pc.closed {
+			pc.mu.Unlock()
+			conn.Close()
+			return
+		}
+		pc.conn = conn
+		pc.mu.Unlock()
+	}
+}
+
+// listen reads the messages sent by the pool over 'conn' until the connection
+// is closed.
+func (pc *poolClient) listen(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	// A job contains a whole block, which is larger than the default limit.
+	scanner.Buffer(nil, 2*int(types.BlockSizeLimit)+4096)
+	for scanner.Scan() {
+		var msg stratumMessage
+		err := json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			pc.log.Println("WARN: received a malformed message from the mining pool:", err)
+			continue
+		}
+		if msg.Method != "" {
+			err = pc.handleNotification(msg)
+			if err != nil {
+				pc.log.Printf("WARN: could not handle %v from the mining pool: %v", msg.Method, err)
+			}
+		} else if msg.ID != nil {
+			pc.handleResponse(*msg.ID, msg.Result)
+		}
+	}
+}
+
+// handleNotification processes a message sent by the pool on its own
+// initiative.
+func (pc *poolClient) handleNotification(msg stratumMessage) error {
+	switch msg.Method {
+	case "mining.set_target":
+		var targetHex string
+		if len(msg.Params) < 1 || json.Unmarshal(msg.Params[0], &targetHex) != nil {
+			return errors.New("missing target")
+		}
+		b, err := hex.DecodeString(targetHex)
+		if err != nil || len(b) != len(types.Target{}) {
+			return errors.New("malformed target")
+		}
+		pc.mu.Lock()
+		copy(pc.target[:], b)
+		pc.hasTarget = true
+		pc.mu.Unlock()
+
+	case "mining.notify":
+		var jobID, blockHex string
+		if len(msg.Params) < 2 || json.Unmarshal(msg.Params[0], &jobID) != nil || json.Unmarshal(msg.Params[1], &blockHex) != nil {
+			return errors.New("missing job")
+		}
+		b, err := hex.DecodeString(blockHex)
+		if err != nil {
+			return err
+		}
+		var block types.Block
+		err = encoding.Unmarshal(b, &block)
+		if err != nil {
+			return err
+		}
+		pc.mu.Lock()
+		pc.jobID = jobID
+		pc.job = block
+		pc.hasJob = true
+		pc.nextNonce = 0
+		pc.mu.Unlock()
+	}
+	return nil
+}
+
+// handleResponse processes the pool's answer to the request with the given
+// id.
+func (pc *poolClient) handleResponse(id uint64, result json.RawMessage) {
+	var accepted bool
+	json.Unmarshal(result, &accepted)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if id == poolAuthorizeID {
+		if !accepted {
+			pc.log.Println("WARN: the mining pool did not authorize worker", pc.worker)
+			return
+		}
+		pc.connected = !pc.closed
+		return
+	}
+	if _, exists := pc.pendingShares[id]; !exists {
+		return
+	}
+	delete(pc.pendingShares, id)
+	if accepted {
+		pc.sharesAccepted++
+	} else {
+		pc.sharesRejected++
+	}
+}
+
+// nextWork hands out the next range of nonces of the current job. false is
+// returned if there is no work to hand out.
+func (pc *poolClient) nextWork() (poolWork, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if !pc.connected || !pc.hasJob || !pc.hasTarget {
+		return poolWork{}, false
+	}
+	w := poolWork{
+		jobID:      pc.jobID,
+		block:      pc.job,
+		target:     pc.target,
+		startNonce: pc.nextNonce,
+	}
+	pc.nextNonce += solveAttempts
+	return w, true
+}
+
+// submitShare submits a solved block of the job 'jobID' to the pool.
+func (pc *poolClient) submitShare(jobID string, b types.Block) error {
+	pc.mu.Lock()
+	id := pc.nextID
+	pc.nextID++
+	pc.pendingShares[id] = struct{}{}
+	pc.mu.Unlock()
+	return pc.send(stratumRequest{
+		ID:     id,
+		Method: "mining.submit",
+		Params: []interface{}{pc.worker, jobID, hex.EncodeToString(b.Nonce[:])},
+	})
+}
+
+// StartPoolMining connects the cpu miner to the stratum-style mining pool at
+// 'poolURL', which has the form [stratum+tcp://]host:port, and mines shares
+// for the pool as 'workerName'. The miner mines with the number of threads
+// that was last given to StartCPUMiningThreads, and does not need the
+// consensus set to be synced while pool mining. If the connection to the pool
+// drops, the miner reconnects. Pool mining is stopped by StopCPUMining, after
+// which solo mining can be started again.
+func (m *Miner) StartPoolMining(poolURL string, workerName string) error {
+	addr, err := parsePoolURL(poolURL)
+	if err != nil {
+		return err
+	}
+	if workerName == "" {
+		return errNoWorkerName
+	}
+	if m.CPUMining() {
+		return errAlreadyMining
+	}
+
+	// The pool is dialed without holding the lock.
+	pc, err := dialPool(addr, workerName, m.log)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.miningOn {
+		pc.close()
+		return errAlreadyMining
+	}
+	m.pool = pc
+	m.startCPUMining(m.cpuThreads)
+	return nil
+}
+
+// PoolConnected returns true if the cpu miner is connected to a mining pool
+// that has authorized the worker.
+func (m *Miner) PoolConnected() bool {
+	m.mu.Lock()
+	pc := m.pool
+	m.mu.Unlock()
+	if pc == nil {
+		return false
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.connected
+}
+
+// PoolShares returns the number of shares that have been accepted and
+// rejected by the mining pool that the cpu miner is connected to. The counts
+// start over each time pool mining is started.
+func (m *Miner) PoolShares() (accepted, rejected int) {
+	m.mu.Lock()
+	pc := m.pool
+	m.mu.Unlock()
+	if pc == nil {
+		return 0, 0
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.sharesAccepted, pc.sharesRejected
+}
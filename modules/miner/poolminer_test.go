@@ -0,0 +1,224 @@
+package miner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationPoolMining runs the cpu miner against a mock mining pool,
+// checking that the shares it submits solve the pool's job and that the share
+// counts follow the answers of the pool.
+func TestIntegrationPoolMining(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationPoolMining")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mt.miner.StartPoolMining("stratum+tcp://nohost", "worker") != errInvalidPoolURL {
+		t.Error("expected errInvalidPoolURL")
+	}
+	if mt.miner.StartPoolMining("localhost:3333", "") != errNoWorkerName {
+		t.Error("expected errNoWorkerName")
+	}
+
+	// Start a mock pool that hands out a single job with an easy target.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	job := types.Block{ParentID: types.BlockID{1}, Timestamp: types.CurrentTimestamp()}
+	target := types.Target{0x0f, 0xff}
+	shares := make(chan []string)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		enc := json.NewEncoder(conn)
+		for scanner.Scan() {
+			var req struct {
+				ID     uint64   `json:"id"`
+				Method string   `json:"method"`
+				Params []string `json:"params"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &req) != nil {
+				return
+			}
+			switch req.Method {
+			case "mining.authorize":
+				enc.Encode(map[string]interface{}{"id": req.ID, "result": true, "error": nil})
+				enc.Encode(map[string]interface{}{"id": nil, "method": "mining.set_target", "params": []string{hex.EncodeToString(target[:])}})
+				enc.Encode(map[string]interface{}{"id": nil, "method": "mining.notify", "params": []interface{}{"job1", hex.EncodeToString(encoding.Marshal(job)), true}})
+			case "mining.submit":
+				// Accept the first share and reject the rest.
+				enc.Encode(map[string]interface{}{"id": req.ID, "result": req.ID == poolAuthorizeID+1, "error": nil})
+				select {
+				case shares <- req.Params:
+				default:
+				}
+			}
+		}
+	}()
+
+	err = mt.miner.StartPoolMining("stratum+tcp://"+l.Addr().String(), "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.StartPoolMining(l.Addr().String(), "worker") != errAlreadyMining {
+		t.Error("expected errAlreadyMining")
+	}
+	for i := 0; i < 2; i++ {
+		var params []string
+		select {
+		case params = <-shares:
+		case <-time.After(30 * time.Second):
+			t.Fatal("no share was submitted to the pool")
+		}
+		if len(params) != 3 || params[0] != "worker" || params[1] != "job1" {
+			t.Fatal("share has the wrong parameters:", params)
+		}
+		nonce, err := hex.DecodeString(params[2])
+		if err != nil || len(nonce) != len(job.Nonce) {
+			t.Fatal("share has a malformed nonce:", params[2])
+		}
+		solved := job
+		copy(solved.Nonce[:], nonce)
+		id := solved.ID()
+		if bytes.Compare(target[:], id[:]) < 0 {
+			t.Fatal("share does not meet the pool target")
+		}
+	}
+	if !mt.miner.PoolConnected() {
+		t.Error("miner does not report that it is connected to the pool")
+	}
+	for i := 0; i < 100; i++ {
+		if accepted, rejected := mt.miner.PoolShares(); accepted == 1 && rejected >= 1 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if accepted, rejected := mt.miner.PoolShares(); accepted != 1 || rejected < 1 {
+		t.Errorf("expected 1 accepted share and at least 1 rejected share, got %v and %v", accepted, rejected)
+	}
+
+	// Stopping the cpu miner disconnects from the pool.
+	mt.miner.StopCPUMining()
+	if mt.miner.PoolConnected() {
+		t.Error("miner is still connected to the pool after stopping")
+	}
+	for i := 0; i < 100; i++ {
+		mt.miner.mu.Lock()
+		mining := mt.miner.mining
+		mt.miner.mu.Unlock()
+		if !mining {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+}
+
+// TestIntegrationPoolReconnect checks that the cpu miner reconnects to the
+// mining pool after the pool drops the connection, and that pool mining uses
+// the configured number of threads.
+func TestIntegrationPoolReconnect(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester("TestIntegrationPoolReconnect")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Configure two mining threads, then stop the cpu miner again.
+	err = mt.miner.StartCPUMiningThreads(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt.miner.StopCPUMining()
+	for i := 0; i < 100; i++ {
+		mt.miner.mu.Lock()
+		mining := mt.miner.mining
+		mt.miner.mu.Unlock()
+		if !mining {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	// Start a mock pool that drops the first connection as soon as the
+	// worker is authorized.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	authorized := make(chan struct{}, 2)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn, drop bool) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				enc := json.NewEncoder(conn)
+				for scanner.Scan() {
+					var req struct {
+						ID     uint64 `json:"id"`
+						Method string `json:"method"`
+					}
+					if json.Unmarshal(scanner.Bytes(), &req) != nil {
+						return
+					}
+					if req.Method == "mining.authorize" {
+						enc.Encode(map[string]interface{}{"id": req.ID, "result": true, "error": nil})
+						authorized <- struct{}{}
+						if drop {
+							return
+						}
+					}
+				}
+			}(conn, i == 0)
+		}
+	}()
+
+	err = mt.miner.StartPoolMining(l.Addr().String(), "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.miner.StopCPUMining()
+	mt.miner.mu.Lock()
+	threads := mt.miner.miningThreads
+	mt.miner.mu.Unlock()
+	if threads != 2 {
+		t.Error("pool mining started with the wrong number of threads:", threads)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-authorized:
+		case <-time.After(30 * time.Second):
+			t.Fatal("miner did not reconnect to the pool")
+		}
+	}
+	for i := 0; i < 100 && !mt.miner.PoolConnected(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !mt.miner.PoolConnected() {
+		t.Error("miner does not report that it is connected to the pool after reconnecting")
+	}
+}
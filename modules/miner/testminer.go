@@ -89,6 +89,14 @@ func (m *Miner) FindBlock() (types.Block, error) {
 // target. A bool is returned indicating whether the block was successfully
 // solved.
 func (m *Miner) SolveBlock(b types.Block, target types.Target) (types.Block, bool) {
+	b, _, solved := solveBlockFrom(b, target, 0)
+	return b, solved
+}
+
+// solveBlockFrom tries to solve 'b' for 'target', trying 'solveAttempts'
+// nonces starting at 'startNonce'. The number of nonces that were tried is
+// returned along with the block.
+func solveBlockFrom(b types.Block, target types.Target, startNonce uint64) (types.Block, uint64, bool) {
 	// Assemble the header.
 	merkleRoot := b.MerkleRoot()
 	header := make([]byte, 80)
@@ -96,15 +104,15 @@ func (m *Miner) SolveBlock(b types.Block, target types.Target) (types.Block, boo
 	binary.LittleEndian.PutUint64(header[40:48], uint64(b.Timestamp))
 	copy(header[48:], merkleRoot[:])
 
-	var nonce uint64
-	for i := 0; i < solveAttempts; i++ {
+	nonce := startNonce
+	for i := uint64(0); i < solveAttempts; i++ {
+		*(*uint64)(unsafe.Pointer(&header[32])) = nonce
 		id := crypto.HashBytes(header)
 		if bytes.Compare(target[:], id[:]) >= 0 {
 			copy(b.Nonce[:], header[32:40])
-			return b, true
+			return b, i + 1, true
 		}
-		*(*uint64)(unsafe.Pointer(&header[32])) = nonce
 		nonce++
 	}
-	return b, false
+	return b, solveAttempts, false
 }
@@ -3,7 +3,9 @@ package modules
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -289,10 +291,77 @@ type (
 	}
 )
 
+// A RejectionReason is a machine-readable code that indicates why a host
+// rejected a negotiation.
+type RejectionReason string
+
+const (
+	// RejectionReasonCollateralBudget indicates that the host does not have
+	// enough collateral budget or confirmed funds left to accept the contract.
+	// Collateral is returned to the budget as existing contracts expire.
+	RejectionReasonCollateralBudget RejectionReason = "collateralbudget"
+
+	// RejectionReasonFull indicates that the host has no storage remaining.
+	// Storage is freed as existing contracts expire.
+	RejectionReasonFull RejectionReason = "full"
+
+	// RejectionReasonInvalid indicates that the proposal itself was not
+	// acceptable to the host. Retrying the same proposal will not help.
+	RejectionReasonInvalid RejectionReason = "invalid"
+
+	// RejectionReasonRateLimited indicates that the host is receiving too many
+	// requests and that the renter should try again later.
+	RejectionReasonRateLimited RejectionReason = "ratelimited"
+)
+
+// negotiationRejectionPrefix is the prefix used to identify a structured
+// rejection on the wire. Older peers will still see a readable error.
+const negotiationRejectionPrefix = "rejected: "
+
+// A NegotiationRejection is a structured rejection that is sent in place of
+// an acceptance. It carries a reason code and, where applicable, a suggested
+// interval after which the request may succeed. A RetryAfter of zero means
+// that no retry interval is suggested.
+type NegotiationRejection struct {
+	Reason     RejectionReason
+	RetryAfter time.Duration
+	Message    string
+}
+
+// Error implements the error interface. The result is also the wire format of
+// the rejection.
+func (nr NegotiationRejection) Error() string {
+	return fmt.Sprintf("%vreason=%v retryafter=%v: %v", negotiationRejectionPrefix, nr.Reason, int64(nr.RetryAfter/time.Second), nr.Message)
+}
+
+// parseNegotiationRejection attempts to parse a structured rejection from a
+// negotiation response.
+func parseNegotiationRejection(resp string) (NegotiationRejection, bool) {
+	if !strings.HasPrefix(resp, negotiationRejectionPrefix) {
+		return NegotiationRejection{}, false
+	}
+	fields := strings.SplitN(strings.TrimPrefix(resp, negotiationRejectionPrefix), ": ", 2)
+	if len(fields) != 2 {
+		return NegotiationRejection{}, false
+	}
+	var reason string
+	var retryAfter int64
+	_, err := fmt.Sscanf(fields[0], "reason=%s retryafter=%d", &reason, &retryAfter)
+	if err != nil || retryAfter < 0 {
+		return NegotiationRejection{}, false
+	}
+	return NegotiationRejection{
+		Reason:     RejectionReason(reason),
+		RetryAfter: time.Duration(retryAfter) * time.Second,
+		Message:    fields[1],
+	}, true
+}
+
 // ReadNegotiationAcceptance reads an accept/reject response from r (usually a
 // net.Conn). If the response is not AcceptResponse, ReadNegotiationAcceptance
 // returns the response as an error. If the response is StopResponse,
-// ErrStopResponse is returned, allowing for direct error comparison.
+// ErrStopResponse is returned, allowing for direct error comparison. If the
+// response is a structured rejection, a NegotiationRejection is returned.
 //
 // Note that since errors returned by ReadNegotiationAcceptance are newly
 // allocated, they cannot be compared to other errors in the traditional
@@ -309,6 +378,9 @@ func ReadNegotiationAcceptance(r io.Reader) error {
 	case StopResponse:
 		return ErrStopResponse
 	default:
+		if nr, ok := parseNegotiationRejection(resp); ok {
+			return nr
+		}
 		return errors.New(resp)
 	}
 }
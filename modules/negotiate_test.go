@@ -3,6 +3,7 @@ package modules
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
@@ -117,6 +118,22 @@ func TestNegotiationResponses(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Write/Read structured rejection
+	buf.Reset()
+	rejection := NegotiationRejection{
+		Reason:     RejectionReasonFull,
+		RetryAfter: 90 * time.Minute,
+		Message:    "host is full: try again later",
+	}
+	err = WriteNegotiationRejection(buf, rejection)
+	if err != rejection {
+		t.Fatal(err)
+	}
+	err = ReadNegotiationAcceptance(buf)
+	if err != rejection {
+		t.Fatal("structured rejection was not preserved:", err)
+	}
+
 	// Write/Read StopResponse
 	buf.Reset()
 	err = WriteNegotiationStop(buf)
@@ -64,6 +64,16 @@ type DownloadInfo struct {
 	StartTime   time.Time `json:"starttime"`
 }
 
+// An AvailabilityReport summarizes a sample of a file's pieces that were
+// fetched from hosts and checked against their Merkle roots.
+type AvailabilityReport struct {
+	SiaPath         string       `json:"siapath"`
+	PiecesSampled   uint64       `json:"piecessampled"`
+	PiecesAvailable uint64       `json:"piecesavailable"`
+	Availability    float64      `json:"availability"`
+	FailedHosts     []NetAddress `json:"failedhosts"`
+}
+
 // An Allowance dictates how much the Renter is allowed to spend in a given
 // period. Note that funds are spent on both storage and bandwidth.
 type Allowance struct {
@@ -120,6 +130,10 @@ type Renter interface {
 	// FileList returns information on all of the files stored by the renter.
 	FileList() []FileInfo
 
+	// FileUpdates returns a channel that is closed the next time the upload
+	// progress or status of any file changes.
+	FileUpdates() <-chan struct{}
+
 	// FinancialMetrics returns the financial metrics of the Renter.
 	FinancialMetrics() RenterFinancialMetrics
 
@@ -140,6 +154,11 @@ type Renter interface {
 	// SetAllowance is called; that is, it may block.
 	SetAllowance(Allowance) error
 
+	// SetAvailabilitySampling sets whether the renter periodically samples
+	// the availability of its files, downloading a few pieces of each file
+	// from the hosts. Sampling is disabled by default.
+	SetAvailabilitySampling(enabled bool) error
+
 	// ShareFiles creates a '.sia' file that can be shared with others.
 	ShareFiles(paths []string, shareDest string) error
 
@@ -148,4 +167,8 @@ type Renter interface {
 
 	// Upload uploads a file using the input parameters.
 	Upload(FileUploadParams) error
+
+	// VerifyFileAvailability fetches a random sample of a file's pieces from
+	// hosts and reports the fraction that were retrieved correctly.
+	VerifyFileAvailability(path string) (AvailabilityReport, error)
 }
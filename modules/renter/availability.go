@@ -0,0 +1,272 @@
+package renter
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errNoPiecesToSample = errors.New("file has no uploaded pieces to sample")
+)
+
+var (
+	// availabilitySampleSize is the number of pieces that are fetched from
+	// hosts when sampling the availability of a file.
+	availabilitySampleSize = func() int {
+		if build.Release == "dev" {
+			return 8
+		}
+		if build.Release == "standard" {
+			return 16
+		}
+		if build.Release == "testing" {
+			return 4
+		}
+		panic("unrecognized build.Release")
+	}()
+
+	// availabilityCheckInterval is how often the repair loop samples the
+	// availability of the files it is tracking, if sampling is enabled.
+	availabilityCheckInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Hour
+		}
+		if build.Release == "standard" {
+			return 24 * time.Hour
+		}
+		if build.Release == "testing" {
+			return 10 * time.Second
+		}
+		panic("unrecognized build.Release")
+	}()
+)
+
+const (
+	// availabilitySuspectLimit is the number of consecutive availability
+	// checks that a piece can fail to be fetched in before it is removed from
+	// its file and repaired.
+	availabilitySuspectLimit = 3
+)
+
+// A pieceSample is a piece that has been selected for availability sampling,
+// along with the contract that covers it.
+type pieceSample struct {
+	contract types.FileContractID
+	host     modules.NetAddress
+	piece    pieceData
+}
+
+// availabilitySamples selects up to n pieces of the file at random.
+func (f *file) availabilitySamples(n int) ([]pieceSample, error) {
+	f.mu.RLock()
+	var all []pieceSample
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			all = append(all, pieceSample{
+				contract: fc.ID,
+				host:     fc.IP,
+				piece:    p,
+			})
+		}
+	}
+	f.mu.RUnlock()
+
+	if n > len(all) {
+		n = len(all)
+	}
+	order, err := crypto.Perm(len(all))
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]pieceSample, n)
+	for i := range samples {
+		samples[i] = all[order[i]]
+	}
+	return samples, nil
+}
+
+// sampleResults sorts the samples of an availability check by outcome.
+// Unreachable samples could not be fetched, which may be temporary, while
+// corrupt samples were fetched but did not match their Merkle root.
+type sampleResults struct {
+	available   []pieceSample
+	unreachable []pieceSample
+	corrupt     []pieceSample
+}
+
+// verifySamples fetches each sampled piece using the downloader of its
+// contract and checks the piece against its Merkle root. Samples whose
+// contract has no downloader count as unreachable.
+func verifySamples(samples []pieceSample, downloaders map[types.FileContractID]contractor.Downloader) (modules.AvailabilityReport, sampleResults) {
+	var report modules.AvailabilityReport
+	var results sampleResults
+	failedHosts := make(map[modules.NetAddress]struct{})
+	for _, s := range samples {
+		report.PiecesSampled++
+		d, ok := downloaders[s.contract]
+		if !ok {
+			results.unreachable = append(results.unreachable, s)
+		} else if data, err := d.Sector(s.piece.MerkleRoot); err != nil {
+			results.unreachable = append(results.unreachable, s)
+		} else if crypto.MerkleRoot(data) != s.piece.MerkleRoot {
+			results.corrupt = append(results.corrupt, s)
+		} else {
+			report.PiecesAvailable++
+			results.available = append(results.available, s)
+			continue
+		}
+		if _, exists := failedHosts[s.host]; !exists {
+			failedHosts[s.host] = struct{}{}
+			report.FailedHosts = append(report.FailedHosts, s.host)
+		}
+	}
+	if report.PiecesSampled > 0 {
+		report.Availability = float64(report.PiecesAvailable) / float64(report.PiecesSampled)
+	}
+	return report, results
+}
+
+// managedSampleAvailability samples the availability of a file, returning
+// the report along with the outcome of each sample.
+func (r *Renter) managedSampleAvailability(name string) (modules.AvailabilityReport, sampleResults, error) {
+	lockID := r.mu.RLock()
+	f, exists := r.files[name]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return modules.AvailabilityReport{}, sampleResults{}, ErrUnknownPath
+	}
+
+	samples, err := f.availabilitySamples(availabilitySampleSize)
+	if err != nil {
+		return modules.AvailabilityReport{}, sampleResults{}, err
+	}
+	if len(samples) == 0 {
+		return modules.AvailabilityReport{}, sampleResults{}, errNoPiecesToSample
+	}
+
+	// Connect to the hosts holding the sampled pieces. Hosts that cannot be
+	// connected to will have all of their samples marked as unreachable.
+	sampled := make(map[types.FileContractID]struct{})
+	for _, s := range samples {
+		sampled[s.contract] = struct{}{}
+	}
+	downloaders := make(map[types.FileContractID]contractor.Downloader)
+	for _, c := range r.hostContractor.Contracts() {
+		if _, ok := sampled[c.ID]; !ok {
+			continue
+		}
+		if _, ok := downloaders[c.ID]; ok {
+			continue
+		}
+		d, err := r.hostContractor.Downloader(c)
+		if err != nil {
+			continue
+		}
+		defer d.Close()
+		downloaders[c.ID] = d
+	}
+
+	report, results := verifySamples(samples, downloaders)
+	report.SiaPath = name
+	return report, results, nil
+}
+
+// removePieces removes the sampled pieces from the file's contracts, so that
+// the repair loop will treat them as missing and upload them again.
+func (f *file) removePieces(samples []pieceSample) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range samples {
+		fc, ok := f.contracts[s.contract]
+		if !ok {
+			continue
+		}
+		for i, p := range fc.Pieces {
+			if p == s.piece {
+				fc.Pieces = append(fc.Pieces[:i], fc.Pieces[i+1:]...)
+				break
+			}
+		}
+		f.contracts[s.contract] = fc
+	}
+}
+
+// markSuspect records a failed sample of a piece that could not be fetched,
+// and returns true once the piece has failed availabilitySuspectLimit checks
+// in a row.
+func (r *Renter) markSuspect(s pieceSample) bool {
+	r.suspectPieces[s]++
+	if r.suspectPieces[s] < availabilitySuspectLimit {
+		return false
+	}
+	delete(r.suspectPieces, s)
+	return true
+}
+
+// managedCheckAvailability samples the availability of a tracked file. Pieces
+// that do not match their Merkle root are removed right away, feeding them to
+// the repair loop. Pieces that cannot be fetched are only marked as suspect,
+// as the host may be offline briefly, and are removed once they have failed
+// availabilitySuspectLimit checks in a row.
+func (r *Renter) managedCheckAvailability(name string) {
+	report, results, err := r.managedSampleAvailability(name)
+	if err == errNoPiecesToSample {
+		return
+	} else if err != nil {
+		r.log.Printf("could not sample availability of %v: %v", name, err)
+		return
+	}
+
+	lockID := r.mu.Lock()
+	for _, s := range results.available {
+		delete(r.suspectPieces, s)
+	}
+	remove := results.corrupt
+	for _, s := range results.unreachable {
+		if r.markSuspect(s) {
+			remove = append(remove, s)
+		}
+	}
+	f, exists := r.files[name]
+	r.mu.Unlock(lockID)
+	if len(results.corrupt)+len(results.unreachable) > 0 {
+		r.log.Printf("%v of %v sampled pieces of %v are unavailable, failed hosts: %v", len(results.corrupt)+len(results.unreachable), report.PiecesSampled, name, report.FailedHosts)
+	}
+	if !exists || len(remove) == 0 {
+		return
+	}
+
+	f.removePieces(remove)
+	r.fileUpdates.notify()
+	f.mu.RLock()
+	err = r.saveFile(f)
+	f.mu.RUnlock()
+	if err != nil {
+		r.log.Printf("failed to save %v after availability sampling: %v", name, err)
+	}
+}
+
+// SetAvailabilitySampling sets whether the repair loop periodically samples
+// the availability of the tracked files. Sampling downloads pieces from the
+// hosts, which costs money, so it is disabled by default.
+func (r *Renter) SetAvailabilitySampling(enabled bool) error {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	r.availabilitySampling = enabled
+	return r.saveSync()
+}
+
+// VerifyFileAvailability fetches a small random sample of a file's pieces from
+// the hosts storing them and checks each piece against its Merkle root. The
+// report contains the fraction of sampled pieces that were retrieved
+// correctly and the hosts that failed to provide their pieces.
+func (r *Renter) VerifyFileAvailability(name string) (modules.AvailabilityReport, error) {
+	report, _, err := r.managedSampleAvailability(name)
+	return report, err
+}
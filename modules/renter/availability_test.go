@@ -0,0 +1,137 @@
+package renter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules/renter/contractor"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// sectorDownloader is a mocked contractor.Downloader that serves sectors from
+// a map.
+type sectorDownloader struct {
+	sectors map[crypto.Hash][]byte
+}
+
+// Sector returns the sector with the given Merkle root, if it exists.
+func (sd sectorDownloader) Sector(root crypto.Hash) ([]byte, error) {
+	data, ok := sd.sectors[root]
+	if !ok {
+		return nil, errors.New("no sector with that root")
+	}
+	return data, nil
+}
+
+// Close is a stub implementation of the Close method.
+func (sectorDownloader) Close() error { return nil }
+
+// TestVerifySamples tests that verifySamples counts the pieces that were
+// retrieved correctly, reports the hosts that failed, and tells unreachable
+// pieces apart from corrupt ones.
+func TestVerifySamples(t *testing.T) {
+	good := []byte("good sector")
+	bad := []byte("bad sector")
+	goodRoot := crypto.MerkleRoot(good)
+	badRoot := crypto.MerkleRoot(bad)
+
+	samples := []pieceSample{
+		{contract: types.FileContractID{1}, host: "foo", piece: pieceData{Chunk: 0, Piece: 0, MerkleRoot: goodRoot}},
+		{contract: types.FileContractID{1}, host: "foo", piece: pieceData{Chunk: 1, Piece: 0, MerkleRoot: goodRoot}},
+		{contract: types.FileContractID{2}, host: "bar", piece: pieceData{Chunk: 0, Piece: 1, MerkleRoot: badRoot}},
+		{contract: types.FileContractID{3}, host: "baz", piece: pieceData{Chunk: 0, Piece: 2, MerkleRoot: goodRoot}},
+	}
+	downloaders := map[types.FileContractID]contractor.Downloader{
+		// foo serves the correct data.
+		{1}: sectorDownloader{sectors: map[crypto.Hash][]byte{goodRoot: good}},
+		// bar serves data that does not match the Merkle root.
+		{2}: sectorDownloader{sectors: map[crypto.Hash][]byte{badRoot: good}},
+		// baz could not be connected to.
+	}
+
+	report, results := verifySamples(samples, downloaders)
+	if report.PiecesSampled != 4 || report.PiecesAvailable != 2 {
+		t.Fatalf("expected 2 of 4 pieces to be available, got %v of %v", report.PiecesAvailable, report.PiecesSampled)
+	}
+	if report.Availability != 0.5 {
+		t.Error("expected availability of 0.5, got", report.Availability)
+	}
+	if len(report.FailedHosts) != 2 || report.FailedHosts[0] != "bar" || report.FailedHosts[1] != "baz" {
+		t.Error("wrong failed hosts:", report.FailedHosts)
+	}
+	if len(results.available) != 2 || results.available[0] != samples[0] || results.available[1] != samples[1] {
+		t.Error("wrong available samples:", results.available)
+	}
+	if len(results.corrupt) != 1 || results.corrupt[0] != samples[2] {
+		t.Error("wrong corrupt samples:", results.corrupt)
+	}
+	if len(results.unreachable) != 1 || results.unreachable[0] != samples[3] {
+		t.Error("wrong unreachable samples:", results.unreachable)
+	}
+}
+
+// TestRemovePieces tests that pieces which failed sampling are removed from
+// the file, causing them to be reported as incomplete.
+func TestRemovePieces(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	f := &file{
+		size:        1,
+		erasureCode: rsc,
+		pieceSize:   1,
+		contracts: map[types.FileContractID]fileContract{
+			{0}: {
+				ID: types.FileContractID{0},
+				IP: "foo",
+				Pieces: []pieceData{
+					{Chunk: 0, Piece: 0},
+				},
+			},
+			{1}: {
+				ID: types.FileContractID{1},
+				IP: "bar",
+				Pieces: []pieceData{
+					{Chunk: 0, Piece: 1},
+				},
+			},
+		},
+	}
+	if len(f.incompleteChunks()) != 0 {
+		t.Fatal("file should be complete")
+	}
+
+	samples, err := f.availabilitySamples(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatal("expected every piece to be sampled, got", len(samples))
+	}
+
+	f.removePieces([]pieceSample{{contract: types.FileContractID{1}, host: "bar", piece: pieceData{Chunk: 0, Piece: 1}}})
+	incomplete := f.incompleteChunks()
+	if len(incomplete) != 1 || len(incomplete[0]) != 1 || incomplete[0][0] != 1 {
+		t.Error("removed piece was not reported as incomplete:", incomplete)
+	}
+	if len(f.contracts[types.FileContractID{0}].Pieces) != 1 {
+		t.Error("piece of a different contract was removed")
+	}
+}
+
+// TestMarkSuspect tests that a piece which cannot be fetched is only reported
+// for removal after failing availabilitySuspectLimit checks in a row.
+func TestMarkSuspect(t *testing.T) {
+	r := &Renter{suspectPieces: make(map[pieceSample]int)}
+	s := pieceSample{contract: types.FileContractID{1}, host: "foo", piece: pieceData{Chunk: 0, Piece: 0}}
+	for i := 1; i < availabilitySuspectLimit; i++ {
+		if r.markSuspect(s) {
+			t.Fatalf("piece was removed after %v failed checks", i)
+		}
+	}
+	if !r.markSuspect(s) {
+		t.Fatal("piece was not removed after reaching the suspect limit")
+	}
+	if _, exists := r.suspectPieces[s]; exists {
+		t.Error("removed piece is still marked as suspect")
+	}
+}
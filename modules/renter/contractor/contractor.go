@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -16,6 +17,7 @@ var (
 	errNilCS           = errors.New("cannot create contractor with nil consensus set")
 	errNilWallet       = errors.New("cannot create contractor with nil wallet")
 	errNilTpool        = errors.New("cannot create contractor with nil transaction pool")
+	errHostBackoff     = errors.New("host asked us to wait before forming another contract")
 	errUnknownContract = errors.New("no record of that contract")
 )
 
@@ -46,6 +48,7 @@ type Contractor struct {
 	blockHeight   types.BlockHeight
 	cachedAddress types.UnlockHash // to prevent excessive address creation
 	contracts     map[types.FileContractID]Contract
+	hostBackoff   map[modules.NetAddress]time.Time // hosts that asked us to retry later
 	lastChange    modules.ConsensusChangeID
 	renewHeight   types.BlockHeight // height at which to renew contracts
 
@@ -177,7 +180,8 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, d
 		tpool:   tp,
 		wallet:  w,
 
-		contracts: make(map[types.FileContractID]Contract),
+		contracts:   make(map[types.FileContractID]Contract),
+		hostBackoff: make(map[modules.NetAddress]time.Time),
 	}
 
 	// Load the prior persistance structures.
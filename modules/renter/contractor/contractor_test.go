@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -184,3 +185,27 @@ func TestWalletBridge(t *testing.T) {
 		t.Error("StartTransaction was not called on the shim")
 	}
 }
+
+// TestNewContractBackoff tests that newContract refuses to negotiate with
+// hosts that have asked the contractor to retry later, and that expired
+// requests are forgotten.
+func TestNewContractBackoff(t *testing.T) {
+	c := &Contractor{
+		hostBackoff: map[modules.NetAddress]time.Time{
+			"foo": time.Now().Add(time.Hour),
+			"bar": time.Now().Add(-time.Hour),
+		},
+	}
+	var host modules.HostDBEntry
+	host.NetAddress = "foo"
+	_, err := c.newContract(host, modules.SectorSize, 100)
+	if err != errHostBackoff {
+		t.Fatal("expected errHostBackoff, got", err)
+	}
+	if _, exists := c.hostBackoff["bar"]; exists {
+		t.Error("expired backoff was not removed")
+	}
+	if _, exists := c.hostBackoff["foo"]; !exists {
+		t.Error("backoff was removed before it expired")
+	}
+}
@@ -28,13 +28,13 @@ type (
 		StartTransaction() transactionBuilder
 	}
 	transactionBuilder interface {
-		AddArbitraryData([]byte) uint64
-		AddFileContract(types.FileContract) uint64
-		AddMinerFee(types.Currency) uint64
-		AddParents([]types.Transaction)
-		AddSiacoinInput(types.SiacoinInput) uint64
-		AddSiacoinOutput(types.SiacoinOutput) uint64
-		AddTransactionSignature(types.TransactionSignature) uint64
+		AddArbitraryData([]byte) (uint64, error)
+		AddFileContract(types.FileContract) (uint64, error)
+		AddMinerFee(types.Currency) (uint64, error)
+		AddParents([]types.Transaction) error
+		AddSiacoinInput(types.SiacoinInput) (uint64, error)
+		AddSiacoinOutput(types.SiacoinOutput) (uint64, error)
+		AddTransactionSignature(types.TransactionSignature) (uint64, error)
 		Drop()
 		FundSiacoins(types.Currency) error
 		Sign(bool) ([]types.Transaction, error)
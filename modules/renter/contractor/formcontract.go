@@ -59,10 +59,14 @@ func formContract(conn net.Conn, host modules.HostDBEntry, fc types.FileContract
 	if err != nil {
 		return Contract{}, modules.WriteNegotiationRejection(conn, errors.New("failed to fund transaction: "+err.Error()))
 	}
-	txnBuilder.AddFileContract(fc)
+	if _, err := txnBuilder.AddFileContract(fc); err != nil {
+		return Contract{}, modules.WriteNegotiationRejection(conn, errors.New("failed to add file contract: "+err.Error()))
+	}
 
 	// add miner fee
-	txnBuilder.AddMinerFee(fee)
+	if _, err := txnBuilder.AddMinerFee(fee); err != nil {
+		return Contract{}, modules.WriteNegotiationRejection(conn, errors.New("failed to add miner fee: "+err.Error()))
+	}
 
 	// create the txn
 	txn, parentTxns := txnBuilder.View()
@@ -81,6 +85,11 @@ func formContract(conn net.Conn, host modules.HostDBEntry, fc types.FileContract
 
 	// read acceptance and txn signed by host
 	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		// pass structured rejections through unchanged so that the caller
+		// can honor the host's retry-after hint
+		if _, ok := err.(modules.NegotiationRejection); ok {
+			return Contract{}, err
+		}
 		return Contract{}, errors.New("host did not accept our proposed contract: " + err.Error())
 	}
 	// host now sends any new parent transactions, inputs and outputs that
@@ -99,12 +108,18 @@ func formContract(conn net.Conn, host modules.HostDBEntry, fc types.FileContract
 	}
 
 	// merge txnAdditions with txnSet
-	txnBuilder.AddParents(newParents)
+	if err := txnBuilder.AddParents(newParents); err != nil {
+		return Contract{}, modules.WriteNegotiationRejection(conn, errors.New("failed to add the host's parents: "+err.Error()))
+	}
 	for _, input := range newInputs {
-		txnBuilder.AddSiacoinInput(input)
+		if _, err := txnBuilder.AddSiacoinInput(input); err != nil {
+			return Contract{}, modules.WriteNegotiationRejection(conn, errors.New("failed to add the host's inputs: "+err.Error()))
+		}
 	}
 	for _, output := range newOutputs {
-		txnBuilder.AddSiacoinOutput(output)
+		if _, err := txnBuilder.AddSiacoinOutput(output); err != nil {
+			return Contract{}, modules.WriteNegotiationRejection(conn, errors.New("failed to add the host's outputs: "+err.Error()))
+		}
 	}
 
 	// sign the txn
@@ -172,7 +187,9 @@ func formContract(conn net.Conn, host modules.HostDBEntry, fc types.FileContract
 		return Contract{}, errors.New("couldn't read the host's signatures: " + err.Error())
 	}
 	for _, sig := range hostSigs {
-		txnBuilder.AddTransactionSignature(sig)
+		if _, err := txnBuilder.AddTransactionSignature(sig); err != nil {
+			return Contract{}, errors.New("couldn't add the host's signatures: " + err.Error())
+		}
 	}
 	var hostRevisionSig types.TransactionSignature
 	if err := encoding.ReadObject(conn, &hostRevisionSig, 2e3); err != nil {
@@ -218,6 +235,21 @@ func (c *Contractor) newContract(host modules.HostDBEntry, filesize uint64, endH
 		return Contract{}, errTooExpensive
 	}
 
+	// skip hosts that have asked us to back off. Expired requests are
+	// forgotten, so that the hosts we stop contacting do not accumulate.
+	c.mu.Lock()
+	now := time.Now()
+	for addr, retryAt := range c.hostBackoff {
+		if !now.Before(retryAt) {
+			delete(c.hostBackoff, addr)
+		}
+	}
+	_, backoff := c.hostBackoff[host.NetAddress]
+	c.mu.Unlock()
+	if backoff {
+		return Contract{}, errHostBackoff
+	}
+
 	// get an address to use for negotiation
 	c.mu.Lock()
 	if c.cachedAddress == (types.UnlockHash{}) {
@@ -303,10 +335,16 @@ func (c *Contractor) newContract(host modules.HostDBEntry, filesize uint64, endH
 	contract, err := formContract(conn, host, fc, txnBuilder, c.tpool, renterCost)
 	if err != nil {
 		txnBuilder.Drop() // return unused outputs to wallet
+		if rej, ok := err.(modules.NegotiationRejection); ok && rej.RetryAfter > 0 {
+			c.mu.Lock()
+			c.hostBackoff[host.NetAddress] = time.Now().Add(rej.RetryAfter)
+			c.mu.Unlock()
+		}
 		return Contract{}, err
 	}
 
 	c.mu.Lock()
+	delete(c.hostBackoff, host.NetAddress)
 	c.contracts[contract.ID] = contract
 	c.cachedAddress = types.UnlockHash{} // clear the cached address
 	c.saveSync()
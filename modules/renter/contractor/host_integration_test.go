@@ -22,7 +22,7 @@ import (
 
 // newTestingWallet is a helper function that creates a ready-to-use wallet
 // and mines some coins into it.
-func newTestingWallet(testdir string, cs modules.ConsensusSet, tp modules.TransactionPool) (modules.Wallet, error) {
+func newTestingWallet(testdir string, g modules.Gateway, cs modules.ConsensusSet, tp modules.TransactionPool) (modules.Wallet, error) {
 	w, err := modWallet.New(cs, tp, filepath.Join(testdir, modules.WalletDir))
 	if err != nil {
 		return nil, err
@@ -42,7 +42,7 @@ func newTestingWallet(testdir string, cs modules.ConsensusSet, tp modules.Transa
 		return nil, err
 	}
 	// give it some money
-	m, err := miner.New(cs, tp, w, filepath.Join(testdir, modules.MinerDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(testdir, modules.MinerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +56,8 @@ func newTestingWallet(testdir string, cs modules.ConsensusSet, tp modules.Transa
 }
 
 // newTestingHost is a helper function that creates a ready-to-use host.
-func newTestingHost(testdir string, cs modules.ConsensusSet, tp modules.TransactionPool) (modules.Host, error) {
-	w, err := newTestingWallet(testdir, cs, tp)
+func newTestingHost(testdir string, g modules.Gateway, cs modules.ConsensusSet, tp modules.TransactionPool) (modules.Host, error) {
+	w, err := newTestingWallet(testdir, g, cs, tp)
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +66,8 @@ func newTestingHost(testdir string, cs modules.ConsensusSet, tp modules.Transact
 
 // newTestingContractor is a helper function that creates a ready-to-use
 // contractor.
-func newTestingContractor(testdir string, cs modules.ConsensusSet, tp modules.TransactionPool) (*Contractor, error) {
-	w, err := newTestingWallet(testdir, cs, tp)
+func newTestingContractor(testdir string, g modules.Gateway, cs modules.ConsensusSet, tp modules.TransactionPool) (*Contractor, error) {
+	w, err := newTestingWallet(testdir, g, cs, tp)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +92,7 @@ func newTestingTrio(name string) (modules.Host, *Contractor, modules.TestMiner,
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -114,17 +114,17 @@ func newTestingTrio(name string) (modules.Host, *Contractor, modules.TestMiner,
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	m, err := miner.New(cs, tp, w, filepath.Join(testdir, modules.MinerDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(testdir, modules.MinerDir))
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
 	// create host and contractor, using same consensus set and gateway
-	h, err := newTestingHost(filepath.Join(testdir, "Host"), cs, tp)
+	h, err := newTestingHost(filepath.Join(testdir, "Host"), g, cs, tp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	c, err := newTestingContractor(filepath.Join(testdir, "Contractor"), cs, tp)
+	c, err := newTestingContractor(filepath.Join(testdir, "Contractor"), g, cs, tp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
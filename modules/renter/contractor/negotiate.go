@@ -42,16 +42,15 @@ func verifySettings(conn net.Conn, host modules.HostDBEntry, hdb hostDB) (module
 
 // startRevision is run at the beginning of each revision iteration. It reads
 // the host's settings confirms that the values are acceptable, and writes an acceptance.
+// Existing contracts can be revised even if the host is not accepting new
+// contracts.
 func startRevision(conn net.Conn, host modules.HostDBEntry, hdb hostDB) error {
 	// verify the host's settings and confirm its identity
 	// TODO: return new host, so we can calculate price accurately
-	recvSettings, err := verifySettings(conn, host, hdb)
+	_, err := verifySettings(conn, host, hdb)
 	if err != nil {
 		// TODO: doesn't make sense to reject here if the err is an I/O error.
 		return modules.WriteNegotiationRejection(conn, err)
-	} else if !recvSettings.AcceptingContracts {
-		// no need to reject; host will already have disconnected at this point
-		return errors.New("host is not accepting contracts")
 	}
 	return modules.WriteNegotiationAcceptance(conn)
 }
@@ -1,6 +1,7 @@
 package contractor
 
 import (
+	"net"
 	"path/filepath"
 	"testing"
 
@@ -50,7 +51,7 @@ func newContractorTester(name string) (*contractorTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	tp, err := transactionpool.New(cs, g)
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +75,7 @@ func newContractorTester(name string) (*contractorTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	m, err := miner.New(cs, tp, w, filepath.Join(testdir, modules.MinerDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(testdir, modules.MinerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -266,3 +267,42 @@ func TestReviseContract(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestStartRevisionNotAccepting checks that a contract can still be revised
+// with a host that is not accepting new contracts.
+func TestStartRevisionNotAccepting(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := modules.HostDBEntry{
+		HostExternalSettings: modules.HostExternalSettings{NetAddress: "foo.com:1234"},
+		PublicKey:            types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: pk[:]},
+	}
+
+	hostConn, renterConn := net.Pipe()
+	defer hostConn.Close()
+	defer renterConn.Close()
+	accepted := make(chan error)
+	go func() {
+		settings := host.HostExternalSettings
+		settings.AcceptingContracts = false
+		err := crypto.WriteSignedObject(hostConn, settings, sk)
+		if err != nil {
+			accepted <- err
+			return
+		}
+		accepted <- modules.ReadNegotiationAcceptance(hostConn)
+	}()
+	err = startRevision(renterConn, host, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = <-accepted
+	if err != nil {
+		t.Fatal("renter did not accept the settings of the host:", err)
+	}
+}
@@ -169,6 +169,46 @@ func newFile(name string, code modules.ErasureCoder, pieceSize, fileSize uint64)
 	}
 }
 
+// fileUpdateNotifier wakes callers that are waiting for the upload progress or
+// status of a file to change. An update that happens while nobody is waiting
+// is remembered and reported to the next caller, so that an update between
+// two calls to wait is not lost. The zero value is ready to use.
+type fileUpdateNotifier struct {
+	ch      chan struct{}
+	pending bool
+	mu      sync.Mutex
+}
+
+// notify wakes every caller that is waiting for a file update.
+func (fun *fileUpdateNotifier) notify() {
+	fun.mu.Lock()
+	defer fun.mu.Unlock()
+	if fun.ch == nil {
+		fun.pending = true
+		return
+	}
+	close(fun.ch)
+	fun.ch = nil
+}
+
+// wait returns a channel that is closed at the next file update. If there was
+// an update that no caller has been woken for yet, the channel is already
+// closed.
+func (fun *fileUpdateNotifier) wait() <-chan struct{} {
+	fun.mu.Lock()
+	defer fun.mu.Unlock()
+	if fun.pending {
+		fun.pending = false
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	if fun.ch == nil {
+		fun.ch = make(chan struct{})
+	}
+	return fun.ch
+}
+
 // DeleteFile removes a file entry from the renter and deletes its data from
 // the hosts it is stored on.
 func (r *Renter) DeleteFile(nickname string) error {
@@ -182,6 +222,7 @@ func (r *Renter) DeleteFile(nickname string) error {
 	os.RemoveAll(filepath.Join(r.persistDir, f.name+ShareExtension))
 	r.saveSync()
 	r.mu.Unlock(lockID)
+	r.fileUpdates.notify()
 
 	// delete the file's associated contract data.
 	f.mu.Lock()
@@ -231,6 +272,13 @@ func (r *Renter) FileList() []modules.FileInfo {
 	return files
 }
 
+// FileUpdates returns a channel that is closed the next time that a file is
+// added, removed or renamed, or that the upload progress or tracking status
+// of a file changes.
+func (r *Renter) FileUpdates() <-chan struct{} {
+	return r.fileUpdates.wait()
+}
+
 // RenameFile takes an existing file and changes the nickname. The original
 // file must exist, and there must not be any file that already has the
 // replacement nickname.
@@ -260,6 +308,7 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 	// Update the entries in the renter.
 	delete(r.files, currentName)
 	r.files[newName] = file
+	r.fileUpdates.notify()
 	err = r.saveSync()
 	if err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -339,3 +340,57 @@ func TestRenterRenameFile(t *testing.T) {
 		t.Error("Expecting ErrPathOverload, got", err)
 	}
 }
+
+// TestRenterFileUpdates checks that FileUpdates wakes its callers when a file
+// changes.
+func TestRenterFileUpdates(t *testing.T) {
+	rt, err := newRenterTester("TestRenterFileUpdates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	f := newTestingFile()
+	f.name = "1"
+	rt.renter.files["1"] = f
+
+	// Nothing has changed, so the channel should not be closed.
+	update := rt.renter.FileUpdates()
+	select {
+	case <-update:
+		t.Fatal("file update reported before any file changed")
+	default:
+	}
+
+	// Renaming the file should close the channel, and later callers should
+	// receive a new channel.
+	err = rt.renter.RenameFile("1", "1a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-update:
+	case <-time.After(time.Second):
+		t.Fatal("renaming a file did not report a file update")
+	}
+	select {
+	case <-rt.renter.FileUpdates():
+		t.Fatal("file update reported before the file changed again")
+	default:
+	}
+
+	// An update that happens while nobody is waiting is reported to the next
+	// caller, once.
+	var fun fileUpdateNotifier
+	fun.notify()
+	select {
+	case <-fun.wait():
+	default:
+		t.Error("update without a waiter was lost")
+	}
+	select {
+	case <-fun.wait():
+		t.Error("update without a waiter was reported twice")
+	default:
+	}
+}
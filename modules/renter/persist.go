@@ -181,16 +181,18 @@ func (r *Renter) saveFile(f *file) error {
 // save stores the current renter data to disk.
 func (r *Renter) save() error {
 	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
+		Tracking             map[string]trackedFile
+		AvailabilitySampling bool
+	}{r.tracking, r.availabilitySampling}
 	return persist.SaveFile(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
 }
 
 // saveSync stores the current renter data to disk and then syncs to disk.
 func (r *Renter) saveSync() error {
 	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
+		Tracking             map[string]trackedFile
+		AvailabilitySampling bool
+	}{r.tracking, r.availabilitySampling}
 	return persist.SaveFileSync(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
 }
 
@@ -232,8 +234,9 @@ func (r *Renter) load() error {
 
 	// Load contracts, repair set, and entropy.
 	data := struct {
-		Tracking  map[string]trackedFile
-		Repairing map[string]string // COMPATv0.4.8
+		Tracking             map[string]trackedFile
+		Repairing            map[string]string // COMPATv0.4.8
+		AvailabilitySampling bool
 	}{}
 	err = persist.LoadFile(saveMetadata, &data, filepath.Join(r.persistDir, PersistFilename))
 	if err != nil {
@@ -242,6 +245,7 @@ func (r *Renter) load() error {
 	if data.Tracking != nil {
 		r.tracking = data.Tracking
 	}
+	r.availabilitySampling = data.AvailabilitySampling
 
 	return nil
 }
@@ -388,6 +392,7 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		r.files[f.name] = f
 		names[i] = f.name
 	}
+	r.fileUpdates.notify()
 	// Save the files.
 	for _, f := range files {
 		r.saveFile(f)
@@ -79,6 +79,16 @@ type Renter struct {
 	tracking      map[string]trackedFile // map from nickname to metadata
 	downloadQueue []*download
 
+	// fileUpdates notifies callers of FileUpdates when the upload progress or
+	// status of a file changes.
+	fileUpdates fileUpdateNotifier
+
+	// availabilitySampling enables the periodic availability checks of the
+	// repair loop. suspectPieces counts the consecutive checks in which a
+	// piece could not be fetched.
+	availabilitySampling bool
+	suspectPieces        map[pieceSample]int
+
 	// constants
 	persistDir string
 
@@ -105,6 +115,8 @@ func New(cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.Transacti
 		files:    make(map[string]*file),
 		tracking: make(map[string]trackedFile),
 
+		suspectPieces: make(map[pieceSample]int),
+
 		persistDir: persistDir,
 		mu:         sync.New(modules.SafeMutexDelay, 1),
 	}
@@ -183,6 +183,7 @@ func (f *file) offlineChunks(hdb hostDB) map[uint64][]uint64 {
 // reuploading their missing pieces. Multiple repair attempts may be necessary
 // before the file reaches full redundancy.
 func (r *Renter) threadedRepairLoop() {
+	var lastAvailabilityCheck time.Time
 	for {
 		time.Sleep(5 * time.Second)
 
@@ -203,6 +204,19 @@ func (r *Renter) threadedRepairLoop() {
 		}
 		r.mu.RUnlock(id)
 
+		// If enabled, periodically sample the availability of the tracked
+		// files. Pieces that fail verification are removed, so that they are
+		// repaired below.
+		id = r.mu.RLock()
+		sampling := r.availabilitySampling
+		r.mu.RUnlock(id)
+		if sampling && time.Since(lastAvailabilityCheck) > availabilityCheckInterval {
+			for name := range repairing {
+				r.managedCheckAvailability(name)
+			}
+			lastAvailabilityCheck = time.Now()
+		}
+
 		// create host pool
 		pool := r.newHostPool()
 		for name, meta := range repairing {
@@ -220,6 +234,7 @@ func (r *Renter) threadedRepairFile(name string, meta trackedFile, pool *hostPoo
 		id := r.mu.Lock()
 		delete(r.tracking, name)
 		r.mu.Unlock(id)
+		r.fileUpdates.notify()
 	}
 
 	id := r.mu.RLock()
@@ -263,6 +278,8 @@ func (r *Renter) repairChunks(f *file, handle io.ReaderAt, chunks map[uint64][]u
 		}
 		// upload to new hosts
 		err := f.repair(chunk, pieces, handle, hosts)
+		// Pieces may have been uploaded even if the repair was aborted.
+		r.fileUpdates.notify()
 		if err != nil {
 			r.log.Printf("aborting repair of %v: %v", f.name, err)
 			return
@@ -80,6 +80,7 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	}
 	r.saveSync()
 	r.mu.Unlock(lockID)
+	r.fileUpdates.notify()
 
 	// Save the .sia file to the renter directory.
 	err = r.saveFile(f)
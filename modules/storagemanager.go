@@ -29,6 +29,10 @@ type (
 		FailedWrites     uint64 `json:"failedwrites"`
 		SuccessfulReads  uint64 `json:"successfulreads"`
 		SuccessfulWrites uint64 `json:"successfulwrites"`
+
+		// SectorsToMove is the number of sectors that are still waiting to be
+		// moved out of the storage folder by MoveSectors.
+		SectorsToMove uint64 `json:"sectorstomove"`
 	}
 
 	// A StorageManager is responsible for managing storage folders and
@@ -54,6 +58,12 @@ type (
 		// The storage manager needs to be able to shut down.
 		Close() error
 
+		// DeduplicationStats returns the number of physical sectors held by
+		// the storage manager, the number of virtual sectors referencing
+		// them, and the number of bytes saved by storing each shared sector
+		// only once.
+		DeduplicationStats() (uniqueSectors, totalReferences, bytesSaved uint64)
+
 		// DeleteSector deletes a sector, meaning that the manager will be
 		// unable to upload that sector and be unable to provide a storage
 		// proof on that sector. DeleteSector is for removing the data
@@ -62,6 +72,12 @@ type (
 		// requests to remove data.
 		DeleteSector(sectorRoot crypto.Hash) error
 
+		// MoveSectors moves all of the sectors in the storage folder at
+		// 'fromFolder' to the storage folder at 'toFolder', one sector at a
+		// time. Sectors that fail to move are reported in the returned error
+		// and stay in the source folder.
+		MoveSectors(fromFolder, toFolder string) error
+
 		// ReadSector will read a sector from the storage manager, returning the
 		// bytes that match the input sector root.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
@@ -81,6 +97,14 @@ type (
 		// storage folder.
 		ResetStorageFolderHealth(index int) error
 
+		// SetFaultInjection will cause the named filesystem operations of the
+		// manager to randomly fail with the given probability, exercising the
+		// error recovery of a running node. Valid operations are "loadFile",
+		// "mkdirAll", "newLogger", "openDatabase", "readFile", "removeFile",
+		// "symlink" and "writeFile". A probability of zero disables fault
+		// injection.
+		SetFaultInjection(probability float64, ops []string) error
+
 		// ResizeStorageFolder will grow or shrink a storage folder in the
 		// manager. The manager may not check that there is enough space
 		// on-disk to support growing the storage folder, but should gracefully
@@ -2,7 +2,12 @@ package modules
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -19,6 +24,11 @@ const (
 )
 
 var (
+	// ErrContextCancelled is the error that gets returned if the context
+	// provided to AcceptTransactionSetWithContext is cancelled before the
+	// transaction set has been added to the transaction pool.
+	ErrContextCancelled = errors.New("transaction set submission was cancelled")
+
 	// ErrDuplicateTransactionSet is the error that gets returned if a
 	// duplicate transaction set is given to the transaction pool.
 	ErrDuplicateTransactionSet = errors.New("transaction set contains only duplicate transaction")
@@ -51,6 +61,72 @@ var (
 	TransactionPoolDir = "transactionpool"
 )
 
+const (
+	// TxStatusUnknown indicates that the transaction pool has not seen the
+	// transaction, or that it has not seen it recently.
+	TxStatusUnknown TxStatus = iota
+
+	// TxStatusUnconfirmed indicates that the transaction is in the
+	// transaction pool, waiting to be put into a block.
+	TxStatusUnconfirmed
+
+	// TxStatusConfirmed indicates that the transaction appeared in one of
+	// the recent blocks of the current blockchain.
+	TxStatusConfirmed
+
+	// TxStatusConflicted indicates that the transaction was recently removed
+	// from the transaction pool because it conflicts with a confirmed
+	// transaction, or because it was replaced by a transaction that pays a
+	// higher fee.
+	TxStatusConflicted
+)
+
+// TxStatus is the status of a transaction as seen by the transaction pool.
+type TxStatus int
+
+// A FeeBucket reports the total encoded size of the unconfirmed transaction
+// sets in the transaction pool that pay a fee per byte of at least
+// MinFeePerByte and less than MaxFeePerByte.
+type FeeBucket struct {
+	MinFeePerByte types.Currency `json:"minfeeperbyte"`
+	MaxFeePerByte types.Currency `json:"maxfeeperbyte"`
+	Size          uint64         `json:"size"`
+}
+
+// A TpoolGraphTransaction lists the transactions in the transaction pool that
+// a transaction spends objects from, and that spend objects created by it.
+type TpoolGraphTransaction struct {
+	ID       types.TransactionID   `json:"id"`
+	Parents  []types.TransactionID `json:"parents"`
+	Children []types.TransactionID `json:"children"`
+}
+
+// A TpoolGraphSet lists the transaction sets in the transaction pool that a
+// transaction set depends on, and that depend on it, along with the
+// dependencies of each transaction in the set. A set depends on another set if
+// it spends an object created by the other set.
+type TpoolGraphSet struct {
+	SetID        crypto.Hash             `json:"setid"`
+	Parents      []crypto.Hash           `json:"parents"`
+	Children     []crypto.Hash           `json:"children"`
+	Transactions []TpoolGraphTransaction `json:"transactions"`
+}
+
+// A TpoolGraph describes the dependencies between the transaction sets in the
+// transaction pool.
+type TpoolGraph struct {
+	Sets []TpoolGraphSet `json:"sets"`
+}
+
+// TpoolMetrics counts the transaction sets that have been submitted to the
+// transaction pool, grouped by the outcome of the submission.
+type TpoolMetrics struct {
+	AcceptedSets  uint64 `json:"acceptedsets"`
+	ConflictSets  uint64 `json:"conflictsets"`
+	DuplicateSets uint64 `json:"duplicatesets"`
+	LowFeeSets    uint64 `json:"lowfeesets"`
+}
+
 // A TransactionPoolSubscriber receives updates about the confirmed and
 // unconfirmed set from the transaction pool. Generally, there is no need to
 // subscribe to both the consensus set and the transaction pool.
@@ -67,6 +143,24 @@ type TransactionPool interface {
 	// transactions.
 	AcceptTransactionSet([]types.Transaction) error
 
+	// AcceptTransactionSetWithContext accepts a set of potentially
+	// interdependent transactions, aborting with ErrContextCancelled if the
+	// context is cancelled first.
+	AcceptTransactionSetWithContext(context.Context, []types.Transaction) error
+
+	// Close saves the unconfirmed transaction sets to disk and shuts down the
+	// transaction pool. The saved sets are re-added, if they are still
+	// valid, when the transaction pool is next created.
+	Close() error
+
+	// DependencyGraph returns the parent and child transaction sets of every
+	// transaction set in the pool.
+	DependencyGraph() TpoolGraph
+
+	// ExportDependencyGraph returns a Graphviz DOT description of the
+	// unconfirmed transactions and the parent/child edges between them.
+	ExportDependencyGraph() ([]byte, error)
+
 	// FeeEstimation returns an estimation for how high the transaction fee
 	// needs to be per byte. The minimum recommended targets getting accepted
 	// in ~3 blocks, and the maximum recommended targets getting accepted
@@ -75,10 +169,29 @@ type TransactionPool interface {
 	// within 10 blocks.
 	FeeEstimation() (minimumRecommended, maximumRecommended types.Currency)
 
+	// FeeHistogram returns the distribution of the fees paid by the
+	// transaction sets in the pool, ordered from the lowest to the highest
+	// fee per byte.
+	FeeHistogram() []FeeBucket
+
 	// IsStandardTransaction returns `err = nil` if the transaction is
 	// standard, otherwise it returns an error explaining what is not standard.
 	IsStandardTransaction(types.Transaction) error
 
+	// Metrics returns the number of transaction sets that have been
+	// accepted, and the number that have been rejected for being duplicates,
+	// paying too little in fees, or conflicting with other transactions.
+	Metrics() TpoolMetrics
+
+	// MineableTransactions returns the unconfirmed transactions whose
+	// dependencies are all satisfied, and which would be valid in the next
+	// block.
+	MineableTransactions() []types.Transaction
+
+	// MinimumFee returns the lowest fee per byte that a transaction set must
+	// pay to be accepted into the transaction pool.
+	MinimumFee() types.Currency
+
 	// PurgeTransactionPool is a temporary function available to the miner. In
 	// the event that a miner mines an unacceptable block, the transaction pool
 	// will be purged to clear out the transaction pool and get rid of the
@@ -86,15 +199,96 @@ type TransactionPool interface {
 	// that make this condition necessary.
 	PurgeTransactionPool()
 
+	// RegisterWatchedAddresses adds addresses to the set of addresses that
+	// watched subscribers are notified about.
+	RegisterWatchedAddresses([]types.UnlockHash)
+
+	// RequiredFee returns the smallest total miner fee that a transaction
+	// set of 'size' encoded bytes containing 'numTransactions' transactions
+	// must pay to be accepted into the transaction pool right now.
+	RequiredFee(size int, numTransactions int) types.Currency
+
+	// RecommendedFee returns the minimum and the recommended miner fees for
+	// a typical transaction set.
+	RecommendedFee() (min types.Currency, recommended types.Currency)
+
+	// SetBroadcastCompression enables or disables compression of the
+	// transaction sets that are broadcast to peers that support it.
+	SetBroadcastCompression(enabled bool)
+
+	// SetMaxAge sets the maximum amount of time that a transaction set can
+	// remain in the transaction pool without being confirmed.
+	SetMaxAge(time.Duration)
+
+	// SetMaxArbitraryDataSize sets the largest number of bytes of arbitrary
+	// data that a transaction set can contain to be accepted into the
+	// transaction pool.
+	SetMaxArbitraryDataSize(n int)
+
+	// SetMaxTransactionSetSize sets the largest encoded size in bytes of a
+	// transaction set that the transaction pool will accept.
+	SetMaxTransactionSetSize(bytes int) error
+
+	// SetMinBroadcastVersion sets the lowest peer version that accepted
+	// transaction sets are broadcast to.
+	SetMinBroadcastVersion(v string) error
+
+	// SetMinimumFee sets the lowest fee per byte that a transaction set must
+	// pay to be accepted into the transaction pool.
+	SetMinimumFee(feePerByte types.Currency)
+
+	// SetRelayRateLimit sets the number of transaction sets that a single
+	// peer may relay to the pool per minute. Zero disables the limit.
+	SetRelayRateLimit(setsPerMinute int) error
+
+	// SetReplaceByFee enables or disables replacing transaction sets in the
+	// pool with double spending sets that pay a higher fee.
+	SetReplaceByFee(enabled bool)
+
 	// TransactionList returns a list of all transactions in the transaction
 	// pool. The transactions are provided in an order that can acceptably be
 	// put into a block.
 	TransactionList() []types.Transaction
 
+	// TransactionListByFee returns a list of all transactions in the
+	// transaction pool, with the transaction sets that pay the highest fee
+	// per byte first. Parents always precede their children.
+	TransactionListByFee() []types.Transaction
+
+	// TransactionPoolSize returns the total size in bytes of all transaction
+	// sets in the transaction pool, and the number of transaction sets.
+	TransactionPoolSize() (size int, count int)
+
+	// TransactionSets returns every transaction set in the transaction pool.
+	TransactionSets() [][]types.Transaction
+
+	// TransactionStatus returns whether the transaction with the given id is
+	// in the transaction pool, was confirmed in a recent block, was recently
+	// removed from the pool due to a conflict, or is unknown.
+	TransactionStatus(types.TransactionID) (TxStatus, error)
+
 	// TransactionPoolSubscribe adds a subscriber to the transaction pool.
 	// Subscribers will receive all consensus set changes as well as
 	// transaction pool changes, and should not subscribe to both.
 	TransactionPoolSubscribe(TransactionPoolSubscriber)
+
+	// TransactionPoolSubscribeWatched adds a subscriber to the transaction
+	// pool that only receives the unconfirmed transactions which spend from
+	// or pay to a watched address.
+	TransactionPoolSubscribeWatched(TransactionPoolSubscriber)
+
+	// Unsubscribe removes a subscriber from the transaction pool. If the
+	// subscriber is not subscribed, no action is taken.
+	Unsubscribe(TransactionPoolSubscriber)
+
+	// ValidateTransactionSet checks whether a transaction set would be
+	// accepted by AcceptTransactionSet, without adding it to the pool or
+	// broadcasting it.
+	ValidateTransactionSet([]types.Transaction) error
+
+	// UnregisterWatchedAddresses removes addresses from the set of watched
+	// addresses.
+	UnregisterWatchedAddresses([]types.UnlockHash)
 }
 
 // ConsensusConflict implements the error interface, and indicates that a
@@ -115,6 +309,24 @@ func (cc ConsensusConflict) Error() string {
 	return string(cc)
 }
 
+// ConflictError implements the error interface, and indicates that a
+// transaction set was rejected because it spends an object that is already
+// spent by a transaction in the transaction pool.
+type ConflictError struct {
+	// ObjectID is the id of the siacoin output, siafund output, or file
+	// contract that is spent by both transactions.
+	ObjectID crypto.Hash
+
+	// TransactionID is the id of the transaction in the transaction pool
+	// that spends the object.
+	TransactionID types.TransactionID
+}
+
+// Error implements the error interface.
+func (ce ConflictError) Error() string {
+	return fmt.Sprintf("transaction set conflicts with transaction %v in the transaction pool: both spend object %v", ce.TransactionID, ce.ObjectID)
+}
+
 // CalculateFee returns the fee-per-byte of a transaction set.
 func CalculateFee(ts []types.Transaction) types.Currency {
 	var sum types.Currency
@@ -5,8 +5,11 @@ package transactionpool
 
 import (
 	"errors"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
 
-	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
@@ -27,6 +30,31 @@ const (
 	// mostly to preserve compatibility with clients that do not add fees.
 	TransactionPoolSizeLimit  = 2e6 - 5e3 - modules.TransactionSetSizeLimit
 	TransactionPoolSizeForFee = 500e3
+
+	// estimatedTransactionSetSize and estimatedTransactionSetLen describe the
+	// typical transaction set that fee recommendations are made for. Funding
+	// a transaction through the wallet usually produces a parent
+	// transaction, so the typical set contains two transactions.
+	estimatedTransactionSetSize = 2e3
+	estimatedTransactionSetLen  = 2
+
+	// defaultMinBroadcastVersion is the lowest peer version that accepted
+	// transaction sets are broadcast to by default. v0.4.7-v0.5.1 broadcasted
+	// both transaction sets and individual transactions, and those versions
+	// act as a bridge between v0.5.2+ and older versions.
+	// COMPATv0.4.6
+	defaultMinBroadcastVersion = "0.4.7"
+
+	// defaultMaxArbitraryDataSize is the default limit on the number of bytes
+	// of arbitrary data that a transaction set can contain. It leaves room
+	// for several full size transactions worth of data, while keeping sets
+	// that are mostly data from filling a large part of the pool.
+	defaultMaxArbitraryDataSize = 64e3
+
+	// defaultMaxTransactionSetSize is the default limit on the encoded size of
+	// a transaction set. Like the arbitrary data limit, it matches the size
+	// limit of the IsStandard rules, so it only has an effect once lowered.
+	defaultMaxTransactionSetSize = modules.TransactionSetSizeLimit
 )
 
 var (
@@ -34,6 +62,15 @@ var (
 	errFullTransactionPool = errors.New("transaction pool cannot accept more transactions")
 	errLowMinerFees        = errors.New("transaction set needs more miner fees to be accepted")
 	errEmptySet            = errors.New("transaction set is empty")
+	errFeeTooLow           = errors.New("transaction set fee per byte is below the minimum fee of the transaction pool")
+	errSetNotFound         = errors.New("transaction set is not in the transaction pool")
+
+	errInvalidArbitraryData    = errors.New("transaction contains malformed arbitrary data under a reserved prefix")
+	errInvalidBroadcastVersion = errors.New("minimum broadcast version is not a valid version number")
+	errLargeArbitraryData      = errors.New("transaction set contains more arbitrary data than the transaction pool accepts")
+
+	errInvalidMaxTransactionSetSize = errors.New("maximum transaction set size must be positive")
+	errTransactionSetTooLarge       = errors.New("transaction set is larger than the transaction pool accepts")
 
 	TransactionMinFee = types.NewCurrency64(2).Mul(types.SiacoinPrecision)
 )
@@ -72,6 +109,17 @@ func relatedObjectIDs(ts []types.Transaction) []ObjectID {
 	return oids
 }
 
+// setFees returns the sum of the miner fees paid by a transaction set.
+func setFees(ts []types.Transaction) types.Currency {
+	var feeSum types.Currency
+	for i := range ts {
+		for _, fee := range ts[i].MinerFees {
+			feeSum = feeSum.Add(fee)
+		}
+	}
+	return feeSum
+}
+
 // checkMinerFees checks that the total amount of transaction fees in the
 // transaction set is sufficient to earn a spot in the transaction pool.
 func (tp *TransactionPool) checkMinerFees(ts []types.Transaction) error {
@@ -80,33 +128,75 @@ func (tp *TransactionPool) checkMinerFees(ts []types.Transaction) error {
 	if tp.transactionListSize > TransactionPoolSizeLimit {
 		return errFullTransactionPool
 	}
+	if setFees(ts).Cmp(tp.poolPressureFee(len(ts))) < 0 {
+		return errLowMinerFees
+	}
+	return nil
+}
 
-	// The first TransactionPoolSizeForFee transactions do not need fees.
-	if tp.transactionListSize > TransactionPoolSizeForFee {
-		// Currently required fees are set on a per-transaction basis. 2 coins
-		// are required per transaction if the free-fee limit has been reached,
-		// adding a larger fee is not useful.
-		var feeSum types.Currency
-		for i := range ts {
-			for _, fee := range ts[i].MinerFees {
-				feeSum = feeSum.Add(fee)
-			}
-		}
-		feeRequired := TransactionMinFee.Mul(types.NewCurrency64(uint64(len(ts))))
-		if feeSum.Cmp(feeRequired) < 0 {
-			return errLowMinerFees
-		}
+// checkMinimumFee checks that the transactions in 'ts' pay at least the
+// configured minimum fee per byte, regardless of how full the transaction
+// pool is. Only the transactions that are new to the pool are checked, so
+// that the children of a set that paid exactly the minimum fee are judged on
+// their own fees and size rather than together with their parents.
+func (tp *TransactionPool) checkMinimumFee(ts []types.Transaction) error {
+	if setFees(ts).Cmp(tp.minimumSizeFee(len(encoding.Marshal(ts)))) < 0 {
+		return errFeeTooLow
 	}
 	return nil
 }
 
+// minimumSizeFee returns the fee required by the configured minimum fee per
+// byte for a transaction set of 'size' bytes.
+func (tp *TransactionPool) minimumSizeFee(size int) types.Currency {
+	return tp.minimumFee.Mul(types.NewCurrency64(uint64(size)))
+}
+
+// poolPressureFee returns the fee required for a transaction set containing
+// 'numTransactions' transactions given how full the transaction pool is.
+func (tp *TransactionPool) poolPressureFee(numTransactions int) types.Currency {
+	// The first TransactionPoolSizeForFee transactions do not need fees.
+	if tp.transactionListSize <= TransactionPoolSizeForFee {
+		return types.ZeroCurrency
+	}
+	// Currently required fees are set on a per-transaction basis. 2 coins are
+	// required per transaction if the free-fee limit has been reached, adding
+	// a larger fee is not useful.
+	return TransactionMinFee.Mul(types.NewCurrency64(uint64(numTransactions)))
+}
+
+// RequiredFee returns the smallest total miner fee that a transaction set of
+// 'size' encoded bytes containing 'numTransactions' transactions must pay to
+// be accepted into the transaction pool right now.
+func (tp *TransactionPool) RequiredFee(size int, numTransactions int) types.Currency {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	sizeFee := tp.minimumSizeFee(size)
+	pressureFee := tp.poolPressureFee(numTransactions)
+	if sizeFee.Cmp(pressureFee) > 0 {
+		return sizeFee
+	}
+	return pressureFee
+}
+
+// RecommendedFee returns the miner fees that a typical transaction set should
+// pay. 'min' is the fee that the pool currently requires, which depends on how
+// full the pool is. 'recommended' adds the fee estimate of the pool on top of
+// the minimum, and has a much better chance of being confirmed quickly.
+func (tp *TransactionPool) RecommendedFee() (min types.Currency, recommended types.Currency) {
+	min = tp.RequiredFee(estimatedTransactionSetSize, estimatedTransactionSetLen)
+	_, maxPerByte := tp.FeeEstimation()
+	recommended = min.Add(maxPerByte.Mul(types.NewCurrency64(estimatedTransactionSetSize)))
+	return min, recommended
+}
+
 // checkTransactionSetComposition checks if the transaction set is valid given
 // the state of the pool. It does not check that each individual transaction
 // would be legal in the next block, but does check things like miner fees and
 // IsStandard.
 func (tp *TransactionPool) checkTransactionSetComposition(ts []types.Transaction) error {
 	// Check that the transaction set is not already known.
-	setID := TransactionSetID(crypto.HashObject(ts))
+	setID := CalculateTransactionSetID(ts)
 	_, exists := tp.transactionSets[setID]
 	if exists {
 		return modules.ErrDuplicateTransactionSet
@@ -130,12 +220,155 @@ func (tp *TransactionPool) checkTransactionSetComposition(ts []types.Transaction
 	if err != nil {
 		return err
 	}
+
+	// Check that the set is not larger than the pool is willing to store.
+	// Sets that depend on sets in the pool are checked after being merged
+	// with their parents, so the limit applies to the combined set.
+	if len(encoding.Marshal(ts)) > tp.maxTransactionSetSize {
+		return errTransactionSetTooLarge
+	}
+
+	// Check that the set does not carry more arbitrary data than the pool is
+	// willing to store.
+	arbitraryDataSize := 0
+	for _, txn := range ts {
+		for _, arb := range txn.ArbitraryData {
+			arbitraryDataSize += len(arb)
+		}
+	}
+	if arbitraryDataSize > tp.maxArbitraryDataSize {
+		return errLargeArbitraryData
+	}
+	return nil
+}
+
+// findDoubleSpend searches for an object that is spent both by a transaction
+// in 'ts' and by a transaction in one of the 'conflicts' sets, returning the
+// object and the transaction in the pool that spends it.
+func (tp *TransactionPool) findDoubleSpend(ts []types.Transaction, conflicts map[TransactionSetID]struct{}) (modules.ConflictError, bool) {
+	spenders := make(map[ObjectID]types.TransactionID)
+	for conflict := range conflicts {
+		for _, txn := range tp.transactionSets[conflict] {
+			txid := txn.ID()
+			for _, oid := range consumedObjectIDs(txn) {
+				spenders[oid] = txid
+			}
+		}
+	}
+	for _, txn := range ts {
+		for _, oid := range consumedObjectIDs(txn) {
+			if spender, exists := spenders[oid]; exists {
+				return modules.ConflictError{
+					ObjectID:      crypto.Hash(oid),
+					TransactionID: spender,
+				}, true
+			}
+		}
+	}
+	return modules.ConflictError{}, false
+}
+
+// replaceByFeeConflicts replaces the transactions in the pool that double
+// spend an object with 'ts', provided that 'ts' pays strictly more in miner
+// fees than all of the replaced transactions combined, by at least the
+// minimum fee of the pool for the size of 'ts'. While the minimum fee is zero,
+// any increase is enough. Transactions that depend on a replaced transaction
+// are evicted along with it. If the
+// replacement is not allowed, 'ce' is returned. If 'dryRun' is set, the
+// replacement is checked but the transaction pool is not modified.
+func (tp *TransactionPool) replaceByFeeConflicts(ts []types.Transaction, conflicts map[TransactionSetID]struct{}, ce modules.ConflictError, dryRun bool) error {
+	consumed := make(map[ObjectID]struct{})
+	for _, txn := range ts {
+		for _, oid := range consumedObjectIDs(txn) {
+			consumed[oid] = struct{}{}
+		}
+	}
+
+	// Strip the double spends, and every transaction that depends on a double
+	// spend, out of the conflicting sets. The transactions in a set are
+	// ordered by dependency, so a single pass finds every descendant.
+	keptSets := make(map[TransactionSetID][]types.Transaction)
+	var replaced []types.Transaction
+	var replacedFees types.Currency
+	var superset []types.Transaction
+	for conflict := range conflicts {
+		evicted := make(map[ObjectID]struct{})
+		for _, txn := range tp.transactionSets[conflict] {
+			evict := false
+			for _, oid := range consumedObjectIDs(txn) {
+				_, doubleSpend := consumed[oid]
+				_, dependent := evicted[oid]
+				if doubleSpend || dependent {
+					evict = true
+				}
+			}
+			if !evict {
+				keptSets[conflict] = append(keptSets[conflict], txn)
+				continue
+			}
+			replaced = append(replaced, txn)
+			replacedFees = replacedFees.Add(setFees([]types.Transaction{txn}))
+			for _, oid := range createdObjectIDs(txn) {
+				evicted[oid] = struct{}{}
+			}
+		}
+		superset = append(superset, keptSets[conflict]...)
+	}
+	// The replacement must pay more than the replaced transactions, and must
+	// also pay the minimum fee of the pool for its own size on top, so that
+	// a set cannot be replaced over and over by adding a single hasting.
+	fees := setFees(ts)
+	bump := tp.minimumSizeFee(len(encoding.Marshal(ts)))
+	if fees.Cmp(replacedFees) <= 0 || fees.Cmp(replacedFees.Add(bump)) < 0 {
+		return ce
+	}
+
+	// Check that the new set is valid once the replaced transactions are
+	// gone.
+	superset = append(superset, ts...)
+	err := tp.checkTransactionSetComposition(superset)
+	if err != nil {
+		return err
+	}
+	_, err = tp.consensusSet.TryTransactionSet(superset)
+	if err != nil {
+		return modules.NewConsensusConflict(err.Error())
+	}
+	if dryRun {
+		return nil
+	}
+
+	// Rebuild the pool without the replaced transactions, which also evicts
+	// any other sets that depend on them, and then add the new set. If the
+	// new set is still rejected, the original pool is restored.
+	var originalSets, remainingSets [][]types.Transaction
+	var originalArrivals, remainingArrivals []time.Time
+	for setID, tSet := range tp.transactionSets {
+		arrival := tp.transactionSetArrivals[setID]
+		originalSets = append(originalSets, tSet)
+		originalArrivals = append(originalArrivals, arrival)
+		if _, exists := conflicts[setID]; exists {
+			tSet = keptSets[setID]
+		}
+		if len(tSet) > 0 {
+			remainingSets = append(remainingSets, tSet)
+			remainingArrivals = append(remainingArrivals, arrival)
+		}
+	}
+	conflicted := tp.readdTransactionSets(remainingSets, remainingArrivals)
+	err = tp.acceptTransactionSet(context.Background(), ts, false)
+	if err != nil {
+		tp.readdTransactionSets(originalSets, originalArrivals)
+		return err
+	}
+	tp.markConflicted(append(conflicted, replaced))
 	return nil
 }
 
 // handleConflicts detects whether the conflicts in the transaction pool are
-// legal children of the new transaction pool set or not.
-func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []TransactionSetID) error {
+// legal children of the new transaction pool set or not. If 'dryRun' is set,
+// the merged set is checked but the transaction pool is not modified.
+func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []TransactionSetID, dryRun bool) error {
 	// Create a list of all the transaction ids that compose the set of
 	// conflicts.
 	conflictMap := make(map[types.TransactionID]TransactionSetID)
@@ -175,7 +408,11 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 				conflicts = append(conflicts, conflict)
 			}
 		}
-		return tp.handleConflicts(dedupSet, conflicts)
+		return tp.handleConflicts(dedupSet, conflicts, dryRun)
+	}
+	err := tp.checkMinimumFee(dedupSet)
+	if err != nil {
+		return err
 	}
 
 	// Merge all of the conflict sets with the input set (input set goes last
@@ -196,28 +433,45 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 
 	// Check the composition of the transaction set, including fees and
 	// IsStandard rules (this is a new set, the rules must be rechecked).
-	err := tp.checkTransactionSetComposition(superset)
+	err = tp.checkTransactionSetComposition(superset)
 	if err != nil {
 		return err
 	}
 
-	// Check that the transaction set is valid.
+	// Check that the transaction set is valid. If the set is invalid because
+	// it double spends an object with one of the conflicts, report which
+	// object and transaction collided.
 	cc, err := tp.consensusSet.TryTransactionSet(superset)
 	if err != nil {
+		if ce, ok := tp.findDoubleSpend(dedupSet, supersetMap); ok {
+			if tp.replaceByFee {
+				return tp.replaceByFeeConflicts(dedupSet, supersetMap, ce, dryRun)
+			}
+			return ce
+		}
 		return modules.NewConsensusConflict(err.Error())
 	}
+	if dryRun {
+		return nil
+	}
 
 	// Remove the conflicts from the transaction pool. The diffs do not need to
-	// be removed, they will be overwritten later in the function.
+	// be removed, they will be overwritten later in the function. The
+	// superset inherits the earliest arrival time of the sets it replaces.
+	arrival := tp.clock.now()
 	for _, conflict := range conflictMap {
 		conflictSet := tp.transactionSets[conflict]
 		tp.transactionListSize -= len(encoding.Marshal(conflictSet))
+		if conflictArrival, exists := tp.transactionSetArrivals[conflict]; exists && conflictArrival.Before(arrival) {
+			arrival = conflictArrival
+		}
 		delete(tp.transactionSets, conflict)
 		delete(tp.transactionSetDiffs, conflict)
+		delete(tp.transactionSetArrivals, conflict)
 	}
 
 	// Add the transaction set to the pool.
-	setID := TransactionSetID(crypto.HashObject(superset))
+	setID := CalculateTransactionSetID(superset)
 	tp.transactionSets[setID] = superset
 	for _, diff := range cc.SiacoinOutputDiffs {
 		tp.knownObjects[ObjectID(diff.ID)] = setID
@@ -229,13 +483,27 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 		tp.knownObjects[ObjectID(diff.ID)] = setID
 	}
 	tp.transactionSetDiffs[setID] = cc
+	tp.transactionSetArrivals[setID] = arrival
 	tp.transactionListSize += len(encoding.Marshal(superset))
 	return nil
 }
 
+// contextCancelled returns true if the context has been cancelled.
+func contextCancelled(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
 // acceptTransactionSet verifies that a transaction set is allowed to be in the
-// transaction pool, and then adds it to the transaction pool.
-func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
+// transaction pool, and then adds it to the transaction pool. If the context is
+// cancelled before the set is added, ErrContextCancelled is returned and the
+// transaction pool is left unchanged. If 'dryRun' is set, the transaction set
+// is verified but never added.
+func (tp *TransactionPool) acceptTransactionSet(ctx context.Context, ts []types.Transaction, dryRun bool) error {
 	if len(ts) == 0 {
 		return errEmptySet
 	}
@@ -258,62 +526,158 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction) error {
 			conflicts = append(conflicts, conflict)
 		}
 	}
+	if contextCancelled(ctx) {
+		return modules.ErrContextCancelled
+	}
 	if len(conflicts) > 0 {
-		return tp.handleConflicts(ts, conflicts)
+		return tp.handleConflicts(ts, conflicts, dryRun)
+	}
+	err = tp.checkMinimumFee(ts)
+	if err != nil {
+		return err
 	}
 	cc, err := tp.consensusSet.TryTransactionSet(ts)
 	if err != nil {
 		return modules.NewConsensusConflict(err.Error())
 	}
+	if contextCancelled(ctx) {
+		return modules.ErrContextCancelled
+	}
+	if dryRun {
+		return nil
+	}
 
 	// Add the transaction set to the pool.
-	setID := TransactionSetID(crypto.HashObject(ts))
+	setID := CalculateTransactionSetID(ts)
 	tp.transactionSets[setID] = ts
 	for _, oid := range oids {
 		tp.knownObjects[oid] = setID
 	}
 	tp.transactionSetDiffs[setID] = cc
+	tp.transactionSetArrivals[setID] = tp.clock.now()
 	tp.transactionListSize += len(encoding.Marshal(ts))
 	return nil
 }
 
-// AcceptTransaction adds a transaction to the unconfirmed set of
-// transactions. If the transaction is accepted, it will be relayed to
+// recordAcceptance updates the acceptance metrics of the transaction pool
+// according to the result of accepting a transaction set.
+func (tp *TransactionPool) recordAcceptance(err error) {
+	switch err.(type) {
+	case modules.ConflictError, modules.ConsensusConflict:
+		atomic.AddUint64(&tp.atomicConflictSets, 1)
+		return
+	}
+	switch err {
+	case nil:
+		atomic.AddUint64(&tp.atomicAcceptedSets, 1)
+	case modules.ErrDuplicateTransactionSet:
+		atomic.AddUint64(&tp.atomicDuplicateSets, 1)
+	case errLowMinerFees, errFeeTooLow:
+		atomic.AddUint64(&tp.atomicLowFeeSets, 1)
+	case errObjectConflict:
+		atomic.AddUint64(&tp.atomicConflictSets, 1)
+	}
+}
+
+// AcceptTransactionSet adds a transaction set to the unconfirmed set of
+// transactions. If the transaction set is accepted, it will be relayed to
 // connected peers.
 func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
+	return tp.AcceptTransactionSetWithContext(context.Background(), ts)
+}
+
+// AcceptTransactionSetWithContext adds a transaction set to the unconfirmed
+// set of transactions, aborting with modules.ErrContextCancelled if 'ctx' is
+// cancelled before the set has been added. If the transaction set is accepted,
+// it will be relayed to connected peers until 'ctx' is cancelled.
+func (tp *TransactionPool) AcceptTransactionSetWithContext(ctx context.Context, ts []types.Transaction) error {
+	return tp.acceptAndRelay(ctx, ts, "")
+}
+
+// acceptAndRelay adds a transaction set to the unconfirmed set of transactions
+// and relays it to peers. If the set was received from a peer, 'origin' is the
+// address of that peer, and the set is not relayed back to it.
+func (tp *TransactionPool) acceptAndRelay(ctx context.Context, ts []types.Transaction, origin modules.NetAddress) error {
+	if contextCancelled(ctx) {
+		return modules.ErrContextCancelled
+	}
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
-	err := tp.acceptTransactionSet(ts)
+	err := tp.acceptTransactionSet(ctx, ts, false)
+	tp.recordAcceptance(err)
 	if err != nil {
 		return err
 	}
 
-	// Notify subscribers and broadcast the transaction set.
-	// NOTE: The transaction set is only broadcast to v0.4.7 peers and above.
-	// v0.4.7-v0.5.1 broadcasted both transaction sets and individual transactions
-	// and those versions act as a bridge between v0.5.2+ and older versions.
-	// COMPATv0.4.6
-	var v047AndAbove []modules.Peer
-	for _, p := range tp.gateway.Peers() {
-		if build.VersionCmp(p.Version, "0.4.7") >= 0 {
-			v047AndAbove = append(v047AndAbove, p)
-		}
-	}
-	go tp.gateway.Broadcast("RelayTransactionSet", ts, v047AndAbove)
+	// Notify subscribers and broadcast the transaction set. The transaction
+	// set is only broadcast to peers at or above the minimum broadcast
+	// version.
 	tp.updateSubscribersTransactions()
-
+	if contextCancelled(ctx) {
+		return nil
+	}
+	broadcastPeers := tp.gateway.PeersByVersion(tp.minBroadcastVersion)
+	var compressedPeers []modules.Peer
+	if tp.broadcastCompression {
+		broadcastPeers, compressedPeers = tp.splitCompressionPeers(broadcastPeers)
+	}
+	// The broadcasts happen in goroutines, and stop sending the set to peers
+	// once the context is cancelled. The compressed broadcast does not wait
+	// for the uncompressed one, which may retry unresponsive peers.
+	go tp.gateway.BroadcastExceptWithContext(ctx, "RelayTransactionSet", ts, broadcastPeers, origin)
+	if len(compressedPeers) != 0 {
+		go tp.gateway.BroadcastExceptWithContext(ctx, "RelayCompressedTransactionSet", compressTransactionSet(ts), compressedPeers, origin)
+	}
 	return nil
 }
 
+// ValidateTransactionSet runs every check that AcceptTransactionSet performs,
+// including consensus validity, miner fees, and conflicts with the transaction
+// sets already in the pool, without adding the set to the pool or relaying it
+// to peers. The same errors are returned as would be returned by
+// AcceptTransactionSet.
+func (tp *TransactionPool) ValidateTransactionSet(ts []types.Transaction) error {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.acceptTransactionSet(context.Background(), ts, true)
+}
+
 // relayTransactionSet is an RPC that accepts a transaction set from a peer. If
 // the accept is successful, the transaction will be relayed to the gateway's
-// other peers.
+// other peers, excluding the peer that sent it. Duplicate transaction sets are
+// dropped silently, as peers will often relay the same set at the same time. A
+// peer that sends a malformed transaction set is disconnected. Sets from peers
+// that exceed the relay rate limit are dropped without being verified, and
+// peers that keep exceeding it are disconnected.
 func (tp *TransactionPool) relayTransactionSet(conn modules.PeerConn) error {
 	var ts []types.Transaction
 	err := encoding.ReadObject(conn, &ts, types.BlockSizeLimit)
 	if err != nil {
+		tp.gateway.Disconnect(modules.NetAddress(conn.RemoteAddr().String()))
 		return err
 	}
-	return tp.AcceptTransactionSet(ts)
+	return tp.acceptRelayedSet(conn, ts)
+}
+
+// acceptRelayedSet accepts a transaction set that was relayed over 'conn',
+// subject to the relay rate limit of the peer. Sets that are already in the
+// pool are dropped silently before they are counted against the limit, as
+// honest peers often relay the same set at the same time. Sets that exceed the
+// limit are dropped without an error as well, so that the gateway does not log
+// each of them.
+func (tp *TransactionPool) acceptRelayedSet(conn modules.PeerConn, ts []types.Transaction) error {
+	if tp.PoolContains(CalculateTransactionSetID(ts)) {
+		return nil
+	}
+	addr := modules.NetAddress(conn.RemoteAddr().String())
+	drop, disconnect := tp.throttleRelay(addr)
+	if disconnect {
+		conn.Close()
+		tp.gateway.Disconnect(addr)
+	}
+	if drop {
+		return nil
+	}
+	return tp.acceptAndRelay(context.Background(), ts, addr)
 }
@@ -2,15 +2,22 @@ package transactionpool
 
 import (
 	"crypto/rand"
+	"net"
 	"testing"
+	"time"
 
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
 // mockGatewayCheckBroadcast is a mock implementation of modules.Gateway that
-// enables testing of selective broadcasting by mocking the Peers and Broadcast
-// methods.
+// enables testing of selective broadcasting by mocking the Peers and
+// BroadcastExceptWithContext methods.
 type mockGatewayCheckBroadcast struct {
 	modules.Gateway
 	peers            []modules.Peer
@@ -23,10 +30,51 @@ func (g *mockGatewayCheckBroadcast) Peers() []modules.Peer {
 	return g.peers
 }
 
-// Broadcast is a mock implementation of Gateway.Broadcast that writes the
-// peers it receives as an argument to the broadcastedPeers channel.
-func (g *mockGatewayCheckBroadcast) Broadcast(_ string, _ interface{}, peers []modules.Peer) {
-	g.broadcastedPeers <- peers
+// PeersByVersion is a mock implementation of Gateway.PeersByVersion that
+// filters the mocked peers.
+func (g *mockGatewayCheckBroadcast) PeersByVersion(minVersion string) []modules.Peer {
+	var peers []modules.Peer
+	for _, p := range g.peers {
+		if build.IsVersion(p.Version) && build.VersionCmp(p.Version, minVersion) >= 0 {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// BroadcastExceptWithContext is a mock implementation of
+// Gateway.BroadcastExceptWithContext that writes the peers other than
+// 'except' to the broadcastedPeers channel. The mocked peers have no address,
+// so an empty 'except' does not exclude any peer.
+func (g *mockGatewayCheckBroadcast) BroadcastExceptWithContext(_ context.Context, _ string, _ interface{}, peers []modules.Peer, except modules.NetAddress) {
+	var filtered []modules.Peer
+	for _, p := range peers {
+		if except == "" || p.NetAddress != except {
+			filtered = append(filtered, p)
+		}
+	}
+	g.broadcastedPeers <- filtered
+}
+
+// mockGatewayBlockBroadcast is a mock implementation of modules.Gateway whose
+// broadcasts block until their context is cancelled, like a broadcast to
+// unresponsive peers.
+type mockGatewayBlockBroadcast struct {
+	modules.Gateway
+	cancelled chan struct{}
+}
+
+// PeersByVersion is a mock implementation of Gateway.PeersByVersion that
+// returns a single peer.
+func (g *mockGatewayBlockBroadcast) PeersByVersion(string) []modules.Peer {
+	return []modules.Peer{{Version: "9.9.9"}}
+}
+
+// BroadcastExceptWithContext is a mock implementation of
+// Gateway.BroadcastExceptWithContext that returns once 'ctx' is cancelled.
+func (g *mockGatewayBlockBroadcast) BroadcastExceptWithContext(ctx context.Context, _ string, _ interface{}, _ []modules.Peer, _ modules.NetAddress) {
+	<-ctx.Done()
+	g.cancelled <- struct{}{}
 }
 
 // TestAcceptTransactionSetBroadcasts tests that AcceptTransactionSet only
@@ -66,6 +114,127 @@ func TestAcceptTransactionSetBroadcasts(t *testing.T) {
 	}
 }
 
+// TestSetMinBroadcastVersion tests that AcceptTransactionSet only broadcasts
+// to peers at or above the configured minimum broadcast version.
+func TestSetMinBroadcastVersion(t *testing.T) {
+	tpt, err := createTpoolTester("TestSetMinBroadcastVersion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockPeers := []modules.Peer{
+		modules.Peer{Version: "0.4.7"},
+		modules.Peer{Version: "0.6.0"},
+		modules.Peer{Version: "9.9.9"},
+	}
+	mg := &mockGatewayCheckBroadcast{
+		Gateway:          tpt.tpool.gateway,
+		peers:            mockPeers,
+		broadcastedPeers: make(chan []modules.Peer, 1),
+	}
+	tpt.tpool.gateway = mg
+
+	// Try an invalid version.
+	err = tpt.tpool.SetMinBroadcastVersion("foo")
+	if err != errInvalidBroadcastVersion {
+		t.Fatal("expected errInvalidBroadcastVersion, got", err)
+	}
+
+	err = tpt.tpool.SetMinBroadcastVersion("0.6.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broadcastedPeers := <-mg.broadcastedPeers
+	if len(broadcastedPeers) != 2 {
+		t.Fatalf("only 2 peers have version >= v0.6.0, but AcceptTransactionSet relayed the transaction set to %v peers", len(broadcastedPeers))
+	}
+	for _, bp := range broadcastedPeers {
+		if bp.Version != "0.6.0" && bp.Version != "9.9.9" {
+			t.Fatalf("AcceptTransactionSet relayed the transaction to a peer with version < v0.6.0 (%v)", bp.Version)
+		}
+	}
+}
+
+// TestAcceptTransactionSetWithContext checks that a cancelled context stops a
+// transaction set from being added to the pool or broadcast.
+func TestAcceptTransactionSetWithContext(t *testing.T) {
+	tpt, err := createTpoolTester("TestAcceptTransactionSetWithContext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mg := &mockGatewayCheckBroadcast{
+		Gateway:          tpt.tpool.gateway,
+		peers:            []modules.Peer{{Version: "9.9.9"}},
+		broadcastedPeers: make(chan []modules.Peer, 1),
+	}
+	tpt.tpool.gateway = mg
+
+	// Submit a transaction set with a context that is already cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = tpt.tpool.AcceptTransactionSetWithContext(ctx, []types.Transaction{{}})
+	if err != modules.ErrContextCancelled {
+		t.Fatal("expected ErrContextCancelled, got", err)
+	}
+	if len(tpt.tpool.transactionSets) != 0 {
+		t.Fatal("cancelled transaction set was added to the pool")
+	}
+	select {
+	case <-mg.broadcastedPeers:
+		t.Fatal("cancelled transaction set was broadcast")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A live context should behave the same as AcceptTransactionSet.
+	err = tpt.tpool.AcceptTransactionSetWithContext(context.Background(), []types.Transaction{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.transactionSets) != 1 {
+		t.Fatal("transaction set was not added to the pool")
+	}
+	<-mg.broadcastedPeers
+}
+
+// TestAcceptTransactionSetWithContextBroadcast checks that cancelling the
+// context of an accepted transaction set stops the broadcast of the set.
+func TestAcceptTransactionSetWithContextBroadcast(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestAcceptTransactionSetWithContextBroadcast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mg := &mockGatewayBlockBroadcast{
+		Gateway:   tpt.tpool.gateway,
+		cancelled: make(chan struct{}, 1),
+	}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.gateway = mg
+	tpt.tpool.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = tpt.tpool.AcceptTransactionSetWithContext(ctx, []types.Transaction{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-mg.cancelled:
+		t.Fatal("broadcast stopped before the context was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case <-mg.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast did not stop when the context was cancelled")
+	}
+}
+
 // TestIntegrationAcceptTransactionSet probes the AcceptTransactionSet method
 // of the transaction pool.
 func TestIntegrationAcceptTransactionSet(t *testing.T) {
@@ -157,6 +326,18 @@ func TestIntegrationConflictingTransactionSets(t *testing.T) {
 	if err == nil {
 		t.Error("transaction should not have passed inspection")
 	}
+	// The error should identify the output that was double spent and the
+	// transaction in the pool that spends it.
+	ce, ok := err.(modules.ConflictError)
+	if !ok {
+		t.Fatal("expected a ConflictError, got", err)
+	}
+	if ce.ObjectID != crypto.Hash(txnSet[txnIndex].SiacoinInputs[0].ParentID) {
+		t.Error("conflict error has the wrong object id")
+	}
+	if ce.TransactionID != txnSet[txnIndex].ID() {
+		t.Error("conflict error has the wrong transaction id")
+	}
 
 	// Purge and try the sets in the reverse order.
 	tpt.tpool.PurgeTransactionPool()
@@ -170,6 +351,112 @@ func TestIntegrationConflictingTransactionSets(t *testing.T) {
 	}
 }
 
+// TestIntegrationReplaceByFee checks that a transaction set double spending a
+// set in the pool replaces it only when replace-by-fee is enabled and the new
+// set pays a strictly higher fee.
+func TestIntegrationReplaceByFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationReplaceByFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create sets that spend the same output, paying a low fee, a fee one
+	// hasting higher, and a much higher fee. The low fee is larger than the
+	// size of any of the sets, so that every set pays a minimum fee of one
+	// hasting per byte.
+	fund := types.NewCurrency64(30e6)
+	lowFee := types.NewCurrency64(100e3)
+	highFee := types.NewCurrency64(1e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wholeTransaction is set to false so that we can use the same signature
+	// to create a double spend.
+	txnSet, err := txnBuilder.Sign(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnIndex := len(txnSet) - 1
+	newSet := func(fee types.Currency, uh types.UnlockHash) []types.Transaction {
+		set := make([]types.Transaction, len(txnSet))
+		copy(set, txnSet)
+		set[txnIndex].MinerFees = append(set[txnIndex].MinerFees, fee)
+		set[txnIndex].SiacoinOutputs = append(set[txnIndex].SiacoinOutputs, types.SiacoinOutput{Value: fund.Sub(fee), UnlockHash: uh})
+		return set
+	}
+	lowSet := newSet(lowFee, types.UnlockHash{1})
+	equalSet := newSet(lowFee, types.UnlockHash{2})
+	bumpSet := newSet(lowFee.Add(types.NewCurrency64(1)), types.UnlockHash{3})
+	highSet := newSet(highFee, types.UnlockHash{1})
+
+	err = tpt.tpool.AcceptTransactionSet(lowSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replace-by-fee is disabled by default.
+	err = tpt.tpool.AcceptTransactionSet(highSet)
+	if _, ok := err.(modules.ConflictError); !ok {
+		t.Fatal("expected a ConflictError, got", err)
+	}
+
+	// A replacement must pay a strictly higher fee.
+	tpt.tpool.SetReplaceByFee(true)
+	err = tpt.tpool.AcceptTransactionSet(equalSet)
+	if _, ok := err.(modules.ConflictError); !ok {
+		t.Fatal("expected a ConflictError, got", err)
+	}
+	// The increase must cover the minimum fee for the size of the
+	// replacement. The bump set pays the minimum fee for its own size, so it
+	// is rejected by the bump rule rather than by the minimum fee.
+	tpt.tpool.SetMinimumFee(types.NewCurrency64(1))
+	if lowFee.Add(types.NewCurrency64(1)).Cmp(types.NewCurrency64(uint64(len(encoding.Marshal(bumpSet))))) < 0 {
+		t.Fatal("bump set does not pay the minimum fee for its size")
+	}
+	err = tpt.tpool.AcceptTransactionSet(bumpSet)
+	if _, ok := err.(modules.ConflictError); !ok {
+		t.Fatal("expected a ConflictError, got", err)
+	}
+	err = tpt.tpool.ValidateTransactionSet(highSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cs countingSubscriber
+	tpt.tpool.TransactionPoolSubscribe(&cs)
+	err = tpt.tpool.AcceptTransactionSet(highSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The low fee transaction should have been evicted, leaving the parent
+	// and the replacement.
+	txns := tpt.tpool.TransactionList()
+	if len(txns) != len(highSet) {
+		t.Fatal("wrong number of transactions in the pool:", len(txns))
+	}
+	for _, txn := range txns {
+		if txn.ID() == lowSet[txnIndex].ID() {
+			t.Error("replaced transaction is still in the pool")
+		}
+	}
+	if len(cs.updates) != 2 || len(cs.updates[1]) != len(highSet) {
+		t.Error("subscriber was not notified of the replacement")
+	}
+
+	// The replacement should be confirmed by the next block.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Error("replacement was not confirmed")
+	}
+}
+
 // TestIntegrationCheckMinerFees probes the checkMinerFees method of the
 // transaction pool.
 func TestIntegrationCheckMinerFees(t *testing.T) {
@@ -212,6 +499,157 @@ func TestIntegrationCheckMinerFees(t *testing.T) {
 	// TODO: fill the pool up all the way and try again.
 }
 
+// TestIntegrationValidateTransactionSet checks that ValidateTransactionSet
+// returns the same errors as AcceptTransactionSet without modifying the
+// transaction pool.
+func TestIntegrationValidateTransactionSet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationValidateTransactionSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create two transaction sets that double spend the same output.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnSet, err := txnBuilder.Sign(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnSetDoubleSpend := make([]types.Transaction, len(txnSet))
+	copy(txnSetDoubleSpend, txnSet)
+	txnIndex := len(txnSet) - 1
+	txnSet[txnIndex].MinerFees = append(txnSet[txnIndex].MinerFees, fund)
+	txnSetDoubleSpend[txnIndex].SiacoinOutputs = append(txnSetDoubleSpend[txnIndex].SiacoinOutputs, types.SiacoinOutput{Value: fund})
+
+	// Validating either set should succeed without adding it to the pool.
+	err = tpt.tpool.ValidateTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.ValidateTransactionSet(txnSetDoubleSpend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.transactionSets) != 0 || tpt.tpool.transactionListSize != 0 {
+		t.Fatal("validating a transaction set modified the pool")
+	}
+
+	// After accepting the first set, the double spend and the duplicate
+	// should both fail validation with the same errors as acceptance.
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.ValidateTransactionSet(txnSet)
+	if err != modules.ErrDuplicateTransactionSet {
+		t.Error("expected ErrDuplicateTransactionSet, got", err)
+	}
+	validateErr := tpt.tpool.ValidateTransactionSet(txnSetDoubleSpend)
+	if validateErr == nil {
+		t.Fatal("double spend should not pass validation")
+	}
+	acceptErr := tpt.tpool.AcceptTransactionSet(txnSetDoubleSpend)
+	if acceptErr == nil || acceptErr.Error() != validateErr.Error() {
+		t.Errorf("validation and acceptance returned different errors: %v, %v", validateErr, acceptErr)
+	}
+	if len(tpt.tpool.transactionSets) != 1 {
+		t.Error("pool should contain exactly one transaction set")
+	}
+	if tpt.tpool.ValidateTransactionSet(nil) != errEmptySet {
+		t.Error("expected errEmptySet")
+	}
+}
+
+// TestIntegrationMinimumFee checks that transaction sets paying less than the
+// configured minimum fee per byte are rejected, even when the transaction pool
+// is empty.
+func TestIntegrationMinimumFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationMinimumFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tpt.tpool.MinimumFee().IsZero() {
+		t.Fatal("minimum fee should default to zero")
+	}
+
+	// Set a minimum fee and check that a free transaction is rejected.
+	feePerByte := types.SiacoinPrecision.Div(types.NewCurrency64(1e3))
+	tpt.tpool.SetMinimumFee(feePerByte)
+	if tpt.tpool.MinimumFee().Cmp(feePerByte) != 0 {
+		t.Fatal("minimum fee was not set")
+	}
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{ArbitraryData: [][]byte{arbData}}})
+	if err != errFeeTooLow {
+		t.Fatal("expected errFeeTooLow, got", err)
+	}
+
+	// A transaction from the wallet paying enough fees should be accepted.
+	txnBuilder := tpt.wallet.StartTransaction()
+	fee := feePerByte.Mul(types.NewCurrency64(2e3))
+	err = txnBuilder.FundSiacoins(fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fee)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resetting the minimum fee to zero should allow free transactions again.
+	tpt.tpool.SetMinimumFee(types.ZeroCurrency)
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{ArbitraryData: [][]byte{arbData}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestIntegrationRecommendedFee checks that the recommended fees follow the
+// minimum fee of the pool.
+func TestIntegrationRecommendedFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationRecommendedFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	min, recommended := tpt.tpool.RecommendedFee()
+	if !min.IsZero() {
+		t.Error("expected a zero minimum fee, got", min)
+	}
+	_, maxPerByte := tpt.tpool.FeeEstimation()
+	if recommended.Cmp(maxPerByte.Mul(types.NewCurrency64(estimatedTransactionSetSize))) != 0 {
+		t.Error("recommended fee does not follow the fee estimate:", recommended)
+	}
+
+	feePerByte := types.SiacoinPrecision.Div(types.NewCurrency64(1e3))
+	tpt.tpool.SetMinimumFee(feePerByte)
+	min2, recommended2 := tpt.tpool.RecommendedFee()
+	if min2.Cmp(feePerByte.Mul(types.NewCurrency64(estimatedTransactionSetSize))) != 0 {
+		t.Error("minimum fee does not follow the minimum fee per byte:", min2)
+	}
+	if recommended2.Cmp(recommended.Add(min2)) != 0 {
+		t.Error("recommended fee does not include the minimum fee:", recommended2)
+	}
+}
+
 // TestTransactionSuperset submits a single transaction to the network,
 // followed by a transaction set containing that single transaction.
 func TestIntegrationTransactionSuperset(t *testing.T) {
@@ -383,6 +821,65 @@ func TestIntegrationNilAccept(t *testing.T) {
 	}
 }
 
+// TestIntegrationMinimumFeeUnconfirmedParent checks that the minimum fee is
+// applied to the transactions that are new to the pool, so that the child of
+// an unconfirmed set that paid no fee only pays for its own size.
+func TestIntegrationMinimumFeeUnconfirmedParent(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationMinimumFeeUnconfirmedParent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a parent set without a fee that sends an output to an address
+	// that anyone can spend.
+	value := types.NewCurrency64(1e9)
+	builder := tpt.wallet.StartTransaction()
+	err = builder.FundSiacoins(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputIndex, err := builder.AddSiacoinOutput(types.SiacoinOutput{Value: value, UnlockHash: types.UnlockConditions{}.UnlockHash()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentSet, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(parentSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Require a fee of one hasting per byte, and spend the output in a child
+	// that pays exactly the minimum fee for its own size.
+	tpt.tpool.SetMinimumFee(types.NewCurrency64(1))
+	parentID := parentSet[len(parentSet)-1].SiacoinOutputID(outputIndex)
+	childSet := func(fee types.Currency) []types.Transaction {
+		return []types.Transaction{{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: parentID}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: value.Sub(fee), UnlockHash: types.UnlockConditions{}.UnlockHash()}},
+			MinerFees:      []types.Currency{fee},
+		}}
+	}
+	// The fee does not change the encoded size as long as it fits in a byte.
+	size := uint64(len(encoding.Marshal(childSet(types.NewCurrency64(1)))))
+	if size > 255 {
+		t.Fatal("child set is too large for the test:", size)
+	}
+	err = tpt.tpool.AcceptTransactionSet(childSet(types.NewCurrency64(size - 1)))
+	if err != errFeeTooLow {
+		t.Fatal("expected errFeeTooLow, got", err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(childSet(types.NewCurrency64(size)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestAcceptFCAndConflictingRevision checks that the transaction pool
 // correctly accepts a file contract in a transaction set followed by a correct
 // revision to that file contract in the a following transaction set, with no
@@ -438,3 +935,152 @@ func TestAcceptFCAndConflictingRevision(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestRelayTransactionSet checks that the RelayTransactionSet RPC accepts
+// transaction sets pushed by peers, drops duplicate sets without an error, and
+// disconnects peers that send malformed sets.
+func TestRelayTransactionSet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestRelayTransactionSet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The mock is installed before any set is accepted, because accepted sets
+	// are broadcast by goroutines that read the gateway.
+	mg := &mockGatewayCheckDisconnect{Gateway: tpt.tpool.gateway}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.gateway = mg
+	tpt.tpool.mu.Unlock()
+
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+
+	// Push a valid transaction set, and then push it again.
+	for i := 0; i < 2; i++ {
+		ourConn, theirConn := net.Pipe()
+		go encoding.WriteObject(theirConn, ts)
+		err = tpt.tpool.relayTransactionSet(ourConn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ourConn.Close()
+		theirConn.Close()
+	}
+	if len(tpt.tpool.TransactionList()) != 1 {
+		t.Fatal("relayed transaction set was not added to the pool")
+	}
+
+	if len(mg.disconnected) != 0 {
+		t.Fatal("peer was disconnected after sending a valid transaction set:", mg.disconnected)
+	}
+
+	// Push a malformed transaction set. The peer should be disconnected.
+	ourConn, theirConn := net.Pipe()
+	defer ourConn.Close()
+	defer theirConn.Close()
+	go encoding.WritePrefix(theirConn, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	err = tpt.tpool.relayTransactionSet(ourConn)
+	if err == nil {
+		t.Fatal("expected an error when relaying a malformed transaction set")
+	}
+	if len(mg.disconnected) != 1 || mg.disconnected[0] != modules.NetAddress(ourConn.RemoteAddr().String()) {
+		t.Fatal("peer was not disconnected after sending a malformed transaction set:", mg.disconnected)
+	}
+}
+
+// TestRelayTransactionSetExcludesOrigin checks that a transaction set received
+// through the RelayTransactionSet RPC is not relayed back to the peer that
+// sent it.
+func TestRelayTransactionSetExcludesOrigin(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestRelayTransactionSetExcludesOrigin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The remote address of a pipe is reported as "pipe", which makes the
+	// first mocked peer the origin of the relayed set.
+	ourConn, theirConn := net.Pipe()
+	defer ourConn.Close()
+	defer theirConn.Close()
+	mg := &mockGatewayCheckBroadcast{
+		Gateway: tpt.tpool.gateway,
+		peers: []modules.Peer{
+			{NetAddress: modules.NetAddress(ourConn.RemoteAddr().String()), Version: "9.9.9"},
+			{NetAddress: "foo:9981", Version: "9.9.9"},
+		},
+		broadcastedPeers: make(chan []modules.Peer, 1),
+	}
+	tpt.tpool.gateway = mg
+
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	go encoding.WriteObject(theirConn, []types.Transaction{{ArbitraryData: [][]byte{arbData}}})
+	err = tpt.tpool.relayTransactionSet(ourConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broadcastedPeers := <-mg.broadcastedPeers
+	if len(broadcastedPeers) != 1 || broadcastedPeers[0].NetAddress != "foo:9981" {
+		t.Fatal("relayed transaction set should only be sent to the other peer, got", broadcastedPeers)
+	}
+}
+
+// TestIntegrationMetrics checks that the acceptance metrics count accepted
+// and rejected transaction sets.
+func TestIntegrationMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationMetrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := tpt.tpool.Metrics()
+
+	// Accept a set and submit it a second time.
+	arbData := make([]byte, 100)
+	copy(arbData, modules.PrefixNonSia[:])
+	txnSet := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != modules.ErrDuplicateTransactionSet {
+		t.Fatal("expected ErrDuplicateTransactionSet, got", err)
+	}
+
+	// Submit a set that spends an output that does not exist.
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{SiacoinInputs: []types.SiacoinInput{{}}}})
+	if _, ok := err.(modules.ConsensusConflict); !ok {
+		t.Fatal("expected a consensus conflict, got", err)
+	}
+
+	// Submit a set that pays too little in fees.
+	tpt.tpool.SetMinimumFee(types.SiacoinPrecision)
+	arbData[len(arbData)-1] = 1
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{ArbitraryData: [][]byte{arbData}}})
+	if err != errFeeTooLow {
+		t.Fatal("expected errFeeTooLow, got", err)
+	}
+
+	after := tpt.tpool.Metrics()
+	if after.AcceptedSets != before.AcceptedSets+1 {
+		t.Error("expected one accepted set, got", after.AcceptedSets-before.AcceptedSets)
+	}
+	if after.DuplicateSets != before.DuplicateSets+1 {
+		t.Error("expected one duplicate set, got", after.DuplicateSets-before.DuplicateSets)
+	}
+	if after.ConflictSets != before.ConflictSets+1 {
+		t.Error("expected one conflicting set, got", after.ConflictSets-before.ConflictSets)
+	}
+	if after.LowFeeSets != before.LowFeeSets+1 {
+		t.Error("expected one low fee set, got", after.LowFeeSets-before.LowFeeSets)
+	}
+}
@@ -0,0 +1,145 @@
+package transactionpool
+
+import (
+	"math"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// defaultMaxAge is the default amount of time that a transaction set can
+	// remain in the transaction pool. The default is effectively infinite, so
+	// transaction sets are only removed once they are confirmed or become
+	// invalid.
+	defaultMaxAge = time.Duration(math.MaxInt64)
+)
+
+var (
+	// ageSweepInterval is how often the transaction pool checks for
+	// transaction sets that have exceeded the maximum age.
+	ageSweepInterval = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return time.Minute
+		case "standard":
+			return 10 * time.Minute
+		case "testing":
+			return 50 * time.Millisecond
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
+// readdTransactionSets purges the transaction pool and then adds each of the
+// transaction sets back to the pool, preserving their arrival times. Sets that
+// arrived more than maxAge ago are evicted. Sets that are no longer valid, for
+// example because they depend on an evicted set or no longer pay enough fees,
+// are not re-added. The sets that were rejected because they double spend
+// another set in the pool are returned.
+func (tp *TransactionPool) readdTransactionSets(sets [][]types.Transaction, arrivals []time.Time) (conflicted [][]types.Transaction) {
+	tp.purge()
+	now := tp.clock.now()
+	for i, set := range sets {
+		if now.Sub(arrivals[i]) > tp.maxAge {
+			continue
+		}
+		err := tp.acceptTransactionSet(context.Background(), set, false)
+		if err == modules.ErrDuplicateTransactionSet {
+			continue
+		} else if err != nil {
+			if isDoubleSpend(err) {
+				conflicted = append(conflicted, set)
+			}
+			continue
+		}
+		setID := CalculateTransactionSetID(set)
+		if _, exists := tp.transactionSets[setID]; exists && !arrivals[i].IsZero() {
+			tp.transactionSetArrivals[setID] = arrivals[i]
+		}
+	}
+	return conflicted
+}
+
+// SetAge returns how long ago the transaction set with the given id arrived
+// in the transaction pool. Zero is returned if the set is not in the pool.
+func (tp *TransactionPool) SetAge(id TransactionSetID) time.Duration {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	arrival, exists := tp.transactionSetArrivals[id]
+	if !exists {
+		return 0
+	}
+	return tp.clock.now().Sub(arrival)
+}
+
+// evictExpiredSets rebuilds the transaction pool without the transaction sets
+// that arrived more than maxAge ago, along with any sets that depend on them.
+// false is returned if no set has expired, in which case the pool is left
+// untouched.
+func (tp *TransactionPool) evictExpiredSets() bool {
+	now := tp.clock.now()
+	expired := false
+	var sets [][]types.Transaction
+	var arrivals []time.Time
+	for setID, tSet := range tp.transactionSets {
+		arrival := tp.transactionSetArrivals[setID]
+		if now.Sub(arrival) > tp.maxAge {
+			expired = true
+		}
+		sets = append(sets, tSet)
+		arrivals = append(arrivals, arrival)
+	}
+	if !expired {
+		return false
+	}
+	tp.readdTransactionSets(sets, arrivals)
+	return true
+}
+
+// threadedEvictExpiredSets periodically evicts the transaction sets that have
+// exceeded the maximum age, so that sets are evicted even while no blocks are
+// arriving. Subscribers are notified whenever sets are evicted. The loop
+// returns when the transaction pool is closed.
+func (tp *TransactionPool) threadedEvictExpiredSets() {
+	for {
+		select {
+		case <-time.After(ageSweepInterval):
+		case <-tp.closeChan:
+			return
+		}
+
+		tp.mu.Lock()
+		if !tp.evictExpiredSets() {
+			tp.mu.Unlock()
+			continue
+		}
+		tp.mu.Demote()
+		tp.updateSubscribersTransactions()
+		tp.mu.DemotedUnlock()
+	}
+}
+
+// SetMaxAge sets the maximum amount of time that a transaction set can remain
+// in the transaction pool without being confirmed. Transaction sets that are
+// already older than the new maximum are evicted immediately, along with any
+// sets that depend on them, and subscribers are notified. Older sets are also
+// evicted whenever a new block arrives, and by a periodic sweep.
+func (tp *TransactionPool) SetMaxAge(d time.Duration) {
+	tp.mu.Lock()
+	tp.maxAge = d
+
+	// Only notify subscribers if a transaction set has expired.
+	if !tp.evictExpiredSets() {
+		tp.mu.Unlock()
+		return
+	}
+	tp.mu.Demote()
+	tp.updateSubscribersTransactions()
+	tp.mu.DemotedUnlock()
+}
@@ -0,0 +1,137 @@
+package transactionpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// mockClock is a clock that only moves forward when it is advanced.
+type mockClock struct {
+	current time.Time
+	mu      sync.Mutex
+}
+
+// now returns the current time of the mock clock.
+func (mc *mockClock) now() time.Time {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.current
+}
+
+// advance moves the mock clock forward by 'd'.
+func (mc *mockClock) advance(d time.Duration) {
+	mc.mu.Lock()
+	mc.current = mc.current.Add(d)
+	mc.mu.Unlock()
+}
+
+// TestIntegrationSetMaxAge checks that the arrival time of each transaction
+// set is recorded, and that sets older than the maximum age are evicted.
+func TestIntegrationSetMaxAge(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationSetMaxAge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &mockClock{current: time.Now()}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.clock = clock
+	tpt.tpool.mu.Unlock()
+
+	// Add two arbitrary data transaction sets.
+	var setIDs []TransactionSetID
+	for i := 0; i < 2; i++ {
+		arbData := make([]byte, 1e3)
+		copy(arbData, modules.PrefixNonSia[:])
+		arbData[100] = byte(i)
+		ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+		err = tpt.tpool.AcceptTransactionSet(ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setIDs = append(setIDs, CalculateTransactionSetID(ts))
+		clock.advance(time.Hour)
+	}
+	if tpt.tpool.SetAge(setIDs[0]) != 2*time.Hour {
+		t.Fatal("set age is not being tracked:", tpt.tpool.SetAge(setIDs[0]))
+	}
+	if tpt.tpool.SetAge(TransactionSetID{}) != 0 {
+		t.Error("unknown set should have an age of zero")
+	}
+
+	// Setting a maximum age that no set has reached should not evict
+	// anything or notify subscribers.
+	var cs countingSubscriber
+	tpt.tpool.TransactionPoolSubscribe(&cs)
+	tpt.tpool.SetMaxAge(3 * time.Hour)
+	if len(tpt.tpool.transactionSets) != 2 || len(cs.updates) != 1 {
+		t.Fatal("sets were evicted before reaching the maximum age")
+	}
+
+	// Lower the maximum age so that only the first set is evicted.
+	tpt.tpool.SetMaxAge(90 * time.Minute)
+	if _, exists := tpt.tpool.transactionSets[setIDs[0]]; exists {
+		t.Error("expired set was not evicted")
+	}
+	if _, exists := tpt.tpool.transactionSets[setIDs[1]]; !exists {
+		t.Error("unexpired set was evicted")
+	}
+	if len(cs.updates) != 2 || len(cs.updates[1]) != 1 {
+		t.Error("subscribers were not notified of the eviction")
+	}
+	if tpt.tpool.SetAge(setIDs[1]) != time.Hour {
+		t.Error("arrival time was not preserved for the remaining set")
+	}
+}
+
+// TestIntegrationAgeSweep checks that transaction sets that exceed the maximum
+// age are evicted by the background sweep, without a new block or a call to
+// SetMaxAge.
+func TestIntegrationAgeSweep(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationAgeSweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &mockClock{current: time.Now()}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.clock = clock
+	tpt.tpool.mu.Unlock()
+
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	err = tpt.tpool.AcceptTransactionSet(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cs countingSubscriber
+	tpt.tpool.TransactionPoolSubscribe(&cs)
+	tpt.tpool.SetMaxAge(time.Hour)
+
+	// Let the set expire, and wait for the sweep to evict it. The pool lock
+	// is held while the subscribers are notified, so the updates can be
+	// inspected once the set is gone.
+	clock.advance(2 * time.Hour)
+	evicted := false
+	for i := 0; i < 100 && !evicted; i++ {
+		time.Sleep(10 * time.Millisecond)
+		tpt.tpool.mu.Lock()
+		evicted = len(tpt.tpool.transactionSets) == 0
+		tpt.tpool.mu.Unlock()
+	}
+	if !evicted {
+		t.Fatal("expired set was not evicted by the sweep")
+	}
+	if len(cs.updates) != 2 || len(cs.updates[1]) != 0 {
+		t.Error("subscribers were not notified of the eviction")
+	}
+}
@@ -0,0 +1,22 @@
+package transactionpool
+
+import (
+	"time"
+)
+
+type (
+	// clock provides the current time to the transaction pool. Arrival times
+	// and the age of transaction sets are measured using the clock so that
+	// tests can control the passage of time.
+	clock interface {
+		now() time.Time
+	}
+
+	// productionClock implements the clock using the system time.
+	productionClock struct{}
+)
+
+// now returns the current system time.
+func (productionClock) now() time.Time {
+	return time.Now()
+}
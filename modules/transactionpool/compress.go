@@ -0,0 +1,114 @@
+package transactionpool
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// compressTransactionSet returns the flate compressed encoding of 'ts'.
+func compressTransactionSet(ts []types.Transaction) []byte {
+	var buf bytes.Buffer
+	// NewWriter only returns an error for an invalid compression level.
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(encoding.Marshal(ts))
+	w.Close()
+	return buf.Bytes()
+}
+
+// decompressTransactionSet decodes a transaction set that was compressed by
+// compressTransactionSet. The decompressed set may be no larger than a block.
+func decompressTransactionSet(b []byte) ([]types.Transaction, error) {
+	r := flate.NewReader(bytes.NewReader(b))
+	defer r.Close()
+	var ts []types.Transaction
+	err := encoding.NewDecoder(io.LimitReader(r, int64(types.BlockSizeLimit))).Decode(&ts)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// splitCompressionPeers splits 'peers' into the peers that are sent
+// uncompressed transaction sets and the peers that support compressed sets.
+// Peers that are no longer being broadcast to are forgotten, and have to
+// announce their support again when they reconnect.
+func (tp *TransactionPool) splitCompressionPeers(peers []modules.Peer) (plain, compressed []modules.Peer) {
+	current := make(map[modules.NetAddress]struct{})
+	for _, p := range peers {
+		current[p.NetAddress] = struct{}{}
+		if _, supported := tp.compressionPeers[p.NetAddress]; supported {
+			compressed = append(compressed, p)
+		} else {
+			plain = append(plain, p)
+		}
+	}
+	for addr := range tp.compressionPeers {
+		if _, exists := current[addr]; !exists {
+			delete(tp.compressionPeers, addr)
+		}
+	}
+	return plain, compressed
+}
+
+// shareCompressionSupport is an RPC that tells a peer that the pool accepts
+// compressed transaction sets. Only pools that accept compressed sets call the
+// RPC, so the calling peer is recorded as supporting them as well.
+func (tp *TransactionPool) shareCompressionSupport(conn modules.PeerConn) error {
+	tp.mu.Lock()
+	tp.compressionPeers[modules.NetAddress(conn.RemoteAddr().String())] = struct{}{}
+	tp.mu.Unlock()
+	return encoding.WriteObject(conn, true)
+}
+
+// requestCompressionSupport is called upon connecting to a peer, and records
+// the peer as supporting compressed transaction sets if it answers the
+// CompressionSupport RPC. Peers that do not know the RPC close the connection
+// without answering.
+func (tp *TransactionPool) requestCompressionSupport(conn modules.PeerConn) error {
+	var supported bool
+	err := encoding.ReadObject(conn, &supported, 1)
+	if err != nil {
+		return err
+	}
+	if supported {
+		tp.mu.Lock()
+		tp.compressionPeers[modules.NetAddress(conn.RemoteAddr().String())] = struct{}{}
+		tp.mu.Unlock()
+	}
+	return nil
+}
+
+// relayCompressedTransactionSet is an RPC that accepts a compressed
+// transaction set from a peer. Apart from the encoding of the set, it behaves
+// like relayTransactionSet.
+func (tp *TransactionPool) relayCompressedTransactionSet(conn modules.PeerConn) error {
+	var b []byte
+	addr := modules.NetAddress(conn.RemoteAddr().String())
+	err := encoding.ReadObject(conn, &b, types.BlockSizeLimit)
+	if err != nil {
+		tp.gateway.Disconnect(addr)
+		return err
+	}
+	ts, err := decompressTransactionSet(b)
+	if err != nil {
+		tp.gateway.Disconnect(addr)
+		return err
+	}
+	return tp.acceptRelayedSet(conn, ts)
+}
+
+// SetBroadcastCompression enables or disables compression of the transaction
+// sets that the pool broadcasts. When enabled, peers that announced support
+// for compressed sets when connecting are sent compressed sets, while other
+// peers continue to receive uncompressed sets. Compression is disabled by
+// default.
+func (tp *TransactionPool) SetBroadcastCompression(enabled bool) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.broadcastCompression = enabled
+}
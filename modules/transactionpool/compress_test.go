@@ -0,0 +1,201 @@
+package transactionpool
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// broadcastCall records the arguments of a call to
+// Gateway.BroadcastExceptWithContext.
+type broadcastCall struct {
+	name  string
+	obj   interface{}
+	peers []modules.Peer
+}
+
+// mockGatewayRecordBroadcast is a mock implementation of modules.Gateway that
+// records every broadcast made through BroadcastExceptWithContext.
+type mockGatewayRecordBroadcast struct {
+	modules.Gateway
+	peers []modules.Peer
+	calls chan broadcastCall
+}
+
+// PeersByVersion is a mock implementation of Gateway.PeersByVersion that
+// returns every mocked peer.
+func (g *mockGatewayRecordBroadcast) PeersByVersion(string) []modules.Peer {
+	return g.peers
+}
+
+// BroadcastExceptWithContext is a mock implementation of
+// Gateway.BroadcastExceptWithContext that records its arguments.
+func (g *mockGatewayRecordBroadcast) BroadcastExceptWithContext(_ context.Context, name string, obj interface{}, peers []modules.Peer, _ modules.NetAddress) {
+	g.calls <- broadcastCall{name, obj, peers}
+}
+
+// TestCompressedBroadcast checks that, with broadcast compression enabled,
+// transaction sets are sent compressed only to peers that announced support
+// for it, and that the compressed set is decoded into an identical set by the
+// receiving pool.
+func TestCompressedBroadcast(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestCompressedBroadcast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := createTpoolTester("TestCompressedBroadcastReceiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ourConn, theirConn := net.Pipe()
+	defer ourConn.Close()
+	defer theirConn.Close()
+	mg := &mockGatewayRecordBroadcast{
+		Gateway: tpt.tpool.gateway,
+		peers: []modules.Peer{
+			{NetAddress: "old:9981", Version: "0.5.2"},
+			{NetAddress: "released:9981", Version: "0.6.0"},
+			{NetAddress: "new:9981", Version: "0.6.0"},
+		},
+		calls: make(chan broadcastCall, 2),
+	}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.gateway = mg
+	tpt.tpool.compressionPeers["new:9981"] = struct{}{}
+	tpt.tpool.mu.Unlock()
+	tpt.tpool.SetBroadcastCompression(true)
+	// The receiver's mock is also installed before it accepts any set, because
+	// accepted sets are broadcast by goroutines that read the gateway.
+	dg := &mockGatewayCheckDisconnect{Gateway: receiver.tpool.gateway}
+	receiver.tpool.mu.Lock()
+	receiver.tpool.gateway = dg
+	receiver.tpool.mu.Unlock()
+
+	// The set is relayed through the RPC so that the broadcast excludes the
+	// peer that relayed it.
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	go encoding.WriteObject(theirConn, ts)
+	err = tpt.tpool.relayTransactionSet(ourConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The two broadcasts are made concurrently, so they may arrive in either
+	// order.
+	plain, compressed := <-mg.calls, <-mg.calls
+	if plain.name == "RelayCompressedTransactionSet" {
+		plain, compressed = compressed, plain
+	}
+	if plain.name != "RelayTransactionSet" || len(plain.peers) != 2 || plain.peers[0].NetAddress != "old:9981" || plain.peers[1].NetAddress != "released:9981" {
+		t.Fatal("uncompressed set should only be sent to the old and released peers, got", plain.name, plain.peers)
+	}
+	if compressed.name != "RelayCompressedTransactionSet" || len(compressed.peers) != 1 || compressed.peers[0].NetAddress != "new:9981" {
+		t.Fatal("compressed set should only be sent to the new peer, got", compressed.name, compressed.peers)
+	}
+	payload, ok := compressed.obj.([]byte)
+	if !ok {
+		t.Fatalf("compressed set was broadcast as %T", compressed.obj)
+	}
+	if len(payload) >= len(encoding.Marshal(ts)) {
+		t.Error("compressed set is not smaller than the uncompressed set")
+	}
+
+	// Deliver the compressed set to the receiving pool.
+	recvConn, sendConn := net.Pipe()
+	defer recvConn.Close()
+	defer sendConn.Close()
+	go encoding.WriteObject(sendConn, payload)
+	err = receiver.tpool.relayCompressedTransactionSet(recvConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := receiver.tpool.TransactionList()
+	if !bytes.Equal(encoding.Marshal(received), encoding.Marshal(ts)) {
+		t.Fatal("received transaction set does not match the broadcast set")
+	}
+
+	// A malformed compressed set disconnects the peer.
+	recvConn, sendConn = net.Pipe()
+	defer recvConn.Close()
+	defer sendConn.Close()
+	go encoding.WriteObject(sendConn, []byte{1, 2, 3})
+	err = receiver.tpool.relayCompressedTransactionSet(recvConn)
+	if err == nil {
+		t.Fatal("expected an error when relaying a malformed compressed set")
+	}
+	if len(dg.disconnected) != 1 {
+		t.Fatal("peer was not disconnected after sending a malformed compressed set:", dg.disconnected)
+	}
+}
+
+// TestCompressedRelayBetweenGateways checks that two pools connected through
+// real gateways learn that they both support compressed transaction sets, and
+// that a set broadcast by one of them reaches the other in compressed form.
+func TestCompressedRelayBetweenGateways(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	sender, err := createTpoolTester("TestCompressedRelayBetweenGatewaysSender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := createTpoolTester("TestCompressedRelayBetweenGatewaysReceiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.gateway.Connect(receiver.gateway.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for both pools to learn that the other supports compression.
+	supports := func(tp *TransactionPool, addr modules.NetAddress) bool {
+		tp.mu.RLock()
+		defer tp.mu.RUnlock()
+		_, exists := tp.compressionPeers[addr]
+		return exists
+	}
+	var senderAddr modules.NetAddress
+	for _, p := range receiver.gateway.Peers() {
+		senderAddr = p.NetAddress
+	}
+	for i := 0; i < 50 && !(supports(sender.tpool, receiver.gateway.Address()) && supports(receiver.tpool, senderAddr)); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !supports(sender.tpool, receiver.gateway.Address()) {
+		t.Fatal("sender did not learn that the receiver supports compression")
+	}
+	if !supports(receiver.tpool, senderAddr) {
+		t.Fatal("receiver did not learn that the sender supports compression")
+	}
+
+	// The receiver is only sent the compressed set, so it can only learn of
+	// the set through the compressed relay.
+	sender.tpool.SetBroadcastCompression(true)
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	err = sender.tpool.AcceptTransactionSet(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && len(receiver.tpool.TransactionList()) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	received := receiver.tpool.TransactionList()
+	if !bytes.Equal(encoding.Marshal(received), encoding.Marshal(ts)) {
+		t.Fatal("receiver did not get the compressed transaction set")
+	}
+}
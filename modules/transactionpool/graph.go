@@ -0,0 +1,322 @@
+package transactionpool
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// graphLabelLen is the number of hex characters of a transaction id that are
+// used to label the transaction in the dependency graph.
+const graphLabelLen = 12
+
+// transactionSetIDSlice is a slice of transaction set ids that can be sorted
+// using the sort package.
+type transactionSetIDSlice []TransactionSetID
+
+// Len returns the number of ids in the slice.
+func (tsids transactionSetIDSlice) Len() int { return len(tsids) }
+
+// Less returns whether element 'i' sorts before element 'j' in byte-order.
+func (tsids transactionSetIDSlice) Less(i, j int) bool {
+	return bytes.Compare(tsids[i][:], tsids[j][:]) < 0
+}
+
+// Swap swaps two elements in the slice.
+func (tsids transactionSetIDSlice) Swap(i, j int) { tsids[i], tsids[j] = tsids[j], tsids[i] }
+
+// createdObjectIDs returns the ids of all objects created by a transaction.
+func createdObjectIDs(t types.Transaction) []ObjectID {
+	var oids []ObjectID
+	for i := range t.SiacoinOutputs {
+		oids = append(oids, ObjectID(t.SiacoinOutputID(uint64(i))))
+	}
+	for i := range t.FileContracts {
+		oids = append(oids, ObjectID(t.FileContractID(uint64(i))))
+	}
+	for i := range t.SiafundOutputs {
+		oids = append(oids, ObjectID(t.SiafundOutputID(uint64(i))))
+	}
+	return oids
+}
+
+// consumedObjectIDs returns the ids of all objects that a transaction spends
+// or otherwise depends upon.
+func consumedObjectIDs(t types.Transaction) []ObjectID {
+	var oids []ObjectID
+	for _, sci := range t.SiacoinInputs {
+		oids = append(oids, ObjectID(sci.ParentID))
+	}
+	for _, fcr := range t.FileContractRevisions {
+		oids = append(oids, ObjectID(fcr.ParentID))
+	}
+	for _, sp := range t.StorageProofs {
+		oids = append(oids, ObjectID(sp.ParentID))
+	}
+	for _, sfi := range t.SiafundInputs {
+		oids = append(oids, ObjectID(sfi.ParentID))
+	}
+	return oids
+}
+
+// A dependencyEdge links a transaction in the pool to a transaction that
+// spends an object created by it.
+type dependencyEdge struct {
+	parent, child types.TransactionID
+}
+
+// dependencyEdges returns the ids of the transaction sets in the pool sorted
+// by id, along with an edge from each parent transaction to every transaction
+// that spends an object created by the parent. The set that holds each spent
+// object is looked up in knownObjects, and only that set is searched for the
+// parent; objects that are not known to the pool were created by the
+// consensus set. Duplicate edges are suppressed, as a child may spend
+// multiple outputs of the same parent. The edges are ordered by the set and
+// position of the child.
+func (tp *TransactionPool) dependencyEdges() (transactionSetIDSlice, []dependencyEdge) {
+	setIDs := make(transactionSetIDSlice, 0, len(tp.transactionSets))
+	for setID := range tp.transactionSets {
+		setIDs = append(setIDs, setID)
+	}
+	sort.Sort(setIDs)
+
+	// The objects created by a set are only indexed once an object held by
+	// the set is spent.
+	creators := make(map[TransactionSetID]map[ObjectID]types.TransactionID)
+	setCreators := func(setID TransactionSetID) map[ObjectID]types.TransactionID {
+		if c, exists := creators[setID]; exists {
+			return c
+		}
+		c := make(map[ObjectID]types.TransactionID)
+		for _, txn := range tp.transactionSets[setID] {
+			txid := txn.ID()
+			for _, oid := range createdObjectIDs(txn) {
+				c[oid] = txid
+			}
+		}
+		creators[setID] = c
+		return c
+	}
+
+	var edges []dependencyEdge
+	found := make(map[dependencyEdge]struct{})
+	for _, setID := range setIDs {
+		for _, txn := range tp.transactionSets[setID] {
+			txid := txn.ID()
+			for _, oid := range consumedObjectIDs(txn) {
+				owner, exists := tp.knownObjects[oid]
+				if !exists {
+					continue
+				}
+				parent, exists := setCreators(owner)[oid]
+				if !exists || parent == txid {
+					continue
+				}
+				e := dependencyEdge{parent, txid}
+				if _, exists := found[e]; exists {
+					continue
+				}
+				found[e] = struct{}{}
+				edges = append(edges, e)
+			}
+		}
+	}
+	return setIDs, edges
+}
+
+// DependencyGraph returns, for every transaction set in the pool, the sets
+// that it spends objects from and the sets that spend objects created by it,
+// along with the parents and children of every transaction in the set. The
+// pool merges a set with the sets that it depends upon when the set is
+// accepted, so most dependencies are between transactions of the same set.
+// The graph is a snapshot of the pool taken under the pool lock. The sets,
+// and the parents and children of each set, are sorted by id. The
+// transactions of each set are listed in the order of the set.
+func (tp *TransactionPool) DependencyGraph() modules.TpoolGraph {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	setIDs, edges := tp.dependencyEdges()
+	txnSets := make(map[types.TransactionID]TransactionSetID)
+	for _, setID := range setIDs {
+		for _, txn := range tp.transactionSets[setID] {
+			txnSets[txn.ID()] = setID
+		}
+	}
+
+	// Record the edges of each transaction, and of each set for edges that
+	// cross between sets.
+	txnParents := make(map[types.TransactionID][]types.TransactionID)
+	txnChildren := make(map[types.TransactionID][]types.TransactionID)
+	parents := make(map[TransactionSetID]map[TransactionSetID]struct{})
+	children := make(map[TransactionSetID]map[TransactionSetID]struct{})
+	for _, e := range edges {
+		txnParents[e.child] = append(txnParents[e.child], e.parent)
+		txnChildren[e.parent] = append(txnChildren[e.parent], e.child)
+		parentSet, childSet := txnSets[e.parent], txnSets[e.child]
+		if parentSet == childSet {
+			continue
+		}
+		if parents[childSet] == nil {
+			parents[childSet] = make(map[TransactionSetID]struct{})
+		}
+		if children[parentSet] == nil {
+			children[parentSet] = make(map[TransactionSetID]struct{})
+		}
+		parents[childSet][parentSet] = struct{}{}
+		children[parentSet][childSet] = struct{}{}
+	}
+
+	sortedIDs := func(ids map[TransactionSetID]struct{}) []crypto.Hash {
+		sorted := make(transactionSetIDSlice, 0, len(ids))
+		for id := range ids {
+			sorted = append(sorted, id)
+		}
+		sort.Sort(sorted)
+		hashes := make([]crypto.Hash, len(sorted))
+		for i, id := range sorted {
+			hashes[i] = crypto.Hash(id)
+		}
+		return hashes
+	}
+	graph := modules.TpoolGraph{Sets: make([]modules.TpoolGraphSet, 0, len(setIDs))}
+	for _, setID := range setIDs {
+		set := tp.transactionSets[setID]
+		txns := make([]modules.TpoolGraphTransaction, 0, len(set))
+		for _, txn := range set {
+			txid := txn.ID()
+			txns = append(txns, modules.TpoolGraphTransaction{
+				ID:       txid,
+				Parents:  txnParents[txid],
+				Children: txnChildren[txid],
+			})
+		}
+		graph.Sets = append(graph.Sets, modules.TpoolGraphSet{
+			SetID:        crypto.Hash(setID),
+			Parents:      sortedIDs(parents[setID]),
+			Children:     sortedIDs(children[setID]),
+			Transactions: txns,
+		})
+	}
+	return graph
+}
+
+// ExportDependencyGraph returns a Graphviz DOT description of the unconfirmed
+// transactions in the pool. Each transaction set is drawn as a cluster, and an
+// edge is drawn from each parent transaction to every transaction that spends
+// an object created by the parent.
+func (tp *TransactionPool) ExportDependencyGraph() ([]byte, error) {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	setIDs, edges := tp.dependencyEdges()
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "digraph transactionpool {")
+	fmt.Fprintln(&buf, "\tnode [shape=box];")
+
+	// Draw every transaction, grouped by transaction set.
+	for i, setID := range setIDs {
+		fmt.Fprintf(&buf, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(&buf, "\t\tlabel=%q;\n", "set "+crypto.Hash(setID).String()[:graphLabelLen])
+		for _, txn := range tp.transactionSets[setID] {
+			txid := txn.ID()
+			fmt.Fprintf(&buf, "\t\t%q [label=%q];\n", txid.String(), txid.String()[:graphLabelLen])
+		}
+		fmt.Fprintln(&buf, "\t}")
+	}
+
+	// Draw an edge from each parent to its children.
+	for _, e := range edges {
+		fmt.Fprintf(&buf, "\t%q -> %q;\n", e.parent.String(), e.child.String())
+	}
+	fmt.Fprintln(&buf, "}")
+	return buf.Bytes(), nil
+}
+
+// expired returns true if the transaction could not be included in a block at
+// the given height because a file contract or file contract revision in the
+// transaction has a proof window that has already opened.
+func expired(t types.Transaction, height types.BlockHeight) bool {
+	for _, fc := range t.FileContracts {
+		if fc.WindowStart <= height {
+			return true
+		}
+	}
+	for _, fcr := range t.FileContractRevisions {
+		if fcr.NewWindowStart <= height {
+			return true
+		}
+	}
+	return false
+}
+
+// MineableTransactions returns the transactions in the pool that could be
+// included in the next block. A transaction is mineable if it has not expired
+// and every parent that it depends upon within the pool is also mineable.
+// Transactions that depend on objects created outside of the pool are assumed
+// to depend on the consensus set, which the pool has already checked. The
+// transactions are returned with parents ahead of their children.
+func (tp *TransactionPool) MineableTransactions() []types.Transaction {
+	// The consensus set must be queried before grabbing the lock, as the
+	// consensus set holds its own lock while updating the transaction pool.
+	height := tp.consensusSet.Height() + 1
+
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	// Sort the set ids so that the output is deterministic, and record which
+	// objects are created within the pool.
+	setIDs := make(transactionSetIDSlice, 0, len(tp.transactionSets))
+	for setID := range tp.transactionSets {
+		setIDs = append(setIDs, setID)
+	}
+	sort.Sort(setIDs)
+	var pending []types.Transaction
+	poolObjects := make(map[ObjectID]struct{})
+	for _, setID := range setIDs {
+		for _, txn := range tp.transactionSets[setID] {
+			pending = append(pending, txn)
+			for _, oid := range createdObjectIDs(txn) {
+				poolObjects[oid] = struct{}{}
+			}
+		}
+	}
+
+	// Repeatedly sweep the pending transactions, moving every transaction
+	// whose parents are all mineable into the output. Sweeping stops once a
+	// pass makes no progress; the remaining transactions are waiting on a
+	// parent that cannot be mined.
+	var mineable []types.Transaction
+	mineableObjects := make(map[ObjectID]struct{})
+	for progress := true; progress; {
+		progress = false
+		var waiting []types.Transaction
+		for _, txn := range pending {
+			ready := !expired(txn, height)
+			for _, oid := range consumedObjectIDs(txn) {
+				_, inPool := poolObjects[oid]
+				_, isMineable := mineableObjects[oid]
+				if inPool && !isMineable {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				waiting = append(waiting, txn)
+				continue
+			}
+			mineable = append(mineable, txn)
+			for _, oid := range createdObjectIDs(txn) {
+				mineableObjects[oid] = struct{}{}
+			}
+			progress = true
+		}
+		pending = waiting
+	}
+	return mineable
+}
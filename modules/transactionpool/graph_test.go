@@ -0,0 +1,237 @@
+package transactionpool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationExportDependencyGraph checks that the dependency graph
+// contains every transaction in the pool and an edge from each parent to its
+// child.
+func TestIntegrationExportDependencyGraph(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationExportDependencyGraph")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty pool should still produce a valid graph.
+	graph, err := tpt.tpool.ExportDependencyGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(graph, []byte("digraph transactionpool {")) || !bytes.HasSuffix(graph, []byte("}\n")) {
+		t.Fatal("empty pool did not produce a valid graph:", string(graph))
+	}
+	if bytes.Contains(graph, []byte("->")) {
+		t.Error("empty pool should not have any edges")
+	}
+
+	// Submit a parent and child in separate calls, the same way as
+	// TestIntegrationTransactionChild.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) != 2 {
+		t.Fatal("test requires a parent and a child transaction")
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err = tpt.tpool.ExportDependencyGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentID := txnSet[0].ID().String()
+	childID := txnSet[1].ID().String()
+	for _, id := range []string{parentID, childID} {
+		if !strings.Contains(string(graph), "\""+id+"\" [label=") {
+			t.Error("graph is missing transaction", id)
+		}
+	}
+	edge := "\"" + parentID + "\" -> \"" + childID + "\";"
+	if strings.Count(string(graph), edge) != 1 {
+		t.Error("graph should contain exactly one edge from the parent to the child:", string(graph))
+	}
+	if strings.Count(string(graph), "subgraph cluster_") != len(tpt.tpool.transactionSets) {
+		t.Error("graph should contain one cluster per transaction set")
+	}
+}
+
+// TestIntegrationDependencyGraph checks that a child submitted after its
+// parent is merged into the parent's set, and that the graph links each
+// transaction to the transactions that it spends from.
+func TestIntegrationDependencyGraph(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationDependencyGraph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.DependencyGraph().Sets) != 0 {
+		t.Fatal("empty pool should have an empty graph")
+	}
+
+	// Submit a parent and child in separate calls, the same way as
+	// TestIntegrationTransactionChild. The pool merges them into one set.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) != 2 {
+		t.Fatal("test requires a parent and a child transaction")
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := tpt.tpool.DependencyGraph()
+	if len(graph.Sets) != 1 || len(graph.Sets[0].Parents) != 0 || len(graph.Sets[0].Children) != 0 {
+		t.Fatal("parent and child should form a single set without dependencies:", graph.Sets)
+	}
+	txns := graph.Sets[0].Transactions
+	if len(txns) != 2 || txns[0].ID != txnSet[0].ID() || txns[1].ID != txnSet[1].ID() {
+		t.Fatal("set does not list the parent and child transactions in order")
+	}
+	if len(txns[0].Parents) != 0 || len(txns[0].Children) != 1 || txns[0].Children[0] != txnSet[1].ID() {
+		t.Error("parent transaction has the wrong dependencies:", txns[0].Parents, txns[0].Children)
+	}
+	if len(txns[1].Parents) != 1 || txns[1].Parents[0] != txnSet[0].ID() || len(txns[1].Children) != 0 {
+		t.Error("child transaction has the wrong dependencies:", txns[1].Parents, txns[1].Children)
+	}
+
+	// A second payment is funded from the unconfirmed change of the first
+	// parent. The pool merges it into the same set, and the graph links it to
+	// the first parent across the two submitted sets.
+	txnBuilder = tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	second, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph = tpt.tpool.DependencyGraph()
+	if len(graph.Sets) != 1 || len(graph.Sets[0].Transactions) != len(txnSet)+len(second) {
+		t.Fatal("second payment should be merged into the set of the first")
+	}
+	var linked bool
+	for _, txn := range graph.Sets[0].Transactions {
+		if txn.ID != second[0].ID() {
+			continue
+		}
+		for _, parent := range txn.Parents {
+			if parent == txnSet[0].ID() {
+				linked = true
+			}
+		}
+	}
+	if !linked {
+		t.Error("graph does not link the second payment to the parent it spends from")
+	}
+}
+
+// TestIntegrationMineableTransactions checks that MineableTransactions
+// returns parents ahead of their children, and excludes expired transactions
+// along with every transaction that depends on them.
+func TestIntegrationMineableTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationMineableTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.MineableTransactions()) != 0 {
+		t.Fatal("empty pool should not have any mineable transactions")
+	}
+
+	// Submit a parent and child in separate calls, the same way as
+	// TestIntegrationTransactionChild. Both should be mineable.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) != 2 {
+		t.Fatal("test requires a parent and a child transaction")
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	mineable := tpt.tpool.MineableTransactions()
+	if len(mineable) != 2 || mineable[0].ID() != txnSet[0].ID() || mineable[1].ID() != txnSet[1].ID() {
+		t.Fatal("parent and child should both be mineable, parent first:", len(mineable))
+	}
+
+	// Add an expired file contract and a child spending an output of the
+	// expired transaction directly to the pool. Neither should be mineable.
+	expiredTxn := types.Transaction{
+		FileContracts:  []types.FileContract{{WindowStart: tpt.cs.Height()}},
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(1)}},
+	}
+	childTxn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: expiredTxn.SiacoinOutputID(0)}},
+	}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.transactionSets[CalculateTransactionSetID([]types.Transaction{expiredTxn})] = []types.Transaction{expiredTxn}
+	tpt.tpool.transactionSets[CalculateTransactionSetID([]types.Transaction{childTxn})] = []types.Transaction{childTxn}
+	tpt.tpool.mu.Unlock()
+	mineable = tpt.tpool.MineableTransactions()
+	if len(mineable) != 2 {
+		t.Fatal("expired transaction or its child was reported as mineable:", len(mineable))
+	}
+	for _, txn := range mineable {
+		if txn.ID() == expiredTxn.ID() || txn.ID() == childTxn.ID() {
+			t.Error("expired transaction or its child was reported as mineable")
+		}
+	}
+}
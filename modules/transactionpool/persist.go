@@ -0,0 +1,239 @@
+package transactionpool
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	logFile         = modules.TransactionPoolDir + ".log"
+	persistFile     = modules.TransactionPoolDir + ".json"
+	persistMetadata = persist.Metadata{
+		Header:  "Transaction Pool Persistence",
+		Version: "0.6.0",
+	}
+
+	// saveInterval is how often the transaction pool saves its unconfirmed
+	// transaction sets to disk, so that they survive a crash. The sets are
+	// also saved when the pool is closed.
+	saveInterval = func() time.Duration {
+		switch build.Release {
+		case "dev":
+			return time.Minute
+		case "standard":
+			return 2 * time.Minute
+		case "testing":
+			return 50 * time.Millisecond
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
+type (
+	// persistence contains the unconfirmed transaction sets of the
+	// transaction pool, along with the time that each set arrived, and the
+	// transactions that were recently confirmed or conflicted.
+	persistence struct {
+		TransactionSets [][]types.Transaction
+		Arrivals        []time.Time
+
+		ConfirmedTransactions  []persistedStatus
+		ConflictedTransactions []persistedStatus
+	}
+
+	// persistedStatus is a transaction that was confirmed or conflicted, along
+	// with the height at which that happened.
+	persistedStatus struct {
+		ID     types.TransactionID
+		Height types.BlockHeight
+	}
+
+	// statusesByID sorts persisted statuses by transaction id, so that the
+	// persist file is deterministic.
+	statusesByID []persistedStatus
+
+	// setsByArrival sorts transaction sets by the time that they arrived in
+	// the pool, so that parents are re-added before the sets that depend on
+	// them.
+	setsByArrival persistence
+)
+
+// Len implements sort.Interface.
+func (sba setsByArrival) Len() int { return len(sba.TransactionSets) }
+
+// Less implements sort.Interface.
+func (sba setsByArrival) Less(i, j int) bool { return sba.Arrivals[i].Before(sba.Arrivals[j]) }
+
+// Swap implements sort.Interface.
+func (sba setsByArrival) Swap(i, j int) {
+	sba.TransactionSets[i], sba.TransactionSets[j] = sba.TransactionSets[j], sba.TransactionSets[i]
+	sba.Arrivals[i], sba.Arrivals[j] = sba.Arrivals[j], sba.Arrivals[i]
+}
+
+// Len implements sort.Interface.
+func (sbi statusesByID) Len() int { return len(sbi) }
+
+// Less implements sort.Interface.
+func (sbi statusesByID) Less(i, j int) bool { return bytes.Compare(sbi[i].ID[:], sbi[j].ID[:]) < 0 }
+
+// Swap implements sort.Interface.
+func (sbi statusesByID) Swap(i, j int) { sbi[i], sbi[j] = sbi[j], sbi[i] }
+
+// persistStatuses converts a map of transaction statuses into a list sorted by
+// transaction id.
+func persistStatuses(statuses map[types.TransactionID]types.BlockHeight) []persistedStatus {
+	var ps []persistedStatus
+	for id, height := range statuses {
+		ps = append(ps, persistedStatus{ID: id, Height: height})
+	}
+	sort.Sort(statusesByID(ps))
+	return ps
+}
+
+// loadStatuses adds the persisted statuses to 'statuses'. Statuses above the
+// current height, which were reverted while the pool was closed, and statuses
+// older than transactionStatusDepth blocks are skipped. Blocks that were
+// reverted and replaced while the pool was closed are not detected, so a
+// transaction of such a block may be reported as confirmed until the status
+// expires.
+func (tp *TransactionPool) loadStatuses(statuses map[types.TransactionID]types.BlockHeight, ps []persistedStatus) {
+	for _, s := range ps {
+		if s.Height > tp.blockHeight || tp.blockHeight-s.Height > transactionStatusDepth {
+			continue
+		}
+		statuses[s.ID] = s.Height
+	}
+}
+
+// persistData returns the unconfirmed transaction sets of the pool, sorted by
+// arrival time, and the statuses of the recently confirmed and conflicted
+// transactions.
+func (tp *TransactionPool) persistData() persistence {
+	var p persistence
+	for setID, tSet := range tp.transactionSets {
+		p.TransactionSets = append(p.TransactionSets, tSet)
+		p.Arrivals = append(p.Arrivals, tp.transactionSetArrivals[setID])
+	}
+	sort.Sort(setsByArrival(p))
+	p.ConfirmedTransactions = persistStatuses(tp.confirmedTransactions)
+	p.ConflictedTransactions = persistStatuses(tp.conflictedTransactions)
+	return p
+}
+
+// initPersist creates the transaction pool directory and logger, restores the
+// statuses of recently confirmed and conflicted transactions, and re-adds the
+// transaction sets that were last saved to disk. Every set goes through the
+// same checks as AcceptTransactionSet, and sets that have become invalid while
+// the pool was closed are dropped. Subscribers are notified of the re-added
+// sets.
+func (tp *TransactionPool) initPersist() error {
+	err := os.MkdirAll(tp.persistDir, 0700)
+	if err != nil {
+		return err
+	}
+	tp.log, err = persist.NewFileLogger(filepath.Join(tp.persistDir, logFile))
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(tp.persistDir, persistFile)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var p persistence
+	err = persist.LoadFile(persistMetadata, &p, filename)
+	if err != nil {
+		return err
+	}
+	if len(p.Arrivals) != len(p.TransactionSets) {
+		p.Arrivals = make([]time.Time, len(p.TransactionSets))
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.loadStatuses(tp.confirmedTransactions, p.ConfirmedTransactions)
+	tp.loadStatuses(tp.conflictedTransactions, p.ConflictedTransactions)
+	for i, set := range p.TransactionSets {
+		if tp.clock.now().Sub(p.Arrivals[i]) > tp.maxAge {
+			continue
+		}
+		err := tp.acceptTransactionSet(context.Background(), set, false)
+		if err != nil {
+			continue
+		}
+		setID := CalculateTransactionSetID(set)
+		if _, exists := tp.transactionSets[setID]; exists && !p.Arrivals[i].IsZero() {
+			tp.transactionSetArrivals[setID] = p.Arrivals[i]
+		}
+	}
+	tp.updateSubscribersTransactions()
+	return nil
+}
+
+// save saves the unconfirmed transaction sets of the pool to disk.
+func (tp *TransactionPool) save() error {
+	return persist.SaveFile(persistMetadata, tp.persistData(), filepath.Join(tp.persistDir, persistFile))
+}
+
+// threadedSave periodically saves the unconfirmed transaction sets of the
+// pool to disk, so that the sets are not lost if the pool is not closed
+// cleanly. A failed save is logged and retried at the next interval. The loop
+// returns when the transaction pool is closed.
+func (tp *TransactionPool) threadedSave() {
+	for {
+		select {
+		case <-time.After(saveInterval):
+		case <-tp.closeChan:
+			return
+		}
+
+		tp.mu.RLock()
+		err := tp.save()
+		tp.mu.RUnlock()
+		if err != nil {
+			tp.log.Println("WARN: could not save the transaction pool:", err)
+		}
+	}
+}
+
+// saveSync saves the unconfirmed transaction sets of the pool to disk, and
+// then syncs to disk.
+func (tp *TransactionPool) saveSync() error {
+	return persist.SaveFileSync(persistMetadata, tp.persistData(), filepath.Join(tp.persistDir, persistFile))
+}
+
+// Close saves the unconfirmed transaction sets of the transaction pool to disk
+// so that they can be re-added when the pool is next created, and
+// unsubscribes the pool from the consensus set. The background threads of the
+// pool are stopped and the logger is closed.
+func (tp *TransactionPool) Close() error {
+	// The consensus set lock may be held while it is waiting on the
+	// transaction pool lock, so the pool is unsubscribed before the pool lock
+	// is grabbed.
+	tp.consensusSet.Unsubscribe(tp)
+	tp.closeOnce.Do(func() { close(tp.closeChan) })
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	var errs []error
+	if err := tp.saveSync(); err != nil {
+		errs = append(errs, fmt.Errorf("save failed: %v", err))
+	}
+	if err := tp.log.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("log.Close failed: %v", err))
+	}
+	return build.JoinErrors(errs, "; ")
+}
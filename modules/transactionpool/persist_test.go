@@ -0,0 +1,120 @@
+package transactionpool
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationPersist checks that the transaction sets in the pool are
+// re-added after a restart, and that sets which became invalid while the pool
+// was closed are dropped.
+func TestIntegrationPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationPersist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restart closes the current transaction pool and creates a new one from
+	// the same persist directory. Each pool needs its own gateway, as the
+	// pool registers an RPC with the gateway.
+	restart := func(tp *TransactionPool, name string) *TransactionPool {
+		err := tp.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := gateway.New("localhost:0", filepath.Join(tp.persistDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		newTP, err := New(tpt.cs, g, tp.persistDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newTP
+	}
+
+	// Add a siacoin transaction and an arbitrary data transaction to the pool.
+	_, err = tpt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	arbTxn := types.Transaction{ArbitraryData: [][]byte{arbData}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{arbTxn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numTxns := len(tpt.tpool.TransactionList())
+	numSets := len(tpt.tpool.transactionSets)
+
+	// Both sets are still valid after a restart.
+	tp := restart(tpt.tpool, "gateway1")
+	if len(tp.TransactionList()) != numTxns || len(tp.transactionSets) != numSets {
+		t.Fatalf("expected %v transactions after restart, got %v", numTxns, len(tp.TransactionList()))
+	}
+	// Closing the old pool a second time does not panic.
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Close the pool again and confirm the sets while it is down. The
+	// siacoin transaction now spends outputs that have already been spent,
+	// and is dropped when the pool is restarted. The arbitrary data
+	// transaction is still valid.
+	tp = restart(tp, "gateway2")
+	err = tp.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) < numTxns {
+		t.Fatal("block does not contain the transactions of the pool")
+	}
+	g, err := gateway.New("localhost:0", filepath.Join(tp.persistDir, "gateway3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err = New(tpt.cs, g, tp.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns := tp.TransactionList()
+	if len(txns) != 1 || txns[0].ID() != arbTxn.ID() {
+		t.Error("expected only the arbitrary data transaction after restart, got", len(txns), "transactions")
+	}
+
+	// The pool is saved periodically, so its sets survive a crash.
+	arbData = make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	arbData[100] = 1
+	crashTxn := types.Transaction{ArbitraryData: [][]byte{arbData}}
+	err = tp.AcceptTransactionSet([]types.Transaction{crashTxn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(4 * saveInterval)
+	g, err = gateway.New("localhost:0", filepath.Join(tp.persistDir, "gateway4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashTP, err := New(tpt.cs, g, tp.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashTP.TransactionList()) != 2 {
+		t.Error("expected 2 transactions after a crash, got", len(crashTP.TransactionList()))
+	}
+}
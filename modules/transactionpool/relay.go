@@ -0,0 +1,77 @@
+package transactionpool
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// relayRateWindow is the window over which the transaction sets relayed
+	// by a peer are counted against the relay rate limit.
+	relayRateWindow = time.Minute
+)
+
+var (
+	errNegativeRelayRateLimit = errors.New("relay rate limit cannot be negative")
+)
+
+// relayWindow tracks the transaction sets relayed by a single peer within the
+// current rate limit window.
+type relayWindow struct {
+	start   time.Time
+	counted int
+	dropped int
+}
+
+// throttleRelay counts a transaction set relayed by 'addr' against the relay
+// rate limit. If the peer has already relayed the maximum number of sets in
+// the current window, the set should be dropped. A peer that keeps relaying
+// sets after reaching the limit, such that as many sets are dropped as the
+// limit allows, should be disconnected.
+func (tp *TransactionPool) throttleRelay(addr modules.NetAddress) (drop bool, disconnect bool) {
+	tp.relayMu.Lock()
+	defer tp.relayMu.Unlock()
+	if tp.relayRateLimit == 0 {
+		return false, false
+	}
+
+	now := tp.clock.now()
+	w, exists := tp.relayWindows[addr]
+	if !exists {
+		// Forget the peers whose windows have expired, so that the windows do
+		// not accumulate as peers come and go.
+		for peer, pw := range tp.relayWindows {
+			if now.Sub(pw.start) >= relayRateWindow {
+				delete(tp.relayWindows, peer)
+			}
+		}
+	}
+	if !exists || now.Sub(w.start) >= relayRateWindow {
+		w = &relayWindow{start: now}
+		tp.relayWindows[addr] = w
+	}
+	if w.counted < tp.relayRateLimit {
+		w.counted++
+		return false, false
+	}
+	w.dropped++
+	return true, w.dropped >= tp.relayRateLimit
+}
+
+// SetRelayRateLimit sets the number of transaction sets that a single peer
+// may relay to the pool per minute. Further sets from the peer are dropped
+// without being verified, and a peer that keeps relaying sets at twice the
+// limit is disconnected. Sets that the pool already has do not count against
+// the limit. A limit of zero, the default, disables rate limiting.
+func (tp *TransactionPool) SetRelayRateLimit(setsPerMinute int) error {
+	if setsPerMinute < 0 {
+		return errNegativeRelayRateLimit
+	}
+	tp.relayMu.Lock()
+	defer tp.relayMu.Unlock()
+	tp.relayRateLimit = setsPerMinute
+	tp.relayWindows = make(map[modules.NetAddress]*relayWindow)
+	return nil
+}
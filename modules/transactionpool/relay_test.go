@@ -0,0 +1,136 @@
+package transactionpool
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// mockGatewayCheckDisconnect is a mock implementation of modules.Gateway that
+// records the peers that the transaction pool asks to disconnect from.
+type mockGatewayCheckDisconnect struct {
+	modules.Gateway
+	disconnected []modules.NetAddress
+}
+
+// Disconnect is a mock implementation of Gateway.Disconnect that records the
+// address it receives as an argument.
+func (g *mockGatewayCheckDisconnect) Disconnect(addr modules.NetAddress) error {
+	g.disconnected = append(g.disconnected, addr)
+	return nil
+}
+
+// relaySet pushes 'ts' to the RelayTransactionSet RPC of the pool and returns
+// the error of the RPC.
+func relaySet(tp *TransactionPool, ts []types.Transaction) error {
+	ourConn, theirConn := net.Pipe()
+	defer ourConn.Close()
+	defer theirConn.Close()
+	go encoding.WriteObject(theirConn, ts)
+	return tp.relayTransactionSet(ourConn)
+}
+
+// TestRelayRateLimit floods the RelayTransactionSet RPC and checks that the
+// relay rate limit drops sets beyond the limit, ignores duplicates, and
+// disconnects a peer that keeps flooding the pool.
+func TestRelayRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestRelayRateLimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mg := &mockGatewayCheckDisconnect{Gateway: tpt.tpool.gateway}
+	clock := &mockClock{current: time.Now()}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.gateway = mg
+	tpt.tpool.clock = clock
+	tpt.tpool.mu.Unlock()
+
+	if tpt.tpool.SetRelayRateLimit(-1) != errNegativeRelayRateLimit {
+		t.Fatal("expected errNegativeRelayRateLimit")
+	}
+	const limit = 3
+	err = tpt.tpool.SetRelayRateLimit(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nextSet returns a new arbitrary data transaction set.
+	var nonce byte
+	nextSet := func() []types.Transaction {
+		nonce++
+		arbData := make([]byte, 100)
+		copy(arbData, modules.PrefixNonSia[:])
+		arbData[len(modules.PrefixNonSia)] = nonce
+		return []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	}
+
+	// The first sets are accepted, and duplicates do not count against the
+	// limit.
+	first := nextSet()
+	err = relaySet(tpt.tpool, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < limit; i++ {
+		err = relaySet(tpt.tpool, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < limit; i++ {
+		err = relaySet(tpt.tpool, nextSet())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tpt.tpool.TransactionList()) != limit {
+		t.Fatal("expected", limit, "transactions in the pool, got", len(tpt.tpool.TransactionList()))
+	}
+
+	// Duplicates do not count against the limit once it has been reached
+	// either.
+	for i := 0; i < 2*limit; i++ {
+		err = relaySet(tpt.tpool, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mg.disconnected) != 0 {
+		t.Fatal("peer was disconnected for relaying duplicates")
+	}
+
+	// Further sets are dropped, and the peer is disconnected once it has
+	// relayed twice the limit.
+	for i := 0; i < limit; i++ {
+		if len(mg.disconnected) != 0 {
+			t.Fatal("peer was disconnected too early")
+		}
+		err = relaySet(tpt.tpool, nextSet())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tpt.tpool.TransactionList()) != limit {
+		t.Fatal("dropped sets were added to the pool")
+	}
+	if len(mg.disconnected) != 1 {
+		t.Fatal("flooding peer was not disconnected")
+	}
+
+	// Once the window has passed, the peer can relay sets again.
+	clock.advance(relayRateWindow)
+	err = relaySet(tpt.tpool, nextSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != limit+1 {
+		t.Fatal("set was not accepted after the window passed")
+	}
+}
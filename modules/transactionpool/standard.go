@@ -3,6 +3,7 @@ package transactionpool
 import (
 	"errors"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -31,7 +32,13 @@ import (
 //		The arbitrary data field can be used to orchestrate soft-forks to Sia
 //		that add features. Legacy miners are at risk of creating invalid blocks
 //		if they include arbitrary data which has meanings that the legacy miner
-//		doesn't understand.
+//		doesn't understand. Data under a reserved prefix must also be well
+//		formed for that prefix.
+//
+// Rule: The amount of arbitrary data in a transaction set is limited.
+//		Arbitrary data is stored by every node on the network, so the
+//		transaction pool can be configured to reject sets that carry large
+//		volumes of it.
 //
 // Rule: The transaction set size is limited.
 //		A group of dependent transactions cannot exceed 100kb to limit how
@@ -100,8 +107,25 @@ func (tp *TransactionPool) IsStandardTransaction(t types.Transaction) error {
 	for _, arb := range t.ArbitraryData {
 		// Check for a whilelisted prefix.
 		copy(prefix[:], arb)
-		if prefix == modules.PrefixHostAnnouncement ||
-			prefix == modules.PrefixNonSia {
+		if prefix == modules.PrefixNonSia {
+			continue
+		}
+		// Host announcements must be well formed and have nothing trailing
+		// the signature, so that the reserved prefix cannot be used to
+		// smuggle arbitrary data into the pool.
+		if prefix == modules.PrefixHostAnnouncement {
+			addr, pk, err := modules.DecodeAnnouncement(arb)
+			if err != nil {
+				return errInvalidArbitraryData
+			}
+			ha := modules.HostAnnouncement{
+				Specifier:  modules.PrefixHostAnnouncement,
+				NetAddress: addr,
+				PublicKey:  pk,
+			}
+			if len(arb) != len(encoding.MarshalAll(ha, crypto.Signature{})) {
+				return errInvalidArbitraryData
+			}
 			continue
 		}
 
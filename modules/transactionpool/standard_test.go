@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -50,3 +52,139 @@ func TestIntegrationLargeTransactions(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestIntegrationArbitraryData checks that the transaction pool rejects
+// malformed data under reserved prefixes, and that the amount of arbitrary
+// data in a transaction set can be limited.
+func TestIntegrationArbitraryData(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationArbitraryData")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A malformed host announcement is rejected.
+	badAnnouncement := append(modules.PrefixHostAnnouncement[:], make([]byte, 32)...)
+	txn := types.Transaction{ArbitraryData: [][]byte{badAnnouncement}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != errInvalidArbitraryData {
+		t.Fatal("expected errInvalidArbitraryData, got", err)
+	}
+
+	// A well formed host announcement is accepted.
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+	announcement, err := modules.CreateAnnouncement("foo.com:1234", spk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn = types.Transaction{ArbitraryData: [][]byte{announcement}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Data trailing a well formed host announcement is rejected.
+	txn = types.Transaction{ArbitraryData: [][]byte{append(announcement, 1, 2, 3)}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != errInvalidArbitraryData {
+		t.Fatal("expected errInvalidArbitraryData, got", err)
+	}
+
+	// Non-Sia data is accepted up to the limit.
+	tpt.tpool.SetMaxArbitraryDataSize(100)
+	arbData := make([]byte, 100)
+	copy(arbData, modules.PrefixNonSia[:])
+	_, err = rand.Read(arbData[16:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn = types.Transaction{ArbitraryData: [][]byte{arbData}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	arbData = append(arbData, 0)
+	txn = types.Transaction{ArbitraryData: [][]byte{arbData}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != errLargeArbitraryData {
+		t.Fatal("expected errLargeArbitraryData, got", err)
+	}
+}
+
+// TestIntegrationMaxTransactionSetSize checks that the transaction pool
+// rejects sets above the maximum size, including sets that only exceed the
+// limit once they are merged with their parents in the pool.
+func TestIntegrationMaxTransactionSetSize(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationMaxTransactionSetSize")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tpt.tpool.SetMaxTransactionSetSize(0)
+	if err != errInvalidMaxTransactionSetSize {
+		t.Fatal("expected errInvalidMaxTransactionSetSize, got", err)
+	}
+
+	// Create a parent and a child transaction.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) <= 1 {
+		t.Fatal("test is invalid unless the transaction set has two or more transactions")
+	}
+	parentSize := len(encoding.Marshal(txnSet[:1]))
+	childSize := len(encoding.Marshal(txnSet[1:]))
+
+	// Each set fits within the limit on its own, but the child is merged
+	// with its parent.
+	limit := parentSize
+	if childSize > limit {
+		limit = childSize
+	}
+	err = tpt.tpool.SetMaxTransactionSetSize(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != errTransactionSetTooLarge {
+		t.Fatal("expected errTransactionSetTooLarge, got", err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != errTransactionSetTooLarge {
+		t.Fatal("expected errTransactionSetTooLarge, got", err)
+	}
+
+	// Raising the limit lets the child in.
+	err = tpt.tpool.SetMaxTransactionSetSize(parentSize + childSize + 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package transactionpool
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// transactionStatusDepth is the number of blocks for which the
+	// transaction pool remembers the transactions that were confirmed, and
+	// the transactions that were removed from the pool due to a conflict.
+	// Older transactions are reported as unknown.
+	transactionStatusDepth = 144
+)
+
+var (
+	errEmptyTransactionID = errors.New("cannot look up the status of an empty transaction id")
+)
+
+// updateConfirmedTransactions records the transactions of the applied blocks
+// in a consensus change as confirmed, and forgets the transactions of the
+// reverted blocks. Transactions that were confirmed or conflicted more than
+// transactionStatusDepth blocks ago are forgotten as well.
+func (tp *TransactionPool) updateConfirmedTransactions(cc modules.ConsensusChange) {
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			delete(tp.confirmedTransactions, txn.ID())
+		}
+		tp.blockHeight--
+	}
+	for _, block := range cc.AppliedBlocks {
+		// The genesis block is at height zero.
+		if block.ID() != types.GenesisBlock.ID() {
+			tp.blockHeight++
+		}
+		for _, txn := range block.Transactions {
+			tp.confirmedTransactions[txn.ID()] = tp.blockHeight
+			delete(tp.conflictedTransactions, txn.ID())
+		}
+	}
+
+	if tp.blockHeight < transactionStatusDepth {
+		return
+	}
+	cutoff := tp.blockHeight - transactionStatusDepth
+	for id, height := range tp.confirmedTransactions {
+		if height < cutoff {
+			delete(tp.confirmedTransactions, id)
+		}
+	}
+	for id, height := range tp.conflictedTransactions {
+		if height < cutoff {
+			delete(tp.conflictedTransactions, id)
+		}
+	}
+}
+
+// isDoubleSpend returns true if a transaction set was rejected with 'err'
+// because it spends an object that is already spent by another set in the
+// pool. Sets rejected by the consensus set are not double spends by this
+// measure, as the consensus set also rejects sets that are merely invalid.
+func isDoubleSpend(err error) bool {
+	_, ok := err.(modules.ConflictError)
+	return ok || err == errObjectConflict
+}
+
+// spendsConflict returns true if a transaction in 'ts' spends an object that
+// is also spent by a different transaction, as recorded in 'spenders'.
+func spendsConflict(ts []types.Transaction, spenders map[ObjectID]types.TransactionID) bool {
+	for _, txn := range ts {
+		txid := txn.ID()
+		for _, oid := range consumedObjectIDs(txn) {
+			if spender, exists := spenders[oid]; exists && spender != txid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markConflicted records the transactions of sets that were removed from the
+// pool due to a conflict. Transactions that have been confirmed are skipped.
+func (tp *TransactionPool) markConflicted(sets [][]types.Transaction) {
+	for _, set := range sets {
+		for _, txn := range set {
+			id := txn.ID()
+			if _, confirmed := tp.confirmedTransactions[id]; !confirmed {
+				tp.conflictedTransactions[id] = tp.blockHeight
+			}
+		}
+	}
+}
+
+// TransactionStatus returns the status of the transaction with the given id.
+// A transaction is unconfirmed while it is in the pool, and confirmed if it
+// appeared in one of the last transactionStatusDepth blocks. A transaction is
+// conflicted if it was removed from the pool in the same period because a
+// block or a replace-by-fee set spent one of its inputs, or spent an input of
+// one of its parents. The transaction pool only knows about transactions that
+// it has seen, so any other transaction is unknown. The statuses are saved
+// along with the unconfirmed transaction sets, so they survive a restart.
+func (tp *TransactionPool) TransactionStatus(id types.TransactionID) (modules.TxStatus, error) {
+	if id == (types.TransactionID{}) {
+		return modules.TxStatusUnknown, errEmptyTransactionID
+	}
+
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	for _, tSet := range tp.transactionSets {
+		for _, txn := range tSet {
+			if txn.ID() == id {
+				return modules.TxStatusUnconfirmed, nil
+			}
+		}
+	}
+	if _, exists := tp.confirmedTransactions[id]; exists {
+		return modules.TxStatusConfirmed, nil
+	}
+	if _, exists := tp.conflictedTransactions[id]; exists {
+		return modules.TxStatusConflicted, nil
+	}
+	return modules.TxStatusUnknown, nil
+}
@@ -0,0 +1,251 @@
+package transactionpool
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationTransactionStatus checks that the status of a transaction
+// follows it from the pool into a block, and that replaced transactions are
+// reported as conflicted.
+func TestIntegrationTransactionStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationTransactionStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create two sets that spend the same output, one paying a higher fee.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnSet, err := txnBuilder.Sign(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnIndex := len(txnSet) - 1
+	newSet := func(fee types.Currency) []types.Transaction {
+		set := make([]types.Transaction, len(txnSet))
+		copy(set, txnSet)
+		set[txnIndex].MinerFees = append(set[txnIndex].MinerFees, fee)
+		set[txnIndex].SiacoinOutputs = append(set[txnIndex].SiacoinOutputs, types.SiacoinOutput{Value: fund.Sub(fee)})
+		return set
+	}
+	lowSet := newSet(types.NewCurrency64(10))
+	highSet := newSet(types.NewCurrency64(100))
+	lowID := lowSet[txnIndex].ID()
+	highID := highSet[txnIndex].ID()
+
+	status := func(id types.TransactionID) modules.TxStatus {
+		s, err := tpt.tpool.TransactionStatus(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if status(lowID) != modules.TxStatusUnknown {
+		t.Error("transaction that was never submitted is not unknown")
+	}
+	err = tpt.tpool.AcceptTransactionSet(lowSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status(lowID) != modules.TxStatusUnconfirmed {
+		t.Error("transaction in the pool is not unconfirmed")
+	}
+
+	// Replace the low fee transaction.
+	tpt.tpool.SetReplaceByFee(true)
+	err = tpt.tpool.AcceptTransactionSet(highSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status(lowID) != modules.TxStatusConflicted {
+		t.Error("replaced transaction is not conflicted")
+	}
+	if status(highID) != modules.TxStatusUnconfirmed {
+		t.Error("replacement transaction is not unconfirmed")
+	}
+
+	// Mine the replacement.
+	block, _ := tpt.miner.FindBlock()
+	err = tpt.cs.AcceptBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status(highID) != modules.TxStatusConfirmed {
+		t.Error("mined transaction is not confirmed")
+	}
+	if status(lowID) != modules.TxStatusConflicted {
+		t.Error("replaced transaction is not conflicted after the block")
+	}
+
+	_, err = tpt.tpool.TransactionStatus(types.TransactionID{})
+	if err != errEmptyTransactionID {
+		t.Error("expected errEmptyTransactionID, got", err)
+	}
+
+	// The statuses survive a restart of the pool.
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gateway.New("localhost:0", filepath.Join(tpt.tpool.persistDir, "gateway2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, g, tpt.tpool.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status(highID) != modules.TxStatusConfirmed {
+		t.Error("confirmed status was not persisted")
+	}
+	if status(lowID) != modules.TxStatusConflicted {
+		t.Error("conflicted status was not persisted")
+	}
+}
+
+// TestIntegrationTransactionStatusLowFee checks that a set dropped from the
+// pool for paying too little in fees is not reported as conflicted, and that a
+// new pool starts counting from the height of the consensus set.
+func TestIntegrationTransactionStatusLowFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationTransactionStatusLowFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpt.tpool.blockHeight != tpt.cs.Height() {
+		t.Fatal("pool height does not match the consensus set:", tpt.tpool.blockHeight, tpt.cs.Height())
+	}
+	g, err := gateway.New("localhost:0", filepath.Join(tpt.tpool.persistDir, "newgateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := New(tpt.cs, g, filepath.Join(tpt.tpool.persistDir, "newtpool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp.blockHeight != tpt.cs.Height() {
+		t.Fatal("new pool does not start at the height of the consensus set:", tp.blockHeight, tpt.cs.Height())
+	}
+
+	// Add a set without fees, then raise the minimum fee and rebuild the
+	// pool. The set is dropped without being conflicted.
+	arbData := make([]byte, 100)
+	copy(arbData, modules.PrefixNonSia[:])
+	txn := types.Transaction{ArbitraryData: [][]byte{arbData}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.SetMinimumFee(types.SiacoinPrecision)
+	tpt.tpool.ProcessConsensusChange(modules.ConsensusChange{})
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("low fee set was not dropped from the pool")
+	}
+	status, err := tpt.tpool.TransactionStatus(txn.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != modules.TxStatusUnknown {
+		t.Error("set dropped for low fees should be unknown, got", status)
+	}
+}
+
+// TestIsDoubleSpend checks that only conflicts with the pool are counted as
+// double spends, and that sets rejected as invalid by the consensus set are
+// not.
+func TestIsDoubleSpend(t *testing.T) {
+	if !isDoubleSpend(modules.ConflictError{}) || !isDoubleSpend(errObjectConflict) {
+		t.Error("conflicts with the pool should be double spends")
+	}
+	if isDoubleSpend(modules.NewConsensusConflict("invalid signature")) || isDoubleSpend(errLowMinerFees) {
+		t.Error("invalid sets should not be double spends")
+	}
+}
+
+// TestIntegrationTransactionStatusBlockDoubleSpend checks that a transaction
+// in the pool is reported as conflicted once a block spends one of its inputs
+// in a different transaction.
+func TestIntegrationTransactionStatusBlockDoubleSpend(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestIntegrationTransactionStatusBlockDoubleSpend")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create two sets that spend the same output and pay the same fee.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnSet, err := txnBuilder.Sign(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnIndex := len(txnSet) - 1
+	fee := types.NewCurrency64(10)
+	newSet := func(data []byte) []types.Transaction {
+		set := make([]types.Transaction, len(txnSet))
+		copy(set, txnSet)
+		set[txnIndex].MinerFees = []types.Currency{fee}
+		set[txnIndex].SiacoinOutputs = []types.SiacoinOutput{{Value: fund.Sub(fee)}}
+		set[txnIndex].ArbitraryData = [][]byte{data}
+		return set
+	}
+	data := make([]byte, 100)
+	copy(data, modules.PrefixNonSia[:])
+	poolSet := newSet(data)
+	data = append([]byte(nil), data...)
+	data[len(data)-1] = 1
+	blockSet := newSet(data)
+	err = tpt.tpool.AcceptTransactionSet(poolSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a block that contains the other set instead of the pool's set.
+	block, target, err := tpt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Transactions = blockSet
+	for solved := false; !solved; {
+		block, solved = tpt.miner.SolveBlock(block, target)
+	}
+	err = tpt.cs.AcceptBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := tpt.tpool.TransactionStatus(poolSet[txnIndex].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != modules.TxStatusConflicted {
+		t.Error("transaction double spent by a block should be conflicted, got", status)
+	}
+	status, err = tpt.tpool.TransactionStatus(blockSet[txnIndex].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != modules.TxStatusConfirmed {
+		t.Error("transaction in the block should be confirmed, got", status)
+	}
+}
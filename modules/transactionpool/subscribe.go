@@ -1,6 +1,9 @@
 package transactionpool
 
 import (
+	"reflect"
+
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -19,12 +22,31 @@ func (tp *TransactionPool) updateSubscribersTransactions() {
 	for _, subscriber := range tp.subscribers {
 		subscriber.ReceiveUpdatedUnconfirmedTransactions(txns, cc)
 	}
+	if len(tp.watchedSubscribers) == 0 {
+		return
+	}
+	watchedTxns := tp.watchedTransactions(txns)
+	for _, subscriber := range tp.watchedSubscribers {
+		subscriber.ReceiveUpdatedUnconfirmedTransactions(watchedTxns, cc)
+	}
+}
+
+// comparableSubscriber returns true if the subscriber can be compared with ==,
+// which Unsubscribe uses to find the subscriber.
+func comparableSubscriber(subscriber modules.TransactionPoolSubscriber) bool {
+	t := reflect.TypeOf(subscriber)
+	return t != nil && t.Comparable()
 }
 
 // TransactionPoolSubscribe adds a subscriber to the transaction pool.
 // Subscribers will receive the full transaction set every time there is a
-// signficant change to the transaction pool.
+// signficant change to the transaction pool. Subscribers that are not
+// comparable, such as a struct that holds a slice, are rejected.
 func (tp *TransactionPool) TransactionPoolSubscribe(subscriber modules.TransactionPoolSubscriber) {
+	if !comparableSubscriber(subscriber) {
+		build.Critical("transaction pool subscriber is not comparable")
+		return
+	}
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
@@ -42,3 +64,25 @@ func (tp *TransactionPool) TransactionPoolSubscribe(subscriber modules.Transacti
 	}
 	subscriber.ReceiveUpdatedUnconfirmedTransactions(txns, cc)
 }
+
+// Unsubscribe removes a subscriber from the transaction pool. If the
+// subscriber is not subscribed to the transaction pool, no action is taken.
+func (tp *TransactionPool) Unsubscribe(subscriber modules.TransactionPoolSubscriber) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	// Search for the subscriber in both subscriber lists and remove it if
+	// found.
+	for i := range tp.subscribers {
+		if tp.subscribers[i] == subscriber {
+			tp.subscribers = append(tp.subscribers[0:i], tp.subscribers[i+1:]...)
+			break
+		}
+	}
+	for i := range tp.watchedSubscribers {
+		if tp.watchedSubscribers[i] == subscriber {
+			tp.watchedSubscribers = append(tp.watchedSubscribers[0:i], tp.watchedSubscribers[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// countingSubscriber is a transaction pool subscriber that records every
+// update it receives.
+type countingSubscriber struct {
+	updates [][]types.Transaction
+}
+
+// ReceiveUpdatedUnconfirmedTransactions records the update.
+func (cs *countingSubscriber) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction, _ modules.ConsensusChange) {
+	cs.updates = append(cs.updates, txns)
+}
+
+// sliceSubscriber is a transaction pool subscriber whose type is not
+// comparable.
+type sliceSubscriber []int
+
+// ReceiveUpdatedUnconfirmedTransactions does nothing.
+func (ss sliceSubscriber) ReceiveUpdatedUnconfirmedTransactions([]types.Transaction, modules.ConsensusChange) {
+}
+
+// TestSubscribeUnsubscribe checks that subscribers receive the pool contents
+// when subscribing and after every change to the pool, and that they stop
+// receiving updates after unsubscribing.
+func TestSubscribeUnsubscribe(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestSubscribeUnsubscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put a transaction in the pool before subscribing.
+	_, err = tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cs countingSubscriber
+	tpt.tpool.TransactionPoolSubscribe(&cs)
+	if len(cs.updates) != 1 || len(cs.updates[0]) == 0 {
+		t.Fatal("new subscriber did not receive the current pool contents")
+	}
+
+	// Mining a block removes the transactions from the pool.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.updates) != 2 || len(cs.updates[1]) != 0 {
+		t.Fatal("subscriber was not told that the pool was emptied by a block")
+	}
+
+	// Purging the pool should also notify subscribers.
+	_, err = tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	numUpdates := len(cs.updates)
+	if len(cs.updates[numUpdates-1]) == 0 {
+		t.Fatal("subscriber was not told about an accepted transaction")
+	}
+	tpt.tpool.PurgeTransactionPool()
+	if len(cs.updates) != numUpdates+1 || len(cs.updates[numUpdates]) != 0 {
+		t.Fatal("subscriber was not told that the pool was purged")
+	}
+
+	// After unsubscribing, no more updates should arrive.
+	tpt.tpool.Unsubscribe(&cs)
+	tpt.tpool.Unsubscribe(&cs)
+	numUpdates = len(cs.updates)
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.updates) != numUpdates {
+		t.Error("subscriber received an update after unsubscribing")
+	}
+}
+
+// TestSubscribeNotComparable checks that subscribers whose type is not
+// comparable are rejected when subscribing, so that Unsubscribe never has to
+// compare them.
+func TestSubscribeNotComparable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestSubscribeNotComparable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	numSubscribers := len(tpt.tpool.subscribers)
+	numWatched := len(tpt.tpool.watchedSubscribers)
+	subscribe := func(f func(modules.TransactionPoolSubscriber)) {
+		defer func() {
+			if r := recover(); r == nil && build.DEBUG {
+				t.Error("subscribing a subscriber that is not comparable did not panic")
+			}
+		}()
+		f(make(sliceSubscriber, 1))
+	}
+	subscribe(tpt.tpool.TransactionPoolSubscribe)
+	subscribe(tpt.tpool.TransactionPoolSubscribeWatched)
+	if len(tpt.tpool.subscribers) != numSubscribers || len(tpt.tpool.watchedSubscribers) != numWatched {
+		t.Error("a subscriber that is not comparable was subscribed")
+	}
+}
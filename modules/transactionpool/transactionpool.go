@@ -1,12 +1,21 @@
 package transactionpool
 
 import (
+	"bytes"
 	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/NebulousLabs/demotemutex"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -18,8 +27,8 @@ var (
 type (
 	// ObjectIDs are the IDs of objects such as siacoin outputs and file
 	// contracts, and are used to see if there are conflicts or overlaps within
-	// the transaction pool. A TransactionSetID is the hash of a transaction
-	// set.
+	// the transaction pool. A TransactionSetID is the hash of the ordered ids
+	// of the transactions in a transaction set, see CalculateTransactionSetID.
 	ObjectID         crypto.Hash
 	TransactionSetID crypto.Hash
 
@@ -27,7 +36,15 @@ type (
 	// rejecting them based on internal criteria such as fees and unconfirmed
 	// double spends.
 	TransactionPool struct {
+		// Acceptance metrics - atomic variables need to be placed at the top
+		// to preserve compatibility with 32bit systems.
+		atomicAcceptedSets  uint64
+		atomicConflictSets  uint64
+		atomicDuplicateSets uint64
+		atomicLowFeeSets    uint64
+
 		// Depedencies of the transaction pool.
+		clock        clock
 		consensusSet modules.ConsensusSet
 		gateway      modules.Gateway
 
@@ -46,6 +63,58 @@ type (
 		transactionSets     map[TransactionSetID][]types.Transaction
 		transactionSetDiffs map[TransactionSetID]modules.ConsensusChange
 		transactionListSize int
+
+		// transactionSetArrivals records when each transaction set arrived in
+		// the pool. Sets that have been in the pool for longer than maxAge
+		// are evicted.
+		transactionSetArrivals map[TransactionSetID]time.Time
+		maxAge                 time.Duration
+
+		// confirmedTransactions and conflictedTransactions map the
+		// transactions that were recently confirmed, or recently removed from
+		// the pool due to a conflict, to the height at which that happened.
+		// blockHeight is the height of the current block.
+		blockHeight            types.BlockHeight
+		confirmedTransactions  map[types.TransactionID]types.BlockHeight
+		conflictedTransactions map[types.TransactionID]types.BlockHeight
+
+		// minimumFee is the lowest fee per byte that the transaction pool
+		// will accept, regardless of how full the pool is.
+		minimumFee types.Currency
+
+		// maxArbitraryDataSize is the largest number of bytes of arbitrary
+		// data that the transaction pool will accept in a transaction set.
+		maxArbitraryDataSize int
+
+		// maxTransactionSetSize is the largest encoded size in bytes of a
+		// transaction set that the transaction pool will accept.
+		maxTransactionSetSize int
+
+		// minBroadcastVersion is the lowest peer version that accepted
+		// transaction sets are broadcast to.
+		minBroadcastVersion string
+
+		// broadcastCompression indicates whether transaction sets are
+		// broadcast in compressed form to the peers that support it.
+		// compressionPeers holds the peers that announced support for
+		// compressed transaction sets through the CompressionSupport RPC.
+		broadcastCompression bool
+		compressionPeers     map[modules.NetAddress]struct{}
+
+		// replaceByFee indicates whether a transaction set that double spends
+		// a transaction set in the pool may replace it by paying a higher
+		// fee. Replace-by-fee is disabled by default.
+		replaceByFee bool
+
+		// relayRateLimit is the number of transaction sets that a peer may
+		// relay to the pool per relayRateWindow, or zero if relaying is not
+		// rate limited. relayWindows tracks the sets relayed by each peer.
+		// The rate limit has a separate lock so that floods of sets can be
+		// throttled without waiting on the pool.
+		relayRateLimit int
+		relayWindows   map[modules.NetAddress]*relayWindow
+		relayMu        sync.Mutex
+
 		// TODO: Write a consistency check comparing transactionSets,
 		// transactionSetDiffs.
 		//
@@ -58,12 +127,29 @@ type (
 		// subscriber.
 		subscribers []modules.TransactionPoolSubscriber
 
-		mu demotemutex.DemoteMutex
+		// Watched subscribers only receive the unconfirmed transactions that
+		// spend from or pay to one of the watched addresses. The watched
+		// addresses have a separate lock so that subscribers can register
+		// addresses while holding their own locks.
+		watchedAddresses   map[types.UnlockHash]struct{}
+		watchedSubscribers []modules.TransactionPoolSubscriber
+		watchMu            sync.Mutex
+
+		// closeChan is closed when the transaction pool is closed, which
+		// stops the background threads of the pool. closeOnce keeps a second
+		// call to Close from closing the channel again.
+		closeChan chan struct{}
+		closeOnce sync.Once
+
+		// Utilities.
+		log        *persist.Logger
+		mu         demotemutex.DemoteMutex
+		persistDir string
 	}
 )
 
 // New creates a transaction pool that is ready to receive transactions.
-func New(cs modules.ConsensusSet, g modules.Gateway) (*TransactionPool, error) {
+func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*TransactionPool, error) {
 	// Check that the input modules are non-nil.
 	if cs == nil {
 		return nil, errNilCS
@@ -74,24 +160,62 @@ func New(cs modules.ConsensusSet, g modules.Gateway) (*TransactionPool, error) {
 
 	// Initialize a transaction pool.
 	tp := &TransactionPool{
+		clock:        productionClock{},
 		consensusSet: cs,
 		gateway:      g,
 
 		knownObjects:        make(map[ObjectID]TransactionSetID),
 		transactionSets:     make(map[TransactionSetID][]types.Transaction),
 		transactionSetDiffs: make(map[TransactionSetID]modules.ConsensusChange),
+
+		transactionSetArrivals: make(map[TransactionSetID]time.Time),
+		maxAge:                 defaultMaxAge,
+
+		confirmedTransactions:  make(map[types.TransactionID]types.BlockHeight),
+		conflictedTransactions: make(map[types.TransactionID]types.BlockHeight),
+
+		maxArbitraryDataSize:  defaultMaxArbitraryDataSize,
+		maxTransactionSetSize: defaultMaxTransactionSetSize,
+		minBroadcastVersion:   defaultMinBroadcastVersion,
+
+		relayWindows:     make(map[modules.NetAddress]*relayWindow),
+		compressionPeers: make(map[modules.NetAddress]struct{}),
+
+		watchedAddresses: make(map[types.UnlockHash]struct{}),
+
+		closeChan: make(chan struct{}),
+
+		persistDir: persistDir,
 	}
 	// Register RPCs
 	// TODO: rename RelayTransactionSet so that the conflicting RPC
 	// RelayTransaction calls v0.4.6 clients and earlier are ignored.
 	g.RegisterRPC("RelayTransactionSet", tp.relayTransactionSet)
+	g.RegisterRPC("RelayCompressedTransactionSet", tp.relayCompressedTransactionSet)
+	g.RegisterRPC("CompressionSupport", tp.shareCompressionSupport)
+	g.RegisterConnectCall("CompressionSupport", tp.requestCompressionSupport)
 
-	// Subscribe the transaction pool to the consensus set.
+	// Subscribe the transaction pool to the consensus set. The pool only
+	// receives the changes that follow the subscription, so the block height
+	// starts at the current height of the consensus set.
+	tp.blockHeight = cs.Height()
 	err := cs.ConsensusSetSubscribe(tp, modules.ConsensusChangeRecent)
 	if err != nil {
 		return nil, errors.New("transactionpool subscription failed: " + err.Error())
 	}
 
+	// Re-add the transaction sets that were in the pool when it was last
+	// closed.
+	err = tp.initPersist()
+	if err != nil {
+		return nil, errors.New("transactionpool persistence startup failed: " + err.Error())
+	}
+
+	// Evict the transaction sets that exceed the maximum age, and save the
+	// pool to disk, in the background.
+	go tp.threadedEvictExpiredSets()
+	go tp.threadedSave()
+
 	return tp, nil
 }
 
@@ -104,6 +228,129 @@ func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
 	return types.NewCurrency64(3).Mul(types.SiacoinPrecision).Div(types.NewCurrency64(1e3)), types.NewCurrency64(5).Mul(types.SiacoinPrecision).Div(types.NewCurrency64(1e3))
 }
 
+// MinimumFee returns the lowest fee per byte that a transaction set must pay
+// to be accepted into the transaction pool.
+func (tp *TransactionPool) MinimumFee() types.Currency {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.minimumFee
+}
+
+// SetMinBroadcastVersion sets the lowest peer version that accepted
+// transaction sets are broadcast to. Raising the version during a network
+// upgrade stops transaction sets from being relayed to outdated peers. The
+// default is v0.4.7.
+func (tp *TransactionPool) SetMinBroadcastVersion(v string) error {
+	if !build.IsVersion(v) {
+		return errInvalidBroadcastVersion
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.minBroadcastVersion = v
+	return nil
+}
+
+// SetReplaceByFee enables or disables replace-by-fee. When enabled, a
+// transaction set that double spends transaction sets already in the pool is
+// accepted if it pays strictly more in miner fees than the sets it conflicts
+// with. The increase must be at least the minimum fee per byte of the pool
+// for every byte of the new set. The conflicting sets, and any sets that
+// depend on them, are evicted.
+func (tp *TransactionPool) SetReplaceByFee(enabled bool) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.replaceByFee = enabled
+}
+
+// Metrics returns the acceptance metrics of the transaction pool. The metrics
+// are read atomically, and do not wait for transaction sets that are being
+// accepted.
+func (tp *TransactionPool) Metrics() modules.TpoolMetrics {
+	return modules.TpoolMetrics{
+		AcceptedSets:  atomic.LoadUint64(&tp.atomicAcceptedSets),
+		ConflictSets:  atomic.LoadUint64(&tp.atomicConflictSets),
+		DuplicateSets: atomic.LoadUint64(&tp.atomicDuplicateSets),
+		LowFeeSets:    atomic.LoadUint64(&tp.atomicLowFeeSets),
+	}
+}
+
+// SetMaxArbitraryDataSize sets the largest number of bytes of arbitrary data
+// that a transaction set can contain to be accepted into the transaction pool.
+// Transaction sets that are already in the pool are not affected. A negative
+// size is treated as zero. The default limit is 64 kB.
+func (tp *TransactionPool) SetMaxArbitraryDataSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.maxArbitraryDataSize = n
+}
+
+// SetMinimumFee sets the lowest fee per byte that a transaction set must pay
+// to be accepted into the transaction pool. The floor applies in addition to
+// the fees that are required once the pool passes
+// TransactionPoolSizeForFee. Only the transactions of a set that are not
+// already in the pool count towards its fees and size. Transaction sets that
+// are already in the pool are not affected. The default minimum fee is zero.
+func (tp *TransactionPool) SetMinimumFee(feePerByte types.Currency) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.minimumFee = feePerByte
+}
+
+// SetMaxTransactionSetSize sets the largest encoded size in bytes of a
+// transaction set that the transaction pool will accept, so that a single
+// large set cannot take up most of the pool. A set that depends on sets
+// already in the pool is merged with them before the limit is checked.
+// Transaction sets that are already in the pool are not removed right away,
+// but the pool re-adds all of its sets whenever it is rebuilt, for example
+// when a block is added or a set expires, and sets above the new limit are
+// dropped at that point. Sizes above the IsStandard limit have no further
+// effect.
+func (tp *TransactionPool) SetMaxTransactionSetSize(bytes int) error {
+	if bytes <= 0 {
+		return errInvalidMaxTransactionSetSize
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.maxTransactionSetSize = bytes
+	return nil
+}
+
+// CalculateTransactionSetID returns the id of a transaction set, which is the
+// hash of the ids of the transactions in the set, in order. Because the ids
+// do not depend on the full transaction data, peers can cheaply compare the
+// ids of the sets they know about before relaying a full set.
+func CalculateTransactionSetID(ts []types.Transaction) TransactionSetID {
+	ids := make([]types.TransactionID, len(ts))
+	for i, txn := range ts {
+		ids[i] = txn.ID()
+	}
+	return TransactionSetID(crypto.HashObject(ids))
+}
+
+// PoolContains returns true if the transaction set with the provided id is in
+// the transaction pool.
+func (tp *TransactionPool) PoolContains(id TransactionSetID) bool {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	_, exists := tp.transactionSets[id]
+	return exists
+}
+
+// TransactionSet returns the transaction set with the provided id, and false
+// if the transaction set is not in the transaction pool.
+func (tp *TransactionPool) TransactionSet(id TransactionSetID) ([]types.Transaction, bool) {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	set, exists := tp.transactionSets[id]
+	if !exists {
+		return nil, false
+	}
+	return append([]types.Transaction(nil), set...), true
+}
+
 // TransactionList returns a list of all transactions in the transaction pool.
 // The transactions are provided in an order that can acceptably be put into a
 // block.
@@ -114,3 +361,117 @@ func (tp *TransactionPool) TransactionList() []types.Transaction {
 	}
 	return txns
 }
+
+// feeRankedSet is a transaction set id along with the fee and the encoded size
+// of the set.
+type feeRankedSet struct {
+	id   TransactionSetID
+	fee  types.Currency
+	size types.Currency
+}
+
+// feeRankedSets is a slice of transaction sets that can be sorted by fee per
+// byte, highest first, using the sort package.
+type feeRankedSets []feeRankedSet
+
+// Len returns the number of sets in the slice.
+func (frs feeRankedSets) Len() int { return len(frs) }
+
+// Less returns whether set 'i' pays a higher fee per byte than set 'j'. Fee
+// rates are compared by cross multiplying, which avoids losing precision to
+// division. Ties are broken by set id so that the order is deterministic.
+func (frs feeRankedSets) Less(i, j int) bool {
+	if c := frs[i].fee.Mul(frs[j].size).Cmp(frs[j].fee.Mul(frs[i].size)); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(frs[i].id[:], frs[j].id[:]) < 0
+}
+
+// Swap swaps two elements in the slice.
+func (frs feeRankedSets) Swap(i, j int) { frs[i], frs[j] = frs[j], frs[i] }
+
+// TransactionListByFee returns a list of all transactions in the transaction
+// pool, ordered so that the transaction sets paying the highest fee per byte
+// come first. The transactions within each set keep their order, and sets in
+// the pool never depend on each other, so parents always precede their
+// children and any prefix of the list can acceptably be put into a block.
+func (tp *TransactionPool) TransactionListByFee() []types.Transaction {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	sets := make(feeRankedSets, 0, len(tp.transactionSets))
+	for id, set := range tp.transactionSets {
+		sets = append(sets, feeRankedSet{
+			id:   id,
+			fee:  setFees(set),
+			size: types.NewCurrency64(uint64(len(encoding.Marshal(set)))),
+		})
+	}
+	sort.Sort(sets)
+
+	var txns []types.Transaction
+	for _, frs := range sets {
+		txns = append(txns, tp.transactionSets[frs.id]...)
+	}
+	return txns
+}
+
+// FeeHistogram returns the distribution of the fees paid by the transaction
+// sets in the pool. Sets are grouped by fee per byte into buckets that each
+// span a power of two hastings, with the sets paying less than one hasting per
+// byte in the first bucket. Only buckets that contain sets are returned,
+// ordered from the lowest fee per byte to the highest.
+func (tp *TransactionPool) FeeHistogram() []modules.FeeBucket {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	// A fee per byte with a bit length of 'e' is at least 2^(e-1) and less
+	// than 2^e, so the bit length identifies the bucket of the set.
+	sizes := make(map[int]uint64)
+	for _, set := range tp.transactionSets {
+		size := uint64(len(encoding.Marshal(set)))
+		feePerByte := setFees(set).Div(types.NewCurrency64(size))
+		sizes[feePerByte.Big().BitLen()] += size
+	}
+	var bitLens []int
+	for bitLen := range sizes {
+		bitLens = append(bitLens, bitLen)
+	}
+	sort.Ints(bitLens)
+
+	buckets := make([]modules.FeeBucket, 0, len(bitLens))
+	for _, bitLen := range bitLens {
+		bucket := modules.FeeBucket{
+			MaxFeePerByte: types.NewCurrency(new(big.Int).Lsh(big.NewInt(1), uint(bitLen))),
+			Size:          sizes[bitLen],
+		}
+		if bitLen > 0 {
+			bucket.MinFeePerByte = types.NewCurrency(new(big.Int).Lsh(big.NewInt(1), uint(bitLen-1)))
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// TransactionSets returns a copy of every transaction set in the transaction
+// pool. The transactions within each set are in an order that can acceptably
+// be put into a block.
+func (tp *TransactionPool) TransactionSets() [][]types.Transaction {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	var sets [][]types.Transaction
+	for _, tSet := range tp.transactionSets {
+		sets = append(sets, append([]types.Transaction(nil), tSet...))
+	}
+	return sets
+}
+
+// TransactionPoolSize returns the total encoded size in bytes of all
+// transaction sets in the transaction pool, along with the number of sets. The
+// size is the same figure that is compared against TransactionPoolSizeForFee
+// when deciding whether incoming transaction sets need to pay fees.
+func (tp *TransactionPool) TransactionPoolSize() (size int, count int) {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.transactionListSize, len(tp.transactionSets)
+}
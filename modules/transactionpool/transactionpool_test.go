@@ -7,6 +7,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/consensus"
 	"github.com/NebulousLabs/Sia/modules/gateway"
@@ -39,7 +40,7 @@ func createTpoolTester(name string) (*tpoolTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	tp, err := New(cs, g)
+	tp, err := New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +61,7 @@ func createTpoolTester(name string) (*tpoolTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	m, err := miner.New(cs, tp, w, filepath.Join(testdir, modules.MinerDir))
+	m, err := miner.New(cs, tp, w, g, filepath.Join(testdir, modules.MinerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -101,20 +102,250 @@ func TestIntegrationNewNilInputs(t *testing.T) {
 	}
 
 	// Try all combinations of nil inputs.
-	_, err = New(nil, nil)
+	_, err = New(nil, nil, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err == nil {
 		t.Error(err)
 	}
-	_, err = New(nil, g)
+	_, err = New(nil, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != errNilCS {
 		t.Error(err)
 	}
-	_, err = New(cs, nil)
+	_, err = New(cs, nil, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != errNilGateway {
 		t.Error(err)
 	}
-	_, err = New(cs, g)
+	_, err = New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
 	if err != nil {
 		t.Error(err)
 	}
 }
+
+// TestTransactionPoolSize checks that TransactionPoolSize reports the size and
+// number of the transaction sets in the transaction pool.
+func TestTransactionPoolSize(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestTransactionPoolSize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, count := tpt.tpool.TransactionPoolSize()
+	if size != 0 || count != 0 {
+		t.Fatal("new transaction pool is not empty:", size, count)
+	}
+
+	// Add two transaction sets to the pool.
+	var expectedSize int
+	for i := 0; i < 2; i++ {
+		arbData := make([]byte, 1e3)
+		copy(arbData, modules.PrefixNonSia[:])
+		arbData[100] = byte(i)
+		ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+		err = tpt.tpool.AcceptTransactionSet(ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedSize += len(encoding.Marshal(ts))
+	}
+	size, count = tpt.tpool.TransactionPoolSize()
+	if size != expectedSize || count != 2 {
+		t.Fatalf("expected size %v and count 2, got size %v and count %v", expectedSize, size, count)
+	}
+	if size != tpt.tpool.transactionListSize || count != len(tpt.tpool.transactionSets) {
+		t.Fatal("reported size does not match the internal size")
+	}
+
+	// Mining a block should empty the pool.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, count = tpt.tpool.TransactionPoolSize()
+	if size != 0 || count != 0 {
+		t.Fatal("transaction pool is not empty after mining:", size, count)
+	}
+}
+
+// TestTransactionSetLookup checks that transaction sets can be found in the
+// transaction pool by their id.
+func TestTransactionSetLookup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestTransactionSetLookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arbData := make([]byte, 100)
+	copy(arbData, modules.PrefixNonSia[:])
+	ts := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	setID := CalculateTransactionSetID(ts)
+	if tpt.tpool.PoolContains(setID) {
+		t.Fatal("transaction set is in the pool before being added")
+	}
+	_, exists := tpt.tpool.TransactionSet(setID)
+	if exists {
+		t.Fatal("transaction set is in the pool before being added")
+	}
+
+	err = tpt.tpool.AcceptTransactionSet(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tpt.tpool.PoolContains(setID) {
+		t.Fatal("transaction set is not in the pool after being added")
+	}
+	set, exists := tpt.tpool.TransactionSet(setID)
+	if !exists || len(set) != 1 || set[0].ID() != ts[0].ID() {
+		t.Fatal("wrong transaction set returned:", set, exists)
+	}
+
+	// The id should only depend on the ordered transaction ids.
+	ts2 := []types.Transaction{ts[0], {ArbitraryData: [][]byte{{1}}}}
+	ts3 := []types.Transaction{ts2[1], ts2[0]}
+	if CalculateTransactionSetID(ts2) == CalculateTransactionSetID(ts3) {
+		t.Error("reordered transaction sets have the same id")
+	}
+	ts[0].TransactionSignatures = []types.TransactionSignature{{}}
+	if CalculateTransactionSetID(ts) != setID {
+		t.Error("signatures should not affect the transaction set id")
+	}
+}
+
+// TestTransactionListByFee checks that TransactionListByFee returns the sets
+// with the highest fee per byte first, with parents preceding their children.
+func TestTransactionListByFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestTransactionListByFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mine a few more blocks so that the wallet has an output to fund each
+	// set.
+	for i := 0; i < 3; i++ {
+		_, err = tpt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Submit three sets of similar size that pay different fees. Each set
+	// contains a parent that funds the child paying the fee. All sets are
+	// signed before any is submitted, so that none of them is funded by the
+	// change of another and they stay independent in the pool.
+	var sets [][]types.Transaction
+	for _, fee := range []uint64{10, 30, 20} {
+		builder := tpt.wallet.StartTransaction()
+		amount := types.SiacoinPrecision.Mul(types.NewCurrency64(fee))
+		err = builder.FundSiacoins(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder.AddMinerFee(amount)
+		set, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(set) != 2 {
+			t.Fatal("expected a parent and a child, got", len(set))
+		}
+		sets = append(sets, set)
+	}
+	for _, set := range sets {
+		err = tpt.tpool.AcceptTransactionSet(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	txns := tpt.tpool.TransactionListByFee()
+	if len(txns) != 6 {
+		t.Fatal("expected six transactions, got", len(txns))
+	}
+	for i, set := range [][]types.Transaction{sets[1], sets[2], sets[0]} {
+		if txns[2*i].ID() != set[0].ID() || txns[2*i+1].ID() != set[1].ID() {
+			t.Error("set", i, "is out of place")
+		}
+	}
+}
+
+// TestFeeHistogram checks that FeeHistogram groups the sets in the pool by fee
+// per byte and accounts for the size of every set.
+func TestFeeHistogram(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestFeeHistogram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.FeeHistogram()) != 0 {
+		t.Fatal("empty pool should have an empty histogram")
+	}
+
+	// Mine a few blocks so that the wallet has an output to fund each set.
+	for i := 0; i < 2; i++ {
+		_, err = tpt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add a set that pays no fee, and two sets that pay very different fees.
+	arbData := make([]byte, 1e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{ArbitraryData: [][]byte{arbData}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fee paying sets are signed before either is submitted, so that they
+	// stay independent in the pool.
+	var sets [][]types.Transaction
+	for _, fee := range []uint64{1, 1000} {
+		builder := tpt.wallet.StartTransaction()
+		amount := types.SiacoinPrecision.Mul(types.NewCurrency64(fee))
+		err = builder.FundSiacoins(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder.AddMinerFee(amount)
+		set, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sets = append(sets, set)
+	}
+	for _, set := range sets {
+		err = tpt.tpool.AcceptTransactionSet(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buckets := tpt.tpool.FeeHistogram()
+	if len(buckets) != 3 {
+		t.Fatal("expected three buckets, got", len(buckets))
+	}
+	if !buckets[0].MinFeePerByte.IsZero() || buckets[0].MaxFeePerByte.Cmp(types.NewCurrency64(1)) != 0 {
+		t.Error("free set should be in the first bucket")
+	}
+	var totalSize uint64
+	for i, bucket := range buckets {
+		if bucket.MinFeePerByte.Cmp(bucket.MaxFeePerByte) >= 0 {
+			t.Error("bucket", i, "has an empty range")
+		}
+		if i > 0 && bucket.MinFeePerByte.Cmp(buckets[i-1].MaxFeePerByte) < 0 {
+			t.Error("buckets are out of order")
+		}
+		totalSize += bucket.Size
+	}
+	size, _ := tpt.tpool.TransactionPoolSize()
+	if totalSize != uint64(size) {
+		t.Errorf("histogram accounts for %v bytes, pool holds %v", totalSize, size)
+	}
+}
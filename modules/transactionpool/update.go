@@ -1,6 +1,8 @@
 package transactionpool
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -11,6 +13,7 @@ func (tp *TransactionPool) purge() {
 	tp.transactionSets = make(map[TransactionSetID][]types.Transaction)
 	tp.transactionSetDiffs = make(map[TransactionSetID]modules.ConsensusChange)
 	tp.transactionListSize = 0
+	tp.transactionSetArrivals = make(map[TransactionSetID]time.Time)
 }
 
 // ProcessConsensusChange gets called to inform the transaction pool of changes
@@ -24,30 +27,46 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// clean out transactions with no dependencies, such as arbitrary data
 	// transactions from the host.
 	txids := make(map[types.TransactionID]struct{})
+	blockSpenders := make(map[ObjectID]types.TransactionID)
 	for _, block := range cc.AppliedBlocks {
 		for _, txn := range block.Transactions {
-			txids[txn.ID()] = struct{}{}
+			txid := txn.ID()
+			txids[txid] = struct{}{}
+			for _, oid := range consumedObjectIDs(txn) {
+				blockSpenders[oid] = txid
+			}
 		}
 	}
+	tp.updateConfirmedTransactions(cc)
 
-	// TODO: Right now, transactions that were reverted to not get saved and
-	// retried, because some transactions such as storage proofs might be
-	// illegal, and there's no good way to preserve dependencies when illegal
-	// transactions are suddenly involved.
-	//
-	// One potential solution is to have modules manually do resubmission if
-	// something goes wrong. Another is to have the transaction pool remember
-	// recent transaction sets on the off chance that they become valid again
-	// due to a reorg.
-	//
-	// Another option is to scan through the blocks transactions one at a time
-	// check if they are valid. If so, lump them in a set with the next guy.
-	// When they stop being valid, you've found a guy to throw away. It's n^2
-	// in the number of transactions in the block.
-
-	// Save all of the current unconfirmed transaction sets into a list.
+	// Transactions in reverted blocks that are not confirmed again by the
+	// applied blocks are re-evaluated and added back to the pool. Reverted
+	// blocks are listed starting with the most recent block, so they are
+	// walked backwards to preserve dependency ordering. Each transaction is
+	// added as its own set; dependent transactions are merged with their
+	// parents by the conflict handling, and transactions that are no longer
+	// valid, such as storage proofs for a different fork, are dropped.
 	var unconfirmedSets [][]types.Transaction
-	for _, tSet := range tp.transactionSets {
+	var arrivals []time.Time
+	now := tp.clock.now()
+	for i := len(cc.RevertedBlocks) - 1; i >= 0; i-- {
+		for _, txn := range cc.RevertedBlocks[i].Transactions {
+			if _, exists := txids[txn.ID()]; exists {
+				continue
+			}
+			unconfirmedSets = append(unconfirmedSets, []types.Transaction{txn})
+			arrivals = append(arrivals, now)
+		}
+	}
+
+	// Save all of the current unconfirmed transaction sets into a list,
+	// along with their arrival times. The reverted transactions go first,
+	// because they were confirmed before any of the unconfirmed sets and
+	// take priority if the two conflict. Sets that spend an object which the
+	// applied blocks spend in a different transaction are double spent by
+	// the blocks, and are recorded as conflicted.
+	var doubleSpent [][]types.Transaction
+	for setID, tSet := range tp.transactionSets {
 		// Compile a new transaction set the removes all transactions duplicated
 		// in the block. Though mostly handled by the dependency manager in the
 		// transaction pool, this should both improve efficiency and will strip
@@ -60,16 +79,18 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 				newTSet = append(newTSet, txn)
 			}
 		}
+		if spendsConflict(newTSet, blockSpenders) {
+			doubleSpent = append(doubleSpent, newTSet)
+		}
 		unconfirmedSets = append(unconfirmedSets, newTSet)
+		arrivals = append(arrivals, tp.transactionSetArrivals[setID])
 	}
 
-	// Purge the transaction pool. Some of the transactions sets may be invalid
-	// after the consensus change.
-	tp.purge()
-
-	// Add all of the unconfirmed transaction sets back to the transaction
-	// pool. The ones that are invalid will throw an error and will not be
-	// re-added.
+	// Purge the transaction pool and add all of the unconfirmed transaction
+	// sets back to the transaction pool. Some of the transaction sets may be
+	// invalid after the consensus change; the ones that are invalid will throw
+	// an error and will not be re-added. Sets that have exceeded the maximum
+	// age are evicted.
 	//
 	// Accepting a transaction set requires locking the consensus set (to check
 	// validity). But, ProcessConsensusChange is only called when the consensus
@@ -81,9 +102,8 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// Which means that no other modules can require a tpool lock when
 	// processing consensus changes. Overall, the locking is pretty fragile and
 	// more rules need to be put in place.
-	for _, set := range unconfirmedSets {
-		tp.acceptTransactionSet(set) // Error is not checked.
-	}
+	conflicted := tp.readdTransactionSets(unconfirmedSets, arrivals)
+	tp.markConflicted(append(conflicted, doubleSpent...))
 
 	// Inform subscribers that an update has executed.
 	tp.mu.Demote()
@@ -92,8 +112,42 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 }
 
 // PurgeTransactionPool deletes all transactions from the transaction pool.
+// Subscribers are informed that the pool has been emptied.
 func (tp *TransactionPool) PurgeTransactionPool() {
 	tp.mu.Lock()
 	tp.purge()
-	tp.mu.Unlock()
+	tp.mu.Demote()
+	tp.updateSubscribersTransactions()
+	tp.mu.DemotedUnlock()
+}
+
+// PurgeTransaction removes the transaction set with the provided id from the
+// transaction pool. Any transaction sets that depend on the removed set are
+// no longer valid and are removed as well. Subscribers are informed of the
+// new state of the pool.
+func (tp *TransactionPool) PurgeTransaction(id TransactionSetID) error {
+	tp.mu.Lock()
+	if _, exists := tp.transactionSets[id]; !exists {
+		tp.mu.Unlock()
+		return errSetNotFound
+	}
+
+	// Rebuild the pool from every other transaction set. Sets that spend
+	// objects created by the removed set will fail validation and will not be
+	// re-added.
+	var sets [][]types.Transaction
+	var arrivals []time.Time
+	for setID, tSet := range tp.transactionSets {
+		if setID == id {
+			continue
+		}
+		sets = append(sets, tSet)
+		arrivals = append(arrivals, tp.transactionSetArrivals[setID])
+	}
+	tp.readdTransactionSets(sets, arrivals)
+
+	tp.mu.Demote()
+	tp.updateSubscribersTransactions()
+	tp.mu.DemotedUnlock()
+	return nil
 }
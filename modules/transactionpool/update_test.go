@@ -39,3 +39,152 @@ func TestArbDataOnly(t *testing.T) {
 		t.Error("transaction was not cleared from the transaction pool")
 	}
 }
+
+// TestPurgeTransaction checks that PurgeTransaction removes a single
+// transaction set along with its children, leaving the rest of the pool
+// intact.
+func TestPurgeTransaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestPurgeTransaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add an unrelated arbitrary data transaction to the pool.
+	arbTxn := types.Transaction{
+		ArbitraryData: [][]byte{
+			append(modules.PrefixNonSia[:], []byte("arb-data")...),
+		},
+	}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{arbTxn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Submit a parent transaction followed by its child, as done in
+	// TestIntegrationTransactionChild.
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 3 {
+		t.Fatal("expecting to see three transactions in the pool")
+	}
+
+	err = tpt.tpool.PurgeTransaction(TransactionSetID{})
+	if err != errSetNotFound {
+		t.Fatal("expected errSetNotFound, got", err)
+	}
+
+	// Purge the set containing the parent, the child should be removed with
+	// it and subscribers should be notified.
+	var cs countingSubscriber
+	tpt.tpool.TransactionPoolSubscribe(&cs)
+	var parentSetID TransactionSetID
+	for _, set := range tpt.tpool.TransactionSets() {
+		for _, txn := range set {
+			if txn.ID() == txnSet[0].ID() {
+				parentSetID = CalculateTransactionSetID(set)
+			}
+		}
+	}
+	err = tpt.tpool.PurgeTransaction(parentSetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns := tpt.tpool.TransactionList()
+	if len(txns) != 1 || txns[0].ID() != arbTxn.ID() {
+		t.Error("purge did not remove exactly the parent and child transactions:", len(txns))
+	}
+	if len(cs.updates) != 2 || len(cs.updates[1]) != 1 {
+		t.Error("subscriber was not notified of the purge")
+	}
+	if tpt.tpool.PoolContains(parentSetID) {
+		t.Error("purged set is still in the pool")
+	}
+}
+
+// TestReorgReaddsTransactions checks that transactions which were confirmed in
+// a block that got reverted by a reorg are added back to the transaction pool.
+func TestReorgReaddsTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester("TestReorgReaddsTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Solve a block that will become the base of a competing fork before
+	// any transactions are submitted, so that it confirms nothing.
+	forkBlock, err := tpt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send a payment and confirm it in a block on the current chain.
+	txns, err := tpt.wallet.SendSiacoins(types.NewCurrency64(1e9), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("confirmed transactions are still in the pool")
+	}
+
+	// Extend the competing fork until it is heavier than the current chain,
+	// reverting the block that confirmed the payment.
+	err = tpt.cs.AcceptBlock(forkBlock)
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatal("expected the fork block to be non-extending, got", err)
+	}
+	height := tpt.cs.Height() + 1
+	b := types.Block{
+		ParentID:     forkBlock.ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(height)}},
+	}
+	target, _ := tpt.cs.ChildTarget(forkBlock.ID())
+	b, solved := tpt.miner.SolveBlock(b, target)
+	if !solved {
+		t.Fatal("could not solve the fork block")
+	}
+	err = tpt.cs.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpt.cs.CurrentBlock().ID() != b.ID() {
+		t.Fatal("the competing fork did not become the current chain")
+	}
+
+	// The payment should be back in the pool.
+	pool := make(map[types.TransactionID]struct{})
+	for _, txn := range tpt.tpool.TransactionList() {
+		pool[txn.ID()] = struct{}{}
+	}
+	for _, txn := range txns {
+		if _, exists := pool[txn.ID()]; !exists {
+			t.Error("reverted transaction was not added back to the pool")
+		}
+	}
+}
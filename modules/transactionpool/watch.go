@@ -0,0 +1,104 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// relevantToWatched returns true if the transaction spends from or pays to
+// any of the watched addresses. The caller must hold the watch lock.
+func (tp *TransactionPool) relevantToWatched(txn types.Transaction) bool {
+	for _, sci := range txn.SiacoinInputs {
+		if _, exists := tp.watchedAddresses[sci.UnlockConditions.UnlockHash()]; exists {
+			return true
+		}
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		if _, exists := tp.watchedAddresses[sco.UnlockHash]; exists {
+			return true
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		if _, exists := tp.watchedAddresses[sfi.UnlockConditions.UnlockHash()]; exists {
+			return true
+		}
+		if _, exists := tp.watchedAddresses[sfi.ClaimUnlockHash]; exists {
+			return true
+		}
+	}
+	for _, sfo := range txn.SiafundOutputs {
+		if _, exists := tp.watchedAddresses[sfo.UnlockHash]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedTransactions returns the subset of 'txns' that is relevant to the
+// watched addresses.
+func (tp *TransactionPool) watchedTransactions(txns []types.Transaction) []types.Transaction {
+	tp.watchMu.Lock()
+	defer tp.watchMu.Unlock()
+	var relevant []types.Transaction
+	for _, txn := range txns {
+		if tp.relevantToWatched(txn) {
+			relevant = append(relevant, txn)
+		}
+	}
+	return relevant
+}
+
+// RegisterWatchedAddresses adds addresses to the set of watched addresses.
+// Subscribers added through TransactionPoolSubscribeWatched will receive every
+// unconfirmed transaction that spends from or pays to a watched address.
+// Registering an address that is already watched has no effect.
+//
+// The watched addresses are protected by their own lock, which means that
+// RegisterWatchedAddresses can be called by a subscriber while the subscriber
+// is holding its own lock.
+func (tp *TransactionPool) RegisterWatchedAddresses(addrs []types.UnlockHash) {
+	tp.watchMu.Lock()
+	defer tp.watchMu.Unlock()
+	for _, addr := range addrs {
+		tp.watchedAddresses[addr] = struct{}{}
+	}
+}
+
+// UnregisterWatchedAddresses removes addresses from the set of watched
+// addresses. Unconfirmed transactions that are only relevant to the removed
+// addresses will no longer be sent to watched subscribers.
+func (tp *TransactionPool) UnregisterWatchedAddresses(addrs []types.UnlockHash) {
+	tp.watchMu.Lock()
+	defer tp.watchMu.Unlock()
+	for _, addr := range addrs {
+		delete(tp.watchedAddresses, addr)
+	}
+}
+
+// TransactionPoolSubscribeWatched adds a subscriber to the transaction pool
+// that only receives the unconfirmed transactions that are relevant to the
+// watched addresses. The full consensus change is still provided with each
+// update. Subscribers that are not comparable are rejected.
+func (tp *TransactionPool) TransactionPoolSubscribeWatched(subscriber modules.TransactionPoolSubscriber) {
+	if !comparableSubscriber(subscriber) {
+		build.Critical("transaction pool subscriber is not comparable")
+		return
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	// Add the subscriber to the watched subscriber list.
+	tp.watchedSubscribers = append(tp.watchedSubscribers, subscriber)
+
+	// Send the new subscriber the relevant portion of the transaction pool.
+	var txns []types.Transaction
+	for _, tSet := range tp.transactionSets {
+		txns = append(txns, tSet...)
+	}
+	var cc modules.ConsensusChange
+	for _, tSetDiff := range tp.transactionSetDiffs {
+		cc = cc.Append(tSetDiff)
+	}
+	subscriber.ReceiveUpdatedUnconfirmedTransactions(tp.watchedTransactions(txns), cc)
+}
@@ -0,0 +1,145 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// scanSubscriber is a transaction pool subscriber that mimics the work that
+// the wallet does for each unconfirmed transaction it receives.
+type scanSubscriber struct {
+	keys     map[types.UnlockHash]struct{}
+	relevant int
+	received int
+}
+
+// ReceiveUpdatedUnconfirmedTransactions scans every transaction for outputs
+// and inputs belonging to the subscriber's keys.
+func (ss *scanSubscriber) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction, _ modules.ConsensusChange) {
+	ss.relevant = 0
+	ss.received = len(txns)
+	for _, txn := range txns {
+		relevant := false
+		for _, sci := range txn.SiacoinInputs {
+			if _, exists := ss.keys[sci.UnlockConditions.UnlockHash()]; exists {
+				relevant = true
+			}
+		}
+		for _, sco := range txn.SiacoinOutputs {
+			if _, exists := ss.keys[sco.UnlockHash]; exists {
+				relevant = true
+			}
+		}
+		_ = txn.ID()
+		if relevant {
+			ss.relevant++
+		}
+	}
+}
+
+// watchTestPool returns a transaction pool containing 'n' single-transaction
+// sets, along with the addresses belonging to one in every 'stride' of the
+// transactions. The pool is not attached to a consensus set.
+func watchTestPool(n, stride int) (*TransactionPool, []types.UnlockHash) {
+	tp := &TransactionPool{
+		transactionSets:     make(map[TransactionSetID][]types.Transaction),
+		transactionSetDiffs: make(map[TransactionSetID]modules.ConsensusChange),
+		watchedAddresses:    make(map[types.UnlockHash]struct{}),
+	}
+	var addrs []types.UnlockHash
+	for i := 0; i < n; i++ {
+		uh := types.UnlockHash(crypto.HashObject(i))
+		txn := types.Transaction{
+			SiacoinOutputs: []types.SiacoinOutput{{
+				Value:      types.NewCurrency64(uint64(i)),
+				UnlockHash: uh,
+			}},
+		}
+		tp.transactionSets[CalculateTransactionSetID([]types.Transaction{txn})] = []types.Transaction{txn}
+		if i%stride == 0 {
+			addrs = append(addrs, uh)
+		}
+	}
+	return tp, addrs
+}
+
+// TestWatchedAddresses checks that watched subscribers only receive the
+// transactions relevant to the watched addresses, and that the set stays
+// consistent as addresses are registered and unregistered.
+func TestWatchedAddresses(t *testing.T) {
+	tp, addrs := watchTestPool(100, 10)
+	ss := &scanSubscriber{keys: make(map[types.UnlockHash]struct{})}
+	for _, addr := range addrs {
+		ss.keys[addr] = struct{}{}
+	}
+
+	// Before any addresses are registered, the watched subscriber should see
+	// nothing.
+	tp.TransactionPoolSubscribeWatched(ss)
+	if ss.received != 0 {
+		t.Fatal("watched subscriber received transactions without any watched addresses:", ss.received)
+	}
+
+	// Register the addresses and check that exactly the relevant
+	// transactions arrive.
+	tp.RegisterWatchedAddresses(addrs)
+	tp.RegisterWatchedAddresses(addrs[:1])
+	tp.updateSubscribersTransactions()
+	if ss.received != len(addrs) || ss.relevant != len(addrs) {
+		t.Fatalf("expected %v relevant transactions, got %v of %v", len(addrs), ss.relevant, ss.received)
+	}
+
+	// Unregister half of the addresses.
+	tp.UnregisterWatchedAddresses(addrs[:len(addrs)/2])
+	tp.updateSubscribersTransactions()
+	if ss.received != len(addrs)-len(addrs)/2 {
+		t.Fatal("unregistered addresses are still being watched:", ss.received)
+	}
+
+	// A transaction spending from a watched address should also be sent.
+	uc := types.UnlockConditions{Timelock: 1}
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{UnlockConditions: uc}},
+	}
+	tp.transactionSets[CalculateTransactionSetID([]types.Transaction{txn})] = []types.Transaction{txn}
+	tp.RegisterWatchedAddresses([]types.UnlockHash{uc.UnlockHash()})
+	tp.updateSubscribersTransactions()
+	if ss.received != len(addrs)-len(addrs)/2+1 {
+		t.Fatal("transaction spending from a watched address was not sent:", ss.received)
+	}
+}
+
+// BenchmarkUpdateSubscribersScan measures the cost of sending a large
+// transaction pool to a subscriber that scans every transaction.
+func BenchmarkUpdateSubscribersScan(b *testing.B) {
+	tp, addrs := watchTestPool(5000, 100)
+	ss := &scanSubscriber{keys: make(map[types.UnlockHash]struct{})}
+	for _, addr := range addrs {
+		ss.keys[addr] = struct{}{}
+	}
+	tp.subscribers = append(tp.subscribers, ss)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tp.updateSubscribersTransactions()
+	}
+}
+
+// BenchmarkUpdateSubscribersWatched measures the cost of sending a large
+// transaction pool to a subscriber that only receives the transactions
+// relevant to its watched addresses.
+func BenchmarkUpdateSubscribersWatched(b *testing.B) {
+	tp, addrs := watchTestPool(5000, 100)
+	ss := &scanSubscriber{keys: make(map[types.UnlockHash]struct{})}
+	for _, addr := range addrs {
+		ss.keys[addr] = struct{}{}
+	}
+	tp.RegisterWatchedAddresses(addrs)
+	tp.watchedSubscribers = append(tp.watchedSubscribers, ss)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tp.updateSubscribersTransactions()
+	}
+}
@@ -3,8 +3,11 @@ package modules
 import (
 	"bytes"
 	"errors"
+	"io"
+	"time"
 
 	"github.com/NebulousLabs/entropy-mnemonics"
+	"golang.org/x/net/context"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
@@ -43,6 +46,10 @@ var (
 	// ErrLockedWallet is returned when an action cannot be performed due to
 	// the wallet being locked.
 	ErrLockedWallet = errors.New("wallet must be unlocked before it can be used")
+
+	// ErrDustOutput is returned when a requested output is below the
+	// wallet's dust threshold.
+	ErrDustOutput = errors.New("output value is below the dust threshold")
 )
 
 type (
@@ -98,6 +105,57 @@ type (
 
 		Inputs  []ProcessedInput  `json:"inputs"`
 		Outputs []ProcessedOutput `json:"outputs"`
+
+		// Note is local metadata attached to the transaction by the user. It
+		// is never broadcast.
+		Note string `json:"note"`
+
+		// Replaced is set on an unconfirmed transaction that was replaced by
+		// a fee bump, and ReplacedBy is the id of the replacing transaction.
+		// Replaced transactions are reported until the replacing transaction
+		// leaves the transaction pool.
+		Replaced   bool                `json:"replaced"`
+		ReplacedBy types.TransactionID `json:"replacedby"`
+	}
+
+	// A ProcessedTransactionIter iterates over processed transactions.
+	ProcessedTransactionIter interface {
+		// Next returns the next transaction, and false once there are no
+		// more transactions.
+		Next() (ProcessedTransaction, bool)
+	}
+
+	// A SiafundClaim describes the siacoins claimable by a siafund output of
+	// the wallet. The claim grows with the siafund pool: ClaimValue is
+	// (SiafundPool - ClaimStart) * Value / SiafundCount, and is paid out to
+	// a claim output when the siafund output is spent.
+	SiafundClaim struct {
+		ID          types.SiafundOutputID `json:"id"`
+		UnlockHash  types.UnlockHash      `json:"unlockhash"`
+		Value       types.Currency        `json:"value"`
+		ClaimStart  types.Currency        `json:"claimstart"`
+		SiafundPool types.Currency        `json:"siafundpool"`
+		ClaimValue  types.Currency        `json:"claimvalue"`
+	}
+
+	// An UnspentOutput is a confirmed siacoin or siafund output that can be
+	// spent by the wallet. ConfirmationHeight is the height of the block that
+	// created the output, and is zero if the wallet has no history for it.
+	UnspentOutput struct {
+		ConfirmationHeight types.BlockHeight `json:"confirmationheight"`
+		FundType           types.Specifier   `json:"fundtype"`
+		ID                 types.OutputID    `json:"id"`
+		UnlockHash         types.UnlockHash  `json:"unlockhash"`
+		Value              types.Currency    `json:"value"`
+	}
+
+	// A SeedExhaustionWarning reports how many addresses of the primary seed
+	// have been used and how many remain. Rotated is set when an exhausted
+	// primary seed was replaced by a new seed, which must be backed up.
+	SeedExhaustionWarning struct {
+		Progress  uint64 `json:"progress"`
+		Remaining uint64 `json:"remaining"`
+		Rotated   bool   `json:"rotated"`
 	}
 
 	// TransactionBuilder is used to construct custom transactions. A transaction
@@ -120,6 +178,11 @@ type (
 		// transaction failed.
 		FundSiacoins(amount types.Currency) error
 
+		// FundSiacoinsIncludingFee will add a siacoin input worth 'amount'
+		// plus 'fee' to the transaction, and add 'fee' as a miner fee. Only
+		// one refund output is created for the combined total.
+		FundSiacoinsIncludingFee(amount, fee types.Currency) error
+
 		// FundSiafunds will add a siafund input of exaclty 'amount' to the
 		// transaction. A parent transaction may be needed to achieve an input
 		// with the correct value. The siafund input will not be signed until
@@ -131,55 +194,67 @@ type (
 		// failed.
 		FundSiafunds(amount types.Currency) error
 
+		// FundSiacoinsFrom adds every one of the provided siacoin outputs to
+		// the transaction as an input, without creating a parent transaction
+		// or a refund. An error is returned if an output does not belong to
+		// the wallet or is already reserved by another transaction. The
+		// inputs will not be signed until 'Sign' is called.
+		FundSiacoinsFrom(ids []types.SiacoinOutputID) error
+
+		// SetChangeAddress sets the address that receives the siacoin
+		// refunds created by 'FundSiacoins'. If it is never called, refunds
+		// are sent to new addresses owned by the wallet.
+		SetChangeAddress(addr types.UnlockHash) error
+
 		// AddParents adds a set of parents to the transaction.
-		AddParents([]types.Transaction)
+		AddParents([]types.Transaction) error
 
 		// AddMinerFee adds a miner fee to the transaction, returning the index
 		// of the miner fee within the transaction.
-		AddMinerFee(fee types.Currency) uint64
+		AddMinerFee(fee types.Currency) (uint64, error)
 
 		// AddSiacoinInput adds a siacoin input to the transaction, returning
 		// the index of the siacoin input within the transaction. When 'Sign'
 		// gets called, this input will be left unsigned.
-		AddSiacoinInput(types.SiacoinInput) uint64
+		AddSiacoinInput(types.SiacoinInput) (uint64, error)
 
 		// AddSiacoinOutput adds a siacoin output to the transaction, returning
 		// the index of the siacoin output within the transaction.
-		AddSiacoinOutput(types.SiacoinOutput) uint64
+		AddSiacoinOutput(types.SiacoinOutput) (uint64, error)
 
 		// AddFileContract adds a file contract to the transaction, returning
 		// the index of the file contract within the transaction.
-		AddFileContract(types.FileContract) uint64
+		AddFileContract(types.FileContract) (uint64, error)
 
 		// AddFileContractRevision adds a file contract revision to the
 		// transaction, returning the index of the file contract revision
 		// within the transaction. When 'Sign' gets called, this revision will
 		// be left unsigned.
-		AddFileContractRevision(types.FileContractRevision) uint64
+		AddFileContractRevision(types.FileContractRevision) (uint64, error)
 
 		// AddStorageProof adds a storage proof to the transaction, returning
 		// the index of the storage proof within the transaction.
-		AddStorageProof(types.StorageProof) uint64
+		AddStorageProof(types.StorageProof) (uint64, error)
 
 		// AddSiafundInput adds a siafund input to the transaction, returning
 		// the index of the siafund input within the transaction. When 'Sign'
 		// is called, this input will be left unsigned.
-		AddSiafundInput(types.SiafundInput) uint64
+		AddSiafundInput(types.SiafundInput) (uint64, error)
 
 		// AddSiafundOutput adds a siafund output to the transaction, returning
 		// the index of the siafund output within the transaction.
-		AddSiafundOutput(types.SiafundOutput) uint64
+		AddSiafundOutput(types.SiafundOutput) (uint64, error)
 
 		// AddArbitraryData adds arbitrary data to the transaction, returning
 		// the index of the data within the transaction.
-		AddArbitraryData(arb []byte) uint64
+		AddArbitraryData(arb []byte) (uint64, error)
 
 		// AddTransactionSignature adds a transaction signature to the
 		// transaction, returning the index of the signature within the
 		// transaction. The signature should already be valid, and shouldn't
 		// sign any of the inputs that were added by calling 'FundSiacoins' or
 		// 'FundSiafunds'.
-		AddTransactionSignature(types.TransactionSignature) uint64
+		AddTransactionSignature(types.TransactionSignature) (uint64, error)
 
 		// Sign will sign any inputs added by 'FundSiacoins' or 'FundSiafunds'
 		// and return a transaction set that contains all parents prepended to
@@ -202,6 +277,14 @@ type (
 		// parents.
 		View() (txn types.Transaction, parents []types.Transaction)
 
+		// ViewSize returns the encoded size in bytes of the transaction and
+		// its parents, not counting signatures that have yet to be added.
+		ViewSize() int
+
+		// ViewFee returns the sum of the miner fees of the transaction and
+		// its parents.
+		ViewFee() types.Currency
+
 		// ViewAdded returns all of the siacoin inputs, siafund inputs, and
 		// parent transactions that have been automatically added by the
 		// builder. Items are returned by index.
@@ -209,7 +292,9 @@ type (
 
 		// Drop indicates that a transaction is no longer useful, will not be
 		// broadcast, and that all of the outputs can be reclaimed. 'Drop'
-		// should only be used before signatures are added.
+		// should only be used before signatures are added. After 'Drop' has
+		// been called, every call that funds, modifies or signs the
+		// transaction returns an error.
 		Drop()
 	}
 
@@ -236,6 +321,15 @@ type (
 		// used to spend coins or extract keys until 'Unlock' is called.
 		Lock() error
 
+		// Locked returns true if the wallet is currently locked, false
+		// otherwise. A wallet that has not been encrypted yet is locked.
+		Locked() bool
+
+		// SetAutoLock sets the duration of inactivity after which the
+		// wallet locks itself. Only spending and seed operations count as
+		// activity. A duration of zero disables the auto-lock.
+		SetAutoLock(d time.Duration) error
+
 		// Unlock must be called before the wallet is usable. All wallets and
 		// wallet seeds are encrypted by default, and the wallet will not know
 		// which addresses to watch for on the blockchain until unlock has been
@@ -259,6 +353,12 @@ type (
 		// byte-order.
 		AllAddresses() []types.UnlockHash
 
+		// AddressIndex returns the index of the seed that generated 'addr',
+		// within the seeds returned by AllSeeds, and the index of the key
+		// within that seed. found is false if the address is not owned by the
+		// wallet.
+		AddressIndex(addr types.UnlockHash) (seedIndex int, keyIndex uint64, found bool)
+
 		// AllSeeds returns all of the seeds that are being tracked by the
 		// wallet, including the primary seed. Only the primary seed is used to
 		// generate new addresses, but the wallet can spend funds sent to
@@ -274,10 +374,36 @@ type (
 		// primary seed.
 		NextAddress() (types.UnlockConditions, error)
 
+		// GenerateAddresses returns 'n' new coin addresses generated from the
+		// primary seed. Either all 'n' addresses are generated or none are.
+		GenerateAddresses(n int) ([]types.UnlockHash, error)
+
+		// SetKeypoolSize sets the number of upcoming primary seed addresses
+		// that are derived ahead of time.
+		SetKeypoolSize(n int) error
+
+		// SetSeedExhaustionThreshold sets the percentage of the primary seed
+		// that can be consumed before seed exhaustion subscribers are warned.
+		SetSeedExhaustionThreshold(percent uint64) error
+
+		// SubscribeSeedExhaustion returns a channel of seed exhaustion
+		// warnings and a function that cancels the subscription.
+		SubscribeSeedExhaustion() (<-chan SeedExhaustionWarning, func())
+
 		// CreateBackup will create a backup of the wallet at the provided
 		// filepath. The backup will have all seeds and keys.
 		CreateBackup(string) error
 
+		// Export writes the seeds, unseeded keys, watched addresses and
+		// transaction notes of the wallet to the writer, encrypted using the
+		// master key.
+		Export(masterKey crypto.TwofishKey, w io.Writer) error
+
+		// Import merges a wallet export created by Export into the wallet.
+		// The export must be encrypted with the same master key as the
+		// wallet.
+		Import(masterKey crypto.TwofishKey, r io.Reader) error
+
 		// LoadBackup will load a backup of the wallet from the provided
 		// address. The backup wallet will be added as an auxiliary seed, not
 		// as a primary seed.
@@ -293,6 +419,13 @@ type (
 		// recovery seed before saving it to disk.
 		LoadSeed(crypto.TwofishKey, Seed) error
 
+		// RecoverSeed is like LoadSeed, but takes an English seed phrase and
+		// scans the blockchain until 'gapLimit' consecutive unused addresses
+		// follow the last used address of the seed, so that funds past a gap
+		// of unused addresses are recovered. A 'gapLimit' of zero uses a
+		// default.
+		RecoverSeed(masterKey crypto.TwofishKey, seed string, gapLimit int) error
+
 		// LoadSiagKeys will take a set of filepaths that point to a siag key
 		// and will have the siag keys loaded into the wallet so that they will
 		// become spendable.
@@ -306,11 +439,34 @@ type (
 		EncryptionManager
 		KeyManager
 
+		// ConsolidateSiafundClaims combines the siacoin outputs created by
+		// siafund claims into a single output. The transactions are given to
+		// the transaction pool and returned. If there is nothing to
+		// consolidate, an empty set is returned.
+		ConsolidateSiafundClaims(masterKey crypto.TwofishKey) ([]types.Transaction, error)
+
 		// ConfirmedBalance returns the confirmed balance of the wallet, minus
 		// any outgoing transactions. ConfirmedBalance will include unconfirmed
 		// refund transacitons.
 		ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siacoinClaimBalance types.Currency)
 
+		// SiafundClaims returns the confirmed siafund outputs of the wallet
+		// along with the siacoins that each output can currently claim. The
+		// claim values add up to the siacoin claim balance reported by
+		// ConfirmedBalance, not counting watched addresses. The claims are
+		// sorted by output id.
+		SiafundClaims() ([]SiafundClaim, error)
+
+		// ProjectedBalance returns the siacoin balance that the wallet will be
+		// able to spend at 'atHeight', counting timelocked and immature
+		// outputs that will have unlocked or matured by then.
+		ProjectedBalance(atHeight types.BlockHeight) types.Currency
+
+		// AddressBalance returns the confirmed siacoin balance of an address
+		// that is owned or watched by the wallet, along with the balance the
+		// address will have once the unconfirmed transactions are confirmed.
+		AddressBalance(addr types.UnlockHash) (confirmed types.Currency, unconfirmed types.Currency, err error)
+
 		// UnconfirmedBalance returns the unconfirmed balance of the wallet.
 		// Outgoing funds and incoming funds are reported separately. Refund
 		// outputs are included, meaning that a sending a single coin to
@@ -326,6 +482,10 @@ type (
 		// transactions related to a given address.
 		AddressUnconfirmedTransactions(types.UnlockHash) []ProcessedTransaction
 
+		// SetTransactionNote attaches a local note to the transaction with
+		// the given id. An empty note removes the existing note.
+		SetTransactionNote(txid types.TransactionID, note string) error
+
 		// Transaction returns the transaction with the given id. The bool
 		// indicates whether the transaction is in the wallet database. The
 		// wallet only stores transactions that are related to the wallet.
@@ -336,6 +496,11 @@ type (
 		// included.
 		Transactions(startHeight types.BlockHeight, endHeight types.BlockHeight) ([]ProcessedTransaction, error)
 
+		// TransactionsIter returns an iterator over the transactions that
+		// were confirmed at heights [startHeight, endHeight], in chronological
+		// order. Unlike Transactions, the history is not copied all at once.
+		TransactionsIter(startHeight types.BlockHeight, endHeight types.BlockHeight) (ProcessedTransactionIter, error)
+
 		// UnconfirmedTransactions returns all unconfirmed transactions
 		// relative to the wallet.
 		UnconfirmedTransactions() []ProcessedTransaction
@@ -348,17 +513,104 @@ type (
 		// RegisterTransaction(types.Transaction{}, nil)
 		StartTransaction() TransactionBuilder
 
+		// BatchSign signs every transaction builder in the batch under a
+		// single wallet lock, returning the transaction sets in order. If any
+		// builder fails to sign, every builder in the batch is dropped.
+		BatchSign(masterKey crypto.TwofishKey, builders []TransactionBuilder, wholeTransaction bool) ([][]types.Transaction, error)
+
+		// UnspentOutputs returns the confirmed siacoin and siafund outputs
+		// that the wallet can spend, excluding outputs that are reserved by
+		// unconfirmed transactions.
+		UnspentOutputs() ([]UnspentOutput, error)
+
+		// DustThreshold returns the value below which the wallet will not
+		// create siacoin outputs.
+		DustThreshold() types.Currency
+
+		// SetDustThreshold sets the value below which the wallet will not
+		// create siacoin outputs.
+		SetDustThreshold(threshold types.Currency)
+
+		// AddWatchAddress adds an address that the wallet tracks the balance
+		// of but cannot spend from. Outputs created before the address was
+		// added are only found by a Rescan.
+		AddWatchAddress(addr types.UnlockHash) error
+
+		// WatchedAddresses returns the watch-only addresses of the wallet.
+		WatchedAddresses() []types.UnlockHash
+
+		// SetIncludeWatchedBalance sets whether ConfirmedBalance includes the
+		// balance of the watch-only addresses.
+		SetIncludeWatchedBalance(include bool)
+
+		// EstimatedFee returns the minimum and the recommended miner fee
+		// for a typical transaction set created by the wallet, based on the
+		// current state of the transaction pool.
+		EstimatedFee() (min types.Currency, recommended types.Currency)
+
 		// SendSiacoins is a tool for sending siacoins from the wallet to an
 		// address. Sending money usually results in multiple transactions. The
 		// transactions are automatically given to the transaction pool, and
 		// are also returned to the caller.
 		SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
 
+		// SendSiacoinsMulti sends siacoins to multiple addresses using a
+		// single transaction. The transactions are automatically given to the
+		// transaction pool, and are also returned to the caller.
+		SendSiacoinsMulti(masterKey crypto.TwofishKey, outputs []types.SiacoinOutput) ([]types.Transaction, error)
+
+		// MultisigAddress creates a multisig address requiring
+		// 'requiredSigs' signatures from 'pubkeys', and starts watching it.
+		MultisigAddress(pubkeys []types.SiaPublicKey, requiredSigs uint64) (types.UnlockHash, types.UnlockConditions, error)
+
+		// SignMessage signs a message with the key of one of the wallet's
+		// addresses, proving ownership of the address. The signature can be
+		// checked with wallet.VerifyMessage, or wallet.VerifyMultisigMessage
+		// for a multisig address.
+		SignMessage(masterKey crypto.TwofishKey, addr types.UnlockHash, msg []byte) (crypto.Signature, error)
+
+		// SendSiacoinsTimelocked sends siacoins to the address of 'dest' with
+		// its timelock set to 'unlockHeight', so that the coins cannot be
+		// spent before that height. The transactions are automatically given
+		// to the transaction pool, and are also returned to the caller.
+		SendSiacoinsTimelocked(masterKey crypto.TwofishKey, amount types.Currency, dest types.UnlockConditions, unlockHeight types.BlockHeight) ([]types.Transaction, error)
+
+		// SendSiacoinsWithFee behaves like SendSiacoins, and also returns the
+		// total miner fee paid by the returned transactions.
+		SendSiacoinsWithFee(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, types.Currency, error)
+
+		// SubscribeConfirmation registers a channel that receives the
+		// confirmation height of a transaction once it is included in a
+		// block, or a height of zero if that block is later reverted.
+		SubscribeConfirmation(id crypto.Hash, ch chan<- types.BlockHeight)
+
+		// BumpFee replaces an unconfirmed transaction of the wallet with a
+		// copy that pays a higher miner fee, and submits it to the
+		// transaction pool. The pool must have replace-by-fee enabled.
+		BumpFee(masterKey crypto.TwofishKey, id crypto.Hash, newFee types.Currency) ([]types.Transaction, error)
+
+		// Defragment combines the smallest outputs of the wallet into larger
+		// outputs at addresses of the wallet once the wallet holds many
+		// outputs. The transactions are submitted to the transaction pool
+		// and returned.
+		Defragment(masterKey crypto.TwofishKey) ([]types.Transaction, error)
+
+		// Rescan replays the consensus changes that follow 'startHeight' to
+		// rebuild the transaction history and balances of the wallet from
+		// that height onwards, calling 'progress' with each height reached.
+		// The rescan stops early if the context is cancelled.
+		Rescan(ctx context.Context, startHeight types.BlockHeight, progress func(types.BlockHeight)) error
+
 		// SendSiafunds is a tool for sending siafunds from the wallet to an
 		// address. Sending money usually results in multiple transactions. The
 		// transactions are automatically given to the transaction pool, and
 		// are also returned to the caller.
 		SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
+
+		// SweepSeed sends all of the siacoins and siafunds controlled by a
+		// seed phrase to the wallet, without tracking the seed. The amounts
+		// that were swept are returned.
+		SweepSeed(seed string) (coins types.Currency, funds types.Currency, err error)
 	}
 )
 
@@ -0,0 +1,75 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errNegativeAutoLock = errors.New("auto-lock timeout cannot be negative")
+)
+
+// markActivity records that a spending or seed operation was performed,
+// delaying the auto-lock. The caller must hold the wallet lock.
+func (w *Wallet) markActivity() {
+	w.lastActivity = time.Now()
+}
+
+// scheduleAutoLock arranges for the wallet to be checked for inactivity after
+// 'd' has passed. Any previously scheduled check is cancelled. The caller must
+// hold the wallet lock.
+func (w *Wallet) scheduleAutoLock(d time.Duration) {
+	w.cancelAutoLock()
+	generation := w.autoLockGeneration
+	w.autoLockTimer = time.AfterFunc(d, func() {
+		w.managedAutoLock(generation)
+	})
+}
+
+// cancelAutoLock stops the scheduled auto-lock check, if any. A check that has
+// already fired and is waiting for the wallet lock is invalidated instead. The
+// caller must hold the wallet lock.
+func (w *Wallet) cancelAutoLock() {
+	w.autoLockGeneration++
+	if w.autoLockTimer != nil {
+		w.autoLockTimer.Stop()
+		w.autoLockTimer = nil
+	}
+}
+
+// managedAutoLock locks the wallet if no spending or seed operations have been
+// performed within the auto-lock timeout. If there has been activity, the
+// check is rescheduled for when the timeout would next expire.
+func (w *Wallet) managedAutoLock(generation uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if generation != w.autoLockGeneration || w.autoLockTimeout == 0 || !w.unlocked {
+		return
+	}
+	idle := time.Since(w.lastActivity)
+	if idle < w.autoLockTimeout {
+		w.scheduleAutoLock(w.autoLockTimeout - idle)
+		return
+	}
+	w.log.Println("INFO: Locking wallet after", w.autoLockTimeout, "of inactivity.")
+	w.lock()
+}
+
+// SetAutoLock sets the duration after which the wallet will lock itself if no
+// spending or seed operations have been performed. A duration of zero disables
+// the auto-lock. Reading balances and transactions does not count as activity.
+func (w *Wallet) SetAutoLock(d time.Duration) error {
+	if d < 0 {
+		return errNegativeAutoLock
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.autoLockTimeout = d
+	w.markActivity()
+	if d == 0 {
+		w.cancelAutoLock()
+	} else if w.unlocked {
+		w.scheduleAutoLock(d)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestAutoLock checks that the wallet locks itself after a period without
+// spending or seed operations, and that activity delays the auto-lock.
+func TestAutoLock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestAutoLock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	if wt.wallet.SetAutoLock(-time.Second) != errNegativeAutoLock {
+		t.Error("expected errNegativeAutoLock")
+	}
+	err = wt.wallet.SetAutoLock(500 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generating an address counts as activity and should delay the lock.
+	time.Sleep(300 * time.Millisecond)
+	_, err = wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if !wt.wallet.Unlocked() {
+		t.Fatal("wallet locked despite recent activity")
+	}
+
+	// Reading the balance does not count as activity.
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	time.Sleep(500 * time.Millisecond)
+	if wt.wallet.Unlocked() {
+		t.Fatal("wallet was not locked after a period of inactivity")
+	}
+	balance2, _, _ := wt.wallet.ConfirmedBalance()
+	if balance2.Cmp(balance) != 0 {
+		t.Error("balance changed after the wallet was auto-locked")
+	}
+
+	// Disabling the auto-lock should keep the wallet unlocked.
+	err = wt.wallet.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.SetAutoLock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(700 * time.Millisecond)
+	if !wt.wallet.Unlocked() {
+		t.Error("wallet was locked with the auto-lock disabled")
+	}
+
+	// A locked wallet refuses to sign, as its secret keys have been wiped.
+	b := wt.wallet.StartTransaction()
+	err = b.FundSiacoins(types.NewCurrency64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Sign(true); err != modules.ErrLockedWallet {
+		t.Error("expected modules.ErrLockedWallet when signing, got", err)
+	}
+	if err := b.FundSiacoins(types.NewCurrency64(1)); err != modules.ErrLockedWallet {
+		t.Error("expected modules.ErrLockedWallet when funding, got", err)
+	}
+}
@@ -0,0 +1,187 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errCannotBumpFee        = errors.New("only transactions that move siacoins out of the wallet can have their fee bumped")
+	errFeeNotIncreased      = errors.New("new fee must be higher than the current fee of the transaction")
+	errTransactionConfirmed = errors.New("transaction has already been confirmed")
+	errUnknownUnconfirmed   = errors.New("wallet has no unconfirmed transaction with that id")
+)
+
+// unconfirmedTransactionSet returns the unconfirmed transaction with the given
+// id along with the unconfirmed parents that it depends on, in the order that
+// they can be put into a block.
+func (w *Wallet) unconfirmedTransactionSet(txid types.TransactionID) (modules.ProcessedTransaction, []types.Transaction, error) {
+	index := -1
+	for i, upt := range w.unconfirmedProcessedTransactions {
+		if upt.TransactionID == txid {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return modules.ProcessedTransaction{}, nil, errUnknownUnconfirmed
+	}
+	pt := w.unconfirmedProcessedTransactions[index]
+	txn := pt.Transaction
+
+	// The unconfirmed transactions are in dependency order, so the parents
+	// can be found by walking backwards from the transaction.
+	needed := make(map[types.SiacoinOutputID]struct{})
+	for _, sci := range txn.SiacoinInputs {
+		needed[sci.ParentID] = struct{}{}
+	}
+	var parents []types.Transaction
+	for i := index - 1; i >= 0; i-- {
+		parent := w.unconfirmedProcessedTransactions[i].Transaction
+		isParent := false
+		for j := range parent.SiacoinOutputs {
+			if _, exists := needed[parent.SiacoinOutputID(uint64(j))]; exists {
+				isParent = true
+			}
+		}
+		if !isParent {
+			continue
+		}
+		for _, sci := range parent.SiacoinInputs {
+			needed[sci.ParentID] = struct{}{}
+		}
+		parents = append([]types.Transaction{parent}, parents...)
+	}
+	return pt, parents, nil
+}
+
+// BumpFee replaces an unconfirmed transaction of the wallet with a copy that
+// pays 'newFee' in miner fees instead. The copy spends the same inputs and
+// creates the same outputs, and the difference in fees is funded from the
+// wallet. The new transaction set is submitted to the transaction pool and
+// returned. The transaction pool only accepts the replacement if
+// replace-by-fee is enabled.
+func (w *Wallet) BumpFee(masterKey crypto.TwofishKey, id crypto.Hash, newFee types.Currency) ([]types.Transaction, error) {
+	w.mu.Lock()
+	if !w.unlocked {
+		w.mu.Unlock()
+		return nil, modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	txid := types.TransactionID(id)
+	if _, exists := w.processedTransactionMap[txid]; exists {
+		w.mu.Unlock()
+		return nil, errTransactionConfirmed
+	}
+	original, parents, err := w.unconfirmedTransactionSet(txid)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	txn := original.Transaction
+
+	// Only plain siacoin transactions that are fully signed by the wallet can
+	// be rebuilt.
+	if len(txn.FileContracts) != 0 || len(txn.FileContractRevisions) != 0 || len(txn.StorageProofs) != 0 || len(txn.SiafundInputs) != 0 || len(txn.SiafundOutputs) != 0 {
+		w.mu.Unlock()
+		return nil, errCannotBumpFee
+	}
+	for _, sci := range txn.SiacoinInputs {
+		if _, exists := w.keys[sci.UnlockConditions.UnlockHash()]; !exists {
+			w.mu.Unlock()
+			return nil, errCannotBumpFee
+		}
+	}
+	var oldFee types.Currency
+	for _, fee := range txn.MinerFees {
+		oldFee = oldFee.Add(fee)
+	}
+	if newFee.Cmp(oldFee) <= 0 {
+		w.mu.Unlock()
+		return nil, errFeeNotIncreased
+	}
+
+	// The outputs of the transaction being replaced must not be used to fund
+	// the replacement. Their previous reservations are kept so that they can
+	// be restored if the fee cannot be bumped.
+	var replacedOutputs []types.OutputID
+	prevSpent := make(map[types.OutputID]types.BlockHeight)
+	for i := range txn.SiacoinOutputs {
+		oid := types.OutputID(txn.SiacoinOutputID(uint64(i)))
+		replacedOutputs = append(replacedOutputs, oid)
+		if height, exists := w.spentOutputs[oid]; exists {
+			prevSpent[oid] = height
+		}
+		w.spentOutputs[oid] = w.consensusSetHeight
+	}
+	w.mu.Unlock()
+
+	// Rebuild the transaction with the new fee. The original inputs are
+	// marked as added by the builder so that they are signed again.
+	bumped := types.Transaction{
+		SiacoinInputs:  txn.SiacoinInputs,
+		SiacoinOutputs: txn.SiacoinOutputs,
+		MinerFees:      []types.Currency{newFee},
+		ArbitraryData:  txn.ArbitraryData,
+	}
+	tb := w.RegisterTransaction(bumped, parents).(*transactionBuilder)
+	for i := range bumped.SiacoinInputs {
+		tb.siacoinInputs = append(tb.siacoinInputs, i)
+	}
+
+	// release returns the outputs reserved to fund the fee difference and
+	// restores the reservations of the outputs of the original transaction.
+	// The original inputs stay reserved, as they are still spent by the
+	// transaction that was meant to be replaced.
+	release := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for _, i := range tb.newParents {
+			for _, sci := range tb.parents[i].SiacoinInputs {
+				delete(w.spentOutputs, types.OutputID(sci.ParentID))
+			}
+		}
+		for _, oid := range replacedOutputs {
+			if height, exists := prevSpent[oid]; exists {
+				w.spentOutputs[oid] = height
+			} else {
+				delete(w.spentOutputs, oid)
+			}
+		}
+		err := w.saveSettings()
+		if err != nil {
+			w.log.Println("ERROR: failed to save the wallet after a failed fee bump:", err)
+		}
+	}
+	err = tb.FundSiacoins(newFee.Sub(oldFee))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	txnSet, err := tb.Sign(true)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	err = w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// Keep the original transaction in the unconfirmed history, marked as
+	// replaced.
+	w.mu.Lock()
+	original.Replaced = true
+	original.ReplacedBy = txnSet[len(txnSet)-1].ID()
+	w.replacedTransactions = append(w.replacedTransactions, original)
+	w.mu.Unlock()
+	return txnSet, nil
+}
@@ -0,0 +1,172 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationBumpFee checks that BumpFee replaces an unconfirmed payment
+// in the transaction pool with a copy that pays a higher fee.
+func TestIntegrationBumpFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationBumpFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	wt.tpool.SetReplaceByFee(true)
+
+	dest := types.UnlockHash{1, 2, 3}
+	txnSet, fee, err := wt.wallet.SendSiacoinsWithFee(types.NewCurrency64(5000), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := txnSet[len(txnSet)-1]
+	id := crypto.Hash(payment.ID())
+
+	_, err = wt.wallet.BumpFee(wt.walletMasterKey, id, fee)
+	if err != errFeeNotIncreased {
+		t.Fatal("expected errFeeNotIncreased, got", err)
+	}
+	_, err = wt.wallet.BumpFee(wt.walletMasterKey, crypto.Hash{}, fee.Mul(types.NewCurrency64(2)))
+	if err != errUnknownUnconfirmed {
+		t.Fatal("expected errUnknownUnconfirmed, got", err)
+	}
+
+	// Bump the fee and check that the replacement took the place of the
+	// original payment.
+	newFee := fee.Mul(types.NewCurrency64(2))
+	bumpedSet, err := wt.wallet.BumpFee(wt.walletMasterKey, id, newFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bumped := bumpedSet[len(bumpedSet)-1]
+	if len(bumped.MinerFees) != 1 || bumped.MinerFees[0].Cmp(newFee) != 0 {
+		t.Error("replacement does not pay the new fee")
+	}
+	if !bytes.Equal(encoding.Marshal(bumped.SiacoinOutputs), encoding.Marshal(payment.SiacoinOutputs)) {
+		t.Error("replacement has different outputs than the original")
+	}
+	pool := make(map[types.TransactionID]struct{})
+	for _, txn := range wt.tpool.TransactionList() {
+		pool[txn.ID()] = struct{}{}
+	}
+	if _, exists := pool[payment.ID()]; exists {
+		t.Error("original payment is still in the pool")
+	}
+	if _, exists := pool[bumped.ID()]; !exists {
+		t.Error("replacement is not in the pool")
+	}
+
+	// Once confirmed, the replacement cannot be bumped again.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.BumpFee(wt.walletMasterKey, crypto.Hash(bumped.ID()), newFee.Mul(types.NewCurrency64(2)))
+	if err != errTransactionConfirmed {
+		t.Error("expected errTransactionConfirmed, got", err)
+	}
+}
+
+// TestBumpFeeFailureRestoresOutputs checks that a fee bump that cannot be
+// funded leaves the outputs of the original transaction spendable.
+func TestBumpFeeFailureRestoresOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestBumpFeeFailureRestoresOutputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	wt.tpool.SetReplaceByFee(true)
+
+	txnSet, _, err := wt.wallet.SendSiacoinsWithFee(types.NewCurrency64(5000), types.UnlockHash{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := txnSet[len(txnSet)-1]
+	wt.wallet.mu.Lock()
+	before := make(map[types.OutputID]bool)
+	for i := range payment.SiacoinOutputs {
+		oid := types.OutputID(payment.SiacoinOutputID(uint64(i)))
+		_, before[oid] = wt.wallet.spentOutputs[oid]
+	}
+	wt.wallet.mu.Unlock()
+
+	// The wallet cannot fund a fee larger than its balance.
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	_, err = wt.wallet.BumpFee(wt.walletMasterKey, crypto.Hash(payment.ID()), balance.Mul(types.NewCurrency64(2)))
+	if err == nil {
+		t.Fatal("expected the fee bump to fail")
+	}
+	wt.wallet.mu.Lock()
+	defer wt.wallet.mu.Unlock()
+	for oid, wasSpent := range before {
+		if _, spent := wt.wallet.spentOutputs[oid]; spent != wasSpent {
+			t.Error("failed fee bump changed the reservation of an output of the original transaction")
+		}
+	}
+}
+
+// TestIntegrationBumpFeeReplacedStatus checks that a transaction replaced by
+// BumpFee is reported as replaced until the replacement is confirmed.
+func TestIntegrationBumpFeeReplacedStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationBumpFeeReplacedStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	wt.tpool.SetReplaceByFee(true)
+
+	txnSet, fee, err := wt.wallet.SendSiacoinsWithFee(types.NewCurrency64(5000), types.UnlockHash{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment := txnSet[len(txnSet)-1]
+	bumpedSet, err := wt.wallet.BumpFee(wt.walletMasterKey, crypto.Hash(payment.ID()), fee.Mul(types.NewCurrency64(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bumped := bumpedSet[len(bumpedSet)-1]
+
+	var foundPayment, foundBumped bool
+	for _, pt := range wt.wallet.UnconfirmedTransactions() {
+		switch pt.TransactionID {
+		case payment.ID():
+			foundPayment = true
+			if !pt.Replaced || pt.ReplacedBy != bumped.ID() {
+				t.Error("original payment is not marked as replaced by the bumped transaction")
+			}
+		case bumped.ID():
+			foundBumped = true
+			if pt.Replaced {
+				t.Error("bumped transaction is marked as replaced")
+			}
+		}
+	}
+	if !foundPayment || !foundBumped {
+		t.Fatal("unconfirmed transactions are missing the original or the bumped transaction")
+	}
+
+	// Once the replacement is confirmed, the original is no longer reported.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range wt.wallet.UnconfirmedTransactions() {
+		if pt.TransactionID == payment.ID() {
+			t.Error("replaced transaction is still reported after the replacement was confirmed")
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// errClaimsBelowFee is returned if the claim outputs of the wallet are not
+	// worth the miner fee of consolidating them.
+	errClaimsBelowFee = errors.New("claim outputs are not worth the miner fee of consolidating them")
+)
+
+// siafundClaimOutputs returns the confirmed, unspent siacoin outputs in the
+// wallet that were created by siafund claims. Claim outputs are identified by
+// comparing the wallet's siacoin outputs against the claim output ids of
+// every siafund output that the wallet has seen.
+func (w *Wallet) siafundClaimOutputs() map[types.SiacoinOutputID]types.SiacoinOutput {
+	allowedHeight := respendAllowedHeight(w.consensusSetHeight)
+	claims := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	for sfoid := range w.historicClaimStarts {
+		scoid := sfoid.SiaClaimOutputID()
+		sco, exists := w.siacoinOutputs[scoid]
+		if !exists || sco.Value.IsZero() {
+			continue
+		}
+		if w.spentOutputs[types.OutputID(scoid)] > allowedHeight {
+			continue
+		}
+		claims[scoid] = sco
+	}
+	return claims
+}
+
+// claimsTransaction creates and signs a transaction that combines the claim
+// outputs into a single output at 'dest', paying 'fee' to the miners. The
+// wallet lock must be held.
+func (w *Wallet) claimsTransaction(claims map[types.SiacoinOutputID]types.SiacoinOutput, total, fee types.Currency, dest types.UnlockHash) (types.Transaction, error) {
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      total.Sub(fee),
+			UnlockHash: dest,
+		}},
+		MinerFees: []types.Currency{fee},
+	}
+	for scoid, sco := range claims {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         scoid,
+			UnlockConditions: w.keys[sco.UnlockHash].UnlockConditions,
+		})
+	}
+	for _, sci := range txn.SiacoinInputs {
+		_, err := addSignatures(&txn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), w.keys[sci.UnlockConditions.UnlockHash()])
+		if err != nil {
+			return types.Transaction{}, err
+		}
+	}
+	return txn, nil
+}
+
+// ConsolidateSiafundClaims gathers the siacoin outputs that were created by
+// siafund claims and combines them into a single output at a fresh address.
+// The miner fee is taken out of the claimed siacoins, and errClaimsBelowFee is
+// returned if the claims are not worth the fee. The siafunds themselves are not
+// touched. The transaction is submitted to the transaction pool and returned.
+// If there are fewer than two claim outputs, there is nothing to consolidate
+// and an empty set is returned.
+func (w *Wallet) ConsolidateSiafundClaims(masterKey crypto.TwofishKey) ([]types.Transaction, error) {
+	w.mu.Lock()
+	if !w.unlocked {
+		w.mu.Unlock()
+		return nil, modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	w.mu.Unlock()
+
+	// The fee depends on the size of the signed transaction, so the
+	// transaction is built without a fee, measured, and rebuilt with a higher
+	// fee until the fee covers its size, as in SendSiacoinsMulti. The
+	// transaction pool is queried without holding the wallet lock. If a claim
+	// output is spent in the meantime, the transaction is rebuilt from the
+	// remaining claim outputs.
+	var fee types.Currency
+	var dest types.UnlockHash
+	var haveDest, sized bool
+	var claims map[types.SiacoinOutputID]types.SiacoinOutput
+	var txn types.Transaction
+	for {
+		w.mu.Lock()
+		if !w.unlocked {
+			w.mu.Unlock()
+			return nil, modules.ErrLockedWallet
+		}
+		current := w.siafundClaimOutputs()
+		if sized {
+			spendable := true
+			for scoid := range claims {
+				if _, exists := current[scoid]; !exists {
+					spendable = false
+					break
+				}
+			}
+			if spendable {
+				break
+			}
+		}
+		claims = current
+		if len(claims) < 2 {
+			w.mu.Unlock()
+			return nil, nil
+		}
+		var total types.Currency
+		for _, sco := range claims {
+			total = total.Add(sco.Value)
+		}
+		if total.Cmp(fee.Add(w.dustThreshold)) <= 0 {
+			w.mu.Unlock()
+			return nil, errClaimsBelowFee
+		}
+		if !haveDest {
+			uc, err := w.nextPrimarySeedAddress()
+			if err != nil {
+				w.mu.Unlock()
+				return nil, err
+			}
+			dest = uc.UnlockHash()
+			haveDest = true
+		}
+		txn, err = w.claimsTransaction(claims, total, fee, dest)
+		w.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		required := w.sizedFee(len(encoding.Marshal([]types.Transaction{txn})), 1)
+		sized = fee.Cmp(required) >= 0
+		if !sized {
+			fee = required
+		}
+	}
+	for scoid := range claims {
+		w.spentOutputs[types.OutputID(scoid)] = w.consensusSetHeight
+	}
+	w.mu.Unlock()
+
+	// Submit the transaction. If the transaction pool rejects it, the claim
+	// outputs are made available again.
+	txnSet := []types.Transaction{txn}
+	err = w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		w.mu.Lock()
+		for scoid := range claims {
+			delete(w.spentOutputs, types.OutputID(scoid))
+		}
+		w.mu.Unlock()
+		return nil, err
+	}
+
+	// Save the spent outputs so that they remain reserved if the wallet
+	// restarts.
+	w.mu.Lock()
+	err = w.saveSettingsSync()
+	w.mu.Unlock()
+	if err != nil {
+		w.log.Println("WARN: could not save spent claim outputs:", err)
+	}
+	return txnSet, nil
+}
+
+// claimsByID sorts siafund claims by the id of their siafund output.
+type claimsByID []modules.SiafundClaim
+
+func (cbi claimsByID) Len() int      { return len(cbi) }
+func (cbi claimsByID) Swap(i, j int) { cbi[i], cbi[j] = cbi[j], cbi[i] }
+func (cbi claimsByID) Less(i, j int) bool {
+	return bytes.Compare(cbi[i].ID[:], cbi[j].ID[:]) < 0
+}
+
+// SiafundClaims returns the confirmed siafund outputs of the wallet together
+// with their claim starts and the siacoins that each output can currently
+// claim from the siafund pool, sorted by output id.
+func (w *Wallet) SiafundClaims() ([]modules.SiafundClaim, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.unlocked {
+		return nil, modules.ErrLockedWallet
+	}
+
+	var claims []modules.SiafundClaim
+	for sfoid, sfo := range w.siafundOutputs {
+		claims = append(claims, modules.SiafundClaim{
+			ID:          sfoid,
+			UnlockHash:  sfo.UnlockHash,
+			Value:       sfo.Value,
+			ClaimStart:  sfo.ClaimStart,
+			SiafundPool: w.siafundPool,
+			ClaimValue:  w.siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value).Div(types.SiafundCount),
+		})
+	}
+	sort.Sort(claimsByID(claims))
+	return claims, nil
+}
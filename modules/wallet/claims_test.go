@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestConsolidateSiafundClaims accrues several siafund claim outputs and then
+// checks that ConsolidateSiafundClaims combines them into a single output.
+func TestConsolidateSiafundClaims(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestConsolidateSiafundClaims")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	err = wt.wallet.LoadSiagKeys(wt.walletMasterKey, []string{"../../types/siag0of1of1.siakey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a second wallet from the same persist directory, which will
+	// discover the siafunds belonging to the siag key.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With no claim outputs, consolidation should be a no-op.
+	txns, err := w.ConsolidateSiafundClaims(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 0 {
+		t.Fatal("expected an empty set when there are no claim outputs")
+	}
+
+	// Grow the siafund pool with file contracts and spend siafunds after each
+	// contract so that claim outputs are created.
+	for i := 0; i < 3; i++ {
+		builder := w.StartTransaction()
+		payout := types.NewCurrency64(1e3).Mul(types.SiacoinPrecision)
+		err = builder.FundSiacoins(payout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder.AddFileContract(types.FileContract{
+			WindowStart:        wt.cs.Height() + 10,
+			WindowEnd:          wt.cs.Height() + 20,
+			Payout:             payout,
+			ValidProofOutputs:  []types.SiacoinOutput{{Value: types.PostTax(wt.cs.Height(), payout)}},
+			MissedProofOutputs: []types.SiacoinOutput{{Value: types.PostTax(wt.cs.Height(), payout)}},
+			UnlockHash:         types.UnlockConditions{}.UnlockHash(),
+		})
+		tSet, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = wt.tpool.AcceptTransactionSet(tSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.SendSiafunds(types.NewCurrency64(1), types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Mine until the claim outputs have matured.
+	for i := types.BlockHeight(0); i <= types.MaturityDelay; i++ {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.mu.Lock()
+	claims := w.siafundClaimOutputs()
+	w.mu.Unlock()
+	if len(claims) < 2 {
+		t.Fatal("expected several claim outputs, got", len(claims))
+	}
+	var claimTotal types.Currency
+	for _, sco := range claims {
+		claimTotal = claimTotal.Add(sco.Value)
+	}
+	siacoinBal, siafundBal, _ := w.ConfirmedBalance()
+
+	// Claims that are not worth the fee should not be consolidated.
+	wt.tpool.SetMinimumFee(types.SiacoinPrecision)
+	_, err = w.ConsolidateSiafundClaims(wt.walletMasterKey)
+	if err != errClaimsBelowFee {
+		t.Fatal("expected errClaimsBelowFee, got", err)
+	}
+
+	// Consolidate the claims while the transaction pool requires a fee, and
+	// confirm the transaction.
+	wt.tpool.SetMinimumFee(types.SiacoinPrecision.Div(types.NewCurrency64(1e6)))
+	txns, err = w.ConsolidateSiafundClaims(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 1 || len(txns[0].SiacoinInputs) != len(claims) || len(txns[0].SiacoinOutputs) != 1 {
+		t.Fatal("claim outputs were not combined into a single output")
+	}
+	if len(txns[0].MinerFees) != 1 || txns[0].MinerFees[0].IsZero() {
+		t.Fatal("consolidation does not pay a miner fee")
+	}
+	if txns[0].SiacoinOutputs[0].Value.Add(txns[0].MinerFees[0]).Cmp(claimTotal) != 0 {
+		t.Error("consolidated output does not hold the claim value less the fee")
+	}
+	if len(txns[0].SiafundInputs) != 0 {
+		t.Error("consolidation should not spend siafunds")
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The claim outputs should be gone, and the miner payout should be the
+	// only change in the siacoin balance.
+	w.mu.Lock()
+	claims = w.siafundClaimOutputs()
+	w.mu.Unlock()
+	if len(claims) != 0 {
+		t.Error("claim outputs remain after consolidation:", len(claims))
+	}
+	newSiacoinBal, newSiafundBal, _ := w.ConfirmedBalance()
+	if newSiacoinBal.Cmp(siacoinBal) <= 0 {
+		t.Error("siacoin balance decreased after consolidation")
+	}
+	if newSiafundBal.Cmp(siafundBal) != 0 {
+		t.Error("siafund balance changed after consolidation")
+	}
+}
+
+// TestSiafundClaims checks that SiafundClaims reports the claim of every
+// siafund output of the wallet, and that the claims grow with the siafund
+// pool and add up to the claim balance of the wallet.
+func TestSiafundClaims(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSiafundClaims")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	err = wt.wallet.LoadSiagKeys(wt.walletMasterKey, []string{"../../types/siag0of1of1.siakey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.SiafundClaims()
+	if err != modules.ErrLockedWallet {
+		t.Fatal("expected ErrLockedWallet, got", err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := w.SiafundClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) == 0 {
+		t.Fatal("wallet with siafunds reports no claims")
+	}
+
+	// Grow the siafund pool with a file contract.
+	builder := w.StartTransaction()
+	payout := types.NewCurrency64(1e9)
+	err = builder.FundSiacoins(payout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddFileContract(types.FileContract{
+		WindowStart:        wt.cs.Height() + 10,
+		WindowEnd:          wt.cs.Height() + 20,
+		Payout:             payout,
+		ValidProofOutputs:  []types.SiacoinOutput{{Value: types.PostTax(wt.cs.Height(), payout)}},
+		MissedProofOutputs: []types.SiacoinOutput{{Value: types.PostTax(wt.cs.Height(), payout)}},
+		UnlockHash:         types.UnlockConditions{}.UnlockHash(),
+	})
+	tSet, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.tpool.AcceptTransactionSet(tSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grown, err := w.SiafundClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grown) != len(claims) {
+		t.Fatal("number of siafund outputs changed without spending siafunds")
+	}
+	var total types.Currency
+	for _, claim := range grown {
+		if claim.SiafundPool.Cmp(claims[0].SiafundPool) <= 0 {
+			t.Error("siafund pool did not grow after adding a file contract")
+		}
+		expected := claim.SiafundPool.Sub(claim.ClaimStart).Mul(claim.Value).Div(types.SiafundCount)
+		if claim.ClaimValue.Cmp(expected) != 0 {
+			t.Error("claim value does not follow the siafund pool")
+		}
+		total = total.Add(claim.ClaimValue)
+	}
+	_, _, claimBalance := w.ConfirmedBalance()
+	if total.Cmp(claimBalance) != 0 {
+		t.Fatalf("claims add up to %v, but the claim balance is %v", total, claimBalance)
+	}
+	if total.IsZero() {
+		t.Fatal("siafund claims did not grow with the siafund pool")
+	}
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// confirmedSubscription is a confirmation subscription whose transaction has
+// been included in a block. The subscription is kept so that the subscribers
+// can be told if the block is reverted.
+type confirmedSubscription struct {
+	height      types.BlockHeight
+	subscribers []chan<- types.BlockHeight
+}
+
+// pendingSubscription is a confirmation subscription whose transaction has not
+// been included in a block yet. The height is the height of the wallet when
+// the latest subscriber registered, so that subscriptions for transactions
+// that are dropped or double spent can be expired.
+type pendingSubscription struct {
+	height      types.BlockHeight
+	subscribers []chan<- types.BlockHeight
+}
+
+// notifyConfirmation sends 'height' to the confirmation subscriber without
+// blocking the wallet.
+func notifyConfirmation(ch chan<- types.BlockHeight, height types.BlockHeight) {
+	select {
+	case ch <- height:
+	default:
+	}
+}
+
+// updateConfirmationSubscribers notifies the confirmation subscribers of the
+// transactions that were reverted or applied by the consensus change. It must
+// be called before the consensus change is applied to the history of the
+// wallet, so that the confirmation heights match the processed transactions.
+func (w *Wallet) updateConfirmationSubscribers(cc modules.ConsensusChange) {
+	if len(w.pendingConfirmations) == 0 && len(w.confirmedSubscriptions) == 0 {
+		return
+	}
+
+	// Subscribers of reverted transactions receive a zero height and are
+	// removed.
+	height := w.consensusSetHeight
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			txid := txn.ID()
+			cs, exists := w.confirmedSubscriptions[txid]
+			if !exists {
+				continue
+			}
+			for _, ch := range cs.subscribers {
+				notifyConfirmation(ch, 0)
+			}
+			delete(w.confirmedSubscriptions, txid)
+		}
+		height--
+	}
+
+	// Subscribers of applied transactions receive the confirmation height.
+	for _, block := range cc.AppliedBlocks {
+		height++
+		for _, txn := range block.Transactions {
+			txid := txn.ID()
+			ps, exists := w.pendingConfirmations[txid]
+			if !exists {
+				continue
+			}
+			for _, ch := range ps.subscribers {
+				notifyConfirmation(ch, height)
+			}
+			delete(w.pendingConfirmations, txid)
+			w.confirmedSubscriptions[txid] = confirmedSubscription{
+				height:      height,
+				subscribers: ps.subscribers,
+			}
+		}
+	}
+
+	// Transactions that have not confirmed for a long time have most likely
+	// been dropped from the transaction pool or double spent, and are no
+	// longer tracked.
+	for txid, ps := range w.pendingConfirmations {
+		if height > ps.height+types.MaturityDelay {
+			delete(w.pendingConfirmations, txid)
+		}
+	}
+
+	// Transactions that are buried deep enough are unlikely to be reverted,
+	// and are no longer tracked.
+	for txid, cs := range w.confirmedSubscriptions {
+		if height > cs.height+types.MaturityDelay {
+			delete(w.confirmedSubscriptions, txid)
+		}
+	}
+}
+
+// SubscribeConfirmation registers 'ch' to receive the confirmation height of
+// the transaction with the given id once the transaction is included in a
+// block. If the transaction is already confirmed, the height is sent right
+// away. If the block containing the transaction is later reverted, 'ch'
+// receives a height of zero and the subscription ends, at which point the
+// caller should subscribe again. Reverts are only reported for
+// types.MaturityDelay blocks after the confirmation. Subscriptions for
+// transactions that are not confirmed within types.MaturityDelay blocks, for
+// example because they were dropped or double spent, end without a
+// notification. The wallet never blocks on 'ch', so it should have room for
+// two values.
+func (w *Wallet) SubscribeConfirmation(id crypto.Hash, ch chan<- types.BlockHeight) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	txid := types.TransactionID(id)
+	if pt, exists := w.processedTransactionMap[txid]; exists {
+		notifyConfirmation(ch, pt.ConfirmationHeight)
+		cs := w.confirmedSubscriptions[txid]
+		cs.height = pt.ConfirmationHeight
+		cs.subscribers = append(cs.subscribers, ch)
+		w.confirmedSubscriptions[txid] = cs
+		return
+	}
+	ps := w.pendingConfirmations[txid]
+	ps.height = w.consensusSetHeight
+	ps.subscribers = append(ps.subscribers, ch)
+	w.pendingConfirmations[txid] = ps
+}
@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationSubscribeConfirmation checks that confirmation subscribers
+// learn the confirmation height of a payment, and are told when the payment
+// is reverted.
+func TestIntegrationSubscribeConfirmation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSubscribeConfirmation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Solve a block that will become the base of a competing fork before the
+	// payment is sent, so that the fork does not confirm the payment.
+	forkBlock, err := wt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := txns[len(txns)-1].ID()
+	ch := make(chan types.BlockHeight, 2)
+	wt.wallet.SubscribeConfirmation(crypto.Hash(txid), ch)
+	select {
+	case <-ch:
+		t.Fatal("subscriber was notified before the payment confirmed")
+	default:
+	}
+
+	// Confirm the payment.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, exists := wt.wallet.Transaction(txid)
+	if !exists {
+		t.Fatal("payment was not confirmed")
+	}
+	select {
+	case height := <-ch:
+		if height != pt.ConfirmationHeight {
+			t.Errorf("expected confirmation height %v, got %v", pt.ConfirmationHeight, height)
+		}
+	default:
+		t.Fatal("subscriber was not notified of the confirmation")
+	}
+
+	// A late subscriber is notified right away.
+	lateCh := make(chan types.BlockHeight, 2)
+	wt.wallet.SubscribeConfirmation(crypto.Hash(txid), lateCh)
+	select {
+	case height := <-lateCh:
+		if height != pt.ConfirmationHeight {
+			t.Errorf("expected confirmation height %v, got %v", pt.ConfirmationHeight, height)
+		}
+	default:
+		t.Fatal("late subscriber was not notified of the confirmation")
+	}
+
+	// Revert the block containing the payment by extending the competing
+	// fork past the current chain.
+	err = wt.cs.AcceptBlock(forkBlock)
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatal("expected the fork block to be non-extending, got", err)
+	}
+	b := types.Block{
+		ParentID:     forkBlock.ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(wt.cs.Height() + 1)}},
+	}
+	target, _ := wt.cs.ChildTarget(forkBlock.ID())
+	b, solved := wt.miner.SolveBlock(b, target)
+	if !solved {
+		t.Fatal("could not solve the fork block")
+	}
+	err = wt.cs.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []chan types.BlockHeight{ch, lateCh} {
+		select {
+		case height := <-c:
+			if height != 0 {
+				t.Error("expected a zero height after the revert, got", height)
+			}
+		default:
+			t.Error("subscriber was not notified of the revert")
+		}
+	}
+}
+
+// TestIntegrationConfirmationExpiry checks that subscriptions for transactions
+// that never confirm are dropped after types.MaturityDelay blocks.
+func TestIntegrationConfirmationExpiry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationConfirmationExpiry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	ch := make(chan types.BlockHeight, 2)
+	wt.wallet.SubscribeConfirmation(crypto.Hash{1}, ch)
+	for i := types.BlockHeight(0); i <= types.MaturityDelay; i++ {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wt.wallet.mu.Lock()
+	pending := len(wt.wallet.pendingConfirmations)
+	wt.wallet.mu.Unlock()
+	if pending != 0 {
+		t.Error("subscription for an unconfirmed transaction was not dropped")
+	}
+	select {
+	case <-ch:
+		t.Error("expired subscriber should not be notified")
+	default:
+	}
+}
@@ -0,0 +1,210 @@
+package wallet
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// defragThreshold is the number of spendable outputs that the wallet
+	// needs to have before Defragment combines any of them.
+	defragThreshold = 50
+
+	// defragBatchSize is the number of outputs that are combined by a single
+	// defragmenting transaction. 35 inputs with one signature each keep the
+	// transaction well below modules.TransactionSizeLimit.
+	defragBatchSize = 35
+)
+
+// A defragBatch is a group of outputs that are combined by a single
+// defragmenting transaction. size is an upper bound on the encoded size of
+// the transaction set holding the transaction.
+type defragBatch struct {
+	ids     []types.SiacoinOutputID
+	outputs []types.SiacoinOutput
+	total   types.Currency
+	size    int
+}
+
+// defragTransaction creates and signs a transaction that combines the outputs
+// of 'batch' into a single output at 'dest', paying 'fee' to the miners.
+func (w *Wallet) defragTransaction(batch defragBatch, fee types.Currency, dest types.UnlockHash) (types.Transaction, error) {
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      batch.total.Sub(fee),
+			UnlockHash: dest,
+		}},
+		MinerFees: []types.Currency{fee},
+	}
+	for i := range batch.ids {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         batch.ids[i],
+			UnlockConditions: w.keys[batch.outputs[i].UnlockHash].UnlockConditions,
+		})
+	}
+	for _, sci := range txn.SiacoinInputs {
+		_, err := addSignatures(&txn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), w.keys[sci.UnlockConditions.UnlockHash()])
+		if err != nil {
+			return types.Transaction{}, err
+		}
+	}
+	return txn, nil
+}
+
+// Defragment combines the smallest confirmed outputs of the wallet into
+// larger outputs at fresh addresses of the wallet, which makes future
+// transactions smaller and cheaper. Outputs are combined in batches of
+// defragBatchSize until fewer than defragThreshold spendable outputs remain;
+// if the wallet already has fewer than defragThreshold outputs, nothing is
+// done and nil is returned. The transactions are submitted to the transaction
+// pool individually and returned.
+func (w *Wallet) Defragment(masterKey crypto.TwofishKey) ([]types.Transaction, error) {
+	w.mu.Lock()
+	if !w.unlocked {
+		w.mu.Unlock()
+		return nil, modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	w.markActivity()
+
+	// Collect the outputs that can be spent, smallest first.
+	var so sortedOutputs
+	allowedHeight := respendAllowedHeight(w.consensusSetHeight)
+	for scoid, sco := range w.siacoinOutputs {
+		if w.spentOutputs[types.OutputID(scoid)] > allowedHeight {
+			continue
+		}
+		if w.consensusSetHeight < w.keys[sco.UnlockHash].UnlockConditions.Timelock {
+			continue
+		}
+		so.ids = append(so.ids, scoid)
+		so.outputs = append(so.outputs, sco)
+	}
+	if so.Len() < defragThreshold {
+		w.mu.Unlock()
+		return nil, nil
+	}
+	sort.Sort(so)
+
+	// Split the outputs into batches and bound the size of each batch. The
+	// size is measured on a signed draft whose fee and output value are both
+	// the whole value of the batch. A currency never encodes larger than a
+	// larger currency, and the fee and output value of the final transaction
+	// are at most the value of the batch, while the destination and the
+	// signatures have a fixed size. The draft therefore encodes at least as
+	// large as the final transaction.
+	var batches []defragBatch
+	for start := 0; start < so.Len(); start += defragBatchSize {
+		end := start + defragBatchSize
+		if end > so.Len() {
+			end = so.Len()
+		}
+		if end-start < 2 {
+			break
+		}
+		batch := defragBatch{
+			ids:     so.ids[start:end],
+			outputs: so.outputs[start:end],
+		}
+		for _, sco := range batch.outputs {
+			batch.total = batch.total.Add(sco.Value)
+		}
+		draft, err := w.defragTransaction(batch, types.ZeroCurrency, types.UnlockHash{})
+		if err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+		draft.MinerFees[0] = batch.total
+		batch.size = len(encoding.Marshal([]types.Transaction{draft}))
+		batches = append(batches, batch)
+	}
+	w.mu.Unlock()
+
+	// The transaction pool is queried without holding the wallet lock.
+	fees := make([]types.Currency, len(batches))
+	for i, batch := range batches {
+		fees[i] = w.sizedFee(batch.size, 1)
+	}
+
+	// Combine the outputs in batches. Each batch replaces its inputs with a
+	// single output, so the number of remaining outputs shrinks by one less
+	// than the size of the batch. Batches whose value would not cover the fee
+	// are skipped; as the outputs are sorted, later batches are larger.
+	// Batches with outputs that were spent while the lock was released are
+	// skipped as well.
+	w.mu.Lock()
+	var txns []types.Transaction
+	remaining := so.Len()
+	allowedHeight = respendAllowedHeight(w.consensusSetHeight)
+	for i, batch := range batches {
+		if remaining < defragThreshold {
+			break
+		}
+		if batch.total.Cmp(fees[i].Add(w.dustThreshold)) <= 0 {
+			continue
+		}
+		spendable := true
+		for _, id := range batch.ids {
+			_, exists := w.siacoinOutputs[id]
+			if !exists || w.spentOutputs[types.OutputID(id)] > allowedHeight {
+				spendable = false
+				break
+			}
+		}
+		if !spendable {
+			continue
+		}
+
+		dest, err := w.nextPrimarySeedAddress()
+		if err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+		txn, err := w.defragTransaction(batch, fees[i], dest.UnlockHash())
+		if err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+		txns = append(txns, txn)
+		remaining -= len(batch.ids) - 1
+	}
+	for _, txn := range txns {
+		for _, sci := range txn.SiacoinInputs {
+			w.spentOutputs[types.OutputID(sci.ParentID)] = w.consensusSetHeight
+		}
+	}
+	err = w.saveSettings()
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Submit the transactions. The outputs of transactions that could not be
+	// submitted are released so that they can be spent again.
+	for i, txn := range txns {
+		err = w.tpool.AcceptTransactionSet([]types.Transaction{txn})
+		if err != nil {
+			w.mu.Lock()
+			for _, unsent := range txns[i:] {
+				for _, sci := range unsent.SiacoinInputs {
+					delete(w.spentOutputs, types.OutputID(sci.ParentID))
+				}
+			}
+			saveErr := w.saveSettings()
+			w.mu.Unlock()
+			if saveErr != nil {
+				w.log.Println("ERROR: failed to save the wallet after a failed defragment:", saveErr)
+			}
+			return txns[:i], err
+		}
+	}
+	return txns, nil
+}
@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationDefragment checks that Defragment combines the outputs of a
+// fragmented wallet and leaves a wallet with few outputs alone.
+func TestIntegrationDefragment(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationDefragment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Fragment the wallet by sending many small outputs to itself.
+	var outputs []types.SiacoinOutput
+	for i := 0; i < defragThreshold+10; i++ {
+		uc, err := wt.wallet.NextAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, types.SiacoinOutput{
+			Value:      types.SiacoinPrecision.Mul(types.NewCurrency64(10)),
+			UnlockHash: uc.UnlockHash(),
+		})
+	}
+	_, err = wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _, _ := wt.wallet.ConfirmedBalance()
+
+	txns, err := wt.wallet.Defragment(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) == 0 {
+		t.Fatal("expected the wallet to be defragmented")
+	}
+	var fees types.Currency
+	for _, txn := range txns {
+		if len(txn.SiacoinInputs) > defragBatchSize || len(txn.SiacoinOutputs) != 1 {
+			t.Fatal("defragmenting transaction has the wrong shape")
+		}
+		for _, fee := range txn.MinerFees {
+			fees = fees.Add(fee)
+		}
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the fees should have left the wallet, apart from the block reward
+	// that matured in the meantime.
+	wt.wallet.mu.Lock()
+	numOutputs := len(wt.wallet.siacoinOutputs)
+	wt.wallet.mu.Unlock()
+	if numOutputs >= defragThreshold {
+		t.Error("wallet still has too many outputs:", numOutputs)
+	}
+	after, _, _ := wt.wallet.ConfirmedBalance()
+	if after.Add(fees).Cmp(before) < 0 {
+		t.Error("defragmenting lost more than the fees:", before, after, fees)
+	}
+
+	// A wallet with few outputs is not defragmented again.
+	txns, err = wt.wallet.Defragment(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txns != nil {
+		t.Error("expected no transactions, got", len(txns))
+	}
+}
+
+// TestIntegrationDefragmentMinimumFee checks that defragmenting transactions
+// pay for their full size when the transaction pool requires a high fee per
+// byte.
+func TestIntegrationDefragmentMinimumFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationDefragmentMinimumFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	var outputs []types.SiacoinOutput
+	for i := 0; i < defragThreshold+10; i++ {
+		uc, err := wt.wallet.NextAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, types.SiacoinOutput{
+			Value:      types.SiacoinPrecision.Mul(types.NewCurrency64(10)),
+			UnlockHash: uc.UnlockHash(),
+		})
+	}
+	_, err = wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Require a fee per byte that a fee estimated for a small transaction
+	// set cannot cover for a full batch.
+	wt.tpool.SetMinimumFee(types.SiacoinPrecision.Div(types.NewCurrency64(100)))
+	txns, err := wt.wallet.Defragment(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) == 0 {
+		t.Fatal("expected the wallet to be defragmented")
+	}
+	for _, txn := range txns {
+		size := len(encoding.Marshal([]types.Transaction{txn}))
+		if txn.MinerFees[0].Cmp(wt.tpool.RequiredFee(size, 1)) < 0 {
+			t.Error("defragmenting transaction does not pay for its size")
+		}
+		// The per-byte estimate is lower than the required fee, so only the
+		// required fee should be paid, not the sum of both.
+		if txn.MinerFees[0].Cmp(wt.tpool.RequiredFee(size, 1).MulFloat(1.25)) > 0 {
+			t.Error("defragmenting transaction pays more than the required fee")
+		}
+	}
+}
@@ -119,7 +119,26 @@ func (w *Wallet) unlock(masterKey crypto.TwofishKey) error {
 	if err != nil {
 		return err
 	}
+	w.loadMultisigKeys()
+
+	// Have the transaction pool watch all of the wallet's addresses, including
+	// the watch-only addresses, so that the wallet is only sent the
+	// unconfirmed transactions relevant to it.
+	addrs := make([]types.UnlockHash, 0, len(w.keys)+len(w.watchedAddresses))
+	for addr := range w.keys {
+		addrs = append(addrs, addr)
+	}
+	for addr := range w.watchedAddresses {
+		addrs = append(addrs, addr)
+	}
+	w.tpool.RegisterWatchedAddresses(addrs)
+	w.masterKey = masterKey
 	w.unlocked = true
+	w.refillKeypool()
+	w.markActivity()
+	if w.autoLockTimeout != 0 {
+		w.scheduleAutoLock(w.autoLockTimeout)
+	}
 	return nil
 }
 
@@ -136,6 +155,8 @@ func (w *Wallet) wipeSecrets() {
 		crypto.SecureWipe(w.seeds[i][:])
 	}
 	crypto.SecureWipe(w.primarySeed[:])
+	crypto.SecureWipe(w.masterKey[:])
+	w.wipeKeypool()
 	w.seeds = w.seeds[:0]
 }
 
@@ -171,6 +192,14 @@ func (w *Wallet) Unlocked() bool {
 	return w.unlocked
 }
 
+// Locked indicates whether the wallet is locked, in which case the seeds and
+// secret keys are not held in memory and the wallet cannot spend coins.
+func (w *Wallet) Locked() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return !w.unlocked
+}
+
 // Lock will erase all keys from memory and prevent the wallet from spending
 // coins until it is unlocked.
 func (w *Wallet) Lock() error {
@@ -180,12 +209,16 @@ func (w *Wallet) Lock() error {
 		return modules.ErrLockedWallet
 	}
 	w.log.Println("INFO: Locking wallet.")
+	w.lock()
+	return nil
+}
 
-	// Wipe all of the seeds and secret keys, they will be replaced upon
-	// calling 'Unlock' again.
+// lock wipes all of the seeds and secret keys from memory, they will be
+// replaced upon calling 'Unlock' again. Any scheduled auto-lock is cancelled.
+func (w *Wallet) lock() {
+	w.cancelAutoLock()
 	w.wipeSecrets()
 	w.unlocked = false
-	return nil
 }
 
 // Unlock will decrypt the wallet seed and load all of the addresses into
@@ -210,7 +243,7 @@ func (w *Wallet) Unlock(masterKey crypto.TwofishKey) error {
 		if err != nil {
 			return errors.New("wallet subscription failed: " + err.Error())
 		}
-		w.tpool.TransactionPoolSubscribe(w)
+		w.tpool.TransactionPoolSubscribeWatched(w)
 		w.mu.Lock()
 		w.subscribed = true
 		w.mu.Unlock()
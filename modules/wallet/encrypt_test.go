@@ -205,11 +205,17 @@ func TestLock(t *testing.T) {
 	}
 
 	// Lock the wallet.
+	if wt.wallet.Locked() {
+		t.Fatal("wallet should not be locked before calling Lock")
+	}
 	siacoinBalance, _, _ := wt.wallet.ConfirmedBalance()
 	err = wt.wallet.Lock()
 	if err != nil {
 		t.Error(err)
 	}
+	if !wt.wallet.Locked() {
+		t.Error("wallet should be locked after calling Lock")
+	}
 	// Compare to the original balance.
 	siacoinBalance2, _, _ := wt.wallet.ConfirmedBalance()
 	if siacoinBalance2.Cmp(siacoinBalance) != 0 {
@@ -230,6 +236,9 @@ func TestLock(t *testing.T) {
 	if !bytes.Equal(wipedKey[:crypto.EntropySize], wt.wallet.primarySeed[:]) {
 		t.Error("primary seed not wiped from memory")
 	}
+	if wt.wallet.masterKey != (crypto.TwofishKey{}) {
+		t.Error("master key not wiped from memory")
+	}
 
 	// Solve the block generated earlier and add it to the consensus set, this
 	// should boost the balance of the wallet.
@@ -242,4 +251,13 @@ func TestLock(t *testing.T) {
 	if siacoinBalance3.Cmp(siacoinBalance2) <= 0 {
 		t.Error("balance should increase after a block was mined")
 	}
+
+	// Unlocking the wallet again should clear the locked status.
+	err = wt.wallet.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wt.wallet.Locked() {
+		t.Error("wallet should not be locked after calling Unlock")
+	}
 }
@@ -0,0 +1,90 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// defaultSeedExhaustionThreshold is the percentage of the primary seed
+	// that can be consumed before seed exhaustion subscribers are warned.
+	defaultSeedExhaustionThreshold = 90
+)
+
+var (
+	errInvalidExhaustionThreshold = errors.New("seed exhaustion threshold must be between 1 and 100 percent")
+)
+
+// seedUsage returns the number of addresses that have been taken from the
+// primary seed when the seed progress is 'progress', including the addresses
+// that are preloaded by the wallet.
+func seedUsage(progress uint64) uint64 {
+	return progress + modules.WalletSeedPreloadDepth
+}
+
+// checkSeedExhaustion sends a warning to all seed exhaustion subscribers if
+// the primary seed usage has crossed the exhaustion threshold since the seed
+// progress was 'oldProgress'. Subscribers that are not ready to receive the
+// warning are skipped so that the wallet is never blocked.
+func (w *Wallet) checkSeedExhaustion(oldProgress uint64) {
+	limit := modules.PublicKeysPerSeed * w.seedExhaustionThreshold / 100
+	newUsage := seedUsage(w.persist.PrimarySeedProgress)
+	if seedUsage(oldProgress) >= limit || newUsage < limit {
+		return
+	}
+
+	var remaining uint64
+	if newUsage < modules.PublicKeysPerSeed {
+		remaining = modules.PublicKeysPerSeed - newUsage
+	}
+	w.notifySeedExhaustion(modules.SeedExhaustionWarning{
+		Progress:  w.persist.PrimarySeedProgress,
+		Remaining: remaining,
+	})
+}
+
+// notifySeedExhaustion sends 'warning' to all seed exhaustion subscribers.
+// Subscribers that are not ready to receive the warning are skipped so that
+// the wallet is never blocked.
+func (w *Wallet) notifySeedExhaustion(warning modules.SeedExhaustionWarning) {
+	for _, c := range w.seedExhaustionSubscribers {
+		select {
+		case c <- warning:
+		default:
+		}
+	}
+}
+
+// SetSeedExhaustionThreshold sets the percentage of the primary seed that can
+// be consumed before seed exhaustion subscribers are warned.
+func (w *Wallet) SetSeedExhaustionThreshold(percent uint64) error {
+	if percent == 0 || percent > 100 {
+		return errInvalidExhaustionThreshold
+	}
+	w.mu.Lock()
+	w.seedExhaustionThreshold = percent
+	w.mu.Unlock()
+	return nil
+}
+
+// SubscribeSeedExhaustion returns a channel of seed exhaustion warnings and a
+// function, safe to call more than once, that cancels the subscription.
+func (w *Wallet) SubscribeSeedExhaustion() (<-chan modules.SeedExhaustionWarning, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextSeedExhaustionSubscriber
+	w.nextSeedExhaustionSubscriber++
+	c := make(chan modules.SeedExhaustionWarning, 1)
+	w.seedExhaustionSubscribers[id] = c
+
+	cancel := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, exists := w.seedExhaustionSubscribers[id]; exists {
+			delete(w.seedExhaustionSubscribers, id)
+			close(c)
+		}
+	}
+	return c, cancel
+}
@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSubscribeSeedExhaustion checks that seed exhaustion subscribers are
+// warned exactly once when the primary seed crosses the exhaustion threshold,
+// and that canceling a subscription closes the channel.
+func TestSubscribeSeedExhaustion(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSubscribeSeedExhaustion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Invalid thresholds should be rejected.
+	if wt.wallet.SetSeedExhaustionThreshold(0) != errInvalidExhaustionThreshold {
+		t.Error("expected errInvalidExhaustionThreshold")
+	}
+	if wt.wallet.SetSeedExhaustionThreshold(101) != errInvalidExhaustionThreshold {
+		t.Error("expected errInvalidExhaustionThreshold")
+	}
+
+	// Set the threshold so that it will be crossed after a few more
+	// addresses are generated.
+	_, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.SetSeedExhaustionThreshold(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := uint64(modules.PublicKeysPerSeed * 10 / 100)
+	used := progress + modules.WalletSeedPreloadDepth
+	if used >= limit {
+		t.Fatal("wallet tester has already used too much of the primary seed")
+	}
+	warnings, cancel := wt.wallet.SubscribeSeedExhaustion()
+
+	// Generate addresses up to just below the threshold, no warning should
+	// be sent.
+	_, err = wt.wallet.GenerateAddresses(int(limit - used - 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-warnings:
+		t.Fatal("warning sent before the threshold was crossed")
+	default:
+	}
+
+	// Cross the threshold with a single address.
+	_, err = wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case warning := <-warnings:
+		if warning.Remaining != modules.PublicKeysPerSeed-limit {
+			t.Error("warning has the wrong remaining count:", warning.Remaining)
+		}
+		if warning.Progress != limit-modules.WalletSeedPreloadDepth {
+			t.Error("warning has the wrong progress:", warning.Progress)
+		}
+	default:
+		t.Fatal("no warning sent after the threshold was crossed")
+	}
+
+	// Additional addresses should not trigger another warning.
+	_, err = wt.wallet.GenerateAddresses(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-warnings:
+		t.Fatal("warning sent twice for the same threshold")
+	default:
+	}
+
+	// Canceling the subscription should close the channel, and canceling
+	// twice should be safe.
+	cancel()
+	cancel()
+	if _, ok := <-warnings; ok {
+		t.Fatal("channel was not closed after the subscription was canceled")
+	}
+}
@@ -0,0 +1,219 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	exportMetadata = persist.Metadata{
+		Header:  "Wallet Export",
+		Version: "0.4.0",
+	}
+
+	errExportKeyMismatch = errors.New("wallet export was encrypted with a different master key")
+)
+
+type (
+	// walletExport contains the state of a wallet that is carried over when
+	// moving the wallet to another machine.
+	walletExport struct {
+		Seeds            []modules.Seed
+		SeedDepths       []uint64
+		UnseededKeys     []spendableKey
+		WatchedAddresses []types.UnlockHash
+		TransactionNotes []TransactionNote
+	}
+
+	// exportFile is an encrypted wallet export. The export is encrypted using
+	// a key derived from the master key and the UID, the same way that seed
+	// files are encrypted.
+	exportFile struct {
+		UID                    UniqueID
+		EncryptionVerification crypto.Ciphertext
+		Export                 crypto.Ciphertext
+	}
+)
+
+// Export writes the seeds and their depths, unseeded keys, watched addresses
+// and transaction notes of the wallet to 'w', encrypted using the master key.
+// The export can be loaded into another wallet using Import.
+func (w *Wallet) Export(masterKey crypto.TwofishKey, dst io.Writer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		return err
+	}
+	w.markActivity()
+
+	export := walletExport{
+		Seeds:            w.seeds,
+		WatchedAddresses: w.persist.WatchedAddresses,
+	}
+
+	// Each seed is exported with the number of addresses that the wallet
+	// tracks for it, so that addresses past the default depth are tracked by
+	// the importing wallet as well.
+	depths := make(map[modules.Seed]uint64)
+	for i, sf := range w.persist.AuxiliarySeedFiles {
+		seed, err := decryptSeedFile(masterKey, sf)
+		if err != nil {
+			return err
+		}
+		depths[seed] = modules.PublicKeysPerSeed
+		if i < len(w.persist.AuxiliarySeedDepths) {
+			depths[seed] = w.persist.AuxiliarySeedDepths[i]
+		}
+		crypto.SecureWipe(seed[:])
+	}
+	depths[w.primarySeed] = seedUsage(w.persist.PrimarySeedProgress)
+	for _, seed := range w.seeds {
+		export.SeedDepths = append(export.SeedDepths, depths[seed])
+	}
+	for _, uk := range w.persist.UnseededKeys {
+		sk, err := decryptSpendableKeyFile(masterKey, uk)
+		if err != nil {
+			return err
+		}
+		export.UnseededKeys = append(export.UnseededKeys, sk)
+	}
+	for id, note := range w.transactionNotes {
+		export.TransactionNotes = append(export.TransactionNotes, TransactionNote{ID: id, Note: note})
+	}
+	sort.Sort(transactionNotesByID(export.TransactionNotes))
+
+	// Encrypt the export.
+	var ef exportFile
+	_, err = rand.Read(ef.UID[:])
+	if err != nil {
+		return err
+	}
+	encKey := uidEncryptionKey(masterKey, ef.UID)
+	ef.EncryptionVerification, err = encKey.EncryptBytes(make([]byte, encryptionVerificationLen))
+	if err != nil {
+		return err
+	}
+	ef.Export, err = encKey.EncryptBytes(encoding.Marshal(export))
+	if err != nil {
+		return err
+	}
+	return encoding.NewEncoder(dst).EncodeAll(exportMetadata, ef)
+}
+
+// Import loads a wallet export created by Export into the wallet. The export
+// is merged with the wallet: seeds, keys and watched addresses that the wallet
+// already has are skipped, and existing transaction notes are kept. The export
+// must have been encrypted with the same master key as the wallet. Watched
+// addresses are tracked right away, the same as with AddWatchAddress, while
+// unseeded keys are tracked once the wallet has been reloaded.
+func (w *Wallet) Import(masterKey crypto.TwofishKey, src io.Reader) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		return err
+	}
+	w.markActivity()
+
+	// Read and decrypt the export.
+	var meta persist.Metadata
+	var ef exportFile
+	err = encoding.NewDecoder(src).DecodeAll(&meta, &ef)
+	if err != nil {
+		return err
+	}
+	if meta.Header != exportMetadata.Header {
+		return persist.ErrBadHeader
+	}
+	if meta.Version != exportMetadata.Version {
+		return persist.ErrBadVersion
+	}
+	encKey := uidEncryptionKey(masterKey, ef.UID)
+	verification, err := encKey.DecryptBytes(ef.EncryptionVerification)
+	if err != nil || !bytes.Equal(verification, make([]byte, encryptionVerificationLen)) {
+		return errExportKeyMismatch
+	}
+	plaintext, err := encKey.DecryptBytes(ef.Export)
+	if err != nil {
+		return errExportKeyMismatch
+	}
+	var export walletExport
+	err = encoding.Unmarshal(plaintext, &export)
+	if err != nil {
+		return err
+	}
+
+	// Merge the seeds, tracking as many addresses of each seed as the
+	// exporting wallet did.
+	for i, seed := range export.Seeds {
+		depth := uint64(modules.PublicKeysPerSeed)
+		if i < len(export.SeedDepths) {
+			depth = export.SeedDepths[i]
+		}
+		err = w.recoverSeed(masterKey, seed, depth)
+		if err != nil && err != errKnownSeed {
+			return err
+		}
+	}
+
+	// Merge the unseeded keys. Keys that have been loaded since the wallet
+	// was unlocked are not in w.keys yet, so the key files are checked as
+	// well.
+	loaded := make(map[types.UnlockHash]struct{})
+	for _, uk := range w.persist.UnseededKeys {
+		sk, err := decryptSpendableKeyFile(masterKey, uk)
+		if err != nil {
+			return err
+		}
+		loaded[sk.UnlockConditions.UnlockHash()] = struct{}{}
+	}
+	for _, sk := range export.UnseededKeys {
+		if _, exists := loaded[sk.UnlockConditions.UnlockHash()]; exists {
+			continue
+		}
+		err = w.loadSpendableKey(masterKey, sk)
+		if err != nil && err != errDuplicateSpendableKey {
+			return err
+		}
+	}
+
+	// Merge the watched addresses.
+	watched := make(map[types.UnlockHash]struct{})
+	for _, addr := range w.persist.WatchedAddresses {
+		watched[addr] = struct{}{}
+	}
+	for _, addr := range export.WatchedAddresses {
+		_, isWatched := watched[addr]
+		_, isSpendable := w.keys[addr]
+		if isWatched || isSpendable {
+			continue
+		}
+		watched[addr] = struct{}{}
+		w.persist.WatchedAddresses = append(w.persist.WatchedAddresses, addr)
+		w.watchAddress(addr)
+	}
+
+	// Merge the transaction notes.
+	for _, tn := range export.TransactionNotes {
+		if _, exists := w.transactionNotes[tn.ID]; !exists {
+			w.transactionNotes[tn.ID] = tn.Note
+		}
+	}
+	return w.saveSettingsSync()
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestExportImport checks that a wallet export can be merged into another
+// wallet that uses the same master key, and is rejected by a wallet that uses
+// a different master key.
+func TestExportImport(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestExportImport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Give the wallet some state beyond the primary seed.
+	watchAddr := types.UnlockHash{1, 2, 3}
+	err = wt.wallet.AddWatchAddress(watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := types.TransactionID{4, 5, 6}
+	err = wt.wallet.SetTransactionNote(txid, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Use more addresses of the primary seed than a seed tracks by default.
+	wt.wallet.mu.Lock()
+	wt.wallet.persist.PrimarySeedProgress = modules.PublicKeysPerSeed
+	wt.wallet.mu.Unlock()
+	var export bytes.Buffer
+	err = wt.wallet.Export(wt.walletMasterKey, &export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primarySeed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a second wallet that uses the same master key.
+	wt2, err := createBlankWalletTester("TestExportImport - 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt2.closeWt()
+	_, err = wt2.wallet.Encrypt(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt2.wallet.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt2.wallet.SetTransactionNote(txid, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt2.wallet.Import(wt.walletMasterKey, bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds, err := wt2.wallet.AllSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 2 || seeds[1] != primarySeed {
+		t.Error("exported seed was not imported")
+	}
+	if len(wt2.wallet.persist.AuxiliarySeedDepths) != 1 || wt2.wallet.persist.AuxiliarySeedDepths[0] != seedUsage(modules.PublicKeysPerSeed) {
+		t.Error("depth of the exported seed was not imported:", wt2.wallet.persist.AuxiliarySeedDepths)
+	}
+	watched := wt2.wallet.WatchedAddresses()
+	if len(watched) != 1 || watched[0] != watchAddr {
+		t.Error("watched address was not imported:", watched)
+	}
+	if _, exists := wt2.wallet.watchedAddresses[watchAddr]; !exists {
+		t.Error("imported watch address is not tracked until the wallet is reloaded")
+	}
+	if wt2.wallet.transactionNotes[txid] != "bar" {
+		t.Error("existing transaction note was overwritten")
+	}
+
+	// Importing the same export again should not duplicate anything.
+	err = wt2.wallet.Import(wt.walletMasterKey, bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds, err = wt2.wallet.AllSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 2 || len(wt2.wallet.WatchedAddresses()) != 1 {
+		t.Error("importing twice duplicated the wallet state")
+	}
+
+	// A wallet with a different master key should reject the export.
+	wt3, err := createWalletTester("TestExportImport - 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt3.closeWt()
+	err = wt3.wallet.Import(wt3.walletMasterKey, bytes.NewReader(export.Bytes()))
+	if err != errExportKeyMismatch {
+		t.Error("expected errExportKeyMismatch, got", err)
+	}
+	err = wt3.wallet.Import(crypto.TwofishKey{}, bytes.NewReader(export.Bytes()))
+	if err == nil {
+		t.Error("import should fail with the wrong wallet master key")
+	}
+}
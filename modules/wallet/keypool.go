@@ -0,0 +1,150 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	errNegativeKeypoolSize = errors.New("keypool size cannot be negative")
+
+	// defaultKeypoolSize is the number of upcoming primary seed keys that the
+	// wallet derives ahead of time.
+	defaultKeypoolSize = func() int {
+		switch build.Release {
+		case "dev":
+			return 10
+		case "standard":
+			return 100
+		case "testing":
+			return 0
+		default:
+			panic("unrecognized build.Release")
+		}
+	}()
+)
+
+// A pooledKey is a spendable key of the primary seed that has been derived
+// ahead of time, along with the index of the key within the seed.
+type pooledKey struct {
+	index uint64
+	key   spendableKey
+}
+
+// wipeKeypool erases all of the secret keys in the keypool.
+func (w *Wallet) wipeKeypool() {
+	for i := range w.keypool {
+		for j := range w.keypool[i].key.SecretKeys {
+			crypto.SecureWipe(w.keypool[i].key.SecretKeys[j][:])
+		}
+	}
+	w.keypool = nil
+}
+
+// nextKeypoolIndex discards any keys in the keypool that have already been
+// handed out, and returns the index of the next key that should be added to
+// the keypool.
+func (w *Wallet) nextKeypoolIndex() uint64 {
+	start := seedUsage(w.persist.PrimarySeedProgress)
+	for len(w.keypool) > 0 && w.keypool[0].index < start {
+		w.keypool = w.keypool[1:]
+	}
+	if len(w.keypool) == 0 {
+		return start
+	}
+	return w.keypool[len(w.keypool)-1].index + 1
+}
+
+// primarySeedKey returns the key at 'index' of the primary seed, taking the
+// key from the keypool if it has already been derived. The keypool is
+// refilled in the background.
+func (w *Wallet) primarySeedKey(index uint64) spendableKey {
+	defer w.refillKeypool()
+	for len(w.keypool) > 0 && w.keypool[0].index < index {
+		w.keypool = w.keypool[1:]
+	}
+	if len(w.keypool) > 0 && w.keypool[0].index == index {
+		key := w.keypool[0].key
+		w.keypool = w.keypool[1:]
+		return key
+	}
+	return generateSpendableKey(w.primarySeed, index)
+}
+
+// refillKeypool starts a background thread to refill the keypool, unless one
+// is already running or the keypool is full.
+func (w *Wallet) refillKeypool() {
+	if w.keypoolRefilling || !w.unlocked || len(w.keypool) >= w.keypoolSize {
+		return
+	}
+	w.keypoolRefilling = true
+	go w.threadedRefillKeypool()
+}
+
+// threadedRefillKeypool derives upcoming keys of the primary seed until the
+// keypool is full. Key derivation is expensive, so the wallet lock is released
+// while each key is derived. Keys beyond 'PublicKeysPerSeed' are never
+// derived, as they would not be found when the seed is recovered. Instead,
+// once every key of the primary seed has been handed out, the wallet rotates
+// to a new primary seed and the keypool is filled from the new seed.
+func (w *Wallet) threadedRefillKeypool() {
+	for {
+		w.mu.Lock()
+		index := w.nextKeypoolIndex()
+		if !w.unlocked || len(w.keypool) >= w.keypoolSize || (index >= modules.PublicKeysPerSeed && len(w.keypool) > 0) {
+			w.keypoolRefilling = false
+			w.mu.Unlock()
+			return
+		}
+		if index >= modules.PublicKeysPerSeed {
+			err := w.rotatePrimarySeed()
+			if err != nil {
+				w.log.Println("WARN: keypool could not rotate the primary seed:", err)
+				w.keypoolRefilling = false
+				w.mu.Unlock()
+				return
+			}
+			w.mu.Unlock()
+			continue
+		}
+		seed := w.primarySeed
+		w.mu.Unlock()
+
+		key := generateSpendableKey(seed, index)
+
+		// The wallet may have been locked or the key may have been handed
+		// out while the key was being derived.
+		w.mu.Lock()
+		if w.unlocked && w.primarySeed == seed && w.nextKeypoolIndex() == index {
+			w.keypool = append(w.keypool, pooledKey{index: index, key: key})
+		}
+		w.mu.Unlock()
+		crypto.SecureWipe(seed[:])
+	}
+}
+
+// SetKeypoolSize sets the number of upcoming primary seed addresses that the
+// wallet derives ahead of time, so that NextAddress and GenerateAddresses do
+// not need to wait for key derivation. A size of zero disables the keypool.
+func (w *Wallet) SetKeypoolSize(n int) error {
+	if n < 0 {
+		return errNegativeKeypoolSize
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.keypoolSize = n
+	if len(w.keypool) > n {
+		excess := w.keypool[n:]
+		w.keypool = w.keypool[:n:n]
+		for i := range excess {
+			for j := range excess[i].key.SecretKeys {
+				crypto.SecureWipe(excess[i].key.SecretKeys[j][:])
+			}
+		}
+	}
+	w.refillKeypool()
+	return nil
+}
@@ -0,0 +1,182 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// waitForKeypool waits until the keypool of the wallet contains 'n' keys.
+func waitForKeypool(w *Wallet, n int) bool {
+	for i := 0; i < 100; i++ {
+		w.mu.RLock()
+		size := len(w.keypool)
+		w.mu.RUnlock()
+		if size == n {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+// TestKeypool checks that the keypool is filled in the background, that
+// addresses are taken from the keypool in order, and that the keypool is wiped
+// when the wallet is locked.
+func TestKeypool(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestKeypool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	if wt.wallet.SetKeypoolSize(-1) != errNegativeKeypoolSize {
+		t.Error("expected errNegativeKeypoolSize")
+	}
+	err = wt.wallet.SetKeypoolSize(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitForKeypool(wt.wallet, 5) {
+		t.Fatal("keypool was not filled")
+	}
+
+	// Addresses should come from the keypool, and match the addresses
+	// derived directly from the seed.
+	seed, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := wt.wallet.GenerateAddresses(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, addr := range addrs {
+		expected := generateSpendableKey(seed, progress+modules.WalletSeedPreloadDepth+uint64(i)).UnlockConditions.UnlockHash()
+		if addr != expected {
+			t.Fatal("keypool address does not match the seed address")
+		}
+	}
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc.UnlockHash() != generateSpendableKey(seed, progress+modules.WalletSeedPreloadDepth+3).UnlockConditions.UnlockHash() {
+		t.Fatal("keypool address does not match the seed address")
+	}
+	if !waitForKeypool(wt.wallet, 5) {
+		t.Fatal("keypool was not refilled")
+	}
+
+	// Shrinking the keypool should discard the excess keys.
+	err = wt.wallet.SetKeypoolSize(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitForKeypool(wt.wallet, 2) {
+		t.Fatal("keypool was not shrunk")
+	}
+
+	// Locking the wallet should wipe the keypool.
+	err = wt.wallet.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitForKeypool(wt.wallet, 0) {
+		t.Fatal("keypool was not wiped when the wallet was locked")
+	}
+}
+
+// TestKeypoolRotation checks that the keypool rotates to a new primary seed
+// once every key of the primary seed has been handed out, and is refilled
+// from the new seed.
+func TestKeypoolRotation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestKeypoolRotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Move the primary seed to its last address.
+	wt.wallet.mu.Lock()
+	wt.wallet.persist.PrimarySeedProgress = modules.PublicKeysPerSeed - modules.WalletSeedPreloadDepth - 1
+	err = wt.wallet.saveSettingsSync()
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSeed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.SetKeypoolSize(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitForKeypool(wt.wallet, 1) {
+		t.Fatal("keypool was not filled with the last key of the seed")
+	}
+
+	// Handing out the last address should make the keypool rotate the seed
+	// and fill up with keys of the new seed.
+	_, err = wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitForKeypool(wt.wallet, 5) {
+		t.Fatal("keypool was not refilled after the seed was exhausted")
+	}
+	newSeed, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newSeed == oldSeed || progress != 0 {
+		t.Fatal("keypool did not rotate the primary seed")
+	}
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc.UnlockHash() != generateSpendableKey(newSeed, modules.WalletSeedPreloadDepth).UnlockConditions.UnlockHash() {
+		t.Fatal("keypool address does not match the new seed")
+	}
+}
+
+// benchmarkNextAddress measures the latency of NextAddress with a keypool of
+// the given size.
+func benchmarkNextAddress(b *testing.B, name string, keypoolSize int) {
+	wt, err := createWalletTester(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer wt.closeWt()
+	err = wt.wallet.SetKeypoolSize(keypoolSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	waitForKeypool(wt.wallet, keypoolSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := wt.wallet.NextAddress()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNextAddress measures the latency of NextAddress without a keypool.
+func BenchmarkNextAddress(b *testing.B) { benchmarkNextAddress(b, "BenchmarkNextAddress", 0) }
+
+// BenchmarkNextAddressKeypool measures the latency of NextAddress when
+// addresses are taken from the keypool.
+func BenchmarkNextAddressKeypool(b *testing.B) {
+	benchmarkNextAddress(b, "BenchmarkNextAddressKeypool", 1000)
+}
@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// signedMessageSpecifier is hashed together with every signed message, so
+	// that a signed message can never double as a signature on a transaction.
+	signedMessageSpecifier = types.Specifier{'s', 'i', 'g', 'n', 'e', 'd', ' ', 'm', 'e', 's', 's', 'a', 'g', 'e'}
+
+	errNoSpendingKey      = errors.New("wallet does not have the spending key of that address")
+	errNonStandardAddress = errors.New("messages can only be signed by addresses with a single ed25519 key")
+	errWatchOnlySign      = errors.New("address is watch-only, the wallet has no spending key to sign with")
+)
+
+// messageHash returns the hash that is signed to prove ownership of an address.
+func messageHash(msg []byte) crypto.Hash {
+	return crypto.HashAll(signedMessageSpecifier, msg)
+}
+
+// standardPublicKey returns the public key of unlock conditions that are
+// satisfied by a single ed25519 signature.
+func standardPublicKey(uc types.UnlockConditions) (crypto.PublicKey, bool) {
+	var pk crypto.PublicKey
+	if uc.SignaturesRequired != 1 || len(uc.PublicKeys) != 1 || uc.PublicKeys[0].Algorithm != types.SignatureEd25519 || len(uc.PublicKeys[0].Key) != len(pk) {
+		return pk, false
+	}
+	copy(pk[:], uc.PublicKeys[0].Key)
+	return pk, true
+}
+
+// SignMessage signs 'msg' with the secret key of 'addr', proving that the
+// wallet controls the address without moving any coins. The signature can be
+// checked with VerifyMessage. Watch-only addresses cannot sign messages. For a
+// multisig address created by MultisigAddress, the message is signed with the
+// wallet's key for the first public key of the address that the wallet holds,
+// and can be checked with VerifyMultisigMessage.
+func (w *Wallet) SignMessage(masterKey crypto.TwofishKey, addr types.UnlockHash, msg []byte) (crypto.Signature, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.unlocked {
+		return crypto.Signature{}, modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+
+	sk, exists := w.keys[addr]
+	if !exists {
+		if ms, isMultisig := w.multisigAddresses[addr]; isMultisig {
+			return w.signMultisigMessage(ms, msg)
+		}
+		for _, watched := range w.persist.WatchedAddresses {
+			if watched == addr {
+				return crypto.Signature{}, errWatchOnlySign
+			}
+		}
+		return crypto.Signature{}, errNoSpendingKey
+	}
+	if _, ok := standardPublicKey(sk.UnlockConditions); !ok || len(sk.SecretKeys) != 1 {
+		return crypto.Signature{}, errNonStandardAddress
+	}
+	return crypto.SignHash(messageHash(msg), sk.SecretKeys[0])
+}
+
+// VerifyMessage checks that 'sig' is a signature on 'msg' created by
+// SignMessage with the key of 'addr'. An address is a hash of its unlock
+// conditions, which means the public key cannot be recovered from the address
+// alone, so the unlock conditions of the address, as returned by
+// NextAddress, must be provided as well.
+func VerifyMessage(addr types.UnlockHash, uc types.UnlockConditions, msg []byte, sig crypto.Signature) bool {
+	if uc.UnlockHash() != addr {
+		return false
+	}
+	pk, ok := standardPublicKey(uc)
+	if !ok {
+		return false
+	}
+	return crypto.VerifyHash(messageHash(msg), pk, sig) == nil
+}
+
+// VerifyMultisigMessage checks that 'sig' is a signature on 'msg' created by
+// SignMessage with the key of 'addr' at 'keyIndex' in the public keys of the
+// unlock conditions 'uc'.
+func VerifyMultisigMessage(addr types.UnlockHash, uc types.UnlockConditions, msg []byte, sig crypto.Signature, keyIndex uint64) bool {
+	if uc.UnlockHash() != addr || keyIndex >= uint64(len(uc.PublicKeys)) {
+		return false
+	}
+	spk := uc.PublicKeys[keyIndex]
+	var pk crypto.PublicKey
+	if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != len(pk) {
+		return false
+	}
+	copy(pk[:], spk.Key)
+	return crypto.VerifyHash(messageHash(msg), pk, sig) == nil
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSignMessage checks that a message signed with the key of a wallet
+// address can be verified against that address, and that watch-only
+// addresses cannot sign messages.
+func TestSignMessage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSignMessage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := uc.UnlockHash()
+	msg := []byte("I control this address")
+	sig, err := wt.wallet.SignMessage(wt.walletMasterKey, addr, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyMessage(addr, uc, msg, sig) {
+		t.Fatal("signed message does not verify")
+	}
+	if VerifyMessage(addr, uc, []byte("something else"), sig) {
+		t.Error("signature verifies for a different message")
+	}
+	other, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyMessage(other.UnlockHash(), other, msg, sig) || VerifyMessage(addr, other, msg, sig) {
+		t.Error("signature verifies for a different address")
+	}
+
+	// The signature of a message cannot be used as a transaction signature.
+	var pk crypto.PublicKey
+	copy(pk[:], uc.PublicKeys[0].Key)
+	if crypto.VerifyHash(crypto.HashBytes(msg), pk, sig) == nil {
+		t.Error("signature verifies for the unprefixed message")
+	}
+
+	// Addresses without a spending key cannot sign.
+	_, err = wt.wallet.SignMessage(wt.walletMasterKey, types.UnlockHash{1}, msg)
+	if err != errNoSpendingKey {
+		t.Error("expected errNoSpendingKey, got", err)
+	}
+	watchAddr := types.UnlockHash{2}
+	err = wt.wallet.AddWatchAddress(watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.SignMessage(wt.walletMasterKey, watchAddr, msg)
+	if err != errWatchOnlySign {
+		t.Error("expected errWatchOnlySign, got", err)
+	}
+	_, err = wt.wallet.SignMessage(crypto.TwofishKey{}, addr, msg)
+	if err == nil {
+		t.Error("expected an error when signing with the wrong master key")
+	}
+}
@@ -1,10 +1,21 @@
 package wallet
 
 import (
+	"errors"
+
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+var (
+	errNoOutputs        = errors.New("at least one output must be provided")
+	errTimelockNotAhead = errors.New("unlock height of a timelocked output must be above the current height")
+	errUntrackedAddress = errors.New("address is neither owned nor watched by the wallet")
+)
+
 // sortedOutputs is a struct containing a slice of siacoin outputs and their
 // corresponding ids. sortedOutputs can be sorted using the sort package.
 type sortedOutputs struct {
@@ -13,7 +24,9 @@ type sortedOutputs struct {
 }
 
 // ConfirmedBalance returns the balance of the wallet according to all of the
-// confirmed transactions.
+// confirmed transactions. The balance of the multisig addresses created by the
+// wallet is always included, and the balance of the other watch-only addresses
+// is included if SetIncludeWatchedBalance has been enabled.
 func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -25,12 +38,161 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 		siafundBalance = siafundBalance.Add(sfo.Value)
 		siafundClaimBalance = siafundClaimBalance.Add(w.siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value).Div(types.SiafundCount))
 	}
+	watchedSiacoins, watchedSiafunds, watchedClaims := w.watchedBalance(w.includeWatchedBalance)
+	siacoinBalance = siacoinBalance.Add(watchedSiacoins)
+	siafundBalance = siafundBalance.Add(watchedSiafunds)
+	siafundClaimBalance = siafundClaimBalance.Add(watchedClaims)
 	return
 }
 
+// ProjectedBalance returns the siacoin balance that the wallet will hold once
+// the chain has reached 'atHeight'. Outputs of timelocked addresses are only
+// counted once their timelock has been reached, and miner payouts and file
+// contract payouts that have not matured yet are counted if they mature at or
+// before 'atHeight'. Watch-only balances are included as in ConfirmedBalance.
+// At the current height, the projected balance of a wallet without timelocked
+// outputs equals the siacoin balance reported by ConfirmedBalance.
+func (w *Wallet) ProjectedBalance(atHeight types.BlockHeight) types.Currency {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var balance types.Currency
+	for _, sco := range w.siacoinOutputs {
+		if w.keys[sco.UnlockHash].UnlockConditions.Timelock <= atHeight {
+			balance = balance.Add(sco.Value)
+		}
+	}
+	for _, dsco := range w.delayedSiacoinOutputs {
+		if dsco.MaturityHeight <= atHeight {
+			balance = balance.Add(dsco.SiacoinOutput.Value)
+		}
+	}
+	for _, sco := range w.watchedSiacoinOutputs {
+		ms, isMultisig := w.multisigAddresses[sco.UnlockHash]
+		if (isMultisig || w.includeWatchedBalance) && ms.uc.Timelock <= atHeight {
+			balance = balance.Add(sco.Value)
+		}
+	}
+	return balance
+}
+
+// AddressBalance returns the siacoin balance of a single address that is owned
+// or watched by the wallet. 'confirmed' is the value of the confirmed outputs
+// at the address. 'unconfirmed' is the balance that the address will have once
+// the unconfirmed transactions are confirmed, meaning that incoming unconfirmed
+// outputs are added and unconfirmed spends from the address are subtracted.
+func (w *Wallet) AddressBalance(addr types.UnlockHash) (confirmed types.Currency, unconfirmed types.Currency, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return types.Currency{}, types.Currency{}, modules.ErrLockedWallet
+	}
+	outputs := w.siacoinOutputs
+	if _, exists := w.keys[addr]; !exists {
+		if _, watched := w.watchedAddresses[addr]; !watched {
+			return types.Currency{}, types.Currency{}, errUntrackedAddress
+		}
+		outputs = w.watchedSiacoinOutputs
+	}
+
+	for _, sco := range outputs {
+		if sco.UnlockHash == addr {
+			confirmed = confirmed.Add(sco.Value)
+		}
+	}
+	var incoming, outgoing types.Currency
+	for _, upt := range w.unconfirmedProcessedTransactions {
+		for _, input := range upt.Inputs {
+			if input.FundType == types.SpecifierSiacoinInput && input.RelatedAddress == addr {
+				outgoing = outgoing.Add(input.Value)
+			}
+		}
+		for _, output := range upt.Outputs {
+			if output.FundType == types.SpecifierSiacoinOutput && output.RelatedAddress == addr {
+				incoming = incoming.Add(output.Value)
+			}
+		}
+	}
+	unconfirmed = confirmed.Add(incoming)
+	if unconfirmed.Cmp(outgoing) < 0 {
+		// The unconfirmed transactions are updated separately from the
+		// confirmed outputs, so for a moment the unconfirmed set may spend
+		// outputs that the wallet no longer has, for example after a reorg.
+		// The unconfirmed balance is reported as zero until the two agree.
+		return confirmed, types.ZeroCurrency, nil
+	}
+	return confirmed, unconfirmed.Sub(outgoing), nil
+}
+
+// outputHeights returns the confirmation heights of the outputs created by the
+// confirmed transaction history of the wallet.
+func (w *Wallet) outputHeights() map[types.OutputID]types.BlockHeight {
+	heights := make(map[types.OutputID]types.BlockHeight)
+	for _, pt := range w.processedTransactions {
+		if len(pt.Inputs) == 0 && len(pt.Outputs) > 0 && pt.Outputs[0].FundType == types.SpecifierMinerPayout {
+			// The miner payouts of a block are identified by the block id.
+			for i := range pt.Outputs {
+				heights[types.OutputID(crypto.HashAll(types.BlockID(pt.TransactionID), uint64(i)))] = pt.ConfirmationHeight
+			}
+			continue
+		}
+		for i := range pt.Transaction.SiacoinOutputs {
+			heights[types.OutputID(pt.Transaction.SiacoinOutputID(uint64(i)))] = pt.ConfirmationHeight
+		}
+		for i := range pt.Transaction.SiafundOutputs {
+			heights[types.OutputID(pt.Transaction.SiafundOutputID(uint64(i)))] = pt.ConfirmationHeight
+		}
+		for _, sfi := range pt.Transaction.SiafundInputs {
+			heights[types.OutputID(sfi.ParentID.SiaClaimOutputID())] = pt.ConfirmationHeight
+		}
+	}
+	return heights
+}
+
+// UnspentOutputs returns the confirmed siacoin and siafund outputs that the
+// wallet can spend. Outputs that have been reserved by a transaction builder
+// within the respend timeout are not returned.
+func (w *Wallet) UnspentOutputs() ([]modules.UnspentOutput, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return nil, modules.ErrLockedWallet
+	}
+
+	heights := w.outputHeights()
+	allowedHeight := respendAllowedHeight(w.consensusSetHeight)
+	var outputs []modules.UnspentOutput
+	for scoid, sco := range w.siacoinOutputs {
+		if w.spentOutputs[types.OutputID(scoid)] > allowedHeight {
+			continue
+		}
+		outputs = append(outputs, modules.UnspentOutput{
+			ConfirmationHeight: heights[types.OutputID(scoid)],
+			FundType:           types.SpecifierSiacoinOutput,
+			ID:                 types.OutputID(scoid),
+			UnlockHash:         sco.UnlockHash,
+			Value:              sco.Value,
+		})
+	}
+	for sfoid, sfo := range w.siafundOutputs {
+		if w.spentOutputs[types.OutputID(sfoid)] > allowedHeight {
+			continue
+		}
+		outputs = append(outputs, modules.UnspentOutput{
+			ConfirmationHeight: heights[types.OutputID(sfoid)],
+			FundType:           types.SpecifierSiafundOutput,
+			ID:                 types.OutputID(sfoid),
+			UnlockHash:         sfo.UnlockHash,
+			Value:              sfo.Value,
+		})
+	}
+	return outputs, nil
+}
+
 // UnconfirmedBalance returns the number of outgoing and incoming siacoins in
 // the unconfirmed transaction set. Refund outputs are included in this
-// reporting.
+// reporting. Change that was below the dust threshold was added to the miner
+// fees, and is therefore counted as outgoing.
 func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incomingSiacoins types.Currency) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -50,37 +212,227 @@ func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incoming
 	return
 }
 
+// DustThreshold returns the value below which the wallet will not create
+// siacoin outputs.
+func (w *Wallet) DustThreshold() types.Currency {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.dustThreshold
+}
+
+// SetDustThreshold sets the value below which the wallet will not create
+// siacoin outputs. Sends that request an output below the threshold are
+// rejected with modules.ErrDustOutput, and change below the threshold is added
+// to the miner fees. A threshold of zero disables the check.
+func (w *Wallet) SetDustThreshold(threshold types.Currency) {
+	w.mu.Lock()
+	w.dustThreshold = threshold
+	w.mu.Unlock()
+}
+
+// checkDust returns modules.ErrDustOutput if any of the outputs are below the
+// dust threshold.
+func (w *Wallet) checkDust(outputs []types.SiacoinOutput) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, output := range outputs {
+		if output.Value.Cmp(w.dustThreshold) < 0 {
+			return modules.ErrDustOutput
+		}
+	}
+	return nil
+}
+
+// EstimatedFee returns the miner fees that a typical transaction set created
+// by the wallet should pay, as recommended by the transaction pool. 'min' is
+// the fee that the transaction pool currently requires, and 'recommended' has
+// a much better chance of being confirmed quickly.
+func (w *Wallet) EstimatedFee() (min types.Currency, recommended types.Currency) {
+	return w.tpool.RecommendedFee()
+}
+
+// sizedFee returns the miner fee for a transaction set of 'size' encoded bytes
+// containing 'numTransactions' transactions: the fee estimate of the
+// transaction pool for that many bytes, or the fee that the pool requires if
+// that is higher. The transaction pool is queried, so the wallet lock should
+// not be held.
+func (w *Wallet) sizedFee(size int, numTransactions int) types.Currency {
+	_, maxPerByte := w.tpool.FeeEstimation()
+	fee := maxPerByte.Mul(types.NewCurrency64(uint64(size)))
+	required := w.tpool.RequiredFee(size, numTransactions)
+	if required.Cmp(fee) > 0 {
+		return required
+	}
+	return fee
+}
+
 // SendSiacoins creates a transaction sending 'amount' to 'dest'. The transaction
 // is submitted to the transaction pool and is also returned.
 func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
-	tpoolFee := types.NewCurrency64(10).Mul(types.SiacoinPrecision) // TODO: better fee algo.
+	txnSet, _, err := w.SendSiacoinsWithFee(amount, dest)
+	return txnSet, err
+}
+
+// SendSiacoinsWithFee creates a transaction sending 'amount' to 'dest', and
+// returns the transaction set along with the total miner fee paid by every
+// transaction in the set. The transaction is submitted to the transaction
+// pool.
+func (w *Wallet) SendSiacoinsWithFee(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, types.Currency, error) {
+	_, tpoolFee := w.EstimatedFee()
 	output := types.SiacoinOutput{
 		Value:      amount,
 		UnlockHash: dest,
 	}
+	err := w.checkDust([]types.SiacoinOutput{output})
+	if err != nil {
+		return nil, types.Currency{}, err
+	}
 
 	txnBuilder := w.StartTransaction()
-	err := txnBuilder.FundSiacoins(amount.Add(tpoolFee))
+	err = txnBuilder.FundSiacoins(amount.Add(tpoolFee))
 	if err != nil {
-		return nil, err
+		txnBuilder.Drop()
+		return nil, types.Currency{}, err
+	}
+	_, err = txnBuilder.AddMinerFee(tpoolFee)
+	if err != nil {
+		txnBuilder.Drop()
+		return nil, types.Currency{}, err
+	}
+	_, err = txnBuilder.AddSiacoinOutput(output)
+	if err != nil {
+		txnBuilder.Drop()
+		return nil, types.Currency{}, err
 	}
-	txnBuilder.AddMinerFee(tpoolFee)
-	txnBuilder.AddSiacoinOutput(output)
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
-		return nil, err
+		txnBuilder.Drop()
+		return nil, types.Currency{}, err
 	}
 	err = w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		txnBuilder.Drop()
+		return nil, types.Currency{}, err
+	}
+
+	var fee types.Currency
+	for _, txn := range txnSet {
+		for _, minerFee := range txn.MinerFees {
+			fee = fee.Add(minerFee)
+		}
+	}
+	return txnSet, fee, nil
+}
+
+// SendSiacoinsMulti creates a single transaction that pays each of the
+// provided outputs, so that only one miner fee is paid for the whole batch.
+// The fee is sized to the encoded transaction set; unconfirmed parents that
+// are already in the transaction pool are not counted. The transaction is
+// submitted to the transaction pool and is also returned.
+// If the wallet cannot cover the sum of the outputs plus the fee,
+// modules.ErrLowBalance is returned and the wallet is left unchanged.
+func (w *Wallet) SendSiacoinsMulti(masterKey crypto.TwofishKey, outputs []types.SiacoinOutput) ([]types.Transaction, error) {
+	if len(outputs) == 0 {
+		return nil, errNoOutputs
+	}
+	w.mu.RLock()
+	unlocked := w.unlocked
+	err := w.checkMasterKey(masterKey)
+	w.mu.RUnlock()
+	if !unlocked {
+		return nil, modules.ErrLockedWallet
+	}
 	if err != nil {
 		return nil, err
 	}
-	return txnSet, nil
+	err = w.checkDust(outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fee depends on the size of the transaction, which is only known
+	// once the transaction has been funded and signed. The transaction is
+	// built with a fee that covers the outputs alone, and is rebuilt with a
+	// higher fee until the fee covers the size of the signed transaction
+	// set. The fee grows every time, so the loop ends once the fee is high
+	// enough or the wallet can no longer fund the transaction.
+	fee := w.sizedFee(len(encoding.Marshal(outputs)), 1)
+	for {
+		total := fee
+		for _, output := range outputs {
+			total = total.Add(output.Value)
+		}
+
+		txnBuilder := w.StartTransaction()
+		err = txnBuilder.FundSiacoins(total)
+		if err != nil {
+			txnBuilder.Drop()
+			return nil, err
+		}
+		_, err = txnBuilder.AddMinerFee(fee)
+		if err != nil {
+			txnBuilder.Drop()
+			return nil, err
+		}
+		for _, output := range outputs {
+			_, err = txnBuilder.AddSiacoinOutput(output)
+			if err != nil {
+				txnBuilder.Drop()
+				return nil, err
+			}
+		}
+		txnSet, err := txnBuilder.Sign(true)
+		if err != nil {
+			txnBuilder.Drop()
+			return nil, err
+		}
+		required := w.sizedFee(len(encoding.Marshal(txnSet)), len(txnSet))
+		if fee.Cmp(required) < 0 {
+			txnBuilder.Drop()
+			fee = required
+			continue
+		}
+		err = w.tpool.AcceptTransactionSet(txnSet)
+		if err != nil {
+			txnBuilder.Drop()
+			return nil, err
+		}
+		return txnSet, nil
+	}
+}
+
+// SendSiacoinsTimelocked creates a transaction sending 'amount' to an output
+// that cannot be spent until the chain has reached 'unlockHeight'. An address
+// is the hash of its unlock conditions, so a timelock cannot be added to an
+// existing address; instead the output is sent to the address of 'dest' with
+// its timelock set to 'unlockHeight'. The recipient needs those unlock
+// conditions, which are the same as 'dest' apart from the timelock, to spend
+// the output. The transaction is submitted to the transaction pool and is
+// also returned.
+//
+// The timelocked output is never counted by ConfirmedBalance, not even when
+// 'dest' belongs to this wallet, because the wallet only tracks the addresses
+// of its own unlock conditions, which carry no timelock. The sender's change
+// output is an ordinary wallet address and is counted by ConfirmedBalance as
+// soon as the transaction is confirmed.
+func (w *Wallet) SendSiacoinsTimelocked(masterKey crypto.TwofishKey, amount types.Currency, dest types.UnlockConditions, unlockHeight types.BlockHeight) ([]types.Transaction, error) {
+	w.mu.RLock()
+	height := w.consensusSetHeight
+	w.mu.RUnlock()
+	if unlockHeight <= height {
+		return nil, errTimelockNotAhead
+	}
+	dest.Timelock = unlockHeight
+	return w.SendSiacoinsMulti(masterKey, []types.SiacoinOutput{{
+		Value:      amount,
+		UnlockHash: dest.UnlockHash(),
+	}})
 }
 
 // SendSiafunds creates a transaction sending 'amount' to 'dest'. The transaction
 // is submitted to the transaction pool and is also returned.
 func (w *Wallet) SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
-	tpoolFee := types.NewCurrency64(10).Mul(types.SiacoinPrecision) // TODO: better fee algo.
+	_, tpoolFee := w.EstimatedFee()
 	output := types.SiafundOutput{
 		Value:      amount,
 		UnlockHash: dest,
@@ -95,8 +447,16 @@ func (w *Wallet) SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]t
 	if err != nil {
 		return nil, err
 	}
-	txnBuilder.AddMinerFee(tpoolFee)
-	txnBuilder.AddSiafundOutput(output)
+	_, err = txnBuilder.AddMinerFee(tpoolFee)
+	if err != nil {
+		txnBuilder.Drop()
+		return nil, err
+	}
+	_, err = txnBuilder.AddSiafundOutput(output)
+	if err != nil {
+		txnBuilder.Drop()
+		return nil, err
+	}
 	txnSet, err := txnBuilder.Sign(true)
 	if err != nil {
 		return nil, err
@@ -1,9 +1,12 @@
 package wallet
 
 import (
+	"errors"
 	"sort"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -102,6 +105,228 @@ func TestIntegrationSendOverUnder(t *testing.T) {
 	}
 }
 
+// TestIntegrationSendSiacoinsMulti checks that SendSiacoinsMulti pays every
+// output with a single transaction, and that a failed send leaves the wallet
+// unchanged.
+func TestIntegrationSendSiacoinsMulti(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSendSiacoinsMulti")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Sending more than the wallet has should fail without reserving any
+	// outputs.
+	wt.wallet.mu.RLock()
+	spent := len(wt.wallet.spentOutputs)
+	wt.wallet.mu.RUnlock()
+	tooManyCoins := types.SiacoinPrecision.Mul(types.NewCurrency64(1e12))
+	_, err = wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, []types.SiacoinOutput{
+		{Value: tooManyCoins, UnlockHash: types.UnlockHash{1}},
+		{Value: types.SiacoinPrecision, UnlockHash: types.UnlockHash{2}},
+	})
+	if err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+	wt.wallet.mu.RLock()
+	if len(wt.wallet.spentOutputs) != spent {
+		t.Error("failed send reserved wallet outputs")
+	}
+	wt.wallet.mu.RUnlock()
+	if _, err := wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, nil); err != errNoOutputs {
+		t.Error("expected errNoOutputs, got", err)
+	}
+	if _, err := wt.wallet.SendSiacoinsMulti(crypto.TwofishKey{}, []types.SiacoinOutput{{Value: types.SiacoinPrecision}}); err != modules.ErrBadEncryptionKey {
+		t.Error("expected ErrBadEncryptionKey, got", err)
+	}
+
+	// Send to three addresses at once.
+	outputs := []types.SiacoinOutput{
+		{Value: types.SiacoinPrecision.Mul(types.NewCurrency64(100)), UnlockHash: types.UnlockHash{1}},
+		{Value: types.SiacoinPrecision.Mul(types.NewCurrency64(200)), UnlockHash: types.UnlockHash{2}},
+		{Value: types.SiacoinPrecision.Mul(types.NewCurrency64(300)), UnlockHash: types.UnlockHash{3}},
+	}
+	txnSet, err := wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txnSet[len(txnSet)-1]
+	if len(txn.MinerFees) != 1 {
+		t.Error("expected a single miner fee, got", len(txn.MinerFees))
+	}
+	for _, output := range outputs {
+		found := false
+		for _, sco := range txn.SiacoinOutputs {
+			if sco.UnlockHash == output.UnlockHash && sco.Value.Cmp(output.Value) == 0 {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("transaction is missing an output to", output.UnlockHash)
+		}
+	}
+
+	// The fee of a large batch covers the size of the batch.
+	wt.tpool.SetMinimumFee(types.SiacoinPrecision.Div(types.NewCurrency64(100)))
+	outputs = nil
+	for i := 0; i < 100; i++ {
+		outputs = append(outputs, types.SiacoinOutput{Value: types.SiacoinPrecision, UnlockHash: types.UnlockHash{byte(i)}})
+	}
+	txnSet, err = wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(encoding.Marshal(txnSet))
+	if txnSet[len(txnSet)-1].MinerFees[0].Cmp(wt.tpool.RequiredFee(size, len(txnSet))) < 0 {
+		t.Error("fee does not cover the size of the transaction set")
+	}
+}
+
+// TestIntegrationSendSiacoinsMultiUnconfirmedChange checks that a batch funded
+// from the unconfirmed change of an earlier send pays the minimum fee of the
+// transaction pool.
+func TestIntegrationSendSiacoinsMultiUnconfirmedChange(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSendSiacoinsMultiUnconfirmedChange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	wt.tpool.SetMinimumFee(types.SiacoinPrecision.Div(types.NewCurrency64(100)))
+
+	// Spend all but 1000 siacoins of the confirmed balance, so that the next
+	// send has to be funded from the change.
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	firstSet, err := wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, []types.SiacoinOutput{
+		{Value: balance.Sub(types.SiacoinPrecision.Mul(types.NewCurrency64(1000))), UnlockHash: types.UnlockHash{1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeIDs := make(map[types.SiacoinOutputID]struct{})
+	for _, txn := range firstSet {
+		for i := range txn.SiacoinOutputs {
+			changeIDs[txn.SiacoinOutputID(uint64(i))] = struct{}{}
+		}
+	}
+
+	secondSet, err := wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, []types.SiacoinOutput{
+		{Value: types.SiacoinPrecision.Mul(types.NewCurrency64(100)), UnlockHash: types.UnlockHash{2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromChange := false
+	for _, txn := range secondSet {
+		for _, sci := range txn.SiacoinInputs {
+			if _, exists := changeIDs[sci.ParentID]; exists {
+				fromChange = true
+			}
+		}
+	}
+	if !fromChange {
+		t.Error("second send was not funded from the unconfirmed change")
+	}
+}
+
+// TestIntegrationSendSiacoinsTimelocked checks that a timelocked output is
+// accepted by the transaction pool, and that it cannot be spent before its
+// unlock height.
+func TestIntegrationSendSiacoinsTimelocked(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSendSiacoinsTimelocked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	sk, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := types.UnlockConditions{
+		PublicKeys:         []types.SiaPublicKey{{Algorithm: types.SignatureEd25519, Key: pk[:]}},
+		SignaturesRequired: 1,
+	}
+	amount := types.SiacoinPrecision.Mul(types.NewCurrency64(100))
+	_, err = wt.wallet.SendSiacoinsTimelocked(wt.walletMasterKey, amount, dest, wt.cs.Height())
+	if err != errTimelockNotAhead {
+		t.Error("expected errTimelockNotAhead, got", err)
+	}
+
+	// Send the coins and confirm the transaction.
+	unlockHeight := wt.cs.Height() + 3
+	txnSet, err := wt.wallet.SendSiacoinsTimelocked(wt.walletMasterKey, amount, dest, unlockHeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest.Timelock = unlockHeight
+	txn := txnSet[len(txnSet)-1]
+	var scoid types.SiacoinOutputID
+	found := false
+	for i, sco := range txn.SiacoinOutputs {
+		if sco.UnlockHash == dest.UnlockHash() && sco.Value.Cmp(amount) == 0 {
+			scoid = txn.SiacoinOutputID(uint64(i))
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("transaction is missing the timelocked output")
+	}
+
+	// spend builds a transaction that spends the timelocked output and
+	// submits it to the transaction pool.
+	spend := func() error {
+		spendTxn := types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{
+				ParentID:         scoid,
+				UnlockConditions: dest,
+			}},
+			SiacoinOutputs: []types.SiacoinOutput{{
+				Value:      amount,
+				UnlockHash: types.UnlockHash{1},
+			}},
+			TransactionSignatures: []types.TransactionSignature{{
+				ParentID:      crypto.Hash(scoid),
+				CoveredFields: types.CoveredFields{WholeTransaction: true},
+			}},
+		}
+		sig, err := crypto.SignHash(spendTxn.SigHash(0), sk)
+		if err != nil {
+			return err
+		}
+		spendTxn.TransactionSignatures[0].Signature = sig[:]
+		return wt.tpool.AcceptTransactionSet([]types.Transaction{spendTxn})
+	}
+	err = spend()
+	if err != modules.NewConsensusConflict(types.ErrTimelockNotSatisfied.Error()) {
+		t.Fatal("expected the timelock to be enforced, got", err)
+	}
+
+	// Once the unlock height has been reached the output can be spent.
+	for wt.cs.Height() < unlockHeight {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = spend()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestIntegrationSpendHalfHalf spends more than half of the coins, and then
 // more than half of the coins again, to make sure that the wallet is not
 // reusing outputs that it has already spent.
@@ -182,3 +407,442 @@ func TestIntegrationSortedOutputsSorting(t *testing.T) {
 		}
 	}
 }
+
+// TestIntegrationDustThreshold checks that the wallet refuses to create
+// outputs below the dust threshold, and that change below the threshold is
+// given to the miners.
+func TestIntegrationDustThreshold(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationDustThreshold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	threshold := types.SiacoinPrecision.Mul(types.NewCurrency64(10))
+	wt.wallet.SetDustThreshold(threshold)
+	if wt.wallet.DustThreshold().Cmp(threshold) != 0 {
+		t.Fatal("dust threshold was not set")
+	}
+
+	// Requested outputs below the threshold should be rejected.
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{1})
+	if err != modules.ErrDustOutput {
+		t.Error("expected ErrDustOutput, got", err)
+	}
+	_, err = wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, []types.SiacoinOutput{
+		{Value: threshold, UnlockHash: types.UnlockHash{1}},
+		{Value: types.SiacoinPrecision, UnlockHash: types.UnlockHash{2}},
+	})
+	if err != modules.ErrDustOutput {
+		t.Error("expected ErrDustOutput, got", err)
+	}
+
+	// Send an amount that leaves less than the threshold as change from the
+	// largest output in the wallet.
+	var largest types.Currency
+	wt.wallet.mu.RLock()
+	for _, sco := range wt.wallet.siacoinOutputs {
+		if sco.Value.Cmp(largest) > 0 {
+			largest = sco.Value
+		}
+	}
+	wt.wallet.mu.RUnlock()
+	_, tpoolFee := wt.wallet.EstimatedFee()
+	change := types.SiacoinPrecision.Mul(types.NewCurrency64(5))
+	txnSet, err := wt.wallet.SendSiacoins(largest.Sub(tpoolFee).Sub(change), types.UnlockHash{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := txnSet[0]
+	if len(parent.SiacoinOutputs) != 1 {
+		t.Error("a refund output was created for change below the dust threshold")
+	}
+	if len(parent.MinerFees) != 1 || parent.MinerFees[0].Cmp(change) != 0 {
+		t.Error("change below the dust threshold was not added to the miner fees:", parent.MinerFees)
+	}
+}
+
+// TestIntegrationEstimatedFee checks that the fee estimates follow the
+// requirements of the transaction pool, and that sending siacoins pays the
+// recommended fee.
+func TestIntegrationEstimatedFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationEstimatedFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// The transaction pool of the tester is nearly empty and has no minimum
+	// fee, so no fee is required.
+	min, recommended := wt.wallet.EstimatedFee()
+	if !min.IsZero() {
+		t.Error("expected a zero minimum fee, got", min)
+	}
+	if recommended.IsZero() {
+		t.Error("recommended fee should not be zero")
+	}
+
+	// Raising the minimum fee of the transaction pool should raise both
+	// estimates.
+	feePerByte := types.SiacoinPrecision.Div(types.NewCurrency64(1e3))
+	wt.tpool.SetMinimumFee(feePerByte)
+	min2, recommended2 := wt.wallet.EstimatedFee()
+	tpoolMin, tpoolRecommended := wt.tpool.RecommendedFee()
+	if min2.Cmp(tpoolMin) != 0 || recommended2.Cmp(tpoolRecommended) != 0 || min2.Cmp(min) <= 0 {
+		t.Error("minimum fee does not follow the transaction pool:", min2)
+	}
+	if recommended2.Cmp(recommended.Add(min2)) != 0 {
+		t.Error("recommended fee does not include the minimum fee:", recommended2)
+	}
+
+	// Sending siacoins should pay the recommended fee and be accepted by the
+	// transaction pool.
+	txnSet, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txnSet[len(txnSet)-1]
+	if len(txn.MinerFees) != 1 || txn.MinerFees[0].Cmp(recommended2) != 0 {
+		t.Error("send did not pay the recommended fee:", txn.MinerFees)
+	}
+}
+
+// TestIntegrationUnspentOutputs checks that UnspentOutputs reports the
+// spendable outputs of the wallet along with their confirmation heights.
+func TestIntegrationUnspentOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationUnspentOutputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	outputs, err := wt.wallet.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	siacoinBal, siafundBal, _ := wt.wallet.ConfirmedBalance()
+	var siacoinSum, siafundSum types.Currency
+	for _, uo := range outputs {
+		switch uo.FundType {
+		case types.SpecifierSiacoinOutput:
+			siacoinSum = siacoinSum.Add(uo.Value)
+			if uo.ConfirmationHeight == 0 || uo.ConfirmationHeight > wt.cs.Height() {
+				t.Error("siacoin output has a bad confirmation height:", uo.ConfirmationHeight)
+			}
+		case types.SpecifierSiafundOutput:
+			siafundSum = siafundSum.Add(uo.Value)
+		default:
+			t.Error("unexpected fund type:", uo.FundType)
+		}
+	}
+	if siacoinSum.Cmp(siacoinBal) != 0 {
+		t.Error("unspent siacoin outputs do not match the confirmed balance")
+	}
+	if siafundSum.Cmp(siafundBal) != 0 {
+		t.Error("unspent siafund outputs do not match the confirmed balance")
+	}
+
+	// Outputs reserved by a transaction builder should not be returned.
+	b := wt.wallet.StartTransaction()
+	err = b.FundSiacoins(types.NewCurrency64(1e3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs2, err := wt.wallet.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs2) != len(outputs)-1 {
+		t.Error("reserved output was returned:", len(outputs), len(outputs2))
+	}
+	b.Drop()
+
+	// A locked wallet should not report its outputs.
+	err = wt.wallet.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.UnspentOutputs()
+	if err != modules.ErrLockedWallet {
+		t.Error("expected ErrLockedWallet, got", err)
+	}
+}
+
+// TestIntegrationSendSiacoinsWithFee checks that SendSiacoinsWithFee reports
+// the fee that was actually paid by the transaction set.
+func TestIntegrationSendSiacoinsWithFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSendSiacoinsWithFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	_, recommended := wt.wallet.EstimatedFee()
+	txnSet, fee, err := wt.wallet.SendSiacoinsWithFee(types.NewCurrency64(5000), types.UnlockHash{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee.Cmp(recommended) != 0 {
+		t.Errorf("expected a fee of %v, got %v", recommended, fee)
+	}
+	var minerFees types.Currency
+	for _, txn := range txnSet {
+		for _, minerFee := range txn.MinerFees {
+			minerFees = minerFees.Add(minerFee)
+		}
+	}
+	if fee.Cmp(minerFees) != 0 {
+		t.Error("reported fee does not match the miner fees of the transaction set")
+	}
+
+	// The unconfirmed balance should drop by exactly the amount and the fee.
+	unconfirmedOut, unconfirmedIn := wt.wallet.UnconfirmedBalance()
+	if unconfirmedOut.Cmp(unconfirmedIn.Add(types.NewCurrency64(5000)).Add(fee)) != 0 {
+		t.Error("balance did not drop by the amount and the reported fee")
+	}
+}
+
+// rejectingTpool is a transaction pool that rejects every transaction set.
+type rejectingTpool struct {
+	modules.TransactionPool
+}
+
+// AcceptTransactionSet is a mock implementation of
+// TransactionPool.AcceptTransactionSet that always returns an error.
+func (rejectingTpool) AcceptTransactionSet([]types.Transaction) error {
+	return errors.New("transaction set rejected")
+}
+
+// TestIntegrationSendSiacoinsWithFeeRejected checks that the outputs reserved
+// by SendSiacoinsWithFee are released when the transaction pool rejects the
+// transaction.
+func TestIntegrationSendSiacoinsWithFeeRejected(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationSendSiacoinsWithFeeRejected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Open the wallet a second time on top of a transaction pool that rejects
+	// every transaction.
+	w, err := New(wt.cs, rejectingTpool{wt.tpool}, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, _, _ := w.ConfirmedBalance()
+	_, _, err = w.SendSiacoinsWithFee(types.NewCurrency64(5000), types.UnlockHash{1})
+	if err == nil {
+		t.Fatal("expected the transaction to be rejected")
+	}
+
+	// Every output should be available again.
+	err = w.StartTransaction().FundSiacoins(balance)
+	if err != nil {
+		t.Fatal("outputs of the rejected transaction are still reserved:", err)
+	}
+}
+
+// TestIntegrationAddressBalance checks that the balance of a single address
+// tracks both confirmed and unconfirmed payments to and from the address.
+func TestIntegrationAddressBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationAddressBalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	_, _, err = wt.wallet.AddressBalance(types.UnlockHash{1})
+	if err != errUntrackedAddress {
+		t.Fatal("expected errUntrackedAddress, got", err)
+	}
+
+	// Pay a fresh address of the wallet.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := uc.UnlockHash()
+	amount := types.NewCurrency64(5000)
+	_, err = wt.wallet.SendSiacoins(amount, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed, unconfirmed, err := wt.wallet.AddressBalance(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed.IsZero() || unconfirmed.Cmp(amount) != 0 {
+		t.Errorf("expected 0 confirmed and %v unconfirmed, got %v and %v", amount, confirmed, unconfirmed)
+	}
+
+	// Confirm the payment.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed, unconfirmed, err = wt.wallet.AddressBalance(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Cmp(amount) != 0 || unconfirmed.Cmp(amount) != 0 {
+		t.Errorf("expected %v confirmed and unconfirmed, got %v and %v", amount, confirmed, unconfirmed)
+	}
+
+	// Spend from the address. The unconfirmed balance drops to zero.
+	var scoid types.SiacoinOutputID
+	for id, sco := range wt.wallet.siacoinOutputs {
+		if sco.UnlockHash == addr {
+			scoid = id
+		}
+	}
+	tb := wt.wallet.StartTransaction()
+	err = tb.FundSiacoinsFrom([]types.SiacoinOutputID{scoid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb.AddMinerFee(amount)
+	txnSet, err := tb.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed, unconfirmed, err = wt.wallet.AddressBalance(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Cmp(amount) != 0 || !unconfirmed.IsZero() {
+		t.Errorf("expected %v confirmed and 0 unconfirmed, got %v and %v", amount, confirmed, unconfirmed)
+	}
+}
+
+// TestIntegrationProjectedBalance checks that the projected balance counts
+// immature miner payouts and timelocked outputs once they become spendable.
+func TestIntegrationProjectedBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationProjectedBalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// The projected balance at the current height matches the confirmed
+	// balance. The payouts that have not matured yet, including the payout of
+	// the new block, are counted once they mature.
+	block, err := wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	height := wt.cs.Height()
+	siacoins, _, _ := wt.wallet.ConfirmedBalance()
+	if wt.wallet.ProjectedBalance(height).Cmp(siacoins) != 0 {
+		t.Error("projected balance at the current height does not match the confirmed balance")
+	}
+	immature := types.ZeroCurrency
+	wt.wallet.mu.RLock()
+	for _, dsco := range wt.wallet.delayedSiacoinOutputs {
+		immature = immature.Add(dsco.SiacoinOutput.Value)
+	}
+	wt.wallet.mu.RUnlock()
+	if immature.Cmp(block.MinerPayouts[0].Value) < 0 {
+		t.Fatal("the payout of the new block is not tracked")
+	}
+	if wt.wallet.ProjectedBalance(height+types.MaturityDelay).Cmp(siacoins.Add(immature)) != 0 {
+		t.Error("projected balance does not include the immature miner payouts")
+	}
+
+	// A timelocked output only counts once its timelock is reached.
+	uc := types.UnlockConditions{Timelock: height + 20, SignaturesRequired: 1}
+	value := types.NewCurrency64(1000)
+	wt.wallet.mu.Lock()
+	wt.wallet.keys[uc.UnlockHash()] = spendableKey{UnlockConditions: uc}
+	wt.wallet.siacoinOutputs[types.SiacoinOutputID{1}] = types.SiacoinOutput{Value: value, UnlockHash: uc.UnlockHash()}
+	wt.wallet.mu.Unlock()
+	if wt.wallet.ProjectedBalance(height+19).Cmp(wt.wallet.ProjectedBalance(height+20).Sub(value)) != 0 {
+		t.Error("timelocked output is not counted at its unlock height")
+	}
+}
+
+// TestIntegrationProjectedBalanceMaturity mines a block and checks that the
+// projected balance counts its payout from the maturity height onwards, and
+// matches the confirmed balance once the payout has matured.
+func TestIntegrationProjectedBalanceMaturity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationProjectedBalanceMaturity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	block, err := wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payoutID := block.MinerPayoutID(0)
+	wt.wallet.mu.RLock()
+	dsco, exists := wt.wallet.delayedSiacoinOutputs[payoutID]
+	wt.wallet.mu.RUnlock()
+	if !exists {
+		t.Fatal("the payout of the new block is not tracked")
+	}
+	maturity := dsco.MaturityHeight
+	payout := block.MinerPayouts[0].Value
+
+	// Before maturity, the payout is only counted by the projection.
+	siacoins, _, _ := wt.wallet.ConfirmedBalance()
+	before := wt.wallet.ProjectedBalance(maturity - 1)
+	after := wt.wallet.ProjectedBalance(maturity)
+	if after.Cmp(before.Add(payout)) != 0 {
+		t.Fatal("payout is not counted from its maturity height:", before, after)
+	}
+	if before.Cmp(siacoins) <= 0 {
+		t.Error("payouts maturing before the new payout are not counted")
+	}
+
+	// Mine until the payout has matured. The projection made before should
+	// match the confirmed balance, as the wallet has not spent anything.
+	for wt.cs.Height() < maturity {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	siacoins, _, _ = wt.wallet.ConfirmedBalance()
+	if siacoins.Cmp(after) != 0 {
+		t.Errorf("confirmed balance at the maturity height is %v, projected %v", siacoins, after)
+	}
+	wt.wallet.mu.RLock()
+	_, exists = wt.wallet.siacoinOutputs[payoutID]
+	wt.wallet.mu.RUnlock()
+	if !exists {
+		t.Error("payout has not matured at its maturity height")
+	}
+}
@@ -0,0 +1,146 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errInvalidRequiredSigs = errors.New("number of required signatures must be between 1 and the number of public keys")
+	errNoMultisigKeys      = errors.New("multisig address has no public keys")
+)
+
+// A multisigAddress holds the unlock conditions of a multisig address, along
+// with the addresses of the wallet's own keys whose public keys appear in the
+// unlock conditions. The secret keys themselves are kept in 'keys' only, so
+// that they are wiped when the wallet is locked.
+type multisigAddress struct {
+	uc   types.UnlockConditions
+	keys []types.UnlockHash
+}
+
+// loadMultisigAddresses restores the multisig addresses from the wallet's
+// persist object. The wallet's keys are matched once it is unlocked.
+func (w *Wallet) loadMultisigAddresses() {
+	for _, uc := range w.persist.MultisigAddresses {
+		w.multisigAddresses[uc.UnlockHash()] = multisigAddress{uc: uc}
+	}
+}
+
+// matchMultisigKeys returns the addresses of the wallet's keys whose public
+// keys appear in the unlock conditions of a multisig address, in the order in
+// which the public keys appear.
+func (w *Wallet) matchMultisigKeys(uc types.UnlockConditions) []types.UnlockHash {
+	type pubkey struct {
+		algorithm types.Specifier
+		key       string
+	}
+	walletPKs := make(map[pubkey]types.UnlockHash)
+	for addr, key := range w.keys {
+		for _, pk := range key.UnlockConditions.PublicKeys {
+			walletPKs[pubkey{pk.Algorithm, string(pk.Key)}] = addr
+		}
+	}
+	var keys []types.UnlockHash
+	matched := make(map[types.UnlockHash]struct{})
+	for _, pk := range uc.PublicKeys {
+		addr, exists := walletPKs[pubkey{pk.Algorithm, string(pk.Key)}]
+		if !exists {
+			continue
+		}
+		if _, exists := matched[addr]; !exists {
+			keys = append(keys, addr)
+			matched[addr] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// loadMultisigKeys matches the wallet's keys to every multisig address. It is
+// called when the wallet is unlocked, after the keys have been generated.
+func (w *Wallet) loadMultisigKeys() {
+	for addr, ms := range w.multisigAddresses {
+		ms.keys = w.matchMultisigKeys(ms.uc)
+		w.multisigAddresses[addr] = ms
+	}
+}
+
+// multisigKey returns a spendable key for a multisig address containing the
+// secret keys that the wallet holds for the public keys of the address. The
+// key may not hold enough secret keys to satisfy the unlock conditions, in
+// which case the remaining signatures need to be collected elsewhere.
+func (w *Wallet) multisigKey(ms multisigAddress) spendableKey {
+	sk := spendableKey{UnlockConditions: ms.uc}
+	for _, addr := range ms.keys {
+		sk.SecretKeys = append(sk.SecretKeys, w.keys[addr].SecretKeys...)
+	}
+	return sk
+}
+
+// MultisigAddress creates a multisig address that requires 'requiredSigs'
+// signatures from 'pubkeys' to spend from, and starts watching it. The
+// balance of the address is included in ConfirmedBalance, unlike the balance
+// of other watched addresses. Outputs of the address can be added to transactions
+// using FundSiacoinsFrom; Sign adds the signatures of any keys that the wallet
+// holds, and the remaining signatures can be collected from the other parties
+// and added with AddTransactionSignature.
+func (w *Wallet) MultisigAddress(pubkeys []types.SiaPublicKey, requiredSigs uint64) (types.UnlockHash, types.UnlockConditions, error) {
+	if len(pubkeys) == 0 {
+		return types.UnlockHash{}, types.UnlockConditions{}, errNoMultisigKeys
+	}
+	if requiredSigs == 0 || requiredSigs > uint64(len(pubkeys)) {
+		return types.UnlockHash{}, types.UnlockConditions{}, errInvalidRequiredSigs
+	}
+	uc := types.UnlockConditions{
+		PublicKeys:         pubkeys,
+		SignaturesRequired: requiredSigs,
+	}
+	addr := uc.UnlockHash()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return types.UnlockHash{}, types.UnlockConditions{}, modules.ErrLockedWallet
+	}
+	if _, exists := w.keys[addr]; exists {
+		return types.UnlockHash{}, types.UnlockConditions{}, errWatchSpendableAddress
+	}
+	if _, exists := w.multisigAddresses[addr]; exists {
+		return addr, uc, nil
+	}
+
+	// The address is watched right away rather than after the next reload, as
+	// the wallet has only just learned its unlock conditions and no outputs
+	// will have been sent to it yet.
+	w.multisigAddresses[addr] = multisigAddress{
+		uc:   uc,
+		keys: w.matchMultisigKeys(uc),
+	}
+	w.persist.MultisigAddresses = append(w.persist.MultisigAddresses, uc)
+	w.watchAddress(addr)
+	watched := false
+	for _, watchedAddr := range w.persist.WatchedAddresses {
+		watched = watched || watchedAddr == addr
+	}
+	if !watched {
+		w.persist.WatchedAddresses = append(w.persist.WatchedAddresses, addr)
+	}
+	err := w.saveSettingsSync()
+	if err != nil {
+		return types.UnlockHash{}, types.UnlockConditions{}, err
+	}
+	return addr, uc, nil
+}
+
+// signMultisigMessage signs 'msg' with the key that the wallet holds for the
+// first of the public keys of the multisig address 'ms'.
+func (w *Wallet) signMultisigMessage(ms multisigAddress, msg []byte) (crypto.Signature, error) {
+	sk := w.multisigKey(ms)
+	if len(sk.SecretKeys) == 0 {
+		return crypto.Signature{}, errNoSpendingKey
+	}
+	return crypto.SignHash(messageHash(msg), sk.SecretKeys[0])
+}
@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationMultisigAddress checks that the wallet tracks the balance of
+// a multisig address, and can spend from it once the signature of the other
+// party has been added.
+func TestIntegrationMultisigAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationMultisigAddress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Create a 2-of-2 multisig address between the wallet and another party.
+	ourUC, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirSK, theirPK, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkeys := []types.SiaPublicKey{
+		ourUC.PublicKeys[0],
+		{Algorithm: types.SignatureEd25519, Key: theirPK[:]},
+	}
+	_, _, err = wt.wallet.MultisigAddress(pubkeys, 3)
+	if err != errInvalidRequiredSigs {
+		t.Fatal("expected errInvalidRequiredSigs, got", err)
+	}
+	addr, uc, err := wt.wallet.MultisigAddress(pubkeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc.UnlockHash() != addr || len(wt.wallet.WatchedAddresses()) != 1 {
+		t.Fatal("multisig address is not watched")
+	}
+
+	// Fund the address. The funds are part of the confirmed balance without
+	// including the other watched balances.
+	amount := types.SiacoinPrecision.Mul(types.NewCurrency64(100))
+	_, err = wt.wallet.SendSiacoins(amount, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, _, _ := wt.wallet.ConfirmedBalance()
+	wt.wallet.mu.RLock()
+	var spendable types.Currency
+	for _, sco := range wt.wallet.siacoinOutputs {
+		spendable = spendable.Add(sco.Value)
+	}
+	wt.wallet.mu.RUnlock()
+	if total.Cmp(spendable.Add(amount)) != 0 {
+		t.Fatal("multisig funds are missing from the confirmed balance")
+	}
+	wt.wallet.SetIncludeWatchedBalance(true)
+	if withWatched, _, _ := wt.wallet.ConfirmedBalance(); withWatched.Cmp(total) != 0 {
+		t.Fatal("multisig funds were counted twice with the watched balance included")
+	}
+
+	// Spend the funds back to the wallet. The wallet's signature alone does
+	// not satisfy the unlock conditions.
+	var scoid types.SiacoinOutputID
+	wt.wallet.mu.Lock()
+	for id := range wt.wallet.watchedSiacoinOutputs {
+		scoid = id
+	}
+	wt.wallet.mu.Unlock()
+	tb := wt.wallet.StartTransaction()
+	err = tb.FundSiacoinsFrom([]types.SiacoinOutputID{scoid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee := types.SiacoinPrecision
+	tb.AddMinerFee(fee)
+	tb.AddSiacoinOutput(types.SiacoinOutput{Value: amount.Sub(fee), UnlockHash: ourUC.UnlockHash()})
+	txnSet, err := tb.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet[len(txnSet)-1].TransactionSignatures) != 1 {
+		t.Fatal("expected the wallet to add one signature")
+	}
+	if wt.tpool.AcceptTransactionSet(txnSet) == nil {
+		t.Fatal("partially signed transaction was accepted")
+	}
+
+	// Add the signature of the other party.
+	txn, parents := tb.View()
+	sig := types.TransactionSignature{
+		ParentID:       crypto.Hash(scoid),
+		CoveredFields:  types.CoveredFields{WholeTransaction: true},
+		PublicKeyIndex: 1,
+	}
+	txn.TransactionSignatures = append(txn.TransactionSignatures, sig)
+	encodedSig, err := crypto.SignHash(txn.SigHash(len(txn.TransactionSignatures)-1), theirSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig.Signature = encodedSig[:]
+	tb.AddTransactionSignature(sig)
+	txn, _ = tb.View()
+	err = wt.tpool.AcceptTransactionSet(append(parents, txn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages are signed with the wallet's key of the address, which is the
+	// first public key of the address.
+	msgSig, err := wt.wallet.SignMessage(wt.walletMasterKey, addr, []byte("escrow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyMultisigMessage(addr, uc, []byte("escrow"), msgSig, 0) {
+		t.Error("multisig message was not signed with the wallet's key")
+	}
+	if VerifyMultisigMessage(addr, uc, []byte("escrow"), msgSig, 1) || VerifyMultisigMessage(addr, uc, []byte("escrow"), msgSig, 2) {
+		t.Error("multisig message verified against the wrong key")
+	}
+}
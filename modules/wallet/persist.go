@@ -1,13 +1,16 @@
 package wallet
 
 import (
+	"bytes"
 	"crypto/rand"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 const (
@@ -36,6 +39,31 @@ type SpendableKeyFile struct {
 	SpendableKey           crypto.Ciphertext
 }
 
+// SpentOutput records an output that the wallet has used to fund a
+// transaction, along with the height at which it was used. The output will not
+// be used to fund another transaction until RespendTimeout blocks have passed.
+type SpentOutput struct {
+	ID     types.OutputID
+	Height types.BlockHeight
+}
+
+// TransactionNote is a local note that the user has attached to a
+// transaction.
+type TransactionNote struct {
+	ID   types.TransactionID
+	Note string
+}
+
+// transactionNotesByID sorts transaction notes by the id of their transaction,
+// so that the notes are persisted in a deterministic order.
+type transactionNotesByID []TransactionNote
+
+func (tn transactionNotesByID) Len() int      { return len(tn) }
+func (tn transactionNotesByID) Swap(i, j int) { tn[i], tn[j] = tn[j], tn[i] }
+func (tn transactionNotesByID) Less(i, j int) bool {
+	return bytes.Compare(tn[i].ID[:], tn[j].ID[:]) < 0
+}
+
 // WalletPersist contains all data that persists on disk during wallet
 // operation.
 type WalletPersist struct {
@@ -58,9 +86,85 @@ type WalletPersist struct {
 	// encrypted using the primary seed encryption password.
 	AuxiliarySeedFiles []SeedFile
 
+	// AuxiliarySeedDepths holds the number of addresses that are tracked for
+	// each auxiliary seed, at the same index as the seed file. Seeds without
+	// an entry, or with fewer than modules.PublicKeysPerSeed addresses, track
+	// modules.PublicKeysPerSeed addresses.
+	AuxiliarySeedDepths []uint64
+
 	// UnseededKeys are list of spendable keys that were not generated by a
 	// random seed.
 	UnseededKeys []SpendableKeyFile
+
+	// SpentOutputs are the outputs that have recently been used to fund
+	// transactions. They are persisted so that outputs reserved by a
+	// transaction are not reused after a restart.
+	SpentOutputs []SpentOutput
+
+	// TransactionNotes are the notes that the user has attached to
+	// transactions. Notes are never broadcast.
+	TransactionNotes []TransactionNote
+
+	// WatchedAddresses are addresses that the wallet tracks the balance of,
+	// but does not have the keys to spend from.
+	WatchedAddresses []types.UnlockHash
+
+	// MultisigAddresses are the unlock conditions of the multisig addresses
+	// created by the wallet. Multisig addresses are also watched addresses.
+	MultisigAddresses []types.UnlockConditions
+}
+
+// respendAllowedHeight returns the height at or below which an output must
+// have been spent for the wallet to consider it available again.
+func respendAllowedHeight(height types.BlockHeight) types.BlockHeight {
+	// Prevent an underflow error.
+	if height < RespendTimeout {
+		return 0
+	}
+	return height - RespendTimeout
+}
+
+// updatePersistSpentOutputs copies the spent outputs that have not yet expired
+// into the wallet's persist object.
+func (w *Wallet) updatePersistSpentOutputs() {
+	allowedHeight := respendAllowedHeight(w.consensusSetHeight)
+	w.persist.SpentOutputs = w.persist.SpentOutputs[:0]
+	for id, height := range w.spentOutputs {
+		if height > allowedHeight {
+			w.persist.SpentOutputs = append(w.persist.SpentOutputs, SpentOutput{ID: id, Height: height})
+		}
+	}
+}
+
+// updatePersistTransactionNotes copies the transaction notes into the
+// wallet's persist object, sorted by transaction id.
+func (w *Wallet) updatePersistTransactionNotes() {
+	w.persist.TransactionNotes = w.persist.TransactionNotes[:0]
+	for id, note := range w.transactionNotes {
+		w.persist.TransactionNotes = append(w.persist.TransactionNotes, TransactionNote{ID: id, Note: note})
+	}
+	sort.Sort(transactionNotesByID(w.persist.TransactionNotes))
+}
+
+// loadSpentOutputs restores the spent outputs from the wallet's persist
+// object, discarding any that have expired relative to the current height of
+// the consensus set. The wallet counts the genesis block when tracking its
+// height, so the wallet height is one greater than the consensus height.
+func (w *Wallet) loadSpentOutputs() {
+	allowedHeight := respendAllowedHeight(w.cs.Height() + 1)
+	for _, so := range w.persist.SpentOutputs {
+		if so.Height > allowedHeight {
+			w.spentOutputs[so.ID] = so.Height
+		}
+	}
+}
+
+// loadTransactionNotes restores the transaction notes from the wallet's
+// persist object.
+func (w *Wallet) loadTransactionNotes() {
+	for _, tn := range w.persist.TransactionNotes {
+		w.transactionNotes[tn.ID] = tn.Note
+	}
 }
 
 // loadSettings reads the wallet's settings from the wallet's settings file,
@@ -73,12 +177,16 @@ func (w *Wallet) loadSettings() error {
 // saveSettings writes the wallet's settings to the wallet's settings file,
 // replacing the existing file.
 func (w *Wallet) saveSettings() error {
+	w.updatePersistSpentOutputs()
+	w.updatePersistTransactionNotes()
 	return persist.SaveFile(settingsMetadata, w.persist, filepath.Join(w.persistDir, settingsFile))
 }
 
 // saveSettingsSync writes the wallet's settings to the wallet's settings file,
 // replacing the existing file, and then syncs to disk.
 func (w *Wallet) saveSettingsSync() error {
+	w.updatePersistSpentOutputs()
+	w.updatePersistTransactionNotes()
 	return persist.SaveFileSync(settingsMetadata, w.persist, filepath.Join(w.persistDir, settingsFile))
 }
 
@@ -124,6 +232,10 @@ func (w *Wallet) initPersist() error {
 	if err != nil {
 		return err
 	}
+	w.loadSpentOutputs()
+	w.loadTransactionNotes()
+	w.loadWatchedAddresses()
+	w.loadMultisigAddresses()
 	return nil
 }
 
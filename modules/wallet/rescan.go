@@ -0,0 +1,277 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errRescanHeight       = errors.New("cannot rescan from a height above the current height of the wallet")
+	errRescanInterrupted  = errors.New("rescan was interrupted before it completed")
+	errRescanUnsubscribed = errors.New("wallet must be unlocked once before it can rescan")
+)
+
+// A rescanner subscribes to the consensus set to replay the consensus changes
+// that follow a given height. The changes are collected and handed to the
+// wallet in one go once the rescanner has caught up, so that the wallet is
+// left unchanged if the rescan is interrupted.
+type rescanner struct {
+	ctx        context.Context
+	progress   func(types.BlockHeight)
+	wallet     *Wallet
+	baseHeight types.BlockHeight
+	height     types.BlockHeight
+	changes    []modules.ConsensusChange
+
+	// done is set once the rescanner has either caught up with the wallet or
+	// been interrupted. Consensus changes that arrive afterwards have already
+	// been processed by the wallet, and are ignored.
+	done        bool
+	interrupted bool
+}
+
+// ProcessConsensusChange collects a consensus change, and rebuilds the state
+// of the wallet once the change that the wallet most recently processed has
+// been reached.
+func (r *rescanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	if r.done {
+		return
+	}
+	select {
+	case <-r.ctx.Done():
+		r.changes = nil
+		r.done = true
+		r.interrupted = true
+		return
+	default:
+	}
+
+	r.changes = append(r.changes, cc)
+	r.height -= types.BlockHeight(len(cc.RevertedBlocks))
+	r.height += types.BlockHeight(len(cc.AppliedBlocks))
+	if r.progress != nil {
+		r.progress(r.height)
+	}
+
+	r.wallet.mu.Lock()
+	defer r.wallet.mu.Unlock()
+	if cc.ID != r.wallet.changeIDs[len(r.wallet.changeIDs)-1].id {
+		return
+	}
+	r.wallet.rebuildFromChanges(r.baseHeight, r.changes)
+	r.changes = nil
+	r.done = true
+}
+
+// A pathChange is a consensus change on the current path of the wallet,
+// along with the height that the wallet reached by processing it.
+type pathChange struct {
+	id     modules.ConsensusChangeID
+	height types.BlockHeight
+}
+
+// updateChangeIDs records the id of a consensus change against the height
+// that the wallet reached by processing it, dropping the changes whose blocks
+// were reverted. Heights that were skipped over by a change that applies
+// several blocks have no change of their own. It must be called after the
+// history of the wallet has been updated.
+func (w *Wallet) updateChangeIDs(cc modules.ConsensusChange) {
+	lowest := w.consensusSetHeight - types.BlockHeight(len(cc.AppliedBlocks))
+	ids := w.changeIDs
+	for len(ids) > 1 && (ids[len(ids)-1].height > lowest || ids[len(ids)-1].height == w.consensusSetHeight) {
+		ids = ids[:len(ids)-1]
+	}
+	w.changeIDs = append(ids, pathChange{id: cc.ID, height: w.consensusSetHeight})
+}
+
+// changeAtHeight returns the most recent change of the current path after
+// which the wallet was at or below 'height'.
+func (w *Wallet) changeAtHeight(height types.BlockHeight) pathChange {
+	i := sort.Search(len(w.changeIDs), func(i int) bool {
+		return w.changeIDs[i].height > height
+	})
+	return w.changeIDs[i-1]
+}
+
+// rebuildFromChanges replaces the transaction history above 'baseHeight' with
+// the history of the consensus changes 'ccs', which must follow the change at
+// which the wallet was at 'baseHeight' and end with the change that the wallet
+// most recently processed. The outputs touched by the changes are added to or
+// removed from the wallet according to their state after the last change.
+func (w *Wallet) rebuildFromChanges(baseHeight types.BlockHeight, ccs []modules.ConsensusChange) {
+	// Pick up any watch addresses that were added since the wallet was
+	// loaded.
+	w.loadWatchedAddresses()
+
+	// Find the final state of every relevant output.
+	siacoinOutputs := make(map[types.SiacoinOutputID]modules.SiacoinOutputDiff)
+	siafundOutputs := make(map[types.SiafundOutputID]modules.SiafundOutputDiff)
+	delayedOutputs := make(map[types.SiacoinOutputID]modules.DelayedSiacoinOutputDiff)
+	for _, cc := range ccs {
+		for _, diff := range cc.SiacoinOutputDiffs {
+			siacoinOutputs[diff.ID] = diff
+		}
+		for _, diff := range cc.SiafundOutputDiffs {
+			siafundOutputs[diff.ID] = diff
+		}
+		for _, diff := range cc.DelayedSiacoinOutputDiffs {
+			delayedOutputs[diff.ID] = diff
+		}
+	}
+	for id, diff := range siacoinOutputs {
+		outputs := w.siacoinOutputs
+		if _, exists := w.keys[diff.SiacoinOutput.UnlockHash]; !exists {
+			if _, watched := w.watchedAddresses[diff.SiacoinOutput.UnlockHash]; !watched {
+				continue
+			}
+			outputs = w.watchedSiacoinOutputs
+		}
+		if diff.Direction == modules.DiffApply {
+			outputs[id] = diff.SiacoinOutput
+		} else {
+			delete(outputs, id)
+		}
+	}
+	for id, diff := range siafundOutputs {
+		outputs := w.siafundOutputs
+		if _, exists := w.keys[diff.SiafundOutput.UnlockHash]; !exists {
+			if _, watched := w.watchedAddresses[diff.SiafundOutput.UnlockHash]; !watched {
+				continue
+			}
+			outputs = w.watchedSiafundOutputs
+		}
+		if diff.Direction == modules.DiffApply {
+			outputs[id] = diff.SiafundOutput
+		} else {
+			delete(outputs, id)
+		}
+	}
+
+	for id, diff := range delayedOutputs {
+		if _, exists := w.keys[diff.SiacoinOutput.UnlockHash]; !exists {
+			continue
+		}
+		if diff.Direction == modules.DiffApply {
+			w.delayedSiacoinOutputs[id] = diff
+		} else {
+			delete(w.delayedSiacoinOutputs, id)
+		}
+	}
+
+	// Rewind the siafund pool to its size at the base height, which is known
+	// from the first change to the pool. If the pool never changes, its
+	// current size is correct throughout.
+	finalPool := w.siafundPool
+	for _, cc := range ccs {
+		if len(cc.SiafundPoolDiffs) == 0 {
+			continue
+		}
+		diff := cc.SiafundPoolDiffs[0]
+		if diff.Direction == modules.DiffApply {
+			w.siafundPool = diff.Previous
+		} else {
+			w.siafundPool = diff.Adjusted
+		}
+		break
+	}
+
+	// Drop the history above the base height and replay the changes.
+	finalHeight := w.consensusSetHeight
+	kept := 0
+	for kept < len(w.processedTransactions) && w.processedTransactions[kept].ConfirmationHeight <= baseHeight {
+		kept++
+	}
+	for _, pt := range w.processedTransactions[kept:] {
+		delete(w.processedTransactionMap, pt.TransactionID)
+	}
+	w.processedTransactions = w.processedTransactions[:kept]
+	w.consensusSetHeight = baseHeight
+	for _, cc := range ccs {
+		for _, diff := range cc.SiafundPoolDiffs {
+			if diff.Direction == modules.DiffApply {
+				w.siafundPool = diff.Adjusted
+			} else {
+				w.siafundPool = diff.Previous
+			}
+		}
+		w.revertHistory(cc)
+		w.applyHistory(cc)
+	}
+
+	// The slice of processed transactions may have been reallocated, so the
+	// map is rebuilt to point into the new slice.
+	w.processedTransactionMap = make(map[types.TransactionID]*modules.ProcessedTransaction)
+	for i := range w.processedTransactions {
+		w.processedTransactionMap[w.processedTransactions[i].TransactionID] = &w.processedTransactions[i]
+	}
+	if build.DEBUG && (w.consensusSetHeight != finalHeight || w.siafundPool.Cmp(finalPool) != 0) {
+		panic("rescan did not end in the current state of the wallet")
+	}
+}
+
+// Rescan replays the consensus changes that follow 'startHeight', rebuilding
+// the transaction history from that height onwards and updating the balances
+// of the wallet's addresses and watched addresses, including watch addresses
+// that were added since the wallet was loaded. Outputs received by watched
+// addresses before 'startHeight' are not found. This is much faster than
+// a full rescan when the wallet only needs to catch up on recent blocks.
+//
+// 'progress', if not nil, is called with the height reached after each
+// consensus change. It is called while the consensus set is locked, and must
+// not call into the consensus set. If 'ctx' is cancelled before the rescan
+// completes, the consensus set stops sending changes, the collected changes
+// are discarded, errRescanInterrupted is returned, and the wallet is left
+// unchanged.
+func (w *Wallet) Rescan(ctx context.Context, startHeight types.BlockHeight, progress func(types.BlockHeight)) error {
+	// The changes are replayed from the most recent change after which the
+	// wallet was below 'startHeight'. A change that applied several blocks
+	// may have taken the wallet past the height below 'startHeight', in which
+	// case the replay starts further back.
+	baseHeight := startHeight
+	if baseHeight > 0 {
+		baseHeight--
+	}
+	w.mu.RLock()
+	unlocked := w.unlocked
+	subscribed := w.subscribed
+	height := w.consensusSetHeight
+	base := w.changeAtHeight(baseHeight)
+	w.mu.RUnlock()
+	if !unlocked {
+		return modules.ErrLockedWallet
+	}
+	if !subscribed {
+		return errRescanUnsubscribed
+	}
+	if startHeight > height {
+		return errRescanHeight
+	}
+
+	// The wallet lock must not be held while subscribing, as the consensus
+	// set holds its own lock while sending changes to the rescanner.
+	r := &rescanner{
+		ctx:        ctx,
+		progress:   progress,
+		wallet:     w,
+		baseHeight: base.height,
+		height:     base.height,
+	}
+	err := w.cs.ConsensusSetSubscribeWithContext(ctx, r, base.id)
+	w.cs.Unsubscribe(r)
+	if err != nil && err == ctx.Err() {
+		return errRescanInterrupted
+	}
+	if err != nil {
+		return err
+	}
+	if r.interrupted || !r.done {
+		return errRescanInterrupted
+	}
+	return nil
+}
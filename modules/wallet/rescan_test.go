@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationRescan checks that a rescan from a recent height picks up the
+// outputs of a watch address that was added after it received them, without
+// changing the transaction history of the wallet.
+func TestIntegrationRescan(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationRescan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Send money to an address, and only then start watching it.
+	wt.wallet.mu.RLock()
+	startHeight := wt.wallet.consensusSetHeight + 1
+	wt.wallet.mu.RUnlock()
+	watchAddr := types.UnlockHash{1, 2, 3}
+	amount := types.NewCurrency64(5000)
+	_, err = wt.wallet.SendSiacoins(amount, watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.AddWatchAddress(watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.SetIncludeWatchedBalance(true)
+	before, _, _ := wt.wallet.ConfirmedBalance()
+	wt.wallet.mu.RLock()
+	height := wt.wallet.consensusSetHeight
+	history := len(wt.wallet.processedTransactions)
+	wt.wallet.mu.RUnlock()
+
+	// A cancelled rescan leaves the wallet unchanged.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = wt.wallet.Rescan(ctx, startHeight, nil)
+	if err != errRescanInterrupted {
+		t.Fatal("expected errRescanInterrupted, got", err)
+	}
+	err = wt.wallet.Rescan(context.Background(), height+1, nil)
+	if err != errRescanHeight {
+		t.Fatal("expected errRescanHeight, got", err)
+	}
+	if balance, _, _ := wt.wallet.ConfirmedBalance(); balance.Cmp(before) != 0 {
+		t.Fatal("failed rescan changed the balance")
+	}
+
+	// Rescan from the height at which the money was sent.
+	var lastProgress types.BlockHeight
+	err = wt.wallet.Rescan(context.Background(), startHeight, func(h types.BlockHeight) {
+		lastProgress = h
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastProgress != height {
+		t.Errorf("last progress report was %v, expected %v", lastProgress, height)
+	}
+	after, _, _ := wt.wallet.ConfirmedBalance()
+	if after.Cmp(before.Add(amount)) != 0 {
+		t.Fatalf("watched balance should be %v, got %v", amount, after.Sub(before))
+	}
+	wt.wallet.mu.RLock()
+	rescannedHistory := len(wt.wallet.processedTransactions)
+	wt.wallet.mu.RUnlock()
+	if rescannedHistory != history {
+		t.Errorf("rescan changed the length of the history from %v to %v", history, rescannedHistory)
+	}
+
+	// The wallet keeps working after a rescan.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.Rescan(context.Background(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.RLock()
+	rescannedHistory = len(wt.wallet.processedTransactions)
+	wt.wallet.mu.RUnlock()
+	if rescannedHistory != history+1 {
+		t.Errorf("full rescan has %v history entries, expected %v", rescannedHistory, history+1)
+	}
+}
+
+// TestIntegrationRescanAfterReorg checks that a rescan from a height that was
+// skipped over by a multi-block reorg starts from the fork point, and does not
+// duplicate the history of the blocks that follow it.
+func TestIntegrationRescanAfterReorg(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationRescanAfterReorg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Solve the first block of a competing fork, paying out to the wallet,
+	// and then extend the current chain by two blocks.
+	wt.wallet.mu.RLock()
+	forkHeight := wt.wallet.consensusSetHeight
+	wt.wallet.mu.RUnlock()
+	csForkHeight := wt.cs.Height()
+	fork, err := wt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Extend the fork to three blocks, which reverts the two blocks of the
+	// current chain and applies the fork in a single consensus change.
+	err = wt.cs.AcceptBlock(fork)
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatal("expected the fork block to be non-extending, got", err)
+	}
+	parent := fork
+	for i := 0; i < 2; i++ {
+		target, _ := wt.cs.ChildTarget(parent.ID())
+		b, solved := wt.miner.SolveBlock(types.Block{
+			ParentID:     parent.ID(),
+			Timestamp:    types.CurrentTimestamp(),
+			MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(csForkHeight + 2 + types.BlockHeight(i))}},
+		}, target)
+		if !solved {
+			t.Fatal("could not solve the fork block")
+		}
+		err = wt.cs.AcceptBlock(b)
+		if i == 0 && err != modules.ErrNonExtendingBlock {
+			t.Fatal("expected the fork block to be non-extending, got", err)
+		} else if i == 1 && err != nil {
+			t.Fatal(err)
+		}
+		parent = b
+	}
+	if wt.cs.CurrentBlock().ID() != parent.ID() {
+		t.Fatal("the competing fork did not become the current chain")
+	}
+	wt.wallet.mu.RLock()
+	height := wt.wallet.consensusSetHeight
+	history := len(wt.wallet.processedTransactions)
+	wt.wallet.mu.RUnlock()
+	if height != forkHeight+3 {
+		t.Fatalf("wallet is at height %v, expected %v", height, forkHeight+3)
+	}
+
+	// Rescan from a height whose predecessor was skipped over by the reorg.
+	err = wt.wallet.Rescan(context.Background(), forkHeight+2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.RLock()
+	rescannedHeight := wt.wallet.consensusSetHeight
+	rescannedHistory := len(wt.wallet.processedTransactions)
+	wt.wallet.mu.RUnlock()
+	if rescannedHeight != height {
+		t.Errorf("rescan left the wallet at height %v, expected %v", rescannedHeight, height)
+	}
+	if rescannedHistory != history {
+		t.Errorf("rescan changed the length of the history from %v to %v", history, rescannedHistory)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"path/filepath"
 
+	"github.com/NebulousLabs/entropy-mnemonics"
+
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
@@ -16,11 +18,25 @@ import (
 const (
 	seedFilePrefix = "Sia Wallet Encrypted Backup Seed - "
 	seedFileSuffix = ".seed"
+
+	// defaultRecoveryGapLimit is the number of consecutive unused addresses
+	// that RecoverSeed scans past the last used address of a seed when no
+	// gap limit is provided. The wallet preloads
+	// modules.WalletSeedPreloadDepth addresses, so gaps in a seed used by
+	// this wallet are usually far smaller.
+	defaultRecoveryGapLimit = 1000
 )
 
 var (
-	errAddressExhaustion = errors.New("current seed has used all available addresses")
+	// seedIDSpecifier is hashed together with a seed to identify the seed in
+	// the keys that are generated from it.
+	seedIDSpecifier = types.Specifier{'s', 'e', 'e', 'd', ' ', 'i', 'd'}
+
+	errAddressExhaustion = errors.New("a single seed does not have enough addresses for the request")
 	errKnownSeed         = errors.New("seed is already known")
+
+	errNegativeAddressCount = errors.New("cannot generate a negative number of addresses")
+	errNegativeGapLimit     = errors.New("gap limit cannot be negative")
 )
 
 type (
@@ -49,6 +65,13 @@ func generateUnlockConditions(pk crypto.PublicKey) types.UnlockConditions {
 	}
 }
 
+// seedID returns the identifier of a seed that is recorded in the keys
+// generated from the seed. The specifier keeps the identifier from matching
+// the master key that is derived from the seed when no password is used.
+func seedID(seed modules.Seed) crypto.Hash {
+	return crypto.HashAll(seedIDSpecifier, seed)
+}
+
 // generateSpendableKey creates the keys and unlock conditions a given index of a
 // seed.
 func generateSpendableKey(seed modules.Seed, index uint64) spendableKey {
@@ -58,6 +81,8 @@ func generateSpendableKey(seed modules.Seed, index uint64) spendableKey {
 	return spendableKey{
 		UnlockConditions: generateUnlockConditions(pk),
 		SecretKeys:       []crypto.SecretKey{sk},
+		seedID:           seedID(seed),
+		keyIndex:         index,
 	}
 }
 
@@ -109,19 +134,27 @@ func decryptSeedFile(masterKey crypto.TwofishKey, sf SeedFile) (seed modules.See
 }
 
 // integrateSeed takes an address seed as input and from that generates
-// 'publicKeysPerSeed' addresses that the wallet is able to spend.
-// integrateSeed should not be called with the primary seed.
-func (w *Wallet) integrateSeed(seed modules.Seed) {
-	for i := uint64(0); i < modules.PublicKeysPerSeed; i++ {
+// 'depth' addresses that the wallet is able to spend, or
+// 'modules.PublicKeysPerSeed' addresses if 'depth' is smaller. integrateSeed
+// should not be called with the primary seed.
+func (w *Wallet) integrateSeed(seed modules.Seed, depth uint64) {
+	if depth < modules.PublicKeysPerSeed {
+		depth = modules.PublicKeysPerSeed
+	}
+	addrs := make([]types.UnlockHash, 0, depth)
+	for i := uint64(0); i < depth; i++ {
 		// Generate the key and check it is new to the wallet.
 		spendableKey := generateSpendableKey(seed, i)
 		w.keys[spendableKey.UnlockConditions.UnlockHash()] = spendableKey
+		addrs = append(addrs, spendableKey.UnlockConditions.UnlockHash())
 	}
+	w.tpool.RegisterWatchedAddresses(addrs)
 	w.seeds = append(w.seeds, seed)
 }
 
-// recoverSeed integrates a recovery seed into the wallet.
-func (w *Wallet) recoverSeed(masterKey crypto.TwofishKey, seed modules.Seed) error {
+// recoverSeed integrates a recovery seed into the wallet, tracking the first
+// 'depth' addresses of the seed.
+func (w *Wallet) recoverSeed(masterKey crypto.TwofishKey, seed modules.Seed, depth uint64) error {
 	// Because the recovery seed does not have a UID, duplication must be
 	// prevented by comparing with the list of decrypted seeds. This can only
 	// occur while the wallet is unlocked.
@@ -144,15 +177,19 @@ func (w *Wallet) recoverSeed(masterKey crypto.TwofishKey, seed modules.Seed) err
 	}
 
 	// Add the seed file to the wallet's set of tracked seeds and save the
-	// wallet settings.
+	// wallet settings. Seeds added before depths were recorded are given the
+	// default depth so that the depths stay aligned with the seed files.
+	for len(w.persist.AuxiliarySeedDepths) < len(w.persist.AuxiliarySeedFiles) {
+		w.persist.AuxiliarySeedDepths = append(w.persist.AuxiliarySeedDepths, modules.PublicKeysPerSeed)
+	}
 	w.persist.AuxiliarySeedFiles = append(w.persist.AuxiliarySeedFiles, seedFile)
+	w.persist.AuxiliarySeedDepths = append(w.persist.AuxiliarySeedDepths, depth)
 	err = w.saveSettingsSync()
 	if err != nil {
 		return err
 	}
-	w.integrateSeed(seed)
+	w.integrateSeed(seed, depth)
 	return nil
-
 }
 
 // createSeed creates a wallet seed and encrypts it using a key derived from
@@ -194,7 +231,7 @@ func (w *Wallet) initPrimarySeed(masterKey crypto.TwofishKey) error {
 
 // initAuxiliarySeeds scans the wallet folder for wallet seeds.
 func (w *Wallet) initAuxiliarySeeds(masterKey crypto.TwofishKey) error {
-	for _, seedFile := range w.persist.AuxiliarySeedFiles {
+	for i, seedFile := range w.persist.AuxiliarySeedFiles {
 		seed, err := decryptSeedFile(masterKey, seedFile)
 		if build.DEBUG && err != nil {
 			panic(err)
@@ -203,29 +240,136 @@ func (w *Wallet) initAuxiliarySeeds(masterKey crypto.TwofishKey) error {
 			w.log.Println("UNLOCK: failed to load an auxiliary seed:", err)
 			continue
 		}
-		w.integrateSeed(seed)
+		depth := uint64(modules.PublicKeysPerSeed)
+		if i < len(w.persist.AuxiliarySeedDepths) {
+			depth = w.persist.AuxiliarySeedDepths[i]
+		}
+		w.integrateSeed(seed, depth)
 	}
 	return nil
 }
 
+// rotatePrimarySeed replaces the primary seed of the wallet with a new random
+// seed, once the current primary seed does not have enough addresses left. The
+// old primary seed is kept as an auxiliary seed, so that its addresses remain
+// tracked and spendable. rotatePrimarySeed is called whenever the wallet needs
+// keys past the end of the primary seed, both when addresses are handed out
+// and when the keypool is refilled. Seed exhaustion subscribers are told about
+// the rotation so that the new seed can be backed up. The wallet lock must be
+// held.
+func (w *Wallet) rotatePrimarySeed() error {
+	var seed modules.Seed
+	_, err := rand.Read(seed[:])
+	if err != nil {
+		return err
+	}
+	seedFile, err := w.encryptAndSaveSeedFile(w.masterKey, seed)
+	if err != nil {
+		return err
+	}
+
+	// Move the old primary seed to the auxiliary seeds and save the wallet
+	// settings. The settings are restored if they cannot be saved, so that the
+	// old primary seed keeps being used.
+	oldPrimarySeedFile := w.persist.PrimarySeedFile
+	oldProgress := w.persist.PrimarySeedProgress
+	numSeedFiles := len(w.persist.AuxiliarySeedFiles)
+	numSeedDepths := len(w.persist.AuxiliarySeedDepths)
+	for len(w.persist.AuxiliarySeedDepths) < len(w.persist.AuxiliarySeedFiles) {
+		w.persist.AuxiliarySeedDepths = append(w.persist.AuxiliarySeedDepths, modules.PublicKeysPerSeed)
+	}
+	w.persist.AuxiliarySeedFiles = append(w.persist.AuxiliarySeedFiles, oldPrimarySeedFile)
+	w.persist.AuxiliarySeedDepths = append(w.persist.AuxiliarySeedDepths, seedUsage(oldProgress))
+	w.persist.PrimarySeedFile = seedFile
+	w.persist.PrimarySeedProgress = 0
+	err = w.saveSettingsSync()
+	if err != nil {
+		w.persist.PrimarySeedFile = oldPrimarySeedFile
+		w.persist.PrimarySeedProgress = oldProgress
+		w.persist.AuxiliarySeedFiles = w.persist.AuxiliarySeedFiles[:numSeedFiles]
+		w.persist.AuxiliarySeedDepths = w.persist.AuxiliarySeedDepths[:numSeedDepths]
+		crypto.SecureWipe(seed[:])
+		return err
+	}
+
+	// The keys of the old seed in the keypool will never be handed out. The
+	// preloaded keys of the new seed are tracked, the same as in createSeed.
+	w.wipeKeypool()
+	addrs := make([]types.UnlockHash, 0, modules.WalletSeedPreloadDepth)
+	for i := uint64(0); i < modules.WalletSeedPreloadDepth; i++ {
+		spendableKey := generateSpendableKey(seed, i)
+		w.keys[spendableKey.UnlockConditions.UnlockHash()] = spendableKey
+		addrs = append(addrs, spendableKey.UnlockConditions.UnlockHash())
+	}
+	w.tpool.RegisterWatchedAddresses(addrs)
+	w.primarySeed = seed
+	w.seeds = append(w.seeds, seed)
+	w.log.Println("INFO: primary seed has run out of addresses, a new primary seed is now in use.")
+	w.notifySeedExhaustion(modules.SeedExhaustionWarning{
+		Progress:  0,
+		Remaining: modules.PublicKeysPerSeed - seedUsage(0),
+		Rotated:   true,
+	})
+	return nil
+}
+
 // nextPrimarySeedAddress fetches the next address from the primary seed.
 func (w *Wallet) nextPrimarySeedAddress() (types.UnlockConditions, error) {
+	ucs, err := w.nextPrimarySeedAddresses(1)
+	if err != nil {
+		return types.UnlockConditions{}, err
+	}
+	return ucs[0], nil
+}
+
+// nextPrimarySeedAddresses fetches the next 'n' addresses from the primary
+// seed. Either all 'n' addresses are generated or none are: the addresses are
+// only tracked by the wallet once the new seed progress has been saved.
+func (w *Wallet) nextPrimarySeedAddresses(n uint64) ([]types.UnlockConditions, error) {
 	// Check that the wallet has been unlocked.
 	if !w.unlocked {
-		return types.UnlockConditions{}, modules.ErrLockedWallet
+		return nil, modules.ErrLockedWallet
+	}
+
+	// Addresses beyond 'PublicKeysPerSeed' would not be found when the seed
+	// is recovered, so the batch must fit within the primary seed. If the
+	// primary seed does not have room for the batch, the wallet rotates to a
+	// new primary seed, which has room for all but the preloaded addresses.
+	if n > modules.PublicKeysPerSeed-modules.WalletSeedPreloadDepth {
+		return nil, errAddressExhaustion
+	}
+	if seedUsage(w.persist.PrimarySeedProgress)+n > modules.PublicKeysPerSeed {
+		err := w.rotatePrimarySeed()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Integrate the next key into the wallet, and return the unlock
-	// conditions. Because the wallet preloads keys, the progress used is
+	// Because the wallet preloads keys, the first key of the batch is at
 	// 'PrimarySeedProgress+modules.WalletSeedPreloadDepth'.
-	spendableKey := generateSpendableKey(w.primarySeed, w.persist.PrimarySeedProgress+modules.WalletSeedPreloadDepth)
-	w.keys[spendableKey.UnlockConditions.UnlockHash()] = spendableKey
-	w.persist.PrimarySeedProgress++
+	start := seedUsage(w.persist.PrimarySeedProgress)
+	keys := make([]spendableKey, 0, n)
+	for i := uint64(0); i < n; i++ {
+		keys = append(keys, w.primarySeedKey(start+i))
+	}
+	w.persist.PrimarySeedProgress += n
 	err := w.saveSettingsSync()
 	if err != nil {
-		return types.UnlockConditions{}, err
+		w.persist.PrimarySeedProgress -= n
+		return nil, err
 	}
-	return spendableKey.UnlockConditions, nil
+
+	ucs := make([]types.UnlockConditions, 0, n)
+	addrs := make([]types.UnlockHash, 0, n)
+	for _, spendableKey := range keys {
+		w.keys[spendableKey.UnlockConditions.UnlockHash()] = spendableKey
+		ucs = append(ucs, spendableKey.UnlockConditions)
+		addrs = append(addrs, spendableKey.UnlockConditions.UnlockHash())
+	}
+	w.tpool.RegisterWatchedAddresses(addrs)
+	w.markActivity()
+	w.checkSeedExhaustion(w.persist.PrimarySeedProgress - n)
+	return ucs, nil
 }
 
 // AllSeeds returns a list of all seeds known to and used by the wallet.
@@ -235,9 +379,33 @@ func (w *Wallet) AllSeeds() ([]modules.Seed, error) {
 	if !w.unlocked {
 		return nil, modules.ErrLockedWallet
 	}
+	w.markActivity()
 	return w.seeds, nil
 }
 
+// AddressIndex returns the position of 'addr' within the seeds of the wallet:
+// the index of the seed in the list returned by AllSeeds, and the index of
+// the key within that seed. found is false if the wallet is locked or does
+// not own the address.
+func (w *Wallet) AddressIndex(addr types.UnlockHash) (seedIndex int, keyIndex uint64, found bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return 0, 0, false
+	}
+	sk, exists := w.keys[addr]
+	if !exists || sk.seedID == (crypto.Hash{}) {
+		return 0, 0, false
+	}
+	w.markActivity()
+	for i, seed := range w.seeds {
+		if seedID(seed) == sk.seedID {
+			return i, sk.keyIndex, true
+		}
+	}
+	return 0, 0, false
+}
+
 // PrimarySeed returns the decrypted primary seed of the wallet.
 func (w *Wallet) PrimarySeed() (modules.Seed, uint64, error) {
 	w.mu.Lock()
@@ -245,6 +413,7 @@ func (w *Wallet) PrimarySeed() (modules.Seed, uint64, error) {
 	if !w.unlocked {
 		return modules.Seed{}, 0, modules.ErrLockedWallet
 	}
+	w.markActivity()
 	return w.primarySeed, w.persist.PrimarySeedProgress, nil
 }
 
@@ -256,6 +425,28 @@ func (w *Wallet) NextAddress() (types.UnlockConditions, error) {
 	return w.nextPrimarySeedAddress()
 }
 
+// GenerateAddresses returns 'n' unlock hashes that are ready to receive
+// siacoins or siafunds. All of the addresses are generated using the primary
+// address seed and are tracked by the wallet. If the primary seed does not
+// have room for 'n' more addresses, the wallet rotates to a new primary seed
+// first. An error is returned if 'n' is more than a new seed can hold.
+func (w *Wallet) GenerateAddresses(n int) ([]types.UnlockHash, error) {
+	if n < 0 {
+		return nil, errNegativeAddressCount
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ucs, err := w.nextPrimarySeedAddresses(uint64(n))
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]types.UnlockHash, 0, len(ucs))
+	for _, uc := range ucs {
+		addrs = append(addrs, uc.UnlockHash())
+	}
+	return addrs, nil
+}
+
 // LoadSeed will track all of the addresses generated by the input seed,
 // reclaiming any funds that were lost due to a deleted file or lost encryption
 // key. An error will be returned if the seed has already been integrated with
@@ -267,5 +458,47 @@ func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 	if err != nil {
 		return err
 	}
-	return w.recoverSeed(masterKey, seed)
+	return w.recoverSeed(masterKey, seed, modules.PublicKeysPerSeed)
+}
+
+// RecoverSeed integrates an English seed phrase into the wallet, the same way
+// as LoadSeed, but first scans the blockchain to find how many addresses of
+// the seed are in use. The scan continues until 'gapLimit' consecutive
+// addresses after the last used address are unused, so funds sent to
+// addresses past a gap are not missed. All of the addresses up to the end of
+// the gap are tracked by the wallet and registered with the transaction pool.
+// A 'gapLimit' of zero uses a default that comfortably exceeds typical usage.
+// As with LoadSeed, the outputs of the seed are found once the wallet has been
+// reloaded.
+func (w *Wallet) RecoverSeed(masterKey crypto.TwofishKey, seedStr string, gapLimit int) error {
+	if gapLimit < 0 {
+		return errNegativeGapLimit
+	}
+	if gapLimit == 0 {
+		gapLimit = defaultRecoveryGapLimit
+	}
+	seed, err := modules.StringToSeed(seedStr, mnemonics.English)
+	if err != nil {
+		return err
+	}
+	w.mu.RLock()
+	unlocked := w.unlocked
+	err = w.checkMasterKey(masterKey)
+	w.mu.RUnlock()
+	if !unlocked {
+		return modules.ErrLockedWallet
+	}
+	if err != nil {
+		return err
+	}
+
+	// The wallet lock must not be held while scanning.
+	depth, err := w.scanSeedDepth(seed, uint64(gapLimit))
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.recoverSeed(masterKey, seed, depth)
 }
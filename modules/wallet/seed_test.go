@@ -5,8 +5,11 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/NebulousLabs/entropy-mnemonics"
+
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -171,3 +174,443 @@ func TestLoadSeed(t *testing.T) {
 		t.Error("AllSeeds returned the wrong seed")
 	}
 }
+
+// TestGenerateAddresses checks that GenerateAddresses produces a batch of
+// tracked addresses and refuses to exceed the capacity of the primary seed.
+func TestGenerateAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestGenerateAddresses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	_, startProgress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := wt.wallet.GenerateAddresses(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 10 {
+		t.Fatal("wrong number of addresses generated:", len(addrs))
+	}
+	_, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress != startProgress+10 {
+		t.Error("primary seed progress was not advanced by the batch:", progress)
+	}
+	seen := make(map[types.UnlockHash]struct{})
+	for _, addr := range addrs {
+		if _, exists := wt.wallet.keys[addr]; !exists {
+			t.Error("generated address is not tracked by the wallet")
+		}
+		seen[addr] = struct{}{}
+	}
+	if len(seen) != len(addrs) {
+		t.Error("generated addresses are not unique")
+	}
+
+	// The generated addresses should still be tracked after a restart, even
+	// though none of them have been used.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if _, exists := w.keys[addr]; !exists {
+			t.Error("generated address is not tracked after a restart")
+		}
+	}
+
+	// Send money to one of the addresses and check that it is detected.
+	_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), addrs[9])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := wt.miner.FindBlock()
+	err = wt.cs.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, pt := range wt.wallet.AddressTransactions(addrs[9]) {
+		for _, output := range pt.Outputs {
+			if output.RelatedAddress == addrs[9] && output.WalletAddress {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("wallet did not detect funds sent to a generated address")
+	}
+
+	// Requesting more addresses than the seed can hold should fail without
+	// advancing the seed.
+	_, progress, err = wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.GenerateAddresses(modules.PublicKeysPerSeed)
+	if err != errAddressExhaustion {
+		t.Fatal("expected errAddressExhaustion, got", err)
+	}
+	_, progress2, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress2 != progress {
+		t.Error("failed batch should not advance the primary seed")
+	}
+	_, err = wt.wallet.GenerateAddresses(-1)
+	if err != errNegativeAddressCount {
+		t.Error("expected errNegativeAddressCount, got", err)
+	}
+}
+
+// TestPrimarySeedRotation checks that the wallet rotates to a new primary seed
+// when the primary seed does not have room for more addresses, that the
+// addresses of the old seed remain tracked, and that the rotation persists.
+func TestPrimarySeedRotation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestPrimarySeedRotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Move the primary seed close to its last address.
+	wt.wallet.mu.Lock()
+	wt.wallet.persist.PrimarySeedProgress = modules.PublicKeysPerSeed - modules.WalletSeedPreloadDepth - 5
+	err = wt.wallet.saveSettingsSync()
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSeed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldAddrs, err := wt.wallet.GenerateAddresses(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != oldSeed {
+		t.Fatal("primary seed was rotated before it ran out of addresses")
+	}
+
+	// A batch that needs more addresses than a new seed holds should fail
+	// without rotating the seed.
+	_, err = wt.wallet.GenerateAddresses(modules.PublicKeysPerSeed - modules.WalletSeedPreloadDepth + 1)
+	if err != errAddressExhaustion {
+		t.Fatal("expected errAddressExhaustion, got", err)
+	}
+	seed, _, err = wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != oldSeed {
+		t.Fatal("primary seed was rotated for a batch that cannot fit in a seed")
+	}
+
+	// The next address does not fit in the primary seed, so the wallet should
+	// rotate to a new seed and tell the seed exhaustion subscribers.
+	warnings, cancel := wt.wallet.SubscribeSeedExhaustion()
+	defer cancel()
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSeed, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newSeed == oldSeed {
+		t.Fatal("primary seed was not rotated")
+	}
+	select {
+	case warning := <-warnings:
+		if !warning.Rotated {
+			t.Error("warning does not report the rotation")
+		}
+	default:
+		t.Error("subscribers were not told about the rotation")
+	}
+	if progress != 1 {
+		t.Error("new primary seed should have a progress of 1, got", progress)
+	}
+	if uc.UnlockHash() != generateSpendableKey(newSeed, modules.WalletSeedPreloadDepth).UnlockConditions.UnlockHash() {
+		t.Error("address was not generated from the new primary seed")
+	}
+	seeds, err := wt.wallet.AllSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 2 || seeds[0] != oldSeed || seeds[1] != newSeed {
+		t.Error("wallet should know both the old and the new primary seed")
+	}
+
+	// After a restart, the new primary seed should be used and the addresses
+	// of both seeds should be tracked.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, progress, err = w.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != newSeed || progress != 1 {
+		t.Error("rotated primary seed was not persisted")
+	}
+	for _, addr := range append(oldAddrs, uc.UnlockHash()) {
+		if _, exists := w.keys[addr]; !exists {
+			t.Error("address is not tracked after a restart")
+		}
+	}
+}
+
+// TestGenerateAddressesSaveFailure checks that no addresses are handed out or
+// tracked if the new seed progress cannot be saved.
+func TestGenerateAddressesSaveFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestGenerateAddressesSaveFailure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	seed, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.Lock()
+	persistDir := wt.wallet.persistDir
+	wt.wallet.persistDir = filepath.Join(persistDir, "missing")
+	wt.wallet.mu.Unlock()
+	_, err = wt.wallet.GenerateAddresses(3)
+	if err == nil {
+		t.Fatal("expected an error when the seed progress cannot be saved")
+	}
+	wt.wallet.mu.Lock()
+	wt.wallet.persistDir = persistDir
+	wt.wallet.mu.Unlock()
+
+	_, progress2, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress2 != progress {
+		t.Error("failed batch should not advance the primary seed")
+	}
+	for i := uint64(0); i < 3; i++ {
+		addr := generateSpendableKey(seed, seedUsage(progress)+i).UnlockConditions.UnlockHash()
+		if _, exists := wt.wallet.keys[addr]; exists {
+			t.Error("address of a failed batch is tracked by the wallet")
+		}
+	}
+}
+
+// TestRecoverSeed checks that RecoverSeed finds addresses of a seed that are
+// past a gap of unused addresses, and that the addresses are still tracked
+// after the wallet is reloaded.
+func TestRecoverSeed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestRecoverSeed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	var seed modules.Seed
+	seed[0] = 2
+	seedStr, err := modules.SeedToString(seed, mnemonics.English)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wt.wallet.RecoverSeed(wt.walletMasterKey, "not a seed", 0) == nil {
+		t.Error("malformed seed was accepted")
+	}
+	if wt.wallet.RecoverSeed(wt.walletMasterKey, seedStr, -1) != errNegativeGapLimit {
+		t.Error("expected errNegativeGapLimit")
+	}
+
+	// Send coins to an early address of the seed and to an address that is
+	// past the addresses that are tracked by default.
+	amount := types.SiacoinPrecision.Mul(types.NewCurrency64(100))
+	nearAddr := generateSpendableKey(seed, 3).UnlockConditions.UnlockHash()
+	farIndex := uint64(modules.PublicKeysPerSeed + 5)
+	farAddr := generateSpendableKey(seed, farIndex).UnlockConditions.UnlockHash()
+	_, err = wt.wallet.SendSiacoinsMulti(wt.walletMasterKey, []types.SiacoinOutput{
+		{Value: amount, UnlockHash: nearAddr},
+		{Value: amount, UnlockHash: farAddr},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A small gap limit stops the scan before the far address.
+	depth, err := wt.wallet.scanSeedDepth(seed, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth != 3+1+10 {
+		t.Error("wrong depth for a small gap limit:", depth)
+	}
+
+	// A large gap limit reaches the far address.
+	gapLimit := uint64(farIndex)
+	err = wt.wallet.RecoverSeed(wt.walletMasterKey, seedStr, int(gapLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := wt.wallet.keys[farAddr]; !exists {
+		t.Fatal("far address was not tracked by the wallet")
+	}
+
+	// The far address is tracked after the wallet has been reloaded.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.persist.AuxiliarySeedDepths) != 1 || w.persist.AuxiliarySeedDepths[0] != farIndex+1+gapLimit {
+		t.Error("seed depth was not persisted:", w.persist.AuxiliarySeedDepths)
+	}
+	confirmed, _, err := w.AddressBalance(farAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Cmp(amount) != 0 {
+		t.Error("reloaded wallet has the wrong balance for the far address:", confirmed)
+	}
+}
+
+// TestAddressIndex checks that AddressIndex finds the seed and key index of
+// addresses generated by the primary seed and by a recovered seed.
+func TestAddressIndex(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestAddressIndex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Find an address of the primary seed.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, progress, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedIndex, keyIndex, found := wt.wallet.AddressIndex(uc.UnlockHash())
+	if !found || seedIndex != 0 || keyIndex != progress-1+modules.WalletSeedPreloadDepth {
+		t.Errorf("wrong index for a primary seed address: %v %v %v", seedIndex, keyIndex, found)
+	}
+
+	// Find an address of a recovered seed.
+	var seed modules.Seed
+	seed[0] = 3
+	seedStr, err := modules.SeedToString(seed, mnemonics.English)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.RecoverSeed(wt.walletMasterKey, seedStr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds, err := wt.wallet.AllSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := generateSpendableKey(seed, 42).UnlockConditions.UnlockHash()
+	seedIndex, keyIndex, found = wt.wallet.AddressIndex(addr)
+	if !found || seeds[seedIndex] != seed || keyIndex != 42 {
+		t.Errorf("wrong index for a recovered seed address: %v %v %v", seedIndex, keyIndex, found)
+	}
+
+	// Addresses past the default depth of a seed are found as well.
+	var deepSeed modules.Seed
+	deepSeed[0] = 4
+	wt.wallet.mu.Lock()
+	err = wt.wallet.recoverSeed(wt.walletMasterKey, deepSeed, modules.PublicKeysPerSeed+10)
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds, err = wt.wallet.AllSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deepAddr := generateSpendableKey(deepSeed, modules.PublicKeysPerSeed+5).UnlockConditions.UnlockHash()
+	seedIndex, keyIndex, found = wt.wallet.AddressIndex(deepAddr)
+	if !found || seeds[seedIndex] != deepSeed || keyIndex != modules.PublicKeysPerSeed+5 {
+		t.Errorf("wrong index for an address past the default depth: %v %v %v", seedIndex, keyIndex, found)
+	}
+
+	// Addresses that the wallet does not own are not found.
+	_, _, found = wt.wallet.AddressIndex(types.UnlockHash{1})
+	if found {
+		t.Error("address not owned by the wallet was found")
+	}
+	wt.wallet.Lock()
+	_, _, found = wt.wallet.AddressIndex(addr)
+	if found {
+		t.Error("address was found while the wallet was locked")
+	}
+}
+
+// TestSpendableKeyEncoding checks that the seed and key index of a spendable
+// key do not change how the key is encoded.
+func TestSpendableKeyEncoding(t *testing.T) {
+	sk := generateSpendableKey(modules.Seed{1}, 3)
+	legacy := struct {
+		UnlockConditions types.UnlockConditions
+		SecretKeys       []crypto.SecretKey
+	}{sk.UnlockConditions, sk.SecretKeys}
+	if !bytes.Equal(encoding.Marshal(sk), encoding.Marshal(legacy)) {
+		t.Fatal("spendable key encoding changed")
+	}
+	var decoded spendableKey
+	err := encoding.Unmarshal(encoding.Marshal(sk), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.UnlockConditions.UnlockHash() != sk.UnlockConditions.UnlockHash() || decoded.SecretKeys[0] != sk.SecretKeys[0] {
+		t.Error("spendable key did not survive encoding")
+	}
+}
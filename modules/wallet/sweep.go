@@ -0,0 +1,262 @@
+package wallet
+
+import (
+	"github.com/NebulousLabs/entropy-mnemonics"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A seedScanner subscribes to the consensus set to find the unspent outputs
+// that belong to the addresses of a seed. The seed is not added to the
+// wallet.
+type seedScanner struct {
+	keys           map[types.UnlockHash]spendableKey
+	keyIndices     map[types.UnlockHash]uint64
+	siacoinOutputs map[types.SiacoinOutputID]types.SiacoinOutput
+	siafundOutputs map[types.SiafundOutputID]types.SiafundOutput
+
+	// largestIndexSeen is the largest index of an address of the seed that
+	// has received an output at any point in the blockchain. It is only set
+	// if 'used' is true.
+	largestIndexSeen uint64
+	used             bool
+}
+
+// newSeedScanner returns a seedScanner that scans for the first 'numKeys'
+// addresses of the seed.
+func newSeedScanner(seed modules.Seed, numKeys uint64) *seedScanner {
+	s := &seedScanner{
+		keys:           make(map[types.UnlockHash]spendableKey),
+		keyIndices:     make(map[types.UnlockHash]uint64),
+		siacoinOutputs: make(map[types.SiacoinOutputID]types.SiacoinOutput),
+		siafundOutputs: make(map[types.SiafundOutputID]types.SiafundOutput),
+	}
+	for i := uint64(0); i < numKeys; i++ {
+		spendableKey := generateSpendableKey(seed, i)
+		s.keys[spendableKey.UnlockConditions.UnlockHash()] = spendableKey
+		s.keyIndices[spendableKey.UnlockConditions.UnlockHash()] = i
+	}
+	return s
+}
+
+// markUsed records that the address with the given unlock hash has received
+// an output.
+func (s *seedScanner) markUsed(uh types.UnlockHash) {
+	index := s.keyIndices[uh]
+	if !s.used || index > s.largestIndexSeen {
+		s.largestIndexSeen = index
+		s.used = true
+	}
+}
+
+// ProcessConsensusChange adds and removes the outputs belonging to the seed
+// as they are created and spent.
+func (s *seedScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	for _, diff := range cc.SiacoinOutputDiffs {
+		if _, exists := s.keys[diff.SiacoinOutput.UnlockHash]; !exists {
+			continue
+		}
+		if diff.Direction == modules.DiffApply {
+			s.markUsed(diff.SiacoinOutput.UnlockHash)
+			s.siacoinOutputs[diff.ID] = diff.SiacoinOutput
+		} else {
+			delete(s.siacoinOutputs, diff.ID)
+		}
+	}
+	for _, diff := range cc.SiafundOutputDiffs {
+		if _, exists := s.keys[diff.SiafundOutput.UnlockHash]; !exists {
+			continue
+		}
+		if diff.Direction == modules.DiffApply {
+			s.markUsed(diff.SiafundOutput.UnlockHash)
+			s.siafundOutputs[diff.ID] = diff.SiafundOutput
+		} else {
+			delete(s.siafundOutputs, diff.ID)
+		}
+	}
+}
+
+// wipe erases all of the secret keys held by the seedScanner.
+func (s *seedScanner) wipe() {
+	for i := range s.keys {
+		for j := range s.keys[i].SecretKeys {
+			crypto.SecureWipe(s.keys[i].SecretKeys[j][:])
+		}
+	}
+}
+
+// scanSeedDepth scans the consensus set for addresses of the seed that have
+// been used, and returns the number of addresses that need to be tracked so
+// that the last used address is followed by 'gapLimit' unused addresses. The
+// blockchain is scanned again with more addresses whenever a used address is
+// found within 'gapLimit' of the end of the scanned addresses. The wallet lock
+// must not be held.
+func (w *Wallet) scanSeedDepth(seed modules.Seed, gapLimit uint64) (uint64, error) {
+	numKeys := gapLimit
+	for {
+		scanner := newSeedScanner(seed, numKeys)
+		err := w.cs.ConsensusSetSubscribe(scanner, modules.ConsensusChangeBeginning)
+		if err != nil {
+			scanner.wipe()
+			return 0, err
+		}
+		w.cs.Unsubscribe(scanner)
+		scanner.wipe()
+		if !scanner.used || scanner.largestIndexSeen+1+gapLimit <= numKeys {
+			return numKeys, nil
+		}
+		numKeys = scanner.largestIndexSeen + 1 + gapLimit
+	}
+}
+
+// SweepSeed scans the consensus set for the unspent outputs belonging to an
+// English seed phrase, and sends all of the siacoins and siafunds to a fresh
+// address of the wallet in a single transaction. The miner fee is taken out of
+// the swept siacoins, or paid by the wallet if the seed does not hold enough
+// siacoins. The seed is not tracked by the wallet. The amounts of siacoins and
+// siafunds that were received by the wallet are returned; if the seed controls
+// no outputs, nothing is sent.
+func (w *Wallet) SweepSeed(seedStr string) (coins, funds types.Currency, err error) {
+	seed, err := modules.StringToSeed(seedStr, mnemonics.English)
+	if err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	}
+	w.mu.RLock()
+	unlocked := w.unlocked
+	w.mu.RUnlock()
+	if !unlocked {
+		return types.ZeroCurrency, types.ZeroCurrency, modules.ErrLockedWallet
+	}
+
+	// Scan the full blockchain for outputs belonging to the seed. The wallet
+	// lock must not be held while subscribing, as the consensus set will hold
+	// its own lock while sending changes to the wallet.
+	scanner := newSeedScanner(seed, modules.PublicKeysPerSeed)
+	defer scanner.wipe()
+	err = w.cs.ConsensusSetSubscribe(scanner, modules.ConsensusChangeBeginning)
+	if err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	}
+	w.cs.Unsubscribe(scanner)
+	if len(scanner.siacoinOutputs) == 0 && len(scanner.siafundOutputs) == 0 {
+		return types.ZeroCurrency, types.ZeroCurrency, nil
+	}
+
+	// Spend every output into a fresh address.
+	dest, err := w.NextAddress()
+	if err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	}
+	var txn types.Transaction
+	for scoid, sco := range scanner.siacoinOutputs {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         scoid,
+			UnlockConditions: scanner.keys[sco.UnlockHash].UnlockConditions,
+		})
+		coins = coins.Add(sco.Value)
+	}
+	for sfoid, sfo := range scanner.siafundOutputs {
+		txn.SiafundInputs = append(txn.SiafundInputs, types.SiafundInput{
+			ParentID:         sfoid,
+			UnlockConditions: scanner.keys[sfo.UnlockHash].UnlockConditions,
+			ClaimUnlockHash:  dest.UnlockHash(),
+		})
+		funds = funds.Add(sfo.Value)
+	}
+	if !funds.IsZero() {
+		txn.SiafundOutputs = []types.SiafundOutput{{
+			Value:      funds,
+			UnlockHash: dest.UnlockHash(),
+		}}
+	}
+	sign := func(txn *types.Transaction) error {
+		for _, sci := range txn.SiacoinInputs {
+			_, err := addSignatures(txn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), scanner.keys[sci.UnlockConditions.UnlockHash()])
+			if err != nil {
+				return err
+			}
+		}
+		for _, sfi := range txn.SiafundInputs {
+			_, err := addSignatures(txn, types.FullCoveredFields, sfi.UnlockConditions, crypto.Hash(sfi.ParentID), scanner.keys[sfi.UnlockConditions.UnlockHash()])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Measure the size of the transaction on a signed draft that sends the
+	// swept coins and also pays them as the fee, which encodes at least as
+	// large as the final transaction. The fee is estimated the same way as
+	// for defragmenting transactions.
+	draft := txn
+	if !coins.IsZero() {
+		draft.SiacoinOutputs = []types.SiacoinOutput{{Value: coins, UnlockHash: dest.UnlockHash()}}
+		draft.MinerFees = []types.Currency{coins}
+	}
+	err = sign(&draft)
+	if err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	}
+	size := len(encoding.Marshal([]types.Transaction{draft}))
+	_, maxPerByte := w.tpool.FeeEstimation()
+	fee := w.tpool.RequiredFee(size, 1).Add(maxPerByte.Mul(types.NewCurrency64(uint64(size))))
+
+	// The fee is taken out of the swept coins. If the seed does not hold
+	// enough siacoins to cover it, the fee is paid by a parent transaction
+	// funded by the wallet instead.
+	var parents []types.Transaction
+	if coins.Cmp(fee.Add(w.DustThreshold())) > 0 {
+		coins = coins.Sub(fee)
+		txn.SiacoinOutputs = []types.SiacoinOutput{{
+			Value:      coins,
+			UnlockHash: dest.UnlockHash(),
+		}}
+		txn.MinerFees = []types.Currency{fee}
+	} else {
+		// The parent transaction makes the set larger, so the fee of a
+		// typical transaction set is added on top.
+		_, parentFee := w.tpool.RecommendedFee()
+		fee = fee.Add(parentFee)
+		txnBuilder := w.StartTransaction()
+		err = txnBuilder.FundSiacoins(fee)
+		if err != nil {
+			txnBuilder.Drop()
+			return types.ZeroCurrency, types.ZeroCurrency, err
+		}
+		_, err = txnBuilder.AddMinerFee(fee)
+		if err != nil {
+			txnBuilder.Drop()
+			return types.ZeroCurrency, types.ZeroCurrency, err
+		}
+		parents, err = txnBuilder.Sign(true)
+		if err != nil {
+			txnBuilder.Drop()
+			return types.ZeroCurrency, types.ZeroCurrency, err
+		}
+		defer func() {
+			if err != nil {
+				txnBuilder.Drop()
+			}
+		}()
+		if !coins.IsZero() {
+			txn.SiacoinOutputs = []types.SiacoinOutput{{
+				Value:      coins,
+				UnlockHash: dest.UnlockHash(),
+			}}
+		}
+	}
+	err = sign(&txn)
+	if err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	}
+
+	err = w.tpool.AcceptTransactionSet(append(parents, txn))
+	if err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	}
+	return coins, funds, nil
+}
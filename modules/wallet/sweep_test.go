@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/NebulousLabs/entropy-mnemonics"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/transactionpool"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSweepSeed checks that SweepSeed sends the outputs controlled by a
+// foreign seed to the wallet without adding the seed to the wallet.
+func TestSweepSeed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSweepSeed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	var seed modules.Seed
+	seed[0] = 1
+	seedStr, err := modules.SeedToString(seed, mnemonics.English)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sweeping a seed without any outputs should do nothing.
+	coins, funds, err := wt.wallet.SweepSeed(seedStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coins.IsZero() || !funds.IsZero() {
+		t.Fatal("empty seed should not sweep anything:", coins, funds)
+	}
+
+	// Send coins to two addresses of the seed.
+	amount := types.NewCurrency64(1e3).Mul(types.SiacoinPrecision)
+	for _, i := range []uint64{0, 7} {
+		addr := generateSpendableKey(seed, i).UnlockConditions.UnlockHash()
+		_, err = wt.wallet.SendSiacoins(amount, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coins, funds, err = wt.wallet.SweepSeed(seedStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fee types.Currency
+	for _, txn := range wt.tpool.TransactionList() {
+		if len(txn.SiacoinInputs) == 2 && len(txn.MinerFees) == 1 {
+			fee = txn.MinerFees[0]
+		}
+	}
+	if fee.IsZero() || coins.Add(fee).Cmp(amount.Mul(types.NewCurrency64(2))) != 0 || !funds.IsZero() {
+		t.Fatal("wrong amounts swept:", coins, fee, funds)
+	}
+	_, incoming := wt.wallet.UnconfirmedBalance()
+	if incoming.Cmp(coins) < 0 {
+		t.Error("swept coins are not incoming to the wallet:", incoming)
+	}
+
+	// The seed should not have been added to the wallet.
+	seeds, err := wt.wallet.AllSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range seeds {
+		if s == seed {
+			t.Fatal("swept seed was added to the wallet")
+		}
+	}
+
+	// Once the sweep is confirmed, the seed should be empty.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coins, _, err = wt.wallet.SweepSeed(seedStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coins.IsZero() {
+		t.Fatal("seed still has coins after being swept:", coins)
+	}
+}
+
+// TestSweepSeedFee checks that SweepSeed pays a miner fee out of the swept
+// coins, so that the sweep is accepted by a transaction pool that requires
+// fees.
+func TestSweepSeedFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSweepSeedFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	var seed modules.Seed
+	seed[0] = 2
+	seedStr, err := modules.SeedToString(seed, mnemonics.English)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := types.NewCurrency64(1e3).Mul(types.SiacoinPrecision)
+	_, err = wt.wallet.SendSiacoins(amount, generateSpendableKey(seed, 0).UnlockConditions.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the transaction pool past the size where fees are required, and
+	// require a fee per byte as well.
+	for i := 0; i < transactionpool.TransactionPoolSizeForFee/10e3; i++ {
+		arbData := make([]byte, 10e3)
+		copy(arbData, modules.PrefixNonSia[:])
+		_, err = rand.Read(arbData[100:116])
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = wt.tpool.AcceptTransactionSet([]types.Transaction{{ArbitraryData: [][]byte{arbData}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wt.tpool.SetMinimumFee(types.SiacoinPrecision.Div(types.NewCurrency64(1e3)))
+
+	coins, _, err := wt.wallet.SweepSeed(seedStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coins.Cmp(amount) >= 0 {
+		t.Fatal("sweep did not pay a fee out of the swept coins:", coins)
+	}
+	var sweep types.Transaction
+	for _, txn := range wt.tpool.TransactionList() {
+		if len(txn.SiacoinInputs) == 1 && txn.SiacoinInputs[0].UnlockConditions.UnlockHash() == generateSpendableKey(seed, 0).UnlockConditions.UnlockHash() {
+			sweep = txn
+		}
+	}
+	if len(sweep.MinerFees) != 1 || coins.Add(sweep.MinerFees[0]).Cmp(amount) != 0 {
+		t.Fatal("sweep fee does not account for the difference in swept coins:", sweep.MinerFees)
+	}
+	size := len(encoding.Marshal([]types.Transaction{sweep}))
+	if sweep.MinerFees[0].Cmp(wt.tpool.RequiredFee(size, 1)) < 0 {
+		t.Error("sweep does not pay the fee required by the transaction pool")
+	}
+}
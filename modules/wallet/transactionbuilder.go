@@ -16,6 +16,30 @@ var (
 	// already added at least one successful signature to the transaction,
 	// meaning that future calls to Sign will result in an invalid transaction.
 	errBuilderAlreadySigned = errors.New("sign has already been called on this transaction builder, multiple calls can cause issues")
+
+	// errBuilderDropped indicates that the transaction builder has been
+	// dropped, and can no longer be funded or signed.
+	errBuilderDropped = errors.New("transaction builder has been dropped and cannot be used")
+
+	// errBatchDoubleSpend indicates that two of the transaction builders
+	// passed to BatchSign spend the same output.
+	errBatchDoubleSpend = errors.New("multiple transaction builders in the batch spend the same output")
+
+	// errOutputReserved indicates that an output passed to FundSiacoinsFrom
+	// is already reserved by another transaction.
+	errOutputReserved = errors.New("output is already reserved by another transaction")
+
+	// errUnknownOutput indicates that an output passed to FundSiacoinsFrom
+	// does not belong to the wallet, or cannot be spent by the wallet yet.
+	errUnknownOutput = errors.New("output does not belong to the wallet")
+
+	// errForeignBuilder indicates that a transaction builder passed to
+	// BatchSign was not created by the wallet.
+	errForeignBuilder = errors.New("transaction builder was not created by this wallet")
+
+	// errInvalidChangeAddress indicates that the provided change address is
+	// empty, which would burn the change.
+	errInvalidChangeAddress = errors.New("change address cannot be the empty unlock hash")
 )
 
 // transactionBuilder allows transactions to be manually constructed, including
@@ -23,8 +47,12 @@ var (
 type transactionBuilder struct {
 	// 'signed' indicates that at least one transaction signature has been
 	// added to the wallet, meaning that future calls to 'Sign' will fail.
+	//
+	// 'dropped' indicates that the outputs claimed by the builder have been
+	// returned to the wallet, meaning that the builder can no longer be used.
 	parents     []types.Transaction
 	signed      bool
+	dropped     bool
 	transaction types.Transaction
 
 	newParents            []int
@@ -32,6 +60,10 @@ type transactionBuilder struct {
 	siafundInputs         []int
 	transactionSignatures []int
 
+	// If 'changeAddress' is set, siacoin refunds created while funding the
+	// transaction are sent to it instead of to a new wallet address.
+	changeAddress *types.UnlockHash
+
 	wallet *Wallet
 }
 
@@ -82,6 +114,23 @@ func addSignatures(txn *types.Transaction, cf types.CoveredFields, uc types.Unlo
 	return newSigIndices, nil
 }
 
+// reserveOutputs marks the outputs as spent and saves the wallet settings, so
+// that the outputs remain reserved if the wallet restarts. If the settings
+// cannot be saved the outputs are released again, leaving the wallet
+// unchanged. The wallet must be locked by the caller.
+func (w *Wallet) reserveOutputs(ids []types.OutputID) error {
+	for _, id := range ids {
+		w.spentOutputs[id] = w.consensusSetHeight
+	}
+	err := w.saveSettings()
+	if err != nil {
+		for _, id := range ids {
+			delete(w.spentOutputs, id)
+		}
+	}
+	return err
+}
+
 // FundSiacoins will add a siacoin input of exaclty 'amount' to the
 // transaction. A parent transaction may be needed to achieve an input with the
 // correct value. The siacoin input will not be signed until 'Sign' is called
@@ -89,6 +138,13 @@ func addSignatures(txn *types.Transaction, cf types.CoveredFields, uc types.Unlo
 func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	tb.wallet.mu.Lock()
 	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return errBuilderDropped
+	}
+	if !tb.wallet.unlocked {
+		return modules.ErrLockedWallet
+	}
+	tb.wallet.markActivity()
 
 	// Collect a value-sorted set of siacoin outputs.
 	var so sortedOutputs
@@ -125,11 +181,7 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 		sco := so.outputs[i]
 		// Check that this output has not recently been spent by the wallet.
 		spendHeight := tb.wallet.spentOutputs[types.OutputID(scoid)]
-		// Prevent an underflow error.
-		allowedHeight := tb.wallet.consensusSetHeight - RespendTimeout
-		if tb.wallet.consensusSetHeight < RespendTimeout {
-			allowedHeight = 0
-		}
+		allowedHeight := respendAllowedHeight(tb.wallet.consensusSetHeight)
 		if spendHeight > allowedHeight {
 			potentialFund = potentialFund.Add(sco.Value)
 			continue
@@ -158,6 +210,12 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 		return modules.ErrPotentialDoubleSpend
 	}
 	if fund.Cmp(amount) < 0 {
+		// Give a clear error if the wallet only has enough money when the
+		// unspendable watch-only outputs are counted.
+		watchedSiacoins, _, _ := tb.wallet.watchedBalance(true)
+		if fund.Add(watchedSiacoins).Cmp(amount) >= 0 {
+			return errWatchOnlyFunds
+		}
 		return modules.ErrLowBalance
 	}
 
@@ -173,15 +231,24 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	}
 	parentTxn.SiacoinOutputs = append(parentTxn.SiacoinOutputs, exactOutput)
 
-	// Create a refund output if needed.
-	if amount.Cmp(fund) != 0 {
-		refundUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
-		if err != nil {
-			return err
+	// Create a refund output if needed. A refund that would be below the
+	// dust threshold is given to the miners instead.
+	if refund := fund.Sub(amount); refund.Cmp(tb.wallet.dustThreshold) < 0 && !refund.IsZero() {
+		parentTxn.MinerFees = append(parentTxn.MinerFees, refund)
+	} else if !refund.IsZero() {
+		var refundUnlockHash types.UnlockHash
+		if tb.changeAddress != nil {
+			refundUnlockHash = *tb.changeAddress
+		} else {
+			refundUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
+			if err != nil {
+				return err
+			}
+			refundUnlockHash = refundUnlockConditions.UnlockHash()
 		}
 		refundOutput := types.SiacoinOutput{
-			Value:      fund.Sub(amount),
-			UnlockHash: refundUnlockConditions.UnlockHash(),
+			Value:      refund,
+			UnlockHash: refundUnlockHash,
 		}
 		parentTxn.SiacoinOutputs = append(parentTxn.SiacoinOutputs, refundOutput)
 	}
@@ -193,9 +260,19 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 			return err
 		}
 	}
-	// Mark the parent output as spent. Must be done after the transaction is
-	// finished because otherwise the txid and output id will change.
-	tb.wallet.spentOutputs[types.OutputID(parentTxn.SiacoinOutputID(0))] = tb.wallet.consensusSetHeight
+	// Reserve the outputs that were spent and the parent output before the
+	// builder is changed, so that the builder is left untouched if the
+	// reservation cannot be saved. The parent output must be reserved after
+	// the transaction is finished because otherwise the txid and output id
+	// will change.
+	reserved := []types.OutputID{types.OutputID(parentTxn.SiacoinOutputID(0))}
+	for _, scoid := range spentScoids {
+		reserved = append(reserved, types.OutputID(scoid))
+	}
+	err = tb.wallet.reserveOutputs(reserved)
+	if err != nil {
+		return err
+	}
 
 	// Add the exact output.
 	newInput := types.SiacoinInput{
@@ -206,10 +283,92 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	tb.parents = append(tb.parents, parentTxn)
 	tb.siacoinInputs = append(tb.siacoinInputs, len(tb.transaction.SiacoinInputs))
 	tb.transaction.SiacoinInputs = append(tb.transaction.SiacoinInputs, newInput)
+	return nil
+}
 
-	// Mark all outputs that were spent as spent.
-	for _, scoid := range spentScoids {
-		tb.wallet.spentOutputs[types.OutputID(scoid)] = tb.wallet.consensusSetHeight
+// FundSiacoinsIncludingFee adds a siacoin input worth 'amount' plus 'fee' to
+// the transaction and adds 'fee' as a miner fee, leaving 'amount' to be spent
+// by the caller. Funding both at once means that the inputs are only selected
+// once and at most one refund output is created. modules.ErrLowBalance is
+// returned if the wallet cannot cover the combined total, in which case the
+// miner fee is not added.
+func (tb *transactionBuilder) FundSiacoinsIncludingFee(amount, fee types.Currency) error {
+	err := tb.FundSiacoins(amount.Add(fee))
+	if err != nil {
+		return err
+	}
+	if !fee.IsZero() {
+		_, err = tb.AddMinerFee(fee)
+	}
+	return err
+}
+
+// FundSiacoinsFrom adds each of the provided siacoin outputs to the
+// transaction as an input. Unlike 'FundSiacoins', no parent transaction or
+// refund output is created, the caller is responsible for spending the full
+// value of the outputs. Either all of the outputs are added or none are. The
+// siacoin inputs will not be signed until 'Sign' is called on the transaction
+// builder. Outputs of multisig addresses created by MultisigAddress can be
+// added as well; 'Sign' only adds the signatures of the keys that the wallet
+// holds for them.
+func (tb *transactionBuilder) FundSiacoinsFrom(ids []types.SiacoinOutputID) error {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return errBuilderDropped
+	}
+	tb.wallet.markActivity()
+
+	// Check that every output can be spent before adding any of them.
+	allowedHeight := respendAllowedHeight(tb.wallet.consensusSetHeight)
+	seen := make(map[types.SiacoinOutputID]struct{})
+	unlockConditions := make(map[types.SiacoinOutputID]types.UnlockConditions)
+	for _, scoid := range ids {
+		// Watched outputs can only be spent if they belong to a multisig
+		// address, whose remaining signatures are collected elsewhere.
+		var uc types.UnlockConditions
+		if sco, watched := tb.wallet.watchedSiacoinOutputs[scoid]; watched {
+			ms, isMultisig := tb.wallet.multisigAddresses[sco.UnlockHash]
+			if !isMultisig {
+				return errWatchOnlyOutput
+			}
+			uc = ms.uc
+		} else {
+			sco, exists := tb.wallet.siacoinOutputs[scoid]
+			if !exists {
+				return errUnknownOutput
+			}
+			uc = tb.wallet.keys[sco.UnlockHash].UnlockConditions
+		}
+		if _, exists := seen[scoid]; exists {
+			return errOutputReserved
+		}
+		seen[scoid] = struct{}{}
+		if tb.wallet.spentOutputs[types.OutputID(scoid)] > allowedHeight {
+			return errOutputReserved
+		}
+		if tb.wallet.consensusSetHeight < uc.Timelock {
+			return errUnknownOutput
+		}
+		unlockConditions[scoid] = uc
+	}
+
+	// Reserve the outputs, then add the inputs.
+	reserved := make([]types.OutputID, 0, len(ids))
+	for _, scoid := range ids {
+		reserved = append(reserved, types.OutputID(scoid))
+	}
+	err := tb.wallet.reserveOutputs(reserved)
+	if err != nil {
+		return err
+	}
+	for _, scoid := range ids {
+		sci := types.SiacoinInput{
+			ParentID:         scoid,
+			UnlockConditions: unlockConditions[scoid],
+		}
+		tb.siacoinInputs = append(tb.siacoinInputs, len(tb.transaction.SiacoinInputs))
+		tb.transaction.SiacoinInputs = append(tb.transaction.SiacoinInputs, sci)
 	}
 	return nil
 }
@@ -221,6 +380,13 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 	tb.wallet.mu.Lock()
 	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return errBuilderDropped
+	}
+	if !tb.wallet.unlocked {
+		return modules.ErrLockedWallet
+	}
+	tb.wallet.markActivity()
 
 	// Create and fund a parent transaction that will add the correct amount of
 	// siafunds to the transaction.
@@ -231,11 +397,7 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 	for sfoid, sfo := range tb.wallet.siafundOutputs {
 		// Check that this output has not recently been spent by the wallet.
 		spendHeight := tb.wallet.spentOutputs[types.OutputID(sfoid)]
-		// Prevent an underflow error.
-		allowedHeight := tb.wallet.consensusSetHeight - RespendTimeout
-		if tb.wallet.consensusSetHeight < RespendTimeout {
-			allowedHeight = 0
-		}
+		allowedHeight := respendAllowedHeight(tb.wallet.consensusSetHeight)
 		if spendHeight > allowedHeight {
 			potentialFund = potentialFund.Add(sfo.Value)
 			continue
@@ -269,6 +431,10 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 		return modules.ErrPotentialDoubleSpend
 	}
 	if fund.Cmp(amount) < 0 {
+		_, watchedSiafunds, _ := tb.wallet.watchedBalance(true)
+		if fund.Add(watchedSiafunds).Cmp(amount) >= 0 {
+			return errWatchOnlyFunds
+		}
 		return modules.ErrLowBalance
 	}
 
@@ -305,11 +471,22 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 		}
 	}
 
-	// Add the exact output.
+	// Reserve the outputs that were spent before the builder is changed, so
+	// that the builder is left untouched if the reservation cannot be saved.
 	claimUnlockConditions, err := tb.wallet.nextPrimarySeedAddress()
 	if err != nil {
 		return err
 	}
+	reserved := make([]types.OutputID, 0, len(spentSfoids))
+	for _, sfoid := range spentSfoids {
+		reserved = append(reserved, types.OutputID(sfoid))
+	}
+	err = tb.wallet.reserveOutputs(reserved)
+	if err != nil {
+		return err
+	}
+
+	// Add the exact output.
 	newInput := types.SiafundInput{
 		ParentID:         parentTxn.SiafundOutputID(0),
 		UnlockConditions: parentUnlockConditions,
@@ -319,101 +496,175 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 	tb.parents = append(tb.parents, parentTxn)
 	tb.siafundInputs = append(tb.siafundInputs, len(tb.transaction.SiafundInputs))
 	tb.transaction.SiafundInputs = append(tb.transaction.SiafundInputs, newInput)
+	return nil
+}
 
-	// Mark all outputs that were spent as spent.
-	for _, sfoid := range spentSfoids {
-		tb.wallet.spentOutputs[types.OutputID(sfoid)] = tb.wallet.consensusSetHeight
+// SetChangeAddress sets the address that receives the siacoin refunds created
+// by future calls to 'FundSiacoins'. If the change address is never set, each
+// refund is sent to a new address from the wallet's primary seed.
+func (tb *transactionBuilder) SetChangeAddress(addr types.UnlockHash) error {
+	if addr == (types.UnlockHash{}) {
+		return errInvalidChangeAddress
+	}
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return errBuilderDropped
 	}
+	tb.changeAddress = &addr
 	return nil
 }
 
 // AddParents adds a set of parents to the transaction.
-func (tb *transactionBuilder) AddParents(newParents []types.Transaction) {
+func (tb *transactionBuilder) AddParents(newParents []types.Transaction) error {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return errBuilderDropped
+	}
 	tb.parents = append(tb.parents, newParents...)
+	return nil
 }
 
 // AddMinerFee adds a miner fee to the transaction, returning the index of the
 // miner fee within the transaction.
-func (tb *transactionBuilder) AddMinerFee(fee types.Currency) uint64 {
+func (tb *transactionBuilder) AddMinerFee(fee types.Currency) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.MinerFees = append(tb.transaction.MinerFees, fee)
-	return uint64(len(tb.transaction.MinerFees) - 1)
+	return uint64(len(tb.transaction.MinerFees) - 1), nil
 }
 
 // AddSiacoinInput adds a siacoin input to the transaction, returning the index
 // of the siacoin input within the transaction. When 'Sign' gets called, this
 // input will be left unsigned.
-func (tb *transactionBuilder) AddSiacoinInput(input types.SiacoinInput) uint64 {
+func (tb *transactionBuilder) AddSiacoinInput(input types.SiacoinInput) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.SiacoinInputs = append(tb.transaction.SiacoinInputs, input)
-	return uint64(len(tb.transaction.SiacoinInputs) - 1)
+	return uint64(len(tb.transaction.SiacoinInputs) - 1), nil
 }
 
 // AddSiacoinOutput adds a siacoin output to the transaction, returning the
 // index of the siacoin output within the transaction.
-func (tb *transactionBuilder) AddSiacoinOutput(output types.SiacoinOutput) uint64 {
+func (tb *transactionBuilder) AddSiacoinOutput(output types.SiacoinOutput) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.SiacoinOutputs = append(tb.transaction.SiacoinOutputs, output)
-	return uint64(len(tb.transaction.SiacoinOutputs) - 1)
+	return uint64(len(tb.transaction.SiacoinOutputs) - 1), nil
 }
 
 // AddFileContract adds a file contract to the transaction, returning the index
 // of the file contract within the transaction.
-func (tb *transactionBuilder) AddFileContract(fc types.FileContract) uint64 {
+func (tb *transactionBuilder) AddFileContract(fc types.FileContract) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.FileContracts = append(tb.transaction.FileContracts, fc)
-	return uint64(len(tb.transaction.FileContracts) - 1)
+	return uint64(len(tb.transaction.FileContracts) - 1), nil
 }
 
 // AddFileContractRevision adds a file contract revision to the transaction,
 // returning the index of the file contract revision within the transaction.
 // When 'Sign' gets called, this revision will be left unsigned.
-func (tb *transactionBuilder) AddFileContractRevision(fcr types.FileContractRevision) uint64 {
+func (tb *transactionBuilder) AddFileContractRevision(fcr types.FileContractRevision) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.FileContractRevisions = append(tb.transaction.FileContractRevisions, fcr)
-	return uint64(len(tb.transaction.FileContractRevisions) - 1)
+	return uint64(len(tb.transaction.FileContractRevisions) - 1), nil
 }
 
 // AddStorageProof adds a storage proof to the transaction, returning the index
 // of the storage proof within the transaction.
-func (tb *transactionBuilder) AddStorageProof(sp types.StorageProof) uint64 {
+func (tb *transactionBuilder) AddStorageProof(sp types.StorageProof) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.StorageProofs = append(tb.transaction.StorageProofs, sp)
-	return uint64(len(tb.transaction.StorageProofs) - 1)
+	return uint64(len(tb.transaction.StorageProofs) - 1), nil
 }
 
 // AddSiafundInput adds a siafund input to the transaction, returning the index
 // of the siafund input within the transaction. When 'Sign' is called, this
 // input will be left unsigned.
-func (tb *transactionBuilder) AddSiafundInput(input types.SiafundInput) uint64 {
+func (tb *transactionBuilder) AddSiafundInput(input types.SiafundInput) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.SiafundInputs = append(tb.transaction.SiafundInputs, input)
-	return uint64(len(tb.transaction.SiafundInputs) - 1)
+	return uint64(len(tb.transaction.SiafundInputs) - 1), nil
 }
 
 // AddSiafundOutput adds a siafund output to the transaction, returning the
 // index of the siafund output within the transaction.
-func (tb *transactionBuilder) AddSiafundOutput(output types.SiafundOutput) uint64 {
+func (tb *transactionBuilder) AddSiafundOutput(output types.SiafundOutput) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.SiafundOutputs = append(tb.transaction.SiafundOutputs, output)
-	return uint64(len(tb.transaction.SiafundOutputs) - 1)
+	return uint64(len(tb.transaction.SiafundOutputs) - 1), nil
 }
 
 // AddArbitraryData adds arbitrary data to the transaction, returning the index
 // of the data within the transaction.
-func (tb *transactionBuilder) AddArbitraryData(arb []byte) uint64 {
+func (tb *transactionBuilder) AddArbitraryData(arb []byte) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.ArbitraryData = append(tb.transaction.ArbitraryData, arb)
-	return uint64(len(tb.transaction.ArbitraryData) - 1)
+	return uint64(len(tb.transaction.ArbitraryData) - 1), nil
 }
 
 // AddTransactionSignature adds a transaction signature to the transaction,
 // returning the index of the signature within the transaction. The signature
 // should already be valid, and shouldn't sign any of the inputs that were
 // added by calling 'FundSiacoins' or 'FundSiafunds'.
-func (tb *transactionBuilder) AddTransactionSignature(sig types.TransactionSignature) uint64 {
+func (tb *transactionBuilder) AddTransactionSignature(sig types.TransactionSignature) (uint64, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	if tb.dropped {
+		return 0, errBuilderDropped
+	}
 	tb.transaction.TransactionSignatures = append(tb.transaction.TransactionSignatures, sig)
-	return uint64(len(tb.transaction.TransactionSignatures) - 1)
+	return uint64(len(tb.transaction.TransactionSignatures) - 1), nil
 }
 
 // Drop discards all of the outputs in a transaction, returning them to the
 // pool so that other transactions may use them. 'Drop' should only be called
-// if a transaction is both unsigned and will not be used any further.
+// if a transaction is both unsigned and will not be used any further. After
+// 'Drop' has been called, every method that funds, modifies or signs the
+// transaction returns errBuilderDropped.
 func (tb *transactionBuilder) Drop() {
 	tb.wallet.mu.Lock()
 	defer tb.wallet.mu.Unlock()
+	tb.drop()
+}
 
+// drop returns the outputs claimed by the builder to the wallet. The wallet
+// must be locked by the caller.
+func (tb *transactionBuilder) drop() {
 	// Iterate through all parents and the transaction itself and restore all
 	// outputs to the list of available outputs.
 	txns := append(tb.parents, tb.transaction)
@@ -421,10 +672,18 @@ func (tb *transactionBuilder) Drop() {
 		for _, sci := range txn.SiacoinInputs {
 			delete(tb.wallet.spentOutputs, types.OutputID(sci.ParentID))
 		}
+		for _, sfi := range txn.SiafundInputs {
+			delete(tb.wallet.spentOutputs, types.OutputID(sfi.ParentID))
+		}
+	}
+	err := tb.wallet.saveSettings()
+	if err != nil {
+		tb.wallet.log.Println("ERROR: failed to save the wallet after dropping a transaction:", err)
 	}
 
 	tb.parents = nil
 	tb.signed = false
+	tb.dropped = true
 	tb.transaction = types.Transaction{}
 
 	tb.newParents = nil
@@ -447,9 +706,25 @@ func (tb *transactionBuilder) Drop() {
 // Sign should not be called more than once. If, for some reason, there is an
 // error while calling Sign, the builder should be dropped.
 func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction, error) {
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+	return tb.sign(wholeTransaction)
+}
+
+// sign signs the inputs added by the builder, see 'Sign'. The wallet must be
+// locked by the caller.
+func (tb *transactionBuilder) sign(wholeTransaction bool) ([]types.Transaction, error) {
+	if tb.dropped {
+		return nil, errBuilderDropped
+	}
 	if tb.signed {
 		return nil, errBuilderAlreadySigned
 	}
+	// The secret keys are wiped while the wallet is locked, so no signatures
+	// could be added.
+	if !tb.wallet.unlocked {
+		return nil, modules.ErrLockedWallet
+	}
 
 	// Create the coveredfields struct.
 	var coveredFields types.CoveredFields
@@ -492,11 +767,15 @@ func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction,
 
 	// For each siacoin input in the transaction that we added, provide a
 	// signature.
-	tb.wallet.mu.Lock()
-	defer tb.wallet.mu.Unlock()
+	tb.wallet.markActivity()
 	for _, inputIndex := range tb.siacoinInputs {
 		input := tb.transaction.SiacoinInputs[inputIndex]
-		key := tb.wallet.keys[input.UnlockConditions.UnlockHash()]
+		key, exists := tb.wallet.keys[input.UnlockConditions.UnlockHash()]
+		if !exists {
+			// Inputs from multisig addresses are signed with whichever keys
+			// the wallet holds for them.
+			key = tb.wallet.multisigKey(tb.wallet.multisigAddresses[input.UnlockConditions.UnlockHash()])
+		}
 		newSigIndices, err := addSignatures(&tb.transaction, coveredFields, input.UnlockConditions, crypto.Hash(input.ParentID), key)
 		if err != nil {
 			return nil, err
@@ -520,6 +799,70 @@ func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction,
 	return txnSet, nil
 }
 
+// BatchSign signs every transaction builder in the batch while holding the
+// wallet lock, so that the wallet cannot change between the signatures. Each
+// builder must have been created by the wallet, and no two builders may spend
+// the same output. The transaction sets are returned in the same order as the
+// builders. If any builder fails to sign, every builder in the batch is
+// dropped, returning all of the outputs that they reserved to the wallet.
+func (w *Wallet) BatchSign(masterKey crypto.TwofishKey, builders []modules.TransactionBuilder, wholeTransaction bool) ([][]types.Transaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return nil, modules.ErrLockedWallet
+	}
+	err := w.checkMasterKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	tbs := make([]*transactionBuilder, len(builders))
+	for i, builder := range builders {
+		tb, ok := builder.(*transactionBuilder)
+		if !ok || tb.wallet != w {
+			return nil, errForeignBuilder
+		}
+		tbs[i] = tb
+	}
+
+	// rollback drops every builder in the batch.
+	rollback := func() {
+		for _, tb := range tbs {
+			tb.drop()
+		}
+	}
+
+	// Check that no output is spent by more than one builder.
+	spent := make(map[types.OutputID]int)
+	for i, tb := range tbs {
+		for _, txn := range append(tb.parents, tb.transaction) {
+			var ids []types.OutputID
+			for _, sci := range txn.SiacoinInputs {
+				ids = append(ids, types.OutputID(sci.ParentID))
+			}
+			for _, sfi := range txn.SiafundInputs {
+				ids = append(ids, types.OutputID(sfi.ParentID))
+			}
+			for _, id := range ids {
+				if j, exists := spent[id]; exists && j != i {
+					rollback()
+					return nil, errBatchDoubleSpend
+				}
+				spent[id] = i
+			}
+		}
+	}
+
+	txnSets := make([][]types.Transaction, len(tbs))
+	for i, tb := range tbs {
+		txnSets[i], err = tb.sign(wholeTransaction)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+	return txnSets, nil
+}
+
 // ViewTransaction returns a transaction-in-progress along with all of its
 // parents, specified by id. An error is returned if the id is invalid.  Note
 // that ids become invalid for a transaction after 'SignTransaction' has been
@@ -528,6 +871,32 @@ func (tb *transactionBuilder) View() (types.Transaction, []types.Transaction) {
 	return tb.transaction, tb.parents
 }
 
+// viewSet returns the incomplete transaction set, with the parents followed
+// by the transaction, in the order that Sign returns it.
+func (tb *transactionBuilder) viewSet() []types.Transaction {
+	set := make([]types.Transaction, 0, len(tb.parents)+1)
+	set = append(set, tb.parents...)
+	return append(set, tb.transaction)
+}
+
+// ViewSize returns the encoded size in bytes of the transaction and its
+// parents. Signatures that are added by 'Sign' are not yet counted.
+func (tb *transactionBuilder) ViewSize() int {
+	return len(encoding.Marshal(tb.viewSet()))
+}
+
+// ViewFee returns the sum of the miner fees of the transaction and its
+// parents, which is the fee that the transaction pool considers for the set.
+func (tb *transactionBuilder) ViewFee() types.Currency {
+	var fee types.Currency
+	for _, txn := range tb.viewSet() {
+		for _, f := range txn.MinerFees {
+			fee = fee.Add(f)
+		}
+	}
+	return fee
+}
+
 // ViewAdded returns all of the siacoin inputs, siafund inputs, and parent
 // transactions that have been automatically added by the builder.
 func (tb *transactionBuilder) ViewAdded() (newParents, siacoinInputs, siafundInputs, transactionSignatures []int) {
@@ -3,6 +3,8 @@ package wallet
 import (
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -37,8 +39,8 @@ func TestViewAdded(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_ = b.AddMinerFee(txnFund)
-	_ = b.AddSiacoinOutput(types.SiacoinOutput{Value: txnFund})
+	b.AddMinerFee(txnFund)
+	b.AddSiacoinOutput(types.SiacoinOutput{Value: txnFund})
 	unfinishedTxn, unfinishedParents := b.View()
 
 	// Create a second builder that extends the first, unsigned transaction. Do
@@ -124,7 +126,7 @@ func TestDoubleSignError(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_ = b.AddMinerFee(txnFund)
+	b.AddMinerFee(txnFund)
 	txnSet, err := b.Sign(true)
 	if err != nil {
 		t.Fatal(err)
@@ -141,3 +143,508 @@ func TestDoubleSignError(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestSpentOutputsPersist checks that outputs reserved by an unsigned
+// transaction remain reserved after the wallet restarts, so that a new
+// transaction does not double spend them.
+func TestSpentOutputsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestSpentOutputsPersist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Mine extra blocks so that the wallet has several outputs to choose
+	// from.
+	for i := 0; i < 3; i++ {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reserve outputs by funding a transaction that is never signed.
+	b := wt.wallet.StartTransaction()
+	txnFund := types.NewCurrency64(100e9)
+	err = b.FundSiacoins(txnFund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, parents := b.View()
+	reserved := make(map[types.SiacoinOutputID]struct{})
+	for _, parent := range parents {
+		for _, sci := range parent.SiacoinInputs {
+			reserved[sci.ParentID] = struct{}{}
+		}
+	}
+	if len(reserved) == 0 {
+		t.Fatal("funding the transaction did not reserve any outputs")
+	}
+
+	// Restart the wallet and fund a second transaction.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2 := w.StartTransaction()
+	err = b2.FundSiacoins(txnFund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, parents2 := b2.View()
+	for _, parent := range parents2 {
+		for _, sci := range parent.SiacoinInputs {
+			if _, exists := reserved[sci.ParentID]; exists {
+				t.Fatal("restarted wallet reused an output reserved before the restart")
+			}
+		}
+	}
+
+	// After the respend timeout, the reservations should expire when the
+	// wallet is loaded.
+	for i := 0; i < RespendTimeout; i++ {
+		_, err = wt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	w2, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := range reserved {
+		if _, exists := w2.spentOutputs[types.OutputID(id)]; exists {
+			t.Error("expired reservation was not cleaned up on load")
+		}
+	}
+}
+
+// TestDropBuilder checks that dropping a transaction builder returns the
+// outputs it claimed to the wallet, and that a dropped builder cannot be used.
+func TestDropBuilder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestDropBuilder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	wt.wallet.mu.RLock()
+	spent := len(wt.wallet.spentOutputs)
+	wt.wallet.mu.RUnlock()
+	b := wt.wallet.StartTransaction()
+	err = b.FundSiacoins(types.NewCurrency64(1e3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.RLock()
+	if len(wt.wallet.spentOutputs) == spent {
+		t.Error("funding the builder did not reserve any outputs")
+	}
+	wt.wallet.mu.RUnlock()
+
+	b.Drop()
+	wt.wallet.mu.RLock()
+	if len(wt.wallet.spentOutputs) != spent {
+		t.Error("dropping the builder did not release the reserved outputs")
+	}
+	wt.wallet.mu.RUnlock()
+	if err := b.FundSiacoins(types.NewCurrency64(1e3)); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	if err := b.FundSiafunds(types.NewCurrency64(1)); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	if _, err := b.Sign(true); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	if err := b.FundSiacoinsIncludingFee(types.NewCurrency64(1e3), types.NewCurrency64(1)); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	if err := b.FundSiacoinsFrom(nil); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	if err := b.SetChangeAddress(types.UnlockHash{1}); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	if err := b.AddParents(nil); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+	adds := []func() (uint64, error){
+		func() (uint64, error) { return b.AddMinerFee(types.NewCurrency64(1)) },
+		func() (uint64, error) { return b.AddSiacoinInput(types.SiacoinInput{}) },
+		func() (uint64, error) { return b.AddSiacoinOutput(types.SiacoinOutput{}) },
+		func() (uint64, error) { return b.AddFileContract(types.FileContract{}) },
+		func() (uint64, error) { return b.AddFileContractRevision(types.FileContractRevision{}) },
+		func() (uint64, error) { return b.AddStorageProof(types.StorageProof{}) },
+		func() (uint64, error) { return b.AddSiafundInput(types.SiafundInput{}) },
+		func() (uint64, error) { return b.AddSiafundOutput(types.SiafundOutput{}) },
+		func() (uint64, error) { return b.AddArbitraryData(nil) },
+		func() (uint64, error) { return b.AddTransactionSignature(types.TransactionSignature{}) },
+	}
+	for i, add := range adds {
+		if _, err := add(); err != errBuilderDropped {
+			t.Errorf("add %v: expected errBuilderDropped, got %v", i, err)
+		}
+	}
+	if txn, _ := b.View(); len(txn.MinerFees) != 0 || len(txn.ArbitraryData) != 0 {
+		t.Error("dropped builder was modified")
+	}
+
+	// The released outputs should be usable by a new builder.
+	b2 := wt.wallet.StartTransaction()
+	err = b2.FundSiacoins(types.NewCurrency64(1e3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2.AddMinerFee(types.NewCurrency64(1e3))
+	txnSet, err := b2.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestChangeAddress checks that siacoin refunds are sent to the change address
+// of the builder when one is set.
+func TestChangeAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestChangeAddress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	b := wt.wallet.StartTransaction()
+	if b.SetChangeAddress(types.UnlockHash{}) != errInvalidChangeAddress {
+		t.Error("expected errInvalidChangeAddress")
+	}
+	changeAddr := types.UnlockHash{7}
+	err = b.SetChangeAddress(changeAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnFund := types.NewCurrency64(1e3)
+	err = b.FundSiacoins(txnFund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddMinerFee(txnFund)
+	txnSet, err := b.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, sco := range txnSet[0].SiacoinOutputs {
+		if sco.UnlockHash == changeAddr {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("refund was not sent to the change address")
+	}
+	err = wt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The refund does not belong to the wallet, so only the exact output of
+	// the parent transaction counts as incoming.
+	_, incoming := wt.wallet.UnconfirmedBalance()
+	if incoming.Cmp(txnFund) != 0 {
+		t.Error("refund to the change address was counted as incoming:", incoming)
+	}
+}
+
+// TestBatchSign checks that BatchSign signs every builder in the batch, and
+// that a failed batch releases the outputs reserved by all of the builders.
+func TestBatchSign(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestBatchSign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Mine a block so that the wallet has two spendable outputs, then fund
+	// two builders and sign them as a batch.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var builders []modules.TransactionBuilder
+	for i := 0; i < 2; i++ {
+		b := wt.wallet.StartTransaction()
+		err = b.FundSiacoins(types.NewCurrency64(1e3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddMinerFee(types.NewCurrency64(1e3))
+		builders = append(builders, b)
+	}
+	_, err = wt.wallet.BatchSign(crypto.TwofishKey{}, builders, true)
+	if err != modules.ErrBadEncryptionKey {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	txnSets, err := wt.wallet.BatchSign(wt.walletMasterKey, builders, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSets) != 2 {
+		t.Fatal("wrong number of transaction sets:", len(txnSets))
+	}
+	for _, txnSet := range txnSets {
+		err = wt.tpool.AcceptTransactionSet(txnSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create two builders that spend the same output, the batch should fail
+	// and release the outputs of both builders.
+	wt.wallet.mu.RLock()
+	spent := len(wt.wallet.spentOutputs)
+	wt.wallet.mu.RUnlock()
+	b1 := wt.wallet.StartTransaction()
+	err = b1.FundSiacoins(types.NewCurrency64(1e3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, _ := b1.View()
+	b2 := wt.wallet.StartTransaction()
+	err = b2.FundSiacoins(types.NewCurrency64(1e3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2.AddSiacoinInput(txn.SiacoinInputs[0])
+	_, err = wt.wallet.BatchSign(wt.walletMasterKey, []modules.TransactionBuilder{b1, b2}, true)
+	if err != errBatchDoubleSpend {
+		t.Fatal("expected errBatchDoubleSpend, got", err)
+	}
+	wt.wallet.mu.RLock()
+	if len(wt.wallet.spentOutputs) != spent {
+		t.Error("failed batch did not release the reserved outputs")
+	}
+	wt.wallet.mu.RUnlock()
+	if _, err := b1.Sign(true); err != errBuilderDropped {
+		t.Error("expected errBuilderDropped, got", err)
+	}
+}
+
+// TestFundSiacoinsFrom checks that FundSiacoinsFrom spends exactly the named
+// outputs and refuses outputs that are reserved or unknown.
+func TestFundSiacoinsFrom(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestFundSiacoinsFrom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	outputs, err := wt.wallet.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scoid types.SiacoinOutputID
+	var value types.Currency
+	for _, uo := range outputs {
+		if uo.FundType == types.SpecifierSiacoinOutput {
+			scoid = types.SiacoinOutputID(uo.ID)
+			value = uo.Value
+			break
+		}
+	}
+	if value.IsZero() {
+		t.Fatal("wallet has no spendable siacoin outputs")
+	}
+
+	// Unknown outputs and duplicated outputs should be rejected without
+	// adding any inputs.
+	b := wt.wallet.StartTransaction()
+	err = b.FundSiacoinsFrom([]types.SiacoinOutputID{scoid, {}})
+	if err != errUnknownOutput {
+		t.Fatal("expected errUnknownOutput, got", err)
+	}
+	err = b.FundSiacoinsFrom([]types.SiacoinOutputID{scoid, scoid})
+	if err != errOutputReserved {
+		t.Fatal("expected errOutputReserved, got", err)
+	}
+	if txn, _ := b.View(); len(txn.SiacoinInputs) != 0 {
+		t.Fatal("failed call added inputs to the transaction")
+	}
+
+	// Spend the full value of the output to a fresh address.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.FundSiacoinsFrom([]types.SiacoinOutputID{scoid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddSiacoinOutput(types.SiacoinOutput{Value: value, UnlockHash: uc.UnlockHash()})
+	txn, _ := b.View()
+	if len(txn.SiacoinInputs) != 1 || txn.SiacoinInputs[0].ParentID != scoid {
+		t.Fatal("transaction does not spend the named output")
+	}
+
+	// The output is now reserved.
+	b2 := wt.wallet.StartTransaction()
+	err = b2.FundSiacoinsFrom([]types.SiacoinOutputID{scoid})
+	if err != errOutputReserved {
+		t.Fatal("expected errOutputReserved, got", err)
+	}
+	b2.Drop()
+
+	txnSet, err := b.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.RLock()
+	_, exists := wt.wallet.siacoinOutputs[scoid]
+	wt.wallet.mu.RUnlock()
+	if exists {
+		t.Error("named output was not spent")
+	}
+}
+
+// TestViewSizeAndFee checks that ViewSize and ViewFee report the size and the
+// miner fees of the transaction along with its parents.
+func TestViewSizeAndFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestViewSizeAndFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	b := wt.wallet.StartTransaction()
+	if !b.ViewFee().IsZero() {
+		t.Error("empty builder should have no fee")
+	}
+	if b.ViewSize() != len(encoding.Marshal([]types.Transaction{{}})) {
+		t.Error("wrong size for an empty builder:", b.ViewSize())
+	}
+
+	// Fund the transaction, which adds a parent, and add two fees.
+	err = b.FundSiacoins(types.NewCurrency64(100e3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddMinerFee(types.NewCurrency64(40e3))
+	b.AddMinerFee(types.NewCurrency64(60e3))
+	if b.ViewFee().Cmp(types.NewCurrency64(100e3)) != 0 {
+		t.Error("wrong fee:", b.ViewFee())
+	}
+	txn, parents := b.View()
+	if b.ViewSize() != len(encoding.Marshal(append(parents, txn))) {
+		t.Error("size does not match the encoded transaction set:", b.ViewSize())
+	}
+
+	// The signed set should be at least as large as the reported size, and
+	// carry the reported fee.
+	size, fee := b.ViewSize(), b.ViewFee()
+	txnSet, err := b.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoding.Marshal(txnSet)) < size {
+		t.Error("signed set is smaller than the reported size")
+	}
+	var setFee types.Currency
+	for _, txn := range txnSet {
+		for _, f := range txn.MinerFees {
+			setFee = setFee.Add(f)
+		}
+	}
+	if setFee.Cmp(fee) != 0 {
+		t.Error("signed set has a different fee than reported:", setFee, fee)
+	}
+}
+
+// TestFundSiacoinsIncludingFee checks that funding an amount and a fee at once
+// produces a single input covering both and adds the fee to the transaction.
+func TestFundSiacoinsIncludingFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestFundSiacoinsIncludingFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// The fee is not added if the wallet cannot cover the combined total.
+	fee := types.NewCurrency64(20e3)
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+	b := wt.wallet.StartTransaction()
+	err = b.FundSiacoinsIncludingFee(balance, fee)
+	if err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+	if !b.ViewFee().IsZero() {
+		t.Error("fee was added after funding failed")
+	}
+
+	// Fund an amount that the wallet can cover.
+	amount := types.NewCurrency64(100e3)
+	b = wt.wallet.StartTransaction()
+	err = b.FundSiacoinsIncludingFee(amount, fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, parents := b.View()
+	if len(txn.SiacoinInputs) != 1 || len(parents) != 1 {
+		t.Fatal("expected a single input from a single parent")
+	}
+	if parents[0].SiacoinOutputs[0].Value.Cmp(amount.Add(fee)) != 0 {
+		t.Error("input does not cover the amount and the fee")
+	}
+	if len(parents[0].SiacoinOutputs) > 2 {
+		t.Error("parent should have at most one refund output")
+	}
+	if b.ViewFee().Cmp(fee) != 0 {
+		t.Error("wrong fee:", b.ViewFee())
+	}
+	b.AddSiacoinOutput(types.SiacoinOutput{Value: amount})
+	txnSet, err := b.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
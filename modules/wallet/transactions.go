@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"errors"
+	"sort"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -33,7 +34,7 @@ func (w *Wallet) AddressTransactions(uh types.UnlockHash) (pts []modules.Process
 			}
 		}
 		if relevant {
-			pts = append(pts, pt)
+			pts = append(pts, w.withNote(pt))
 		}
 	}
 	return pts
@@ -62,7 +63,7 @@ func (w *Wallet) AddressUnconfirmedTransactions(uh types.UnlockHash) (pts []modu
 			}
 		}
 		if relevant {
-			pts = append(pts, pt)
+			pts = append(pts, w.withNote(pt))
 		}
 	}
 	return pts
@@ -77,37 +78,108 @@ func (w *Wallet) Transaction(txid types.TransactionID) (modules.ProcessedTransac
 	if !exists {
 		return modules.ProcessedTransaction{}, exists
 	}
-	return *pt, exists
+	return w.withNote(*pt), exists
+}
+
+// transactionIter iterates over the confirmed transactions of a wallet that
+// fall within a range of confirmation heights.
+type transactionIter struct {
+	w         *Wallet
+	index     int
+	endHeight types.BlockHeight
+}
+
+// Next returns the next transaction in the range, and false if there are no
+// more transactions. The wallet lock is only held for the duration of the
+// call.
+func (ti *transactionIter) Next() (modules.ProcessedTransaction, bool) {
+	ti.w.mu.Lock()
+	defer ti.w.mu.Unlock()
+	if ti.index >= len(ti.w.processedTransactions) {
+		return modules.ProcessedTransaction{}, false
+	}
+	pt := ti.w.processedTransactions[ti.index]
+	if pt.ConfirmationHeight > ti.endHeight {
+		return modules.ProcessedTransaction{}, false
+	}
+	ti.index++
+	return ti.w.withNote(pt), true
 }
 
 // Transactions returns all transactions relevant to the wallet that were
 // confirmed in the range [startHeight, endHeight].
 func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+	iter, err := w.TransactionsIter(startHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+	for pt, ok := iter.Next(); ok; pt, ok = iter.Next() {
+		pts = append(pts, pt)
+	}
+	return pts, nil
+}
+
+// TransactionsIter returns an iterator over the transactions relevant to the
+// wallet that were confirmed in the range [startHeight, endHeight], in
+// chronological order. Transactions are fetched one at a time, so the full
+// history is never copied. Transactions confirmed while iterating are
+// returned if they fall within the range. If blocks are reverted while
+// iterating, some transactions may be skipped.
+func (w *Wallet) TransactionsIter(startHeight, endHeight types.BlockHeight) (modules.ProcessedTransactionIter, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	if startHeight > w.consensusSetHeight || startHeight > endHeight {
 		return nil, errOutOfBounds
 	}
-	if len(w.processedTransactions) == 0 {
-		return nil, nil
-	}
 
-	for _, pt := range w.processedTransactions {
-		if pt.ConfirmationHeight > endHeight {
-			break
-		}
-		if pt.ConfirmationHeight >= startHeight {
-			pts = append(pts, pt)
-		}
-	}
-	return pts, nil
+	// The processed transactions are sorted by confirmation height, so the
+	// first transaction in the range can be found with a binary search.
+	index := sort.Search(len(w.processedTransactions), func(i int) bool {
+		return w.processedTransactions[i].ConfirmationHeight >= startHeight
+	})
+	return &transactionIter{
+		w:         w,
+		index:     index,
+		endHeight: endHeight,
+	}, nil
 }
 
 // UnconfirmedTransactions returns the set of unconfirmed transactions that are
-// relevant to the wallet.
+// relevant to the wallet. Transactions that were replaced by a fee bump follow
+// the transactions in the pool, marked as replaced.
 func (w *Wallet) UnconfirmedTransactions() []modules.ProcessedTransaction {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return w.unconfirmedProcessedTransactions
+	if len(w.unconfirmedProcessedTransactions)+len(w.replacedTransactions) == 0 {
+		return w.unconfirmedProcessedTransactions
+	}
+	pts := make([]modules.ProcessedTransaction, 0, len(w.unconfirmedProcessedTransactions)+len(w.replacedTransactions))
+	for _, pt := range w.unconfirmedProcessedTransactions {
+		pts = append(pts, w.withNote(pt))
+	}
+	for _, pt := range w.replacedTransactions {
+		pts = append(pts, w.withNote(pt))
+	}
+	return pts
+}
+
+// withNote returns the processed transaction with its note filled in.
+func (w *Wallet) withNote(pt modules.ProcessedTransaction) modules.ProcessedTransaction {
+	pt.Note = w.transactionNotes[pt.TransactionID]
+	return pt
+}
+
+// SetTransactionNote attaches a local note to the transaction with the given
+// id, replacing any existing note. An empty note removes the existing note.
+// Notes are saved alongside the wallet settings and are never broadcast.
+func (w *Wallet) SetTransactionNote(txid types.TransactionID, note string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if note == "" {
+		delete(w.transactionNotes, txid)
+	} else {
+		w.transactionNotes[txid] = note
+	}
+	return w.saveSettingsSync()
 }
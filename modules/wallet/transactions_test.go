@@ -1,8 +1,10 @@
 package wallet
 
 import (
+	"sort"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -87,6 +89,76 @@ func TestIntegrationTransactions(t *testing.T) {
 	}
 }
 
+// TestIntegrationTransactionsIter checks that the transaction iterator returns
+// the same transactions as Transactions, in chronological order, and picks up
+// transactions that are confirmed while iterating.
+func TestIntegrationTransactionsIter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationTransactionsIter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	_, err = wt.wallet.TransactionsIter(5, 4)
+	if err != errOutOfBounds {
+		t.Fatal("expected errOutOfBounds, got", err)
+	}
+
+	// Iterate over part of the history.
+	txns, err := wt.wallet.Transactions(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter, err := wt.wallet.TransactionsIter(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var iterTxns []modules.ProcessedTransaction
+	for pt, ok := iter.Next(); ok; pt, ok = iter.Next() {
+		iterTxns = append(iterTxns, pt)
+	}
+	if len(iterTxns) != len(txns) || len(txns) != 3 {
+		t.Fatal("iterator returned the wrong number of transactions:", len(iterTxns), len(txns))
+	}
+	for i := range txns {
+		if iterTxns[i].TransactionID != txns[i].TransactionID {
+			t.Error("iterator returned transactions in the wrong order")
+		}
+		if i > 0 && iterTxns[i].ConfirmationHeight < iterTxns[i-1].ConfirmationHeight {
+			t.Error("iterator is not chronological")
+		}
+	}
+	if _, ok := iter.Next(); ok {
+		t.Error("exhausted iterator returned a transaction")
+	}
+
+	// Transactions that are confirmed while iterating should be returned.
+	iter, err = wt.wallet.TransactionsIter(0, 1e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ok := iter.Next(); ok; _, ok = iter.Next() {
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, ok := iter.Next()
+	if !ok {
+		t.Fatal("iterator did not return the newly confirmed transaction")
+	}
+	txns, err = wt.wallet.Transactions(0, 1e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.TransactionID != txns[len(txns)-1].TransactionID {
+		t.Error("iterator returned the wrong transaction")
+	}
+}
+
 // TestIntegrationAddressTransactions checks grabbing the history for a single
 // address.
 func TestIntegrationAddressTransactions(t *testing.T) {
@@ -131,3 +203,88 @@ func TestIntegrationAddressTransactions(t *testing.T) {
 		t.Error("addresses unconfirmed transactions should be empty")
 	}
 }
+
+// TestIntegrationTransactionNotes checks that notes attached to transactions
+// are returned with the transaction history and survive a restart.
+func TestIntegrationTransactionNotes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationTransactionNotes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := sendTxns[len(sendTxns)-1].ID()
+	err = wt.wallet.SetTransactionNote(txid, "rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, pt := range wt.wallet.UnconfirmedTransactions() {
+		if pt.TransactionID == txid {
+			found = true
+			if pt.Note != "rent" {
+				t.Error("unconfirmed transaction has the wrong note:", pt.Note)
+			}
+		} else if pt.Note != "" {
+			t.Error("note was attached to the wrong transaction")
+		}
+	}
+	if !found {
+		t.Fatal("sent transaction was not in the unconfirmed history")
+	}
+
+	// The note should be present once the transaction is confirmed.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, exists := wt.wallet.Transaction(txid)
+	if !exists {
+		t.Fatal("sent transaction was not confirmed")
+	}
+	if pt.Note != "rent" {
+		t.Error("confirmed transaction has the wrong note:", pt.Note)
+	}
+
+	// The note should survive a restart.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.transactionNotes[txid] != "rent" {
+		t.Error("note was not persisted:", w.transactionNotes[txid])
+	}
+
+	// Setting an empty note should remove it.
+	err = wt.wallet.SetTransactionNote(txid, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, _ = wt.wallet.Transaction(txid)
+	if pt.Note != "" {
+		t.Error("note was not removed:", pt.Note)
+	}
+}
+
+// TestPersistTransactionNotesOrder checks that the transaction notes are
+// persisted sorted by transaction id.
+func TestPersistTransactionNotesOrder(t *testing.T) {
+	w := &Wallet{transactionNotes: make(map[types.TransactionID]string)}
+	for i := 0; i < 20; i++ {
+		w.transactionNotes[types.TransactionID{byte(20 - i)}] = "note"
+	}
+	w.updatePersistTransactionNotes()
+	if len(w.persist.TransactionNotes) != 20 {
+		t.Fatal("wrong number of notes persisted:", len(w.persist.TransactionNotes))
+	}
+	if !sort.IsSorted(transactionNotesByID(w.persist.TransactionNotes)) {
+		t.Error("transaction notes were not sorted by id")
+	}
+}
@@ -51,28 +51,36 @@ type SavedKey033x struct {
 	Visible          bool
 }
 
+// decryptSpendableKeyFile decrypts a spendable key file using the master key.
+func decryptSpendableKeyFile(masterKey crypto.TwofishKey, uk SpendableKeyFile) (sk spendableKey, err error) {
+	// Verify that the decryption key is correct.
+	encKey := uidEncryptionKey(masterKey, uk.UID)
+	expectedDecryptedVerification := make([]byte, crypto.EntropySize)
+	decryptedVerification, err := encKey.DecryptBytes(uk.EncryptionVerification)
+	if err != nil {
+		return spendableKey{}, err
+	}
+	if !bytes.Equal(expectedDecryptedVerification, decryptedVerification) {
+		return spendableKey{}, modules.ErrBadEncryptionKey
+	}
+
+	// Decrypt the spendable key.
+	encodedKey, err := encKey.DecryptBytes(uk.SpendableKey)
+	if err != nil {
+		return spendableKey{}, err
+	}
+	err = encoding.Unmarshal(encodedKey, &sk)
+	if err != nil {
+		return spendableKey{}, err
+	}
+	return sk, nil
+}
+
 // initUnseededKeys loads all of the unseeded keys into the wallet after the
 // wallet gets unlocked.
 func (w *Wallet) initUnseededKeys(masterKey crypto.TwofishKey) error {
 	for _, uk := range w.persist.UnseededKeys {
-		// Verify that the decryption key is correct.
-		encKey := uidEncryptionKey(masterKey, uk.UID)
-		expectedDecryptedVerification := make([]byte, crypto.EntropySize)
-		decryptedVerification, err := encKey.DecryptBytes(uk.EncryptionVerification)
-		if err != nil {
-			return err
-		}
-		if !bytes.Equal(expectedDecryptedVerification, decryptedVerification) {
-			return modules.ErrBadEncryptionKey
-		}
-
-		// Decrypt the spendable key and add it to the wallet.
-		encodedKey, err := encKey.DecryptBytes(uk.SpendableKey)
-		if err != nil {
-			return err
-		}
-		var sk spendableKey
-		err = encoding.Unmarshal(encodedKey, &sk)
+		sk, err := decryptSpendableKeyFile(masterKey, uk)
 		if err != nil {
 			return err
 		}
@@ -12,43 +12,69 @@ import (
 // outputs as understood by the wallet.
 func (w *Wallet) updateConfirmedSet(cc modules.ConsensusChange) {
 	for _, diff := range cc.SiacoinOutputDiffs {
-		// Verify that the diff is relevant to the wallet.
-		_, exists := w.keys[diff.SiacoinOutput.UnlockHash]
-		if !exists {
-			continue
+		// Verify that the diff is relevant to the wallet. Outputs of
+		// watch-only addresses are kept apart from the spendable outputs.
+		outputs := w.siacoinOutputs
+		if _, exists := w.keys[diff.SiacoinOutput.UnlockHash]; !exists {
+			if _, watched := w.watchedAddresses[diff.SiacoinOutput.UnlockHash]; !watched {
+				continue
+			}
+			outputs = w.watchedSiacoinOutputs
 		}
 
-		_, exists = w.siacoinOutputs[diff.ID]
+		_, exists := outputs[diff.ID]
 		if diff.Direction == modules.DiffApply {
 			if build.DEBUG && exists {
 				panic("adding an existing output to wallet")
 			}
-			w.siacoinOutputs[diff.ID] = diff.SiacoinOutput
+			outputs[diff.ID] = diff.SiacoinOutput
 		} else {
 			if build.DEBUG && !exists {
 				panic("deleting nonexisting output from wallet")
 			}
-			delete(w.siacoinOutputs, diff.ID)
+			delete(outputs, diff.ID)
 		}
 	}
 	for _, diff := range cc.SiafundOutputDiffs {
 		// Verify that the diff is relevant to the wallet.
-		_, exists := w.keys[diff.SiafundOutput.UnlockHash]
-		if !exists {
-			continue
+		outputs := w.siafundOutputs
+		if _, exists := w.keys[diff.SiafundOutput.UnlockHash]; !exists {
+			if _, watched := w.watchedAddresses[diff.SiafundOutput.UnlockHash]; !watched {
+				continue
+			}
+			outputs = w.watchedSiafundOutputs
 		}
 
-		_, exists = w.siafundOutputs[diff.ID]
+		_, exists := outputs[diff.ID]
 		if diff.Direction == modules.DiffApply {
 			if build.DEBUG && exists {
 				panic("adding an existing output to wallet")
 			}
-			w.siafundOutputs[diff.ID] = diff.SiafundOutput
+			outputs[diff.ID] = diff.SiafundOutput
 		} else {
 			if build.DEBUG && !exists {
 				panic("deleting nonexisting output from wallet")
 			}
-			delete(w.siafundOutputs, diff.ID)
+			delete(outputs, diff.ID)
+		}
+	}
+	for _, diff := range cc.DelayedSiacoinOutputDiffs {
+		// Verify that the diff is relevant to the wallet.
+		if _, exists := w.keys[diff.SiacoinOutput.UnlockHash]; !exists {
+			continue
+		}
+
+		_, exists := w.delayedSiacoinOutputs[diff.ID]
+		if diff.Direction == modules.DiffApply {
+			if build.DEBUG && exists {
+				panic("adding an existing delayed output to wallet")
+			}
+			w.delayedSiacoinOutputs[diff.ID] = diff
+		} else {
+			if build.DEBUG && !exists {
+				panic("deleting nonexisting delayed output from wallet")
+			}
+			delete(w.delayedSiacoinOutputs, diff.ID)
 		}
 	}
 	for _, diff := range cc.SiafundPoolDiffs {
@@ -212,8 +238,10 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	w.updateConfirmedSet(cc)
+	w.updateConfirmationSubscribers(cc)
 	w.revertHistory(cc)
 	w.applyHistory(cc)
+	w.updateChangeIDs(cc)
 }
 
 // ReceiveUpdatedUnconfirmedTransactions updates the wallet's unconfirmed
@@ -235,7 +263,8 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction,
 		}
 		for _, sci := range txn.SiacoinInputs {
 			_, exists := w.keys[sci.UnlockConditions.UnlockHash()]
-			if exists {
+			_, watched := w.watchedAddresses[sci.UnlockConditions.UnlockHash()]
+			if exists || watched {
 				relevant = true
 			}
 			pt.Inputs = append(pt.Inputs, modules.ProcessedInput{
@@ -247,7 +276,8 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction,
 		}
 		for i, sco := range txn.SiacoinOutputs {
 			_, exists := w.keys[sco.UnlockHash]
-			if exists {
+			_, watched := w.watchedAddresses[sco.UnlockHash]
+			if exists || watched {
 				relevant = true
 			}
 			pt.Outputs = append(pt.Outputs, modules.ProcessedOutput{
@@ -269,4 +299,18 @@ func (w *Wallet) ReceiveUpdatedUnconfirmedTransactions(txns []types.Transaction,
 			w.unconfirmedProcessedTransactions = append(w.unconfirmedProcessedTransactions, pt)
 		}
 	}
+
+	// Forget the replaced transactions whose replacement has been confirmed
+	// or dropped from the transaction pool.
+	unconfirmed := make(map[types.TransactionID]struct{})
+	for _, txn := range txns {
+		unconfirmed[txn.ID()] = struct{}{}
+	}
+	var replaced []modules.ProcessedTransaction
+	for _, pt := range w.replacedTransactions {
+		if _, exists := unconfirmed[pt.ReplacedBy]; exists {
+			replaced = append(replaced, pt)
+		}
+	}
+	w.replacedTransactions = replaced
 }
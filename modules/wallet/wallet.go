@@ -5,10 +5,13 @@ package wallet
 
 import (
 	"errors"
+	"io"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
@@ -32,9 +35,27 @@ var (
 // matched to the corresponding public keys in the unlock conditions. All
 // addresses that are to be used in 'FundSiacoins' or 'FundSiafunds' in the
 // transaction builder must conform to this form of spendable key.
+//
+// Keys that were generated from a seed also record which seed generated them,
+// and their index within that seed. The seed is identified by seedID, which
+// is the zero hash for keys that did not come from a seed. The location is
+// not part of the encoding of the key.
 type spendableKey struct {
 	UnlockConditions types.UnlockConditions
 	SecretKeys       []crypto.SecretKey
+
+	seedID   crypto.Hash
+	keyIndex uint64
+}
+
+// MarshalSia implements the encoding.SiaMarshaler interface.
+func (sk spendableKey) MarshalSia(w io.Writer) error {
+	return encoding.NewEncoder(w).EncodeAll(sk.UnlockConditions, sk.SecretKeys)
+}
+
+// UnmarshalSia implements the encoding.SiaUnmarshaler interface.
+func (sk *spendableKey) UnmarshalSia(r io.Reader) error {
+	return encoding.NewDecoder(r).DecodeAll(&sk.UnlockConditions, &sk.SecretKeys)
 }
 
 // Wallet is an object that tracks balances, creates keys and addresses,
@@ -44,11 +65,14 @@ type Wallet struct {
 	// in memory. subscribed indicates whether the wallet has subscribed to the
 	// consensus set yet - the wallet is unable to subscribe to the consensus
 	// set until it has been unlocked for the first time. The primary seed is
-	// used to generate new addresses for the wallet.
+	// used to generate new addresses for the wallet. The master key is kept
+	// while the wallet is unlocked so that a new primary seed can be
+	// encrypted when the current one runs out of addresses.
 	unlocked    bool
 	subscribed  bool
 	persist     WalletPersist
 	primarySeed modules.Seed
+	masterKey   crypto.TwofishKey
 
 	// The wallet's dependencies. The items 'consensusSetHeight' and
 	// 'siafundPool' are tracked separately from the consensus set to minimize
@@ -59,6 +83,12 @@ type Wallet struct {
 	consensusSetHeight types.BlockHeight
 	siafundPool        types.Currency
 
+	// changeIDs holds the consensus changes of the current path, in order,
+	// along with the height that the wallet reached by processing each of
+	// them. It allows the consensus changes following a height to be
+	// replayed by Rescan.
+	changeIDs []pathChange
+
 	// The following set of fields are responsible for tracking the confirmed
 	// outputs, and for being able to spend them. The seeds are used to derive
 	// the keys that are tracked on the blockchain. All keys are pregenerated
@@ -74,6 +104,27 @@ type Wallet struct {
 	siafundOutputs map[types.SiafundOutputID]types.SiafundOutput
 	spentOutputs   map[types.OutputID]types.BlockHeight
 
+	// delayedSiacoinOutputs are the miner payouts and file contract payouts
+	// of the wallet that have not matured yet. They are kept so that the
+	// wallet can project its balance at future heights.
+	delayedSiacoinOutputs map[types.SiacoinOutputID]modules.DelayedSiacoinOutputDiff
+
+	// Outputs sent to watch-only addresses are tracked separately from the
+	// spendable outputs, so that they are never used to fund transactions.
+	// They only count towards the confirmed balance if
+	// 'includeWatchedBalance' is set.
+	watchedAddresses      map[types.UnlockHash]struct{}
+	watchedSiacoinOutputs map[types.SiacoinOutputID]types.SiacoinOutput
+	watchedSiafundOutputs map[types.SiafundOutputID]types.SiafundOutput
+	includeWatchedBalance bool
+
+	// multisigAddresses are watched addresses whose unlock conditions are
+	// known to the wallet. Their outputs can be added to transactions, and
+	// are signed with whichever of the keys the wallet holds. Unlike other
+	// watched addresses, their balance is always part of the confirmed
+	// balance.
+	multisigAddresses map[types.UnlockHash]multisigAddress
+
 	// The following fields are kept to track transaction history.
 	// walletTransactions are stored in chronological order, and have a map for
 	// constant time random access. The set of full transactions is kept as
@@ -90,11 +141,52 @@ type Wallet struct {
 	processedTransactionMap          map[types.TransactionID]*modules.ProcessedTransaction
 	unconfirmedProcessedTransactions []modules.ProcessedTransaction
 
+	// replacedTransactions are the unconfirmed transactions of the wallet
+	// that were replaced by BumpFee, kept while the replacing transaction is
+	// unconfirmed.
+	replacedTransactions []modules.ProcessedTransaction
+
 	// TODO: Storing the whole set of historic outputs is expensive and
 	// unnecessary. There's a better way to do it.
 	historicOutputs     map[types.OutputID]types.Currency
 	historicClaimStarts map[types.SiafundOutputID]types.Currency
 
+	// transactionNotes are local notes that the user has attached to
+	// transactions.
+	transactionNotes map[types.TransactionID]string
+
+	// Seed exhaustion subscribers are warned when the progress of the primary
+	// seed crosses 'seedExhaustionThreshold' percent of the addresses
+	// available to the seed.
+	seedExhaustionThreshold      uint64
+	seedExhaustionSubscribers    map[int]chan modules.SeedExhaustionWarning
+	nextSeedExhaustionSubscriber int
+
+	// Confirmation subscribers are notified when a transaction is included
+	// in a block, and again if that block is reverted.
+	pendingConfirmations   map[types.TransactionID]pendingSubscription
+	confirmedSubscriptions map[types.TransactionID]confirmedSubscription
+
+	// Siacoin outputs below the dust threshold are never created by the
+	// wallet. Change that would fall below the threshold is added to the
+	// miner fees instead.
+	dustThreshold types.Currency
+
+	// The wallet locks itself once 'autoLockTimeout' has passed since
+	// 'lastActivity'. 'autoLockTimer' fires the next auto-lock check, and
+	// incrementing 'autoLockGeneration' invalidates a check that has already
+	// fired.
+	autoLockTimeout    time.Duration
+	autoLockGeneration uint64
+	autoLockTimer      *time.Timer
+	lastActivity       time.Time
+
+	// The keypool holds upcoming keys of the primary seed that have been
+	// derived ahead of time by a background thread.
+	keypool          []pooledKey
+	keypoolSize      int
+	keypoolRefilling bool
+
 	persistDir string
 	log        *persist.Logger
 	mu         sync.RWMutex
@@ -118,15 +210,33 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir stri
 		cs:    cs,
 		tpool: tpool,
 
+		changeIDs: []pathChange{{id: modules.ConsensusChangeBeginning}},
+
 		keys:           make(map[types.UnlockHash]spendableKey),
 		siacoinOutputs: make(map[types.SiacoinOutputID]types.SiacoinOutput),
 		siafundOutputs: make(map[types.SiafundOutputID]types.SiafundOutput),
 		spentOutputs:   make(map[types.OutputID]types.BlockHeight),
 
+		delayedSiacoinOutputs: make(map[types.SiacoinOutputID]modules.DelayedSiacoinOutputDiff),
+
+		watchedAddresses:      make(map[types.UnlockHash]struct{}),
+		watchedSiacoinOutputs: make(map[types.SiacoinOutputID]types.SiacoinOutput),
+		watchedSiafundOutputs: make(map[types.SiafundOutputID]types.SiafundOutput),
+		multisigAddresses:     make(map[types.UnlockHash]multisigAddress),
+
 		processedTransactionMap: make(map[types.TransactionID]*modules.ProcessedTransaction),
 
 		historicOutputs:     make(map[types.OutputID]types.Currency),
 		historicClaimStarts: make(map[types.SiafundOutputID]types.Currency),
+		transactionNotes:    make(map[types.TransactionID]string),
+
+		seedExhaustionThreshold:   defaultSeedExhaustionThreshold,
+		seedExhaustionSubscribers: make(map[int]chan modules.SeedExhaustionWarning),
+
+		pendingConfirmations:   make(map[types.TransactionID]pendingSubscription),
+		confirmedSubscriptions: make(map[types.TransactionID]confirmedSubscription),
+
+		keypoolSize: defaultKeypoolSize,
 
 		persistDir: persistDir,
 	}
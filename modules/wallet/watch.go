@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errDuplicateWatchAddress = errors.New("address is already being watched")
+	errWatchOnlyFunds        = errors.New("amount can only be covered by funds at watch-only addresses, which the wallet cannot spend")
+	errWatchOnlyOutput       = errors.New("output belongs to a watch-only address, which the wallet cannot spend")
+	errWatchSpendableAddress = errors.New("address is spendable by the wallet and does not need to be watched")
+)
+
+// loadWatchedAddresses restores the watched addresses from the wallet's
+// persist object.
+func (w *Wallet) loadWatchedAddresses() {
+	for _, addr := range w.persist.WatchedAddresses {
+		w.watchedAddresses[addr] = struct{}{}
+	}
+}
+
+// watchedBalance returns the confirmed balance held by the watch-only
+// addresses of the wallet. If 'all' is false, only the balance of the
+// multisig addresses created by the wallet is returned.
+func (w *Wallet) watchedBalance(all bool) (siacoinBalance types.Currency, siafundBalance types.Currency, siafundClaimBalance types.Currency) {
+	for _, sco := range w.watchedSiacoinOutputs {
+		if _, isMultisig := w.multisigAddresses[sco.UnlockHash]; !all && !isMultisig {
+			continue
+		}
+		siacoinBalance = siacoinBalance.Add(sco.Value)
+	}
+	for _, sfo := range w.watchedSiafundOutputs {
+		if _, isMultisig := w.multisigAddresses[sfo.UnlockHash]; !all && !isMultisig {
+			continue
+		}
+		siafundBalance = siafundBalance.Add(sfo.Value)
+		siafundClaimBalance = siafundClaimBalance.Add(w.siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value).Div(types.SiafundCount))
+	}
+	return
+}
+
+// watchAddress starts tracking 'addr' as a watch-only address right away, and
+// has the transaction pool send the wallet the unconfirmed transactions that
+// are relevant to the address. The wallet lock must be held.
+func (w *Wallet) watchAddress(addr types.UnlockHash) {
+	w.watchedAddresses[addr] = struct{}{}
+	w.tpool.RegisterWatchedAddresses([]types.UnlockHash{addr})
+}
+
+// AddWatchAddress adds an address that the wallet tracks but cannot spend
+// from, such as an address whose keys are kept in cold storage. Outputs sent to
+// the address are tracked from the next block onwards, and unconfirmed
+// transactions involving the address are reported right away. Outputs that were
+// created before the address was added are only found by a Rescan from a
+// height below their creation.
+func (w *Wallet) AddWatchAddress(addr types.UnlockHash) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	}
+	if _, exists := w.keys[addr]; exists {
+		return errWatchSpendableAddress
+	}
+	for _, watched := range w.persist.WatchedAddresses {
+		if watched == addr {
+			return errDuplicateWatchAddress
+		}
+	}
+	w.persist.WatchedAddresses = append(w.persist.WatchedAddresses, addr)
+	w.watchAddress(addr)
+	return w.saveSettingsSync()
+}
+
+// WatchedAddresses returns the watch-only addresses of the wallet, sorted in
+// byte-order.
+func (w *Wallet) WatchedAddresses() []types.UnlockHash {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	addrs := make(types.UnlockHashSlice, len(w.persist.WatchedAddresses))
+	copy(addrs, w.persist.WatchedAddresses)
+	sort.Sort(addrs)
+	return addrs
+}
+
+// SetIncludeWatchedBalance sets whether ConfirmedBalance includes the balance
+// of the watch-only addresses. Watched balances are excluded by default,
+// except for the balance of the multisig addresses created by the wallet,
+// which is always included.
+func (w *Wallet) SetIncludeWatchedBalance(include bool) {
+	w.mu.Lock()
+	w.includeWatchedBalance = include
+	w.mu.Unlock()
+}
@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestIntegrationWatchAddress checks that the wallet tracks the balance of a
+// watch-only address without ever spending from it.
+func TestIntegrationWatchAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationWatchAddress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Watch an address that the wallet does not have the keys for.
+	watchAddr := types.UnlockHash{1, 2, 3}
+	err = wt.wallet.AddWatchAddress(watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.AddWatchAddress(watchAddr)
+	if err != errDuplicateWatchAddress {
+		t.Error("expected errDuplicateWatchAddress, got", err)
+	}
+	err = wt.wallet.AddWatchAddress(wt.wallet.AllAddresses()[0])
+	if err != errWatchSpendableAddress {
+		t.Error("expected errWatchSpendableAddress, got", err)
+	}
+	watched := wt.wallet.WatchedAddresses()
+	if len(watched) != 1 || watched[0] != watchAddr {
+		t.Error("wrong watched addresses:", watched)
+	}
+
+	// Send money to the watched address and confirm it.
+	amount := types.NewCurrency64(5000)
+	_, err = wt.wallet.SendSiacoins(amount, watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The address is tracked without reloading the wallet.
+	spendable, _, _ := wt.wallet.ConfirmedBalance()
+	wt.wallet.SetIncludeWatchedBalance(true)
+	total, _, _ := wt.wallet.ConfirmedBalance()
+	wt.wallet.SetIncludeWatchedBalance(false)
+	if total.Cmp(spendable.Add(amount)) != 0 {
+		t.Fatalf("watched balance should be %v, got %v", amount, total.Sub(spendable))
+	}
+
+	// The address is still tracked once the wallet is reloaded.
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(wt.walletMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.WatchedAddresses()) != 1 {
+		t.Fatal("watched address was not persisted")
+	}
+	spendable, _, _ = w.ConfirmedBalance()
+	w.SetIncludeWatchedBalance(true)
+	total, _, _ = w.ConfirmedBalance()
+	if total.Cmp(spendable.Add(amount)) != 0 {
+		t.Fatalf("watched balance should be %v, got %v", amount, total.Sub(spendable))
+	}
+
+	// The watched output cannot be used to fund a transaction.
+	if len(w.watchedSiacoinOutputs) != 1 {
+		t.Fatal("expected one watched output, got", len(w.watchedSiacoinOutputs))
+	}
+	for scoid := range w.watchedSiacoinOutputs {
+		err = w.StartTransaction().FundSiacoinsFrom([]types.SiacoinOutputID{scoid})
+		if err != errWatchOnlyOutput {
+			t.Error("expected errWatchOnlyOutput, got", err)
+		}
+	}
+	err = w.StartTransaction().FundSiacoins(total)
+	if err != errWatchOnlyFunds {
+		t.Error("expected errWatchOnlyFunds, got", err)
+	}
+	err = w.StartTransaction().FundSiacoins(total.Add(types.NewCurrency64(1)))
+	if err != modules.ErrLowBalance {
+		t.Error("expected ErrLowBalance, got", err)
+	}
+}
+
+// TestIntegrationWatchAddressUnconfirmed checks that unconfirmed transactions
+// paying to watch-only and multisig addresses are reported by a wallet that
+// has no other part in the transactions.
+func TestIntegrationWatchAddressUnconfirmed(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester("TestIntegrationWatchAddressUnconfirmed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Create a second wallet that watches an address and a multisig address.
+	w, err := New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, "watcher"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, err := w.Encrypt(crypto.TwofishKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock(crypto.TwofishKey(crypto.HashObject(seed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	watchAddr := types.UnlockHash{1, 2, 3}
+	err = w.AddWatchAddress(watchAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pk, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	multisigAddr, _, err := w.MultisigAddress([]types.SiaPublicKey{{Algorithm: types.SignatureEd25519, Key: pk[:]}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pay both addresses from the first wallet.
+	for _, addr := range []types.UnlockHash{watchAddr, multisigAddr} {
+		_, err = wt.wallet.SendSiacoins(types.NewCurrency64(5000), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	paid := make(map[types.UnlockHash]bool)
+	for _, pt := range w.UnconfirmedTransactions() {
+		for _, output := range pt.Outputs {
+			paid[output.RelatedAddress] = true
+		}
+	}
+	if !paid[watchAddr] {
+		t.Error("unconfirmed payment to the watched address was not reported")
+	}
+	if !paid[multisigAddr] {
+		t.Error("unconfirmed payment to the multisig address was not reported")
+	}
+}
@@ -110,7 +110,8 @@ Host (h):
 Miner (m):
 	The miner provides a basic CPU mining implementation as well as an API
 	for external miners to use.
-	The miner requires the consensus set, transaction pool, and wallet.
+	The miner requires the gateway, consensus set, transaction pool, and
+	wallet.
 	Example:
 		siad -M gctwm
 Explorer (e):